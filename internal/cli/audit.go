@@ -0,0 +1,260 @@
+// Package cli — audit.go implements the "loam audit" command.
+//
+// The audit command cross-checks the host port allocations of every managed
+// environment. Allocations are normally conflict-free by construction, but
+// manual edits to labels or external processes grabbing ports can break
+// that over time. The audit reports two kinds of problems:
+//   - conflict: two environments (or one environment twice) claim the same
+//     host port and protocol, or an allocation is invalid
+//   - occupied: a stopped environment's port is now held by another process,
+//     so starting that environment would fail
+//
+// Running environments are not checked against the OS, because their own
+// containers hold their ports and would be indistinguishable from a foreign
+// process.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/port"
+)
+
+// Audit finding kinds.
+const (
+	auditConflict = "conflict"
+	auditOccupied = "occupied"
+)
+
+// auditFinding describes one problem found by the audit.
+type auditFinding struct {
+	Kind         string   `json:"kind"`
+	HostPort     int      `json:"hostPort"`
+	Protocol     string   `json:"protocol"`
+	Environments []string `json:"environments"`
+	Message      string   `json:"message"`
+}
+
+// NewAuditCommand creates the "audit" cobra command.
+// It is called from NewRootCommand to register as a subcommand.
+func NewAuditCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "audit",
+		Short: "Check all environments for host port conflicts",
+		Long: `Check the host port allocations of all managed environments.
+
+Reports ports claimed by more than one environment, and ports of stopped
+environments that are now in use by another process. Exits with code 4
+when any problem is found.
+
+Examples:
+  loam audit
+  loam audit --output json`,
+
+		Args: cobra.NoArgs,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAudit(cmd.Context())
+		},
+	}
+}
+
+// runAudit loads every environment from container labels and reports
+// cross-environment conflicts and ports occupied by foreign processes.
+func runAudit(ctx context.Context) error {
+	// Allocations live in container labels, so Docker is mandatory here.
+	cli, err := docker.NewClient()
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "Docker is required for audit but is not available", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	containers, err := docker.ListManagedContainers(ctx, cli)
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "failed to list managed containers", err)
+	}
+
+	envs := make([]*model.WorktreeEnv, 0)
+	for envName, group := range docker.GroupContainersByEnv(containers) {
+		env, buildErr := docker.BuildWorktreeEnv(envName, group)
+		if buildErr != nil {
+			printWarning("skipping environment %q: %v", envName, buildErr)
+			continue
+		}
+		envs = append(envs, env)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+
+	allocations := collectAuditAllocations(envs)
+	VerboseLog("Auditing %d allocation(s) across %d environment(s)...", len(allocations), len(envs))
+
+	findings := findPortConflicts(allocations)
+	findings = append(findings, findOccupiedPorts(envs, port.NewScanner().IsPortAvailable)...)
+
+	return printAuditResult(len(envs), len(allocations), findings)
+}
+
+// collectAuditAllocations flattens the allocations of all environments into
+// one set. The report names the environments involved, so ServiceName is
+// set to the environment name.
+func collectAuditAllocations(envs []*model.WorktreeEnv) []model.PortAllocation {
+	var allocations []model.PortAllocation
+	for _, env := range envs {
+		for _, pa := range env.PortAllocations {
+			pa.ServiceName = env.Name
+			allocations = append(allocations, pa)
+		}
+	}
+	return allocations
+}
+
+// findPortConflicts reports every invalid allocation and every host port
+// claimed more than once in the combined set.
+//
+// model.ValidatePortAllocations enforces the same rules but stops at the
+// first problem, so it serves as the fast path: only when it fails is the
+// set scanned again to collect all problems for the report.
+func findPortConflicts(allocations []model.PortAllocation) []auditFinding {
+	// Validate works on a copy because it fills in a default protocol.
+	combined := append([]model.PortAllocation(nil), allocations...)
+	if model.ValidatePortAllocations(combined) == nil {
+		return nil
+	}
+
+	// portKey identifies a host port binding; different protocols on the
+	// same port do not conflict.
+	type portKey struct {
+		hostPort int
+		protocol string
+	}
+
+	var findings []auditFinding
+	owners := make(map[portKey][]string)
+	var keys []portKey // first-seen order, for stable output
+
+	for i := range combined {
+		pa := &combined[i]
+		if err := pa.Validate(); err != nil {
+			findings = append(findings, auditFinding{
+				Kind:         auditConflict,
+				HostPort:     pa.HostPort,
+				Protocol:     pa.Protocol,
+				Environments: []string{pa.ServiceName},
+				Message:      err.Error(),
+			})
+			continue
+		}
+
+		key := portKey{hostPort: pa.HostPort, protocol: pa.Protocol}
+		if _, seen := owners[key]; !seen {
+			keys = append(keys, key)
+		}
+		owners[key] = append(owners[key], pa.ServiceName)
+	}
+
+	for _, key := range keys {
+		envNames := owners[key]
+		if len(envNames) < 2 {
+			continue
+		}
+		findings = append(findings, auditFinding{
+			Kind:         auditConflict,
+			HostPort:     key.hostPort,
+			Protocol:     key.protocol,
+			Environments: envNames,
+			Message: fmt.Sprintf("host port %d/%s is claimed by %s",
+				key.hostPort, key.protocol, strings.Join(envNames, ", ")),
+		})
+	}
+
+	return findings
+}
+
+// findOccupiedPorts reports ports of stopped environments that are in use on
+// the host. isAvailable is port.Scanner.IsPortAvailable in production; tests
+// pass a stub so no real ports are touched.
+func findOccupiedPorts(envs []*model.WorktreeEnv, isAvailable func(port int, protocol string) bool) []auditFinding {
+	var findings []auditFinding
+	for _, env := range envs {
+		if env.Status != model.StatusStopped {
+			continue
+		}
+		for _, pa := range env.PortAllocations {
+			protocol := pa.Protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			if isAvailable(pa.HostPort, protocol) {
+				continue
+			}
+			findings = append(findings, auditFinding{
+				Kind:         auditOccupied,
+				HostPort:     pa.HostPort,
+				Protocol:     protocol,
+				Environments: []string{env.Name},
+				Message: fmt.Sprintf("host port %d/%s of stopped environment %q is in use by another process",
+					pa.HostPort, protocol, env.Name),
+			})
+		}
+	}
+	return findings
+}
+
+// printAuditResult outputs the audit report in text or JSON format and
+// returns a CLIError when problems were found, so the command exits non-zero.
+func printAuditResult(envCount, allocationCount int, findings []auditFinding) error {
+	if IsJSONOutput() {
+		printAuditResultJSON(envCount, allocationCount, findings)
+	} else {
+		printAuditResultText(envCount, allocationCount, findings)
+	}
+
+	if len(findings) > 0 {
+		return model.NewCLIError(model.ExitPortAllocationFailed,
+			fmt.Sprintf("audit found %d port problem(s)", len(findings)))
+	}
+	return nil
+}
+
+// printAuditResultJSON outputs the audit report as structured JSON.
+func printAuditResultJSON(envCount, allocationCount int, findings []auditFinding) {
+	type resultJSON struct {
+		Environments int            `json:"environments"`
+		Allocations  int            `json:"allocations"`
+		Findings     []auditFinding `json:"findings"`
+	}
+
+	result := resultJSON{
+		Environments: envCount,
+		Allocations:  allocationCount,
+		// Use an empty slice so a clean audit shows [] instead of null.
+		Findings: make([]auditFinding, 0, len(findings)),
+	}
+	result.Findings = append(result.Findings, findings...)
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(data))
+}
+
+// printAuditResultText outputs the audit report as human-readable text:
+// a summary line, then one line per finding.
+func printAuditResultText(envCount, allocationCount int, findings []auditFinding) {
+	fmt.Printf("Audited %d port allocation(s) across %d environment(s).\n", allocationCount, envCount)
+	if len(findings) == 0 {
+		fmt.Println("No port problems found.")
+		return
+	}
+
+	fmt.Println()
+	for _, f := range findings {
+		fmt.Printf("  %-9s %s\n", strings.ToUpper(f.Kind), f.Message)
+	}
+}
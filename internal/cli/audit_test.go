@@ -0,0 +1,137 @@
+// Package cli — audit_test.go contains unit tests for the pure checks
+// behind "loam audit". Docker and real port scanning are not needed.
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// auditTestEnvs returns three environments where feature-a and feature-b
+// both claim host port 13000/tcp.
+func auditTestEnvs() []*model.WorktreeEnv {
+	return []*model.WorktreeEnv{
+		{Name: "feature-a", Status: model.StatusRunning, PortAllocations: []model.PortAllocation{
+			{ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+		}},
+		{Name: "feature-b", Status: model.StatusStopped, PortAllocations: []model.PortAllocation{
+			{ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+			{ContainerPort: 5432, HostPort: 25432, Protocol: "tcp"},
+		}},
+		{Name: "feature-c", Status: model.StatusStopped, PortAllocations: []model.PortAllocation{
+			{ContainerPort: 3000, HostPort: 33000, Protocol: "tcp"},
+		}},
+	}
+}
+
+// TestCollectAuditAllocations verifies that allocations are attributed to
+// their environment without modifying the environments themselves.
+func TestCollectAuditAllocations(t *testing.T) {
+	t.Parallel()
+
+	envs := auditTestEnvs()
+	allocations := collectAuditAllocations(envs)
+
+	require.Len(t, allocations, 4)
+	assert.Equal(t, "feature-a", allocations[0].ServiceName)
+	assert.Equal(t, "feature-b", allocations[2].ServiceName)
+	assert.Empty(t, envs[0].PortAllocations[0].ServiceName, "source allocations must not be mutated")
+}
+
+// TestFindPortConflicts verifies that cross-environment conflicts and invalid
+// allocations are all reported, while distinct protocols do not conflict.
+func TestFindPortConflicts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no conflicts", func(t *testing.T) {
+		t.Parallel()
+		allocations := []model.PortAllocation{
+			{ServiceName: "a", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+			{ServiceName: "b", ContainerPort: 3000, HostPort: 13000, Protocol: "udp"},
+			{ServiceName: "b", ContainerPort: 3000, HostPort: 23000},
+		}
+		assert.Empty(t, findPortConflicts(allocations))
+	})
+
+	t.Run("conflicts across environments", func(t *testing.T) {
+		t.Parallel()
+		findings := findPortConflicts(collectAuditAllocations(auditTestEnvs()))
+
+		require.Len(t, findings, 1)
+		assert.Equal(t, auditConflict, findings[0].Kind)
+		assert.Equal(t, 13000, findings[0].HostPort)
+		assert.Equal(t, []string{"feature-a", "feature-b"}, findings[0].Environments)
+		assert.Contains(t, findings[0].Message, "13000/tcp")
+	})
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		t.Parallel()
+		allocations := []model.PortAllocation{
+			{ServiceName: "a", ContainerPort: 3000, HostPort: 80, Protocol: "tcp"},
+			{ServiceName: "a", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+			{ServiceName: "b", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+			{ServiceName: "a", ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+			{ServiceName: "c", ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+		}
+		findings := findPortConflicts(allocations)
+
+		require.Len(t, findings, 3)
+		assert.Contains(t, findings[0].Message, "out of range", "invalid allocations are reported")
+		assert.Equal(t, 13000, findings[1].HostPort)
+		assert.Equal(t, 15432, findings[2].HostPort)
+	})
+}
+
+// TestFindOccupiedPorts verifies that only stopped environments are checked
+// against the host, with a stub standing in for the port scanner.
+func TestFindOccupiedPorts(t *testing.T) {
+	t.Parallel()
+
+	var checked []int
+	inUse := map[int]bool{13000: true, 33000: true}
+	isAvailable := func(port int, protocol string) bool {
+		checked = append(checked, port)
+		assert.Equal(t, "tcp", protocol)
+		return !inUse[port]
+	}
+
+	findings := findOccupiedPorts(auditTestEnvs(), isAvailable)
+
+	assert.ElementsMatch(t, []int{13000, 25432, 33000}, checked, "running feature-a must not be scanned")
+	require.Len(t, findings, 2)
+	assert.Equal(t, auditOccupied, findings[0].Kind)
+	assert.Equal(t, []string{"feature-b"}, findings[0].Environments)
+	assert.Equal(t, []string{"feature-c"}, findings[1].Environments)
+}
+
+// TestPrintAuditResult verifies the JSON report shape and the exit code.
+func TestPrintAuditResult(t *testing.T) {
+	setJSONOutput(t, true)
+
+	var err error
+	out := captureStdout(t, func() { err = printAuditResult(2, 3, nil) })
+	require.NoError(t, err)
+	assert.Contains(t, out, `"findings": []`)
+
+	findings := []auditFinding{{Kind: auditConflict, HostPort: 13000, Protocol: "tcp",
+		Environments: []string{"a", "b"}, Message: "host port 13000/tcp is claimed by a, b"}}
+	out = captureStdout(t, func() { err = printAuditResult(2, 3, findings) })
+
+	var cliErr *model.CLIError
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitPortAllocationFailed, cliErr.Code)
+
+	var result struct {
+		Environments int            `json:"environments"`
+		Allocations  int            `json:"allocations"`
+		Findings     []auditFinding `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, 2, result.Environments)
+	assert.Equal(t, findings, result.Findings)
+}
@@ -0,0 +1,248 @@
+// Package cli — bulk.go implements the shared machinery behind
+// "loam stop --all", "loam start --all", and "loam remove --all".
+//
+// Bulk operations discover every managed environment from Docker container
+// labels (across all repositories, optionally filtered by --repo), run the
+// per-environment operation concurrently with a bounded worker pool, and
+// report success or failure for each environment. A failure in one
+// environment never stops the others; the command exits non-zero at the end
+// if any of them failed.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// bulkWorkers is the maximum number of environments operated on at once.
+// Each operation may spawn `docker compose`, so a small bound keeps the
+// Docker daemon and the host from being flooded.
+const bulkWorkers = 4
+
+// bulkTarget is a single environment selected for a bulk operation,
+// together with the containers that belong to it.
+type bulkTarget struct {
+	env        *model.WorktreeEnv
+	containers []model.ContainerInfo
+}
+
+// bulkResult records the outcome of a bulk operation for one environment.
+// Err is nil on success.
+type bulkResult struct {
+	Name string
+	Err  error
+}
+
+// bulkOp performs a lifecycle operation (stop, start, ...) on one environment.
+// It is a function type so tests can substitute a fake for the Docker-backed
+// implementation.
+type bulkOp func(ctx context.Context, target bulkTarget) error
+
+// validateBulkArgs checks that exactly one of an environment name or --all
+// was given, and that --repo is only used together with --all.
+func validateBulkArgs(args []string, all bool, repo string) error {
+	switch {
+	case all && len(args) > 0:
+		return model.NewCLIError(model.ExitGeneralError, "cannot specify an environment name together with --all")
+	case !all && len(args) == 0:
+		return model.NewCLIError(model.ExitGeneralError, "environment name is required (or use --all)")
+	case !all && repo != "":
+		return model.NewCLIError(model.ExitGeneralError, "--repo can only be used with --all")
+	}
+	return nil
+}
+
+// listBulkTargets queries Docker for all managed containers and returns the
+// environments whose status is one of want (any status if want is empty),
+// filtered by repository if repo is non-empty.
+func listBulkTargets(ctx context.Context, cli *docker.Client, repo string, want ...model.WorktreeStatus) ([]bulkTarget, error) {
+	containers, err := docker.ListManagedContainers(ctx, cli)
+	if err != nil {
+		return nil, model.WrapCLIError(model.ExitDockerNotRunning, "failed to list managed containers", err)
+	}
+
+	repoFilter, err := resolveRepoFilter(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectBulkTargets(containers, repoFilter, want...), nil
+}
+
+// selectBulkTargets groups containers into environments and keeps those whose
+// status is one of want (any status if want is empty) and, when repoFilter
+// is non-empty, whose source repository matches it. Environments with unparseable labels are skipped with a verbose
+// warning. The result is sorted by environment name for stable output.
+func selectBulkTargets(containers []model.ContainerInfo, repoFilter string, want ...model.WorktreeStatus) []bulkTarget {
+	groups := docker.GroupContainersByEnv(containers)
+
+	targets := make([]bulkTarget, 0, len(groups))
+	for envName, group := range groups {
+		env, err := docker.BuildWorktreeEnv(envName, group)
+		if err != nil {
+			VerboseLog("Warning: skipping environment %q: %v", envName, err)
+			continue
+		}
+		if len(want) > 0 && !slices.Contains(want, env.Status) {
+			continue
+		}
+		if repoFilter != "" && filepath.Clean(env.SourceRepoPath) != repoFilter {
+			continue
+		}
+		targets = append(targets, bulkTarget{env: env, containers: group})
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].env.Name < targets[j].env.Name
+	})
+	return targets
+}
+
+// resolveRepoFilter converts the --repo flag value into the repository root
+// path stored in the loam.source-repo label. A path inside a repository
+// (including a worktree) is resolved to its main repository root so that
+// "--repo ." works from anywhere in the project. An empty value disables
+// filtering.
+func resolveRepoFilter(repo string) (string, error) {
+	if repo == "" {
+		return "", nil
+	}
+
+	absRepo, err := filepath.Abs(repo)
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitGeneralError, fmt.Sprintf("invalid --repo path %q", repo), err)
+	}
+	if _, statErr := os.Stat(absRepo); statErr != nil {
+		// The repository may have been deleted while its containers remain,
+		// so match the literal path instead of failing.
+		return filepath.Clean(absRepo), nil
+	}
+
+	root, err := worktree.NewManager().GetRepoRoot(absRepo)
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitGitError, fmt.Sprintf("--repo %q is not inside a Git repository", repo), err)
+	}
+	return filepath.Clean(root), nil
+}
+
+// runBulk applies op to every target using at most workers goroutines and
+// returns one result per target, in the same order as targets.
+//
+// Errors are collected rather than returned early, so every environment gets
+// its chance to run regardless of failures elsewhere.
+func runBulk(ctx context.Context, targets []bulkTarget, workers int, op bulkOp) []bulkResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]bulkResult, len(targets))
+
+	// jobs carries target indexes to the workers. Writing results by index
+	// means no mutex is needed: each slot is written by exactly one goroutine.
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				t := targets[i]
+				VerboseLog("Processing environment %q...", t.env.Name)
+				results[i] = bulkResult{Name: t.env.Name, Err: op(ctx, t)}
+			}
+		}()
+	}
+
+	for i := range targets {
+		jobs <- i
+	}
+	// Closing the channel ends each worker's range loop once the queue drains.
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// printBulkResult reports per-environment outcomes of a bulk operation and
+// returns a CLIError if any environment failed, so the command exits non-zero.
+//
+// action is the past-tense verb used in output (e.g., "stopped").
+func printBulkResult(action string, results []bulkResult) error {
+	if IsJSONOutput() {
+		printBulkResultJSON(action, results)
+	} else {
+		printBulkResultText(action, results)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("%d of %d environment(s) could not be %s", failed, len(results), action))
+	}
+	return nil
+}
+
+// printBulkResultJSON outputs bulk operation results as structured JSON.
+func printBulkResultJSON(action string, results []bulkResult) {
+	type envResultJSON struct {
+		Name    string `json:"name"`
+		Success bool   `json:"success"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	type resultJSON struct {
+		Action       string          `json:"action"`
+		Environments []envResultJSON `json:"environments"`
+	}
+
+	result := resultJSON{
+		Action:       action,
+		Environments: make([]envResultJSON, 0, len(results)),
+	}
+	for _, r := range results {
+		entry := envResultJSON{Name: r.Name, Success: r.Err == nil}
+		if r.Err != nil {
+			entry.Error = r.Err.Error()
+		}
+		result.Environments = append(result.Environments, entry)
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(data))
+}
+
+// printBulkResultText outputs bulk operation results as human-readable text,
+// one line per environment followed by a summary line.
+func printBulkResultText(action string, results []bulkResult) {
+	if len(results) == 0 {
+		fmt.Printf("No worktree environments to be %s.\n", action)
+		return
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("  %-20s failed: %v\n", r.Name, r.Err)
+			continue
+		}
+		succeeded++
+		fmt.Printf("  %-20s %s\n", r.Name, action)
+	}
+	fmt.Printf("\n%d of %d environment(s) %s\n", succeeded, len(results), action)
+}
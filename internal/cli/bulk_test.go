@@ -0,0 +1,191 @@
+// Package cli — bulk_test.go contains unit tests for the "stop --all" /
+// "start --all" machinery. The Docker layer is replaced by in-memory
+// ContainerInfo values and fake bulkOp functions, so no daemon is needed.
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// makeBulkContainer builds a managed ContainerInfo for an environment whose
+// labels point at worktreePath and sourceRepo.
+func makeBulkContainer(t *testing.T, envName, status, worktreePath, sourceRepo string) model.ContainerInfo {
+	t.Helper()
+	env := &model.WorktreeEnv{
+		Name:           envName,
+		Branch:         envName,
+		WorktreePath:   worktreePath,
+		SourceRepoPath: sourceRepo,
+		ConfigPattern:  model.PatternImage,
+		CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	labels, err := docker.BuildLabels(env)
+	require.NoError(t, err)
+	return model.ContainerInfo{
+		ContainerID:   "id-" + envName,
+		ContainerName: envName + "-app",
+		Status:        status,
+		Labels:        labels,
+	}
+}
+
+// makeBulkTargets returns n targets named env-0 … env-(n-1).
+func makeBulkTargets(n int) []bulkTarget {
+	targets := make([]bulkTarget, n)
+	for i := range targets {
+		targets[i] = bulkTarget{env: &model.WorktreeEnv{Name: fmt.Sprintf("env-%d", i)}}
+	}
+	return targets
+}
+
+// TestValidateBulkArgs verifies the mutual exclusion of a name and --all.
+func TestValidateBulkArgs(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateBulkArgs([]string{"feature-auth"}, false, ""))
+	assert.NoError(t, validateBulkArgs(nil, true, ""))
+	assert.NoError(t, validateBulkArgs(nil, true, "/repo"))
+
+	assert.Error(t, validateBulkArgs([]string{"feature-auth"}, true, ""), "name and --all are exclusive")
+	assert.Error(t, validateBulkArgs(nil, false, ""), "either a name or --all is required")
+	assert.Error(t, validateBulkArgs([]string{"feature-auth"}, false, "/repo"), "--repo requires --all")
+}
+
+// TestSelectBulkTargets verifies status and repository filtering across
+// multiple environments, and that results are sorted by name.
+func TestSelectBulkTargets(t *testing.T) {
+	t.Parallel()
+
+	// Worktree paths must exist, otherwise environments are reported as orphaned.
+	base := t.TempDir()
+	repoA := filepath.Join(base, "repo-a")
+	repoB := filepath.Join(base, "repo-b")
+
+	containers := []model.ContainerInfo{
+		makeBulkContainer(t, "zeta", "running", base, repoA),
+		makeBulkContainer(t, "alpha", "running", base, repoB),
+		makeBulkContainer(t, "beta", "exited", base, repoA),
+		makeBulkContainer(t, "gone", "running", filepath.Join(base, "missing"), repoA),
+	}
+
+	t.Run("running in all repositories", func(t *testing.T) {
+		t.Parallel()
+		targets := selectBulkTargets(containers, "", model.StatusRunning)
+		require.Len(t, targets, 2, "orphaned and stopped environments must be excluded")
+		assert.Equal(t, "alpha", targets[0].env.Name)
+		assert.Equal(t, "zeta", targets[1].env.Name)
+		assert.Len(t, targets[0].containers, 1)
+	})
+
+	t.Run("running in one repository", func(t *testing.T) {
+		t.Parallel()
+		targets := selectBulkTargets(containers, repoA, model.StatusRunning)
+		require.Len(t, targets, 1)
+		assert.Equal(t, "zeta", targets[0].env.Name)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		t.Parallel()
+		targets := selectBulkTargets(containers, "", model.StatusStopped)
+		require.Len(t, targets, 1)
+		assert.Equal(t, "beta", targets[0].env.Name)
+	})
+
+	t.Run("any status", func(t *testing.T) {
+		t.Parallel()
+		targets := selectBulkTargets(containers, repoA)
+		require.Len(t, targets, 3, "orphaned environments are included")
+		assert.Equal(t, "beta", targets[0].env.Name)
+		assert.Equal(t, "gone", targets[1].env.Name)
+		assert.Equal(t, "zeta", targets[2].env.Name)
+	})
+}
+
+// TestRunBulk_ContinuesPastFailures verifies that every environment is
+// processed even when some fail, and that results keep the input order.
+func TestRunBulk_ContinuesPastFailures(t *testing.T) {
+	t.Parallel()
+
+	targets := makeBulkTargets(6)
+	errBoom := errors.New("boom")
+
+	var calls atomic.Int32
+	results := runBulk(context.Background(), targets, 3, func(_ context.Context, tgt bulkTarget) error {
+		calls.Add(1)
+		if tgt.env.Name == "env-1" || tgt.env.Name == "env-4" {
+			return errBoom
+		}
+		return nil
+	})
+
+	assert.Equal(t, int32(6), calls.Load(), "every environment should be processed")
+	require.Len(t, results, 6)
+	for i, r := range results {
+		assert.Equal(t, fmt.Sprintf("env-%d", i), r.Name, "results must keep input order")
+		if i == 1 || i == 4 {
+			assert.ErrorIs(t, r.Err, errBoom)
+		} else {
+			assert.NoError(t, r.Err)
+		}
+	}
+}
+
+// TestRunBulk_BoundedConcurrency verifies that no more than the requested
+// number of operations run at the same time, and that they do overlap.
+func TestRunBulk_BoundedConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const workers = 2
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+	)
+
+	runBulk(context.Background(), makeBulkTargets(8), workers, func(_ context.Context, _ bulkTarget) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+
+		// Hold the slot long enough for other workers to pile up.
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	})
+
+	assert.Equal(t, workers, maxSeen, "operations should run concurrently up to the worker limit")
+}
+
+// TestPrintBulkResult_ExitStatus verifies that any failure turns into a
+// non-zero CLIError while an all-success run returns nil.
+func TestPrintBulkResult_ExitStatus(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, printBulkResult("stopped", []bulkResult{{Name: "a"}, {Name: "b"}}))
+	assert.NoError(t, printBulkResult("stopped", nil), "nothing to do is not a failure")
+
+	err := printBulkResult("stopped", []bulkResult{{Name: "a"}, {Name: "b", Err: errors.New("boom")}})
+	var cliErr *model.CLIError
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitGeneralError, cliErr.Code)
+	assert.Contains(t, cliErr.Message, "1 of 2")
+}
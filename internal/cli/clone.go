@@ -0,0 +1,100 @@
+// Package cli — clone.go implements "loam create --clone-url".
+//
+// On a machine without a local checkout (e.g., a CI box provisioning review
+// environments), --clone-url clones the repository first and then creates
+// the environment from the clone, as if create had been run inside it.
+// Clones are kept under the user cache directory by default, so repeated
+// runs for the same URL reuse the clone and only fetch.
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// cloneRepo returns the root of a local clone of url to create the
+// environment from: dir if given, or a directory named after the
+// repository under the user cache directory (see defaultCloneDir).
+//
+// An existing clone of the same URL at that location is reused after a
+// fetch; cloned reports whether a new clone was made, so that a failed
+// create can remove it. Any other existing, non-empty directory is an
+// error rather than being overwritten.
+func cloneRepo(wm *worktree.Manager, url, dir string) (root string, cloned bool, err error) {
+	if dir == "" {
+		dir, err = defaultCloneDir(url)
+		if err != nil {
+			return "", false, err
+		}
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", false, model.WrapCLIError(model.ExitGeneralError, "failed to resolve clone directory", err)
+	}
+
+	if entries, readErr := os.ReadDir(dir); readErr == nil && len(entries) > 0 {
+		originURL, urlErr := wm.GetRemoteURL(dir, worktree.DefaultRemote)
+		if urlErr != nil || originURL != url {
+			return "", false, model.NewCLIError(model.ExitGitError,
+				fmt.Sprintf("clone directory %s already exists and is not a clone of %s; choose another with --clone-dir", dir, url))
+		}
+		VerboseLog("Reusing clone of %s at %s; fetching...", url, dir)
+		if fetchErr := wm.Fetch(dir, worktree.DefaultRemote); fetchErr != nil {
+			return "", false, model.WrapCLIError(model.ExitGitError, "failed to fetch into existing clone", fetchErr)
+		}
+		return dir, false, nil
+	}
+
+	VerboseLog("Cloning %s into %s...", url, dir)
+	if cloneErr := wm.Clone(url, dir); cloneErr != nil {
+		return "", false, model.WrapCLIError(model.ExitGitError, fmt.Sprintf("failed to clone %s", url), cloneErr)
+	}
+	return dir, true, nil
+}
+
+// defaultCloneDir returns where --clone-url clones url when --clone-dir is
+// not given: <user cache dir>/loam/clones/<repository name>.
+func defaultCloneDir(url string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitGeneralError,
+			"cannot determine a directory for the clone; pass --clone-dir", err)
+	}
+	return filepath.Join(cacheDir, "loam", "clones", repoNameFromURL(url)), nil
+}
+
+// repoNameFromURL derives a directory name from a Git URL the way
+// `git clone` does: the last path component without a ".git" suffix.
+// It handles URLs ("https://host/org/repo.git"), scp-like addresses
+// ("git@host:org/repo.git"), and local paths.
+func repoNameFromURL(url string) string {
+	name := strings.TrimRight(url, "/")
+	name = strings.TrimSuffix(name, ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || name == "." || name == ".." {
+		return "repo"
+	}
+	return name
+}
+
+// cloneBaseRef returns the base for a new branch in a fresh clone. A clone
+// only has the default branch locally, so a branch that exists on the
+// remote is started from its remote-tracking branch (and tracks it), the
+// way `git checkout <branch>` would; otherwise base is returned unchanged.
+func cloneBaseRef(wm *worktree.Manager, repoRoot, branch, base string) string {
+	if base != "" || branch == "" || wm.BranchExists(repoRoot, branch) {
+		return base
+	}
+	remoteBranch := worktree.DefaultRemote + "/" + branch
+	if wm.BranchExists(repoRoot, "refs/remotes/"+remoteBranch) {
+		return remoteBranch
+	}
+	return base
+}
@@ -0,0 +1,101 @@
+// Package cli — clone_test.go contains tests for "loam create --clone-url".
+// A local repository stands in for the remote.
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// TestRepoNameFromURL verifies the clone directory names derived from
+// common URL forms.
+func TestRepoNameFromURL(t *testing.T) {
+	t.Parallel()
+
+	for url, want := range map[string]string{
+		"https://github.com/acme/app.git": "app",
+		"https://github.com/acme/app/":    "app",
+		"git@github.com:acme/app.git":     "app",
+		"host:app.git":                    "app",
+		"/srv/git/app":                    "app",
+		"/":                               "repo",
+	} {
+		assert.Equal(t, want, repoNameFromURL(url), url)
+	}
+}
+
+// TestRunCreate_CloneURL runs create outside any repository: the remote is
+// cloned, a branch that exists only on the remote is checked out at its
+// remote commit, and a second run reuses the clone. This test uses
+// os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_CloneURL(t *testing.T) {
+	setJSONOutput(t, false)
+
+	remotePath := setupTestRepo(t)
+	runTestGit(t, remotePath, "checkout", "-q", "-b", "feature-review")
+	require.NoError(t, os.WriteFile(filepath.Join(remotePath, "review.txt"), []byte("review\n"), 0o644))
+	runTestGit(t, remotePath, "add", "review.txt")
+	runTestGit(t, remotePath, "commit", "-q", "-m", "review")
+	runTestGit(t, remotePath, "checkout", "-q", "-")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	workDir := t.TempDir()
+	require.NoError(t, os.Chdir(workDir))
+
+	cloneDir := filepath.Join(workDir, "clones", "app")
+	flags := &createFlags{cloneURL: remotePath, cloneDir: cloneDir, noStart: true}
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-review", flags))
+	})
+
+	worktreePath := filepath.Join(workDir, "clones", "app-feature-review")
+	assert.FileExists(t, filepath.Join(worktreePath, "review.txt"), "the remote branch must be checked out")
+	marker, err := worktree.ReadMarkerFile(worktreePath)
+	require.NoError(t, err)
+	assert.Equal(t, cloneDir, marker.SourceRepoPath)
+
+	// A second environment reuses the existing clone.
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-new",
+			&createFlags{cloneURL: remotePath, cloneDir: cloneDir, noStart: true}))
+	})
+	assert.DirExists(t, filepath.Join(workDir, "clones", "app-feature-new"))
+	assert.NoFileExists(t, filepath.Join(workDir, "clones", "app-feature-new", "review.txt"),
+		"a branch missing on the remote starts from the default branch")
+}
+
+// TestRunCreate_CloneURLErrors verifies that --clone-url is rejected inside
+// a repository and that a clone directory holding something else is never
+// overwritten. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_CloneURLErrors(t *testing.T) {
+	remotePath := setupTestRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+
+	require.NoError(t, os.Chdir(setupTestRepo(t)))
+	err = runCreate(context.Background(), "feature-x", &createFlags{cloneURL: remotePath, noStart: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be used inside a Git repository")
+
+	workDir := t.TempDir()
+	require.NoError(t, os.Chdir(workDir))
+	occupied := filepath.Join(workDir, "occupied")
+	require.NoError(t, os.MkdirAll(occupied, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(occupied, "keep.txt"), []byte("keep"), 0o644))
+
+	err = runCreate(context.Background(), "feature-x", &createFlags{cloneURL: remotePath, cloneDir: occupied, noStart: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a clone of")
+	assert.FileExists(t, filepath.Join(occupied, "keep.txt"))
+}
@@ -0,0 +1,83 @@
+// Package cli — composefiles.go assembles the Compose file chain of an
+// environment.
+//
+// create runs Compose with the configuration's files, then the generated
+// worktree override, then any files given with --compose-file, so that a
+// team's own override (e.g., mounting debugging tools) is applied last. The
+// chain is recorded in the loam.compose-files label, and start, stop, and
+// remove pass Compose the same files.
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// resolveExtraComposeFiles validates the --compose-file values and returns
+// them as absolute paths, so that they still resolve when Compose runs in
+// the worktree's .devcontainer directory.
+func resolveExtraComposeFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, fmt.Sprintf("invalid --compose-file %q", p), err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, fmt.Sprintf("invalid --compose-file %q", p), err)
+		}
+		if info.IsDir() {
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("invalid --compose-file %q: is a directory", p))
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved, nil
+}
+
+// composeFileChain returns the "-f" files for a Compose environment in
+// merge order: composeFiles from the configuration, the generated override
+// (its path as Compose should see it), and then extra, so that the user's
+// files take precedence over ours.
+func composeFileChain(composeFiles []string, override string, extra []string) []string {
+	chain := make([]string, 0, len(composeFiles)+1+len(extra))
+	chain = append(chain, composeFiles...)
+	chain = append(chain, override)
+	return append(chain, extra...)
+}
+
+// envComposeFiles returns the Compose files to run lifecycle commands for
+// env with, followed by extra (start --compose-file). The recorded chain
+// (env.ComposeFiles) is used if there is one; environments created before
+// it was recorded fall back to the dockerComposeFile entries of the
+// rewritten devcontainer.json (see envDevcontainerDir), which end with the
+// override.
+// Without either, nil is returned and Compose uses its default files;
+// extra is then dropped with a warning, since it cannot be applied on top
+// of files that are not known.
+func envComposeFiles(env *model.WorktreeEnv, extra []string) []string {
+	files := env.ComposeFiles
+	if len(files) == 0 {
+		raw, err := devcontainer.LoadConfig(filepath.Join(envDevcontainerDir(env), "devcontainer.json"))
+		if err != nil {
+			VerboseLog("Could not read Compose files of environment %q: %v", env.Name, err)
+		} else {
+			files = devcontainer.GetComposeFiles(raw)
+		}
+	}
+	if len(files) == 0 {
+		if len(extra) > 0 {
+			printWarning("--compose-file ignored: the Compose files of environment %q are not known", env.Name)
+		}
+		return nil
+	}
+	return append(append([]string{}, files...), extra...)
+}
@@ -0,0 +1,87 @@
+// Package cli — composefiles_test.go contains unit tests for assembling the
+// Compose file chain of an environment.
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// TestComposeFileChain verifies the merge order: the configuration's files,
+// then the generated override, then the user's files last.
+func TestComposeFileChain(t *testing.T) {
+	chain := composeFileChain(
+		[]string{"../docker-compose.yml", "docker-compose.dev.yml"},
+		devcontainer.ComposeOverrideFileName,
+		[]string{"/home/dev/debug.yml", "/home/dev/mounts.yml"},
+	)
+	assert.Equal(t, []string{
+		"../docker-compose.yml",
+		"docker-compose.dev.yml",
+		devcontainer.ComposeOverrideFileName,
+		"/home/dev/debug.yml",
+		"/home/dev/mounts.yml",
+	}, chain)
+
+	assert.Equal(t, []string{"docker-compose.yml", devcontainer.ComposeOverrideFileName},
+		composeFileChain([]string{"docker-compose.yml"}, devcontainer.ComposeOverrideFileName, nil))
+}
+
+// TestResolveExtraComposeFiles verifies that --compose-file values are made
+// absolute and that missing files and directories are rejected.
+func TestResolveExtraComposeFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "debug.yml")
+	require.NoError(t, os.WriteFile(file, []byte("services: {}\n"), 0o644))
+
+	resolved, err := resolveExtraComposeFiles([]string{file})
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, resolved)
+
+	resolved, err = resolveExtraComposeFiles(nil)
+	require.NoError(t, err)
+	assert.Nil(t, resolved)
+
+	_, err = resolveExtraComposeFiles([]string{filepath.Join(dir, "missing.yml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing.yml")
+
+	_, err = resolveExtraComposeFiles([]string{dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is a directory")
+}
+
+// TestEnvComposeFiles verifies that lifecycle commands use the recorded
+// chain, fall back to the rewritten devcontainer.json, and append the
+// extra files last.
+func TestEnvComposeFiles(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:         "feature-auth",
+		WorktreePath: t.TempDir(),
+		ComposeFiles: []string{"docker-compose.yml", devcontainer.ComposeOverrideFileName},
+	}
+	assert.Equal(t, []string{"docker-compose.yml", devcontainer.ComposeOverrideFileName, "/extra.yml"},
+		envComposeFiles(env, []string{"/extra.yml"}))
+	assert.Len(t, env.ComposeFiles, 2, "the recorded chain must not be modified")
+
+	// Without a recorded chain, the rewritten devcontainer.json is used.
+	env.ComposeFiles = nil
+	dcDir := filepath.Join(env.WorktreePath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{
+		"dockerComposeFile": ["docker-compose.yml", "docker-compose.worktree.yml"],
+		"service": "app"
+	}`), 0o644))
+	assert.Equal(t, []string{"docker-compose.yml", "docker-compose.worktree.yml"}, envComposeFiles(env, nil))
+
+	// Without either, Compose's defaults are left alone.
+	env.WorktreePath = t.TempDir()
+	assert.Nil(t, envComposeFiles(env, nil))
+}
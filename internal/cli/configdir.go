@@ -0,0 +1,97 @@
+// Package cli — configdir.go implements "loam create --config-dir".
+//
+// By default create writes its generated files — the rewritten
+// devcontainer.json and the Compose override — into the worktree's
+// .devcontainer directory, where they show up as changes next to the
+// user's work. --config-dir writes them to a directory outside the
+// worktree instead. Compose is then run with absolute "-f" paths, so the
+// configuration's own files are still read from the worktree and relative
+// paths in them resolve as before. The directory is recorded in the
+// loam.config-dir label for start, stop, and remove.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// configDirAuto is the --config-dir value selecting the default location
+// (see defaultConfigDir).
+const configDirAuto = "auto"
+
+// resolveConfigDir returns the absolute directory for the generated files
+// of envName from the --config-dir value, or "" if none was given.
+func resolveConfigDir(value, envName string) (string, error) {
+	switch value {
+	case "":
+		return "", nil
+	case configDirAuto:
+		return defaultConfigDir(envName)
+	}
+	dir, err := filepath.Abs(value)
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitGeneralError, "failed to resolve --config-dir", err)
+	}
+	return dir, nil
+}
+
+// defaultConfigDir returns where "--config-dir auto" puts the generated
+// files of envName: <user cache dir>/loam/configs/<name>.
+func defaultConfigDir(envName string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitGeneralError,
+			"cannot determine a directory for the generated files; pass a path to --config-dir", err)
+	}
+	return filepath.Join(cacheDir, "loam", "configs", envName), nil
+}
+
+// envDevcontainerDir returns the directory holding the generated files of
+// env: its config directory if it has one, otherwise the worktree's
+// .devcontainer directory. Compose commands for the environment run there.
+func envDevcontainerDir(env *model.WorktreeEnv) string {
+	if env.ConfigDir != "" {
+		return env.ConfigDir
+	}
+	return filepath.Join(env.WorktreePath, ".devcontainer")
+}
+
+// prepareConfigDir creates dir for the generated files. Whatever create
+// writes there is recorded in rb, so a failed create leaves no trace of it.
+func prepareConfigDir(dir string, rb *createRollback) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return model.WrapCLIError(model.ExitGeneralError, "failed to create --config-dir", err)
+	}
+	rb.push(fmt.Sprintf("generated files in %s", dir), func() error { return removeConfigDir(dir) })
+	VerboseLog("Writing generated files to %s", dir)
+	return nil
+}
+
+// removeConfigDir deletes the generated files in dir and then dir itself
+// if that leaves it empty. Other files are left alone: dir was chosen by
+// the user and may hold more than loam put there. Missing files are not an
+// error.
+func removeConfigDir(dir string) error {
+	for _, name := range []string{"devcontainer.json", devcontainer.ComposeOverrideFileName} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		VerboseLog("Keeping %s: it holds files not generated by loam", dir)
+		return nil
+	}
+	return os.Remove(dir)
+}
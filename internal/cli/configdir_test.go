@@ -0,0 +1,103 @@
+// Package cli — configdir_test.go contains unit tests for
+// "loam create --config-dir".
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// TestResolveConfigDir verifies that no value means no config directory,
+// that paths are made absolute, and that "auto" selects a per-environment
+// directory under the user cache directory.
+func TestResolveConfigDir(t *testing.T) {
+	dir, err := resolveConfigDir("", "feature-auth")
+	require.NoError(t, err)
+	assert.Empty(t, dir)
+
+	dir, err = resolveConfigDir("generated", "feature-auth")
+	require.NoError(t, err)
+	assert.True(t, filepath.IsAbs(dir))
+	assert.Equal(t, "generated", filepath.Base(dir))
+
+	dir, err = resolveConfigDir(configDirAuto, "feature-auth")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(dir, filepath.Join("loam", "configs", "feature-auth")), dir)
+}
+
+// TestRunCreate_ConfigDir verifies that with --config-dir the worktree's
+// .devcontainer directory is left as checked out, that the generated files
+// are written to the config directory, and that the Compose files they
+// reference resolve from there, so Compose can start the environment with
+// the recorded chain. This test uses os.Chdir, so it must NOT use
+// t.Parallel().
+func TestRunCreate_ConfigDir(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+	original, err := os.ReadFile(filepath.Join(repoDir, ".devcontainer", "devcontainer.json"))
+	require.NoError(t, err)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	configDir := filepath.Join(t.TempDir(), "configs", "feature-cfg")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature-cfg", &createFlags{
+			path:      worktreePath,
+			noStart:   true,
+			configDir: configDir,
+		}))
+	})
+
+	// The worktree holds no generated files.
+	inWorktree, err := os.ReadFile(filepath.Join(worktreePath, ".devcontainer", "devcontainer.json"))
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(inWorktree))
+	assert.NoFileExists(t, filepath.Join(worktreePath, ".devcontainer", devcontainer.ComposeOverrideFileName))
+
+	// The config directory does, and its references are absolute.
+	overridePath := filepath.Join(configDir, devcontainer.ComposeOverrideFileName)
+	override, err := os.ReadFile(overridePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(override), docker.LabelConfigDir+": "+configDir)
+
+	env := &model.WorktreeEnv{Name: "feature-cfg", WorktreePath: worktreePath, ConfigDir: configDir}
+	assert.Equal(t, configDir, envDevcontainerDir(env))
+	files := envComposeFiles(env, nil)
+	assert.Equal(t, []string{filepath.Join(worktreePath, ".devcontainer", "docker-compose.yml"), overridePath}, files)
+	for _, f := range files {
+		assert.FileExists(t, f)
+	}
+
+	// remove cleans the directory up again.
+	require.NoError(t, removeConfigDir(configDir))
+	assert.NoDirExists(t, configDir)
+}
+
+// TestRemoveConfigDir verifies that only the generated files are deleted
+// and that a directory holding other files is kept.
+func TestRemoveConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"devcontainer.json", devcontainer.ComposeOverrideFileName, "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644))
+	}
+
+	require.NoError(t, removeConfigDir(dir))
+	assert.NoFileExists(t, filepath.Join(dir, "devcontainer.json"))
+	assert.NoFileExists(t, filepath.Join(dir, devcontainer.ComposeOverrideFileName))
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+
+	require.NoError(t, removeConfigDir(filepath.Join(dir, "missing")))
+}
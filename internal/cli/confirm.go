@@ -0,0 +1,84 @@
+// Package cli — confirm.go implements the confirmation prompt of
+// destructive commands.
+//
+// A destructive command asks before it acts. The global --yes flag answers
+// the prompt in advance, for scripts. Without a terminal on stdin nobody can
+// answer, so the action is refused unless --yes is given rather than
+// proceeding, or failing on a read, depending on what happens to be piped
+// in.
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// confirmPrompt asks the user to confirm a destructive action. The
+// question goes to out (stderr for the real prompt, so that stdout stays
+// valid JSON) and the answer is read from in.
+type confirmPrompt struct {
+	in  io.Reader
+	out io.Writer
+
+	// interactive reports whether in is a terminal.
+	interactive bool
+}
+
+// newStdinPrompt returns the prompt reading from stdin.
+func newStdinPrompt() confirmPrompt {
+	return confirmPrompt{in: os.Stdin, out: os.Stderr, interactive: isTerminal(os.Stdin)}
+}
+
+// isTerminal reports whether f is a character device, i.e., a terminal
+// rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// yesNo prints summary, which describes what is about to happen, and asks
+// question with a [y/N] suffix. It returns nil if the user answers "y" or
+// "yes" (in any case) or --yes was given, and an ExitUserCancelled error
+// otherwise, including at end of input.
+func (p confirmPrompt) yesNo(summary, question string) error {
+	return p.ask(summary, question+" [y/N] ", func(answer string) bool {
+		answer = strings.ToLower(answer)
+		return answer == "y" || answer == "yes"
+	})
+}
+
+// typed prints summary and asks the user to type expected. Only an exact
+// match (ignoring surrounding spaces) confirms; otherwise it behaves like
+// yesNo.
+func (p confirmPrompt) typed(summary, expected string) error {
+	return p.ask(summary, fmt.Sprintf("Type %q to confirm: ", expected), func(answer string) bool {
+		return answer == expected
+	})
+}
+
+// ask prints summary and prompt and checks the trimmed answer with accept.
+// Nothing is printed when --yes was given.
+func (p confirmPrompt) ask(summary, prompt string, accept func(answer string) bool) error {
+	if assumeYes {
+		return nil
+	}
+	if !p.interactive {
+		return model.NewCLIError(model.ExitUserCancelled,
+			"confirmation required but stdin is not a terminal; pass --yes to proceed")
+	}
+
+	_, _ = fmt.Fprint(p.out, summary, prompt)
+	scanner := bufio.NewScanner(p.in)
+	if scanner.Scan() && accept(strings.TrimSpace(scanner.Text())) {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return model.WrapCLIError(model.ExitGeneralError, "failed to read user input", err)
+	}
+	return model.NewCLIError(model.ExitUserCancelled, "operation cancelled by user")
+}
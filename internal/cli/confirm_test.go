@@ -0,0 +1,90 @@
+// Package cli — confirm_test.go contains unit tests for the confirmation
+// prompt of destructive commands.
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// setAssumeYes sets the global --yes flag for the duration of a test.
+// Tests using it must not call t.Parallel().
+func setAssumeYes(t *testing.T, enabled bool) {
+	t.Helper()
+	prev := assumeYes
+	assumeYes = enabled
+	t.Cleanup(func() { assumeYes = prev })
+}
+
+// requireExitCode asserts that err is a CLIError with the given code.
+func requireExitCode(t *testing.T, err error, code model.ExitCode) {
+	t.Helper()
+	var cliErr *model.CLIError
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, code, cliErr.Code)
+}
+
+// TestConfirmPrompt_YesNo verifies the accepted answers and that
+// everything else, including end of input, cancels with ExitUserCancelled.
+func TestConfirmPrompt_YesNo(t *testing.T) {
+	setAssumeYes(t, false)
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"  yes  \r\n", true},
+		{"n\n", false},
+		{"yep\n", false},
+		{"\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		p := confirmPrompt{in: strings.NewReader(tt.input), out: &out, interactive: true}
+		err := p.yesNo("About to do it.\n", "Continue?")
+		if tt.want {
+			assert.NoError(t, err, "input %q", tt.input)
+		} else {
+			requireExitCode(t, err, model.ExitUserCancelled)
+		}
+		assert.Equal(t, "About to do it.\nContinue? [y/N] ", out.String())
+	}
+}
+
+// TestConfirmPrompt_NotATerminal verifies that without a terminal the
+// prompt refuses without reading input, even if the input would confirm.
+func TestConfirmPrompt_NotATerminal(t *testing.T) {
+	setAssumeYes(t, false)
+
+	var out strings.Builder
+	p := confirmPrompt{in: strings.NewReader("y\n"), out: &out}
+	err := p.yesNo("", "Continue?")
+	requireExitCode(t, err, model.ExitUserCancelled)
+	assert.Contains(t, err.Error(), "--yes")
+	assert.Empty(t, out.String())
+
+	requireExitCode(t, p.typed("", "myrepo"), model.ExitUserCancelled)
+}
+
+// TestConfirmPrompt_AssumeYes verifies that --yes confirms without
+// prompting, with or without a terminal.
+func TestConfirmPrompt_AssumeYes(t *testing.T) {
+	setAssumeYes(t, true)
+
+	for _, interactive := range []bool{true, false} {
+		var out strings.Builder
+		p := confirmPrompt{in: strings.NewReader(""), out: &out, interactive: interactive}
+		assert.NoError(t, p.yesNo("summary\n", "Continue?"))
+		assert.NoError(t, p.typed("summary\n", "myrepo"))
+		assert.Empty(t, out.String())
+	}
+}
@@ -5,7 +5,7 @@
 // its associated Dev Container environment with shifted ports.
 //
 // Orchestration steps:
-//  1. Determine source repository path
+//  1. Determine source repository path (--repo or the current directory)
 //  2. Determine environment name
 //  3. Determine worktree path
 //  4. Create Git worktree
@@ -15,20 +15,29 @@
 //  8. Extract and allocate shifted ports
 //  9. Build labels and copy/rewrite devcontainer configuration
 //  10. Start containers (unless --no-start)
-//  11. Output results (text or JSON)
+//  11. Output results (text or JSON) and run the post-create hook, if any
+//
+// If a step fails after the worktree was created, the branch, worktree, and
+// containers created by this run are removed again (see rollback.go) unless
+// --rollback-on-failure=false is given.
 package cli
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/mmr-tortoise/loam/internal/config"
 	"github.com/mmr-tortoise/loam/internal/devcontainer"
 	"github.com/mmr-tortoise/loam/internal/docker"
 	"github.com/mmr-tortoise/loam/internal/model"
@@ -39,10 +48,78 @@ import (
 // createFlags holds the flag values for the create command.
 // These are bound to cobra flags in NewCreateCommand.
 type createFlags struct {
-	base    string // --base: base commit/branch for the worktree
-	path    string // --path: custom worktree directory path
-	name    string // --name: custom environment name
-	noStart bool   // --no-start: skip container startup
+	base     string // --base: base commit/branch for the worktree
+	path     string // --path: custom worktree directory path
+	name     string // --name: custom environment name
+	nameFrom string // --env-name-from: how the environment name is derived (branch, dir, custom)
+	noStart  bool   // --no-start: skip container startup
+	reuse    bool   // --reuse: attach to an existing worktree at the target path
+	noPorts  bool   // --no-ports: publish no host ports at all
+	fromPR   int    // --from-pr: GitHub pull request number to check out
+	detach   bool   // --detach: check out a commit with a detached HEAD, creating no branch
+
+	newBranchOnly bool // --new-branch-only: fail instead of checking out an existing branch
+
+	quietGit bool // --quiet-git: pass --quiet to git worktree add
+
+	initSubmodules bool // --init-submodules: check out submodules in the new worktree
+
+	repo string // --repo: repository to create the environment from (default: current directory)
+
+	cloneURL string // --clone-url: clone this repository when not run inside one
+	cloneDir string // --clone-dir: where --clone-url clones to (default: user cache dir)
+
+	projectName   string   // --project-name: Compose project name (default: prefix and environment name)
+	projectPrefix string   // --compose-project-prefix: Compose project name prefix ("none": no prefix)
+	skipPortCheck bool     // --skip-port-check: rely on label-based conflict detection only
+	forcePorts    []string // --force-port: CONTAINER=HOST host ports that bypass the shift formula
+	pull          string   // --pull: image pull policy (always, missing, never)
+	network       string   // --network: existing Docker network the containers also join
+
+	attachToNetwork string // --attach-to-network: environment whose Compose network the containers also join
+
+	buildArgs []string // --build-arg: KEY=VALUE overrides of build.args (Pattern B)
+	baseImage string   // --base-image: image replacing the configured one (Pattern A)
+
+	remoteUser    string // --remote-user: remoteUser of the rewritten devcontainer.json
+	containerUser string // --container-user: containerUser (Pattern A/B) or the primary service's user (Pattern C/D)
+
+	composeFiles []string // --compose-file: extra Compose files applied after the override (Pattern C/D)
+	configDir    string   // --config-dir: write generated files outside the worktree (Pattern C/D)
+
+	annotatePorts bool // --annotate-ports: name the container port in portsAttributes labels (Pattern A/B)
+
+	noGitignore bool // --no-gitignore: don't add the generated files to .devcontainer/.gitignore
+
+	copyEnvFromMain bool     // --copy-env-from-main: seed untracked files from the main checkout
+	copyFiles       []string // --copy-file: allowlist patterns for --copy-env-from-main
+	copyExclude     []string // --copy-exclude: denylist patterns for --copy-env-from-main
+
+	shellInit   bool // --shell-init: print an eval-able cd/export snippet instead of the summary
+	tailOnStart bool // --tail-on-start: follow the primary container's logs after starting
+
+	postCreate string // --post-create: host command run after a successful create (see postcreate.go)
+
+	printConfig bool // --print-config: print the generated configuration instead of creating anything (see printconfig.go)
+
+	index    int  // --index: explicit worktree index (port band)
+	indexSet bool // true if --index was given; 0 is a valid index, so a sentinel won't do
+
+	maxEnvironments int // --max-environments: environment limit (0: project config or default)
+
+	rollbackOnFailure bool // --rollback-on-failure: undo a partially created environment
+
+	skipSpaceCheck bool // --skip-space-check: don't compare .devcontainer's size with the free disk space
+
+	timeout     time.Duration // --timeout: limit for everything but container startup (0: none)
+	pullTimeout time.Duration // --pull-timeout: limit for container startup, including pulls and builds (0: none)
+
+	commit string // commit to check out with --detach; set by resolveCreateBranch
+
+	// dockerOffline is set by runCreate when --no-start is given and Docker
+	// is not available: the steps that would consult Docker then use Git
+	// or are skipped, instead of each trying to connect.
+	dockerOffline bool
 }
 
 // NewCreateCommand creates the "create" cobra command.
@@ -51,7 +128,7 @@ func NewCreateCommand() *cobra.Command {
 	flags := &createFlags{}
 
 	cmd := &cobra.Command{
-		Use:   "create <branch-name>",
+		Use:   "create [branch-name | commit]",
 		Short: "Create a new worktree environment with Dev Containers",
 		Long: `Create a new Git worktree and launch its associated Dev Container environment.
 
@@ -64,61 +141,408 @@ The command automatically:
 Examples:
   loam create feature-auth
   loam create --base main bugfix-login
+  loam create --base auto bugfix-login
   loam create --path ~/dev/feature-auth feature-auth
-  loam create --no-start feature-auth`,
-
-		// Args validates that exactly one positional argument (branch name) is provided.
-		Args: cobra.ExactArgs(1),
+  loam create --env-name-from dir --path ~/dev/auth feature/auth
+  loam create --no-start feature-auth
+  loam create --print-config feature-auth
+  loam create --new-branch-only ci-run-1234
+  loam create --no-ports feature-auth
+  loam create --project-name acme-auth feature-auth
+  loam create --compose-project-prefix acme feature-auth
+  loam create --network shared-proxy feature-auth
+  loam create --attach-to-network feature-api feature-web
+  loam create --config-dir auto feature-auth
+  loam create --build-arg NODE_VERSION=22 feature-auth
+  loam create --base-image node:22-bookworm feature-auth
+  loam create --timeout 1m --pull-timeout 20m feature-auth
+  loam create --reuse --path ../myproject-feature-auth feature-auth
+  loam create --from-pr 123
+  loam create --detach v1.2.0
+  loam create --init-submodules feature-auth
+  loam create --repo ~/src/app feature-auth
+  loam create --clone-url https://github.com/acme/app.git feature-auth
+  loam create --index 3 feature-auth
+  loam create --max-environments 4 feature-auth
+  eval "$(loam create --shell-init feature-auth)"
+  loam create --copy-env-from-main feature-auth
+  loam create --copy-env-from-main --copy-file '.env*' --copy-exclude .env.production feature-auth`,
+
+		// Args allows the branch name to be omitted only with --from-pr,
+		// which derives a default branch name from the PR number, and with
+		// --detach, where the argument is a commit that defaults to HEAD.
+		Args: cobra.RangeArgs(0, 1),
 
 		// RunE is used instead of Run so we can return errors. Cobra will
 		// pass them to the Execute error handler in root.go.
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCreate(cmd.Context(), args[0], flags)
+			// Changed reports whether the user passed the flag explicitly.
+			flags.indexSet = cmd.Flags().Changed("index")
+
+			branchName, err := resolveCreateBranch(args, flags)
+			if err != nil {
+				return err
+			}
+			return runCreate(cmd.Context(), branchName, flags)
 		},
 	}
 
 	// Register command-specific flags.
-	cmd.Flags().StringVar(&flags.base, "base", "", "Base commit/branch for the worktree (default: HEAD)")
+	cmd.Flags().StringVar(&flags.base, "base", "",
+		`Base commit/branch for the worktree, or "auto" for the repository's default branch (default: HEAD)`)
 	cmd.Flags().StringVar(&flags.path, "path", "", "Worktree directory path (default: ../<repo>-<branch>)")
 	cmd.Flags().StringVar(&flags.name, "name", "", "Environment name (default: sanitized branch name)")
+	cmd.Flags().StringVar(&flags.nameFrom, "env-name-from", envNameFromBranch,
+		"Derive the environment name from: branch, dir (basename of --path), or custom (requires --name)")
 	cmd.Flags().BoolVar(&flags.noStart, "no-start", false, "Create worktree only, don't start containers")
+	cmd.Flags().BoolVar(&flags.printConfig, "print-config", false,
+		"Print the generated devcontainer.json (and Compose override) without creating anything")
+	cmd.Flags().BoolVar(&flags.noPorts, "no-ports", false,
+		"Publish no host ports (labels and environment are still applied)")
+	cmd.Flags().StringArrayVar(&flags.forcePorts, "force-port", nil,
+		"Pin a container port to a host port, bypassing the port shift (CONTAINER=HOST, repeatable)")
+	cmd.Flags().BoolVar(&flags.skipPortCheck, "skip-port-check", false,
+		"Don't probe host ports; avoid only ports recorded by other environments (for remote Docker hosts)")
+	cmd.Flags().StringVar(&flags.projectName, "project-name", "",
+		"Compose project name for Compose configurations (default: <prefix>-<environment name>)")
+	cmd.Flags().StringVar(&flags.projectPrefix, "compose-project-prefix", "",
+		`Prefix of the Compose project name, or "none" (default: short hash of the repository path)`)
+	cmd.Flags().StringVar(&flags.network, "network", "",
+		"Existing Docker network to attach the containers to (Compose services keep their default network too)")
+	cmd.Flags().StringVar(&flags.attachToNetwork, "attach-to-network", "",
+		"Attach the containers to the Compose network of another environment, given by name")
+	// StringArray rather than StringSlice: a value may contain commas.
+	cmd.Flags().StringArrayVar(&flags.buildArgs, "build-arg", nil,
+		"Build argument KEY=VALUE overriding build.args for Dockerfile configurations (repeatable)")
+	cmd.Flags().StringVar(&flags.baseImage, "base-image", "",
+		"Image to use instead of the configured image (image configurations only)")
+	cmd.Flags().StringVar(&flags.remoteUser, "remote-user", "",
+		"User that tools and terminals run as in the container (overrides remoteUser)")
+	cmd.Flags().StringVar(&flags.containerUser, "container-user", "",
+		"User the container runs as (overrides containerUser, or sets user: of the primary Compose service)")
+	cmd.Flags().StringArrayVar(&flags.composeFiles, "compose-file", nil,
+		"Extra Compose file applied after the generated override for Compose configurations (repeatable)")
+	cmd.Flags().StringVar(&flags.configDir, "config-dir", "",
+		`Write the generated devcontainer.json and Compose override to this directory instead of the worktree, or "auto" for <user cache dir>/loam/configs/<name> (Compose configurations)`)
+	cmd.Flags().BoolVar(&flags.annotatePorts, "annotate-ports", false,
+		"Add the container port to the portsAttributes label of each shifted port (image and Dockerfile configurations)")
+	cmd.Flags().BoolVar(&flags.noGitignore, "no-gitignore", false,
+		"Don't add the generated files to the worktree's .devcontainer/.gitignore")
+	cmd.Flags().StringVar(&flags.pull, "pull", "",
+		"Image pull policy: always, missing, or never (default: pull missing images)")
+	cmd.Flags().BoolVar(&flags.reuse, "reuse", false,
+		"Use an existing worktree at the target path if it is on the requested branch")
+	cmd.Flags().IntVar(&flags.fromPR, "from-pr", 0, "Check out a GitHub pull request by number (default branch/name: pr-<number>)")
+	cmd.Flags().BoolVar(&flags.newBranchOnly, "new-branch-only", false,
+		"Fail if the branch already exists instead of checking it out")
+	cmd.Flags().BoolVar(&flags.detach, "detach", false,
+		"Check out the given commit (default: HEAD) with a detached HEAD instead of a branch (default name: short SHA)")
+	cmd.Flags().BoolVar(&flags.quietGit, "quiet-git", false,
+		"Pass --quiet to git worktree add, keeping its progress output out of error messages")
+	cmd.Flags().BoolVar(&flags.initSubmodules, "init-submodules", false,
+		"Run git submodule update --init --recursive in the new worktree")
+	cmd.Flags().StringVar(&flags.repo, "repo", "", "Repository to create the environment from (default: current directory)")
+	cmd.Flags().StringVar(&flags.cloneURL, "clone-url", "",
+		"Clone this repository and create the environment from the clone (only outside a Git repository)")
+	cmd.Flags().StringVar(&flags.cloneDir, "clone-dir", "",
+		"Directory for --clone-url (default: <user cache dir>/loam/clones/<repo>)")
+	cmd.Flags().BoolVar(&flags.copyEnvFromMain, "copy-env-from-main", false,
+		"Copy untracked files (e.g., .env) from the main checkout into the new worktree")
+	cmd.Flags().StringSliceVar(&flags.copyFiles, "copy-file", worktree.DefaultSeedFiles,
+		"Files to copy with --copy-env-from-main (glob, relative to repo root; repeatable)")
+	cmd.Flags().StringSliceVar(&flags.copyExclude, "copy-exclude", nil,
+		"Files never copied by --copy-env-from-main (glob, matches path or file name; repeatable)")
+	cmd.Flags().BoolVar(&flags.shellInit, "shell-init", false,
+		"Print shell commands (cd, exports) for eval instead of the normal output")
+	cmd.Flags().BoolVar(&flags.tailOnStart, "tail-on-start", false,
+		"Follow the primary container's logs after starting, until Ctrl-C (containers keep running)")
+	cmd.Flags().StringVar(&flags.postCreate, "post-create", "",
+		"Host command to run in the new worktree after a successful create (default: postCreateHook of .loam.json)")
+	cmd.Flags().IntVar(&flags.index, "index", 0,
+		fmt.Sprintf("Worktree index 0-%d selecting the port band (default: lowest free index)", port.MaxWorktreeIndex))
+	cmd.Flags().IntVar(&flags.maxEnvironments, "max-environments", 0,
+		fmt.Sprintf("Maximum number of concurrent environments, 1-%d (default: maxEnvironments in %s, or %d)",
+			port.DefaultMaxEnvironments, config.FileName, port.DefaultMaxEnvironments))
+	cmd.Flags().BoolVar(&flags.rollbackOnFailure, "rollback-on-failure", true,
+		"Remove the branch, worktree, and containers created by this run if a later step fails")
+	cmd.Flags().BoolVar(&flags.skipSpaceCheck, "skip-space-check", false,
+		"Don't check that the worktree's file system has room for the .devcontainer directory")
+	cmd.Flags().DurationVar(&flags.timeout, "timeout", 0,
+		"Time limit for creating the worktree and configuration, excluding container startup (default: none)")
+	cmd.Flags().DurationVar(&flags.pullTimeout, "pull-timeout", 0,
+		"Time limit for starting the containers, including image pulls and builds (default: none)")
 
 	return cmd
 }
 
+// resolveCreateBranch determines the branch name for the create command from
+// its positional arguments and flags.
+//
+// Without --from-pr the branch name argument is required. With --from-pr it
+// is optional and overrides the default "pr-<N>" local branch name. --base is
+// rejected with --from-pr because the PR head already determines the commit.
+//
+// With --detach the optional argument is a commit rather than a branch. It is
+// stored in flags.commit and the returned branch name is empty, since a
+// detached worktree has no branch.
+func resolveCreateBranch(args []string, flags *createFlags) (string, error) {
+	if flags.shellInit && IsJSONOutput() {
+		return "", model.NewCLIError(model.ExitGeneralError, "--shell-init cannot be used with --output json")
+	}
+
+	if flags.detach {
+		switch {
+		case flags.fromPR != 0:
+			return "", model.NewCLIError(model.ExitGeneralError, "--detach cannot be used with --from-pr")
+		case flags.base != "":
+			return "", model.NewCLIError(model.ExitGeneralError, "--base cannot be used with --detach; pass the commit as the argument")
+		case flags.reuse:
+			return "", model.NewCLIError(model.ExitGeneralError, "--reuse cannot be used with --detach")
+		case flags.newBranchOnly:
+			return "", model.NewCLIError(model.ExitGeneralError, "--new-branch-only cannot be used with --detach, which creates no branch")
+		}
+		flags.commit = "HEAD"
+		if len(args) == 1 {
+			flags.commit = args[0]
+		}
+		return "", nil
+	}
+
+	if flags.newBranchOnly && flags.reuse {
+		return "", model.NewCLIError(model.ExitGeneralError, "--new-branch-only cannot be used with --reuse")
+	}
+
+	if flags.fromPR < 0 {
+		return "", model.NewCLIError(model.ExitGeneralError, "--from-pr must be a positive pull request number")
+	}
+
+	if flags.fromPR == 0 {
+		if len(args) != 1 {
+			return "", model.NewCLIError(model.ExitGeneralError, "branch name is required (or use --from-pr <number>)")
+		}
+		return args[0], nil
+	}
+
+	if flags.base != "" {
+		return "", model.NewCLIError(model.ExitGeneralError, "--base cannot be used with --from-pr")
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return pullRequestBranch(flags.fromPR), nil
+}
+
+// projectPrefixNone is the --compose-project-prefix value that disables the
+// prefix, making the environment name the Compose project name.
+const projectPrefixNone = "none"
+
+// resolveProjectPrefix returns the Compose project name prefix for an
+// environment of the repository at repoRoot: none with --project-name,
+// which names the project in full; the --compose-project-prefix value if
+// given; and model.DefaultProjectPrefix otherwise.
+func resolveProjectPrefix(flags *createFlags, repoRoot string) (string, error) {
+	switch {
+	case flags.projectName != "":
+		if flags.projectPrefix != "" {
+			return "", model.NewCLIError(model.ExitGeneralError, "--compose-project-prefix cannot be used with --project-name")
+		}
+		return "", nil
+	case flags.projectPrefix == projectPrefixNone:
+		return "", nil
+	case flags.projectPrefix != "":
+		if err := model.ValidateProjectName(flags.projectPrefix); err != nil {
+			return "", model.WrapCLIError(model.ExitGeneralError, "invalid --compose-project-prefix", err)
+		}
+		return flags.projectPrefix, nil
+	}
+	return model.DefaultProjectPrefix(repoRoot), nil
+}
+
 // runCreate is the main orchestration function for the create command.
-// It coordinates all the steps needed to create a worktree environment.
+// It coordinates all the steps needed to create a worktree environment
+// (see createEnv), sharing one Docker connection among them.
 func runCreate(ctx context.Context, branchName string, flags *createFlags) error {
+	dc := newDockerConn()
+	defer dc.close()
+	return createEnv(ctx, branchName, flags, dc)
+}
+
+// createEnv runs the steps of create, consulting Docker through dc.
+//
+// Side effects from Step 4 onwards are recorded in a createRollback, which
+// runs when createEnv returns an error.
+func createEnv(ctx context.Context, branchName string, flags *createFlags, dc *dockerConn) (retErr error) {
+	if flags.timeout < 0 || flags.pullTimeout < 0 {
+		return model.NewCLIError(model.ExitGeneralError, "--timeout and --pull-timeout must not be negative")
+	}
+	// --print-config creates nothing, so like --no-start it gets by
+	// without Docker. A clone would be a side effect.
+	if flags.printConfig {
+		if flags.cloneURL != "" {
+			return model.NewCLIError(model.ExitGeneralError, "--print-config cannot be combined with --clone-url")
+		}
+		flags.noStart = true
+	}
+
+	// --timeout bounds every step except container startup, which gets its
+	// own --pull-timeout (see runPullPhase). parent is kept for that step
+	// and for following logs, neither of which --timeout applies to.
+	parent := ctx
+	ctx, cancel := withPhaseTimeout(parent, flags.timeout)
+	defer cancel()
+
 	// Step 1: Determine the source repository path.
 	// We need the repo root to create worktrees relative to it.
 	wm := worktree.NewManager()
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		return model.WrapCLIError(model.ExitGeneralError, "failed to get current directory", err)
+	wm.SetQuiet(flags.quietGit)
+
+	// The repository is looked up from --repo, or else from the current
+	// directory.
+	start := flags.repo
+	var err error
+	if start == "" {
+		start, err = os.Getwd()
+		if err != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to get current directory", err)
+		}
+	} else {
+		if flags.cloneURL != "" {
+			return model.NewCLIError(model.ExitGeneralError, "--repo cannot be combined with --clone-url")
+		}
+		start, err = filepath.Abs(flags.repo)
+		if err != nil {
+			return model.WrapCLIError(model.ExitGeneralError, fmt.Sprintf("invalid --repo path %q", flags.repo), err)
+		}
 	}
 
-	repoRoot, err := wm.GetRepoRoot(cwd)
-	if err != nil {
+	// With --clone-url, the repository is cloned first when there is no
+	// local checkout to create the worktree from.
+	cloned := false
+	repoRoot, err := wm.GetRepoRoot(start)
+	switch {
+	case err != nil && flags.repo != "":
+		return model.WrapCLIError(model.ExitGitError, fmt.Sprintf("--repo %s is not inside a Git repository", start), err)
+	case err == nil && flags.cloneURL != "":
+		return model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("--clone-url cannot be used inside a Git repository (%s); run it from another directory", repoRoot))
+	case err != nil && flags.cloneURL == "":
 		return model.WrapCLIError(model.ExitGitError, "not inside a Git repository", err)
+	case err != nil:
+		repoRoot, cloned, err = cloneRepo(wm, flags.cloneURL, flags.cloneDir)
+		if err != nil {
+			return err
+		}
 	}
 	VerboseLog("Source repository: %s", repoRoot)
 
-	// Step 2: Determine environment name.
-	// Default: sanitize the branch name by replacing slashes with hyphens.
-	envName := flags.name
-	if envName == "" {
-		envName = sanitizeBranchName(branchName)
+	// With --new-branch-only, an existing branch is an error rather than
+	// something to check out, so stale state is never picked up silently.
+	if flags.newBranchOnly && branchName != "" && wm.BranchExists(repoRoot, branchName) {
+		return model.NewCLIError(model.ExitGitError,
+			fmt.Sprintf("branch %q already exists (--new-branch-only); delete it or choose another name", branchName))
+	}
+
+	projectConfig, err := config.Load(repoRoot)
+	if err != nil {
+		return model.WrapCLIError(model.ExitGeneralError, "failed to load project configuration", err)
+	}
+
+	// Step 2: Determine environment name (see resolveEnvName).
+	// A detached worktree has no branch, so the short commit SHA stands in
+	// for the branch name.
+	nameBranch := branchName
+	if flags.detach && flags.name == "" && (flags.nameFrom == "" || flags.nameFrom == envNameFromBranch) {
+		nameBranch, err = wm.ShortCommit(repoRoot, flags.commit)
+		if err != nil {
+			return model.WrapCLIError(model.ExitGitError, fmt.Sprintf("cannot resolve commit %q", flags.commit), err)
+		}
 	}
-	if validateErr := model.ValidateName(envName); validateErr != nil {
-		return model.WrapCLIError(model.ExitGeneralError, "invalid environment name", validateErr)
+	envName, err := resolveEnvName(flags, nameBranch, flags.path)
+	if err != nil {
+		return err
 	}
 	VerboseLog("Environment name: %s", envName)
 
+	// The project name only matters for Compose configurations, but it is
+	// validated before any side effects since the pattern is not known yet.
+	if flags.projectName != "" {
+		if validateErr := model.ValidateProjectName(flags.projectName); validateErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "invalid --project-name", validateErr)
+		}
+	}
+	projectPrefix, err := resolveProjectPrefix(flags, repoRoot)
+	if err != nil {
+		return err
+	}
+	pullPolicy, err := docker.ParsePullPolicy(flags.pull)
+	if err != nil {
+		return model.WrapCLIError(model.ExitGeneralError, "invalid --pull", err)
+	}
+	buildArgs, err := parseBuildArgs(flags.buildArgs)
+	if err != nil {
+		return err
+	}
+	forcedPorts, err := parseForcePorts(flags.forcePorts)
+	if err != nil {
+		return err
+	}
+	if len(forcedPorts) > 0 && flags.noPorts {
+		return model.NewCLIError(model.ExitGeneralError, "--force-port cannot be combined with --no-ports")
+	}
+	if flags.attachToNetwork != "" && flags.network != "" {
+		return model.NewCLIError(model.ExitGeneralError, "--attach-to-network cannot be combined with --network")
+	}
+	extraComposeFiles, err := resolveExtraComposeFiles(flags.composeFiles)
+	if err != nil {
+		return err
+	}
+	configDir, err := resolveConfigDir(flags.configDir, envName)
+	if err != nil {
+		return err
+	}
+	allocCfg, err := resolveAllocatorConfig(flags, projectConfig)
+	if err != nil {
+		return err
+	}
+	if flags.noStart && !dockerAvailable(ctx, dc) {
+		VerboseLog("Docker not available; continuing without it (--no-start)")
+		flags.dockerOffline = true
+	}
+	if flags.base == baseAuto {
+		flags.base, err = resolveDefaultBase(wm, repoRoot)
+		if err != nil {
+			return err
+		}
+		VerboseLog("Base: %s (--base auto)", flags.base)
+	}
+
 	// Step 3: Determine worktree path.
-	// Default: sibling directory named <repo>-<envName>.
+	// --path wins; otherwise the worktreePathTemplate from the project
+	// configuration is rendered. Default: sibling directory <repo>-<envName>.
 	worktreePath := flags.path
-	if worktreePath == "" {
+	worktreeIndex := model.UnknownWorktreeIndex
+	templated := false
+	switch {
+	case worktreePath != "":
+	case projectConfig.WorktreePathTemplate != "":
+		// The template may refer to the index, so it is determined now
+		// rather than in Step 8.
+		worktreeIndex, err = resolveWorktreeIndex(ctx, dc, wm, repoRoot, envName, flags, allocCfg)
+		if err != nil {
+			return err
+		}
+		worktreePath, err = worktree.RenderPathTemplate(projectConfig.WorktreePathTemplate, repoRoot, worktree.PathTemplateData{
+			Repo:   filepath.Base(repoRoot),
+			Branch: branchName,
+			Name:   envName,
+			Index:  worktreeIndex,
+		})
+		if err != nil {
+			return model.WrapCLIError(model.ExitGeneralError,
+				fmt.Sprintf("invalid worktreePathTemplate in %s", config.FileName), err)
+		}
+		templated = true
+	default:
 		repoName := filepath.Base(repoRoot)
 		worktreePath = filepath.Join(filepath.Dir(repoRoot), repoName+"-"+envName)
 	}
@@ -129,12 +553,174 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 	}
 	VerboseLog("Worktree path: %s", worktreePath)
 
+	// Step 3.5: Validate an explicit --index before touching Git, so that a
+	// conflict does not leave a half-created worktree behind.
+	if flags.indexSet {
+		if indexErr := validateRequestedIndex(ctx, dc, wm, repoRoot, envName, flags.index, allocCfg, flags.dockerOffline); indexErr != nil {
+			return indexErr
+		}
+		worktreeIndex = flags.index
+	}
+
+	// From here on, --attach-to-network is handled as a --network naming
+	// the other environment's network.
+	if flags.attachToNetwork != "" {
+		network, attachErr := resolveAttachNetwork(ctx, dc, flags.attachToNetwork)
+		if attachErr != nil {
+			return attachErr
+		}
+		VerboseLog("Attaching to network %s of environment %q", network, flags.attachToNetwork)
+		flags.network = network
+	}
+
+	// A missing --network would only surface when the containers start,
+	// after the worktree has been created; check it up front instead.
+	if flags.network != "" && !flags.noStart {
+		if networkErr := checkNetworkExists(ctx, dc, flags.network); networkErr != nil {
+			return networkErr
+		}
+	}
+
+	if flags.printConfig {
+		return printEnvConfig(ctx, dc, wm, flags, configPreview{
+			repoRoot:          repoRoot,
+			envName:           envName,
+			branchName:        branchName,
+			worktreePath:      worktreePath,
+			worktreeIndex:     worktreeIndex,
+			projectPrefix:     projectPrefix,
+			buildArgs:         buildArgs,
+			forcedPorts:       forcedPorts,
+			extraComposeFiles: extraComposeFiles,
+			configDir:         configDir,
+			allocCfg:          allocCfg,
+		})
+	}
+
+	// A copy of .devcontainer that runs out of space halfway would leave a
+	// broken worktree, so make sure it fits before creating anything.
+	if !flags.skipSpaceCheck {
+		if srcConfig, findErr := devcontainer.FindDevContainerJSON(repoRoot); findErr == nil && srcConfig != "" {
+			if spaceErr := checkWorktreeSpace(filepath.Dir(srcConfig), worktreePath, freeDiskBytes); spaceErr != nil {
+				return spaceErr
+			}
+		}
+	}
+
+	// From here on, every side effect is recorded so that a failure in a
+	// later step does not leave a half-built environment behind. Rollback
+	// output goes to stderr so that stdout stays valid JSON.
+	rb := &createRollback{}
+	defer func() {
+		if retErr != nil {
+			rb.run(flags.rollbackOnFailure, os.Stderr)
+		}
+	}()
+	if cloned {
+		rb.push(fmt.Sprintf("clone of %s at %s", flags.cloneURL, repoRoot), func() error { return os.RemoveAll(repoRoot) })
+	}
+	removeWorktree := func() error { return wm.Remove(repoRoot, worktreePath, true) }
+	deleteBranch := func() error { return wm.DeleteBranch(repoRoot, branchName) }
+
+	// Templates typically nest worktrees (e.g., ~/worktrees/<repo>/<branch>),
+	// so the parent directories may not exist yet.
+	if templated {
+		if mkErr := os.MkdirAll(filepath.Dir(worktreePath), 0o755); mkErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to create worktree parent directory", mkErr)
+		}
+	}
+
 	// Step 4: Create Git worktree.
-	VerboseLog("Creating Git worktree for branch %q...", branchName)
-	if addErr := wm.Add(repoRoot, branchName, worktreePath, flags.base); addErr != nil {
-		return model.WrapCLIError(model.ExitGitError, "failed to create worktree", addErr)
+	// With --reuse, an existing worktree for the branch is used as-is.
+	// With --from-pr, the PR head is fetched from the remote into a new local
+	// branch; otherwise the branch is created (or checked out) locally.
+	reused := false
+	if flags.reuse {
+		reused, err = checkReusableWorktree(wm, repoRoot, worktreePath, branchName)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case reused:
+		VerboseLog("Reusing existing worktree at %s", worktreePath)
+		rb.push(fmt.Sprintf("existing worktree at %s (reused)", worktreePath), nil)
+	case flags.detach:
+		VerboseLog("Creating detached Git worktree at %q...", flags.commit)
+		if addErr := wm.AddDetached(repoRoot, worktreePath, flags.commit); addErr != nil {
+			return model.WrapCLIError(model.ExitGitError, "failed to create worktree", addErr)
+		}
+		rb.push(fmt.Sprintf("Git worktree at %s", worktreePath), removeWorktree)
+		VerboseLog("Git worktree created successfully")
+	case flags.fromPR > 0:
+		// PR metadata is informational only, so a failed lookup is not fatal.
+		if info, lookupErr := lookupPullRequest(ctx, repoRoot, flags.fromPR); lookupErr != nil {
+			VerboseLog("Skipping PR metadata lookup: %v", lookupErr)
+		} else {
+			VerboseLog("Pull request #%d: %s (head: %s)", info.Number, info.Title, info.headLabel())
+		}
+
+		ref := pullRequestRef(flags.fromPR)
+		VerboseLog("Fetching %s/%s into branch %q...", pullRequestRemote, ref, branchName)
+		branchExisted := wm.BranchExists(repoRoot, branchName)
+		if addErr := wm.AddFromRemote(repoRoot, pullRequestRemote, ref, branchName, worktreePath); addErr != nil {
+			if flags.rollbackOnFailure {
+				deleteLeakedBranch(wm, repoRoot, branchName, branchExisted)
+			}
+			return model.WrapCLIError(model.ExitGitError,
+				fmt.Sprintf("failed to create worktree for pull request #%d", flags.fromPR), addErr)
+		}
+		// AddFromRemote never reuses an existing branch, so both are new.
+		rb.push(fmt.Sprintf("branch %q", branchName), deleteBranch)
+		rb.push(fmt.Sprintf("Git worktree at %s", worktreePath), removeWorktree)
+		VerboseLog("Git worktree created successfully")
+	default:
+		VerboseLog("Creating Git worktree for branch %q...", branchName)
+		// Only a branch created by this run is deleted on rollback.
+		branchExisted := wm.BranchExists(repoRoot, branchName)
+		base := flags.base
+		if flags.cloneURL != "" {
+			base = cloneBaseRef(wm, repoRoot, branchName, base)
+		}
+		if !branchExisted {
+			warnIfBaseBehind(wm, repoRoot, base)
+		}
+		if addErr := wm.Add(repoRoot, branchName, worktreePath, base); addErr != nil {
+			if flags.rollbackOnFailure {
+				deleteLeakedBranch(wm, repoRoot, branchName, branchExisted)
+			}
+			return model.WrapCLIError(model.ExitGitError, "failed to create worktree", addErr)
+		}
+		if !branchExisted {
+			rb.push(fmt.Sprintf("branch %q", branchName), deleteBranch)
+		}
+		rb.push(fmt.Sprintf("Git worktree at %s", worktreePath), removeWorktree)
+		VerboseLog("Git worktree created successfully")
+	}
+
+	// Step 4.4: Check out submodules. `git worktree add` leaves their
+	// directories empty. Opt-in, since it may clone over the network.
+	if flags.initSubmodules {
+		VerboseLog("Initializing submodules...")
+		if subErr := wm.InitSubmodules(worktreePath); subErr != nil {
+			return model.WrapCLIError(model.ExitGitError, "failed to initialize submodules", subErr)
+		}
+	}
+
+	// Step 4.5: Seed gitignored files (e.g., .env) from the main checkout.
+	// `git worktree add` only checks out tracked files, so without this the
+	// Dev Container may fail to start for lack of local configuration.
+	if flags.copyEnvFromMain {
+		copied, copyErr := worktree.CopySeedFiles(repoRoot, worktreePath, flags.copyFiles, flags.copyExclude)
+		if copyErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to copy files from the main checkout", copyErr)
+		}
+		for _, rel := range copied {
+			VerboseLog("Copied %s from the main checkout", rel)
+		}
+		VerboseLog("Copied %d file(s) from the main checkout", len(copied))
 	}
-	VerboseLog("Git worktree created successfully")
 
 	// Step 5: Place marker file with initial configPattern=none.
 	// The marker file is always created first with PatternNone, then updated
@@ -148,6 +734,9 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 		ConfigPattern:  model.PatternNone,
 		CreatedAt:      time.Now().UTC().Format(time.RFC3339),
 	}
+	if worktreeIndex != model.UnknownWorktreeIndex {
+		marker.Index = &worktreeIndex
+	}
 	if writeErr := worktree.WriteMarkerFile(worktreePath, marker); writeErr != nil {
 		return model.WrapCLIError(model.ExitGeneralError, "failed to write marker file", writeErr)
 	}
@@ -173,9 +762,11 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 			Status:         model.StatusNoContainer,
 			ConfigPattern:  model.PatternNone,
 			CreatedAt:      time.Now().UTC(),
+			Index:          model.UnknownWorktreeIndex,
 		}
-		printCreateResult(env)
-		return nil
+		rb.commit()
+		printCreateResult(env, flags.shellInit)
+		return runPostCreateHook(parent, resolvePostCreateHook(flags, projectConfig), env, "", tailLogsOutput(flags))
 	}
 	VerboseLog("Found devcontainer.json: %s", devcontainerPath)
 
@@ -193,16 +784,11 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 	}
 
 	// Step 7: Detect configuration pattern.
-	// For Compose patterns, we need to count services from the Compose file.
-	composeServiceCount := 0
-	composeFiles := devcontainer.GetComposeFiles(rawConfig)
-	if len(composeFiles) > 0 {
-		composeServiceCount = countComposeServices(rawConfig)
+	pattern, composeFiles, configDir, err := detectConfigPattern(rawConfig, flags, buildArgs, extraComposeFiles, configDir)
+	if err != nil {
+		return err
 	}
 
-	pattern := devcontainer.DetectPattern(rawConfig, composeServiceCount)
-	VerboseLog("Detected pattern: %s", pattern)
-
 	// Step 7.5: Update the marker file with the detected config pattern.
 	// The marker was initially created with PatternNone in Step 5;
 	// now that we know the actual pattern, update it.
@@ -213,39 +799,25 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 	VerboseLog("Marker file updated with pattern: %s", pattern)
 
 	// Step 8: Extract ports and allocate shifted ports.
-	defaultServiceName := envName
-	if rawConfig.Service != "" {
-		defaultServiceName = rawConfig.Service
-	}
-	originalPorts := devcontainer.ExtractPorts(rawConfig, defaultServiceName)
-	VerboseLog("Found %d port(s) to allocate", len(originalPorts))
-
-	// Determine worktree index by counting existing environments.
-	worktreeIndex, err := determineWorktreeIndex(ctx)
-	if err != nil {
-		VerboseLog("Could not determine worktree index, using 1: %v", err)
-		worktreeIndex = 1
+	// Determine worktree index, unless the path template needed it in Step 3.
+	// The marker records a newly resolved index, so that later creates
+	// see it as taken even while the environment has no containers.
+	if worktreeIndex == model.UnknownWorktreeIndex {
+		worktreeIndex, err = resolveWorktreeIndex(ctx, dc, wm, repoRoot, envName, flags, allocCfg)
+		if err != nil {
+			return err
+		}
+		marker.Index = &worktreeIndex
+		if updateErr := worktree.WriteMarkerFile(worktreePath, marker); updateErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to update marker file", updateErr)
+		}
 	}
 	VerboseLog("Worktree index: %d", worktreeIndex)
 
-	scanner := port.NewScanner()
-	allocator := port.NewAllocator(scanner)
-
-	// Load existing allocations from running containers to avoid conflicts.
-	existingAllocs, err := loadExistingAllocations(ctx)
-	if err != nil {
-		VerboseLog("Could not load existing allocations: %v", err)
-	} else {
-		allocator.SetExistingAllocations(existingAllocs)
-	}
-
-	portAllocations, err := allocator.AllocatePorts(originalPorts, worktreeIndex)
+	composeServices, portAllocations, err := allocateEnvPorts(ctx, dc, devcontainerPath, rawConfig, pattern, composeFiles,
+		envName, worktreeIndex, flags, allocCfg, forcedPorts)
 	if err != nil {
-		return model.WrapCLIError(model.ExitPortAllocationFailed, "port allocation failed", err)
-	}
-
-	for _, pa := range portAllocations {
-		VerboseLog("Port allocated: %s", pa.String())
+		return err
 	}
 
 	// Step 9: Build labels for the environment.
@@ -258,8 +830,17 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 		ConfigPattern:   pattern,
 		PortAllocations: portAllocations,
 		CreatedAt:       time.Now().UTC(),
+		Index:           worktreeIndex,
+		ProjectName:     flags.projectName,
+		ShutdownAction:  rawConfig.ShutdownAction,
+	}
+	if pattern.IsCompose() {
+		env.ProjectPrefix = projectPrefix
+	}
+	labels, err := docker.BuildLabels(env)
+	if err != nil {
+		return model.WrapCLIError(model.ExitGeneralError, "invalid environment", err)
 	}
-	labels := docker.BuildLabels(env)
 
 	// Step 9.5: Copy .devcontainer directory and rewrite configuration.
 	srcDevcontainerDir := filepath.Dir(devcontainerPath)
@@ -270,31 +851,62 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 		return model.WrapCLIError(model.ExitGeneralError, "failed to copy .devcontainer directory", err)
 	}
 
+	// Build paths outside .devcontainer are not copied; warn so a failing
+	// build in the worktree is not a mystery.
+	for _, warning := range devcontainer.CheckBuildPaths(srcDevcontainerDir, dstDevcontainerDir, repoRoot, worktreePath, rawConfig.Build) {
+		printWarning("%s", warning)
+	}
+
+	var rewrittenJSON []byte
 	if pattern.IsCompose() {
-		// Pattern C/D: Generate Compose override YAML.
-		VerboseLog("Generating Compose override YAML...")
+		// Pattern C/D: Point the Compose file paths at the worktree.
+		// Files outside .devcontainer (e.g., "../docker-compose.yml") were not
+		// copied above and would otherwise resolve against the wrong checkout.
+		resolvedComposeFiles, err := devcontainer.ResolveComposeFiles(srcDevcontainerDir, dstDevcontainerDir, repoRoot, worktreePath, composeFiles)
+		if err != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to prepare Compose files", err)
+		}
+		composeFiles = resolvedComposeFiles
+
+		// With --config-dir the generated files go there, and every path
+		// Compose and devcontainer.json see becomes absolute.
+		overrideRef := devcontainer.ComposeOverrideFileName
+		if configDir != "" {
+			if err := prepareConfigDir(configDir, rb); err != nil {
+				return err
+			}
+			composeFiles = composeFilePaths(dstDevcontainerDir, composeFiles)
+			overrideRef = filepath.Join(configDir, devcontainer.ComposeOverrideFileName)
+			env.ConfigDir = configDir
+			// From here on, the generated files are written to configDir,
+			// and Compose runs there.
+			dstDevcontainerDir = configDir
+		}
 
-		// Determine all services for the override.
-		services := rawConfig.RunServices
-		if len(services) == 0 && rawConfig.Service != "" {
-			services = []string{rawConfig.Service}
+		// Record the full chain so lifecycle commands use the same files.
+		env.ComposeFiles = composeFileChain(composeFiles, overrideRef, extraComposeFiles)
+		labels, err = docker.BuildLabels(env)
+		if err != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "invalid environment", err)
 		}
+		VerboseLog("Compose files for worktree: %v", env.ComposeFiles)
 
-		overrideData, err := devcontainer.GenerateComposeOverride(envName, services, portAllocations, labels)
+		overrideData, err := generateComposeOverride(env, rawConfig, composeServices, labels, flags,
+			parseComposeResourcesOrWarn(dstDevcontainerDir, composeFiles))
 		if err != nil {
-			return model.WrapCLIError(model.ExitGeneralError, "failed to generate Compose override", err)
+			return err
 		}
 
-		overridePath := filepath.Join(dstDevcontainerDir, "docker-compose.worktree.yml")
+		overridePath := filepath.Join(dstDevcontainerDir, devcontainer.ComposeOverrideFileName)
 		if writeErr := devcontainer.WriteComposeOverride(overridePath, overrideData); writeErr != nil {
 			return model.WrapCLIError(model.ExitGeneralError, "failed to write Compose override", writeErr)
 		}
 		VerboseLog("Compose override written to: %s", overridePath)
 
 		// Rewrite devcontainer.json to include the override file.
-		rewrittenJSON, err := devcontainer.RewriteComposeConfig(rawJSON, envName, "docker-compose.worktree.yml")
+		rewrittenJSON, err = rewriteComposeConfig(rawJSON, envName, composeFiles, overrideRef, flags)
 		if err != nil {
-			return model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json for Compose", err)
+			return err
 		}
 
 		dstDevcontainerJSON := filepath.Join(dstDevcontainerDir, "devcontainer.json")
@@ -303,10 +915,9 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 		}
 	} else {
 		// Pattern A/B: Rewrite devcontainer.json directly.
-		VerboseLog("Rewriting devcontainer.json for pattern %s...", pattern)
-		rewrittenJSON, err := devcontainer.RewriteConfig(rawJSON, envName, worktreeIndex, portAllocations, labels)
+		rewrittenJSON, err = rewriteImageConfig(rawJSON, env, labels, flags, buildArgs)
 		if err != nil {
-			return model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+			return err
 		}
 
 		dstDevcontainerJSON := filepath.Join(dstDevcontainerDir, "devcontainer.json")
@@ -315,10 +926,34 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 		}
 	}
 
+	// The checksum of the rewritten file lets remove --keep-worktree tell
+	// whether it is still safe to restore (see cleanupGeneratedFiles).
+	if env.ConfigDir == "" {
+		marker.ConfigChecksum = worktree.ConfigChecksum(rewrittenJSON)
+		if updateErr := worktree.WriteMarkerFile(worktreePath, marker); updateErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to update marker file", updateErr)
+		}
+	}
+
+	// Step 9.6: Keep the generated files out of `git status`, so they are
+	// not committed from the worktree by accident.
+	if !flags.noGitignore && env.ConfigDir == "" {
+		ignoreGeneratedFiles(wm, worktreePath, pattern)
+	}
+
 	// Step 10: Start containers (unless --no-start).
 	if !flags.noStart {
 		VerboseLog("Starting containers...")
-		if err := startContainers(ctx, pattern, dstDevcontainerDir, composeFiles, envName, rawConfig); err != nil {
+		// Recorded before starting: a failed start may still have created
+		// some of the containers. The rollback must run even if ctx was
+		// cancelled (e.g., by Ctrl-C), hence WithoutCancel.
+		rb.push(fmt.Sprintf("containers of environment %q", envName), func() error {
+			return removeStartedContainers(context.WithoutCancel(ctx), pattern, dstDevcontainerDir, env.ComposeFiles, env.ComposeProjectName())
+		})
+		err := runPullPhase(parent, flags.pullTimeout, func(startCtx context.Context) error {
+			return startContainers(startCtx, pattern, dstDevcontainerDir, env.ComposeFiles, env.ComposeProjectName(), pullPolicy, rawConfig)
+		})
+		if err != nil {
 			return err
 		}
 		env.Status = model.StatusRunning
@@ -327,90 +962,1027 @@ func runCreate(ctx context.Context, branchName string, flags *createFlags) error
 		VerboseLog("Skipping container startup (--no-start)")
 	}
 
-	// Step 11: Output results.
-	printCreateResult(env)
-	return nil
+	// Step 11: Output results. The environment is complete at this point,
+	// so a failure to follow logs afterwards must not roll it back.
+	rb.commit()
+	printCreateResult(env, flags.shellInit)
+	if err := runPostCreateHook(parent, resolvePostCreateHook(flags, projectConfig), env, rawConfig.Service, tailLogsOutput(flags)); err != nil {
+		return err
+	}
+	return tailCreatedEnv(parent, env, rawConfig.Service, flags, followEnvLogs)
 }
 
-// sanitizeBranchName converts a Git branch name to a valid environment name.
-// Replaces "/" with "-" and strips invalid characters.
-func sanitizeBranchName(branch string) string {
-	// Replace common branch name separators with hyphens.
-	name := strings.ReplaceAll(branch, "/", "-")
-	name = strings.ReplaceAll(name, "_", "-")
-
-	// Remove any characters that aren't alphanumeric or hyphens.
-	var result strings.Builder
-	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
-		}
+// deleteLeakedBranch deletes branchName after a failed worktree add, if the
+// add created it: `git worktree add -b` creates the branch before it checks
+// out the worktree, so a late failure leaves the branch behind, and the
+// next create with the same name would report that it already exists. The
+// rollback stack does not cover this, since its branch step is only pushed
+// once the worktree exists, so callers run it with --rollback-on-failure.
+// existed is whether the branch existed before.
+func deleteLeakedBranch(wm *worktree.Manager, repoRoot, branchName string, existed bool) {
+	if existed || !wm.BranchExists(repoRoot, branchName) {
+		return
 	}
-	name = result.String()
-
-	// Trim leading/trailing hyphens.
-	name = strings.Trim(name, "-")
-
-	if name == "" {
-		name = "worktree"
+	if err := wm.DeleteBranch(repoRoot, branchName); err != nil {
+		printWarning("could not delete branch %q left behind by the failed worktree add: %v", branchName, err)
+		return
 	}
-	return name
+	VerboseLog("Deleted branch %q created by the failed worktree add", branchName)
 }
 
-// countComposeServices returns the number of services defined in the
-// devcontainer's Compose configuration. For Pattern C, this is 1.
-// For Pattern D, this is 2 or more.
-func countComposeServices(raw *devcontainer.RawDevContainer) int {
-	// The runServices field explicitly lists which services to start.
-	// If present, its length gives us the service count.
-	if len(raw.RunServices) > 0 {
-		return len(raw.RunServices)
+// detectConfigPattern detects the configuration pattern of rawConfig and
+// checks the pattern-specific flags against it. It returns the Compose
+// files of the configuration and configDir, which is cleared for patterns
+// other than Compose. --base-image is applied to rawConfig.
+func detectConfigPattern(rawConfig *devcontainer.RawDevContainer, flags *createFlags, buildArgs map[string]string, extraComposeFiles []string, configDir string) (model.ConfigPattern, []string, string, error) {
+	// For Compose patterns, we need to count services from the Compose file.
+	composeServiceCount := 0
+	composeFiles := devcontainer.GetComposeFiles(rawConfig)
+	if len(composeFiles) > 0 {
+		composeServiceCount = countComposeServices(rawConfig)
 	}
 
-	// If runServices is not set, there's at least the primary service.
-	if raw.Service != "" {
-		return 1
+	pattern := devcontainer.DetectPattern(rawConfig, composeServiceCount)
+	VerboseLog("Detected pattern: %s", pattern)
+	// Unlike the flags below, --base-image is an error for other patterns:
+	// ignoring it would start the environment on an image the user asked
+	// not to use.
+	if flags.baseImage != "" {
+		if pattern != model.PatternImage {
+			return "", nil, "", model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("--base-image only applies to image-based configurations, not pattern %s", pattern))
+		}
+		VerboseLog("Using image %s instead of %s (--base-image)", flags.baseImage, rawConfig.Image)
+		rawConfig.Image = flags.baseImage
+	}
+	if len(buildArgs) > 0 && pattern != model.PatternDockerfile {
+		printWarning("--build-arg only applies to Dockerfile-based configurations; ignored for pattern %s", pattern)
+	}
+	if len(extraComposeFiles) > 0 && !pattern.IsCompose() {
+		printWarning("--compose-file only applies to Compose configurations; ignored for pattern %s", pattern)
+	}
+	if configDir != "" && !pattern.IsCompose() {
+		printWarning("--config-dir only applies to Compose configurations; ignored for pattern %s", pattern)
+		configDir = ""
+	}
+	if flags.annotatePorts && pattern.IsCompose() {
+		printWarning("--annotate-ports only applies to image and Dockerfile configurations; ignored for pattern %s", pattern)
+	}
+	return pattern, composeFiles, configDir, nil
+}
+
+// allocateEnvPorts extracts the ports of the configuration at
+// devcontainerPath and allocates host ports for them in the port band of
+// worktreeIndex. For Compose patterns it also returns the services of the
+// Compose files, which are checked against the override's services when it
+// is generated.
+func allocateEnvPorts(ctx context.Context, dc *dockerConn, devcontainerPath string, rawConfig *devcontainer.RawDevContainer, pattern model.ConfigPattern, composeFiles []string,
+	envName string, worktreeIndex int, flags *createFlags, allocCfg port.AllocatorConfig, forcedPorts map[int]int) ([]devcontainer.ComposeService, []model.PortAllocation, error) {
+	defaultServiceName := envName
+	if rawConfig.Service != "" {
+		defaultServiceName = rawConfig.Service
+	}
+	var composeServices []devcontainer.ComposeService
+	if pattern.IsCompose() {
+		composeServices = parseComposeServicesOrWarn(filepath.Dir(devcontainerPath), composeFiles)
+	}
+	// With --no-ports nothing is extracted, so no ports are allocated and
+	// the rewritten config publishes none (see the rewrite step).
+	var originalPorts []model.PortSpec
+	if flags.noPorts {
+		VerboseLog("Port forwarding disabled (--no-ports)")
+	} else {
+		var err error
+		originalPorts, err = devcontainer.ExtractPorts(rawConfig, defaultServiceName)
+		if err != nil {
+			return nil, nil, model.WrapCLIError(model.ExitGeneralError, "invalid port configuration in devcontainer.json", err)
+		}
+		if pattern.IsCompose() {
+			originalPorts = devcontainer.MergeComposePorts(originalPorts, composeServices)
+		}
+		originalPorts = skipHostNetworkPorts(rawConfig, pattern, composeServices, originalPorts)
+		VerboseLog("Found %d port(s) to allocate", len(originalPorts))
+		warnUnusedForcedPorts(forcedPorts, originalPorts)
+		forcedPorts = pinLocalPorts(forcedPorts, originalPorts)
+	}
+
+	// Ports of a remote Docker host cannot be probed from here; with
+	// --skip-port-check only the allocations in container labels are avoided.
+	scanner := port.NewScanner()
+	if flags.skipPortCheck {
+		VerboseLog("Skipping host port probing (--skip-port-check)")
+		scanner.SetSkipProbe(true)
+	}
+	allocator := port.NewAllocator(scanner)
+	allocator.SetConfig(allocCfg)
+	// Report every port that cannot be allocated, not just the first.
+	allocator.SetPreflight(true)
+	allocator.SetForcedPorts(forcedPorts)
+
+	// Load existing allocations from running containers to avoid conflicts.
+	// Without Docker there is nothing to load; the worktree index keeps the
+	// ports apart from other environments.
+	if !flags.dockerOffline {
+		existingAllocs, loadErr := loadExistingAllocations(ctx, dc)
+		if loadErr != nil {
+			VerboseLog("Could not load existing allocations: %v", loadErr)
+		} else {
+			allocator.SetExistingAllocations(existingAllocs)
+		}
+		publishedPorts, loadErr := loadPublishedPorts(ctx, dc)
+		if loadErr != nil {
+			VerboseLog("Could not load ports published by other containers: %v", loadErr)
+		} else {
+			allocator.SetReservedPorts(publishedPorts)
+		}
+	}
+
+	portAllocations, err := allocator.AllocatePorts(originalPorts, worktreeIndex)
+	if err != nil {
+		return nil, nil, model.WrapCLIError(model.ExitPortAllocationFailed, "port allocation failed", err)
+	}
+
+	for _, pa := range portAllocations {
+		VerboseLog("Port allocated: %s", pa.String())
+	}
+	return composeServices, portAllocations, nil
+}
+
+// generateComposeOverride generates the Compose override YAML of env
+// (Pattern C/D): labels and shifted ports for the primary service, the
+// runServices, and every service that received a port allocation.
+// resources are the volumes and networks of the Compose files to label.
+func generateComposeOverride(env *model.WorktreeEnv, rawConfig *devcontainer.RawDevContainer, composeServices []devcontainer.ComposeService, labels map[string]string, flags *createFlags, resources devcontainer.ComposeResources) ([]byte, error) {
+	VerboseLog("Generating Compose override YAML...")
+
+	// Determine all services for the override.
+	services := rawConfig.RunServices
+	if len(services) == 0 && rawConfig.Service != "" {
+		services = []string{rawConfig.Service}
+	}
+	services = appendAllocatedServices(services, env.PortAllocations)
+	if env.ConfigPattern == model.PatternComposeMulti {
+		warnUnlistedDependencies(composeServices, services)
+	}
+
+	opts := devcontainer.ComposeOverrideOptions{
+		ResetPorts:      flags.noPorts,
+		ExternalNetwork: flags.network,
+		Resources:       resources,
+		ResourceLabels:  docker.BuildResourceLabels(env),
+	}
+	// containerUser is not applied to Compose services, so
+	// --container-user sets the user of the primary service instead.
+	if flags.containerUser != "" && rawConfig.Service != "" {
+		opts.ServiceUsers = map[string]string{rawConfig.Service: flags.containerUser}
+	}
+	overrideData, err := devcontainer.GenerateComposeOverrideWithOptions(env.ComposeProjectName(), services, env.PortAllocations, labels, opts)
+	if err != nil {
+		return nil, model.WrapCLIError(model.ExitGeneralError, "failed to generate Compose override", err)
+	}
+	return overrideData, nil
+}
+
+// rewriteComposeConfig rewrites the devcontainer.json of a Pattern C/D
+// environment to use composeFiles and the override (see
+// devcontainer.RewriteComposeConfig) and applies --remote-user.
+func rewriteComposeConfig(rawJSON []byte, envName string, composeFiles []string, overrideRef string, flags *createFlags) ([]byte, error) {
+	rewrittenJSON, err := devcontainer.RewriteComposeConfig(rawJSON, envName, composeFiles, overrideRef)
+	if err != nil {
+		return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json for Compose", err)
+	}
+	if flags.remoteUser != "" {
+		rewrittenJSON, err = devcontainer.SetUsers(rewrittenJSON, flags.remoteUser, "")
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json for Compose", err)
+		}
+	}
+	return rewrittenJSON, nil
+}
+
+// rewriteImageConfig rewrites the devcontainer.json of a Pattern A/B
+// environment: shifted appPort and labels (see devcontainer.RewriteConfig),
+// followed by the edits of --network, --base-image, --build-arg,
+// --remote-user, --container-user, and --annotate-ports.
+func rewriteImageConfig(rawJSON []byte, env *model.WorktreeEnv, labels map[string]string, flags *createFlags, buildArgs map[string]string) ([]byte, error) {
+	VerboseLog("Rewriting devcontainer.json for pattern %s...", env.ConfigPattern)
+	rewrittenJSON, err := devcontainer.RewriteConfig(rawJSON, env.Name, env.Index, env.PortAllocations, labels)
+	if err != nil {
+		return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+	}
+	if flags.network != "" {
+		rewrittenJSON, err = devcontainer.SetRunArgsNetwork(rewrittenJSON, flags.network)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+		}
+	}
+	if flags.baseImage != "" {
+		rewrittenJSON, err = devcontainer.SetImage(rewrittenJSON, flags.baseImage)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+		}
+	}
+	if len(buildArgs) > 0 && env.ConfigPattern == model.PatternDockerfile {
+		rewrittenJSON, err = devcontainer.SetBuildArgs(rewrittenJSON, buildArgs)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+		}
+	}
+	if flags.remoteUser != "" || flags.containerUser != "" {
+		rewrittenJSON, err = devcontainer.SetUsers(rewrittenJSON, flags.remoteUser, flags.containerUser)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+		}
+	}
+	if flags.annotatePorts {
+		rewrittenJSON, err = devcontainer.AnnotatePortsAttributes(rewrittenJSON, env.PortAllocations)
+		if err != nil {
+			return nil, model.WrapCLIError(model.ExitGeneralError, "failed to rewrite devcontainer.json", err)
+		}
+	}
+	return rewrittenJSON, nil
+}
+
+// withPhaseTimeout returns a child of parent that expires after d, or one
+// without a deadline if d is 0.
+func withPhaseTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
+
+// runPullPhase runs start, the container startup step of create, with a
+// context derived from parent and bounded by pullTimeout. parent must be
+// the context create was called with rather than the --timeout one, so
+// that a first-run image pull or build taking many minutes does not force
+// a large --timeout on the quick steps. The timer starts when this phase
+// does. Running out of time is reported as such, naming the flag.
+func runPullPhase(parent context.Context, pullTimeout time.Duration, start func(ctx context.Context) error) error {
+	ctx, cancel := withPhaseTimeout(parent, pullTimeout)
+	defer cancel()
+
+	err := start(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning,
+			fmt.Sprintf("starting the containers did not finish within --pull-timeout %s", pullTimeout), err)
+	}
+	return err
+}
+
+// ignoreGeneratedFiles adds the files create generated in the worktree's
+// .devcontainer directory to its .gitignore: the Compose override, and the
+// rewritten devcontainer.json if it is not tracked (i.e., it was copied
+// from the main checkout). A tracked devcontainer.json is left out, since
+// ignore rules do not apply to tracked files. Failures are warnings; the
+// environment works without the entries.
+func ignoreGeneratedFiles(wm *worktree.Manager, worktreePath string, pattern model.ConfigPattern) {
+	var entries []string
+	if pattern.IsCompose() {
+		entries = append(entries, devcontainer.ComposeOverrideFileName)
+	}
+	if !wm.IsTracked(worktreePath, filepath.Join(".devcontainer", "devcontainer.json")) {
+		entries = append(entries, "devcontainer.json")
+	}
+	if len(entries) == 0 {
+		return
+	}
+	added, err := worktree.EnsureIgnored(filepath.Join(worktreePath, ".devcontainer"), entries)
+	if err != nil {
+		printWarning("could not update .devcontainer/.gitignore: %v", err)
+		return
+	}
+	for _, entry := range added {
+		VerboseLog("Added %s to .devcontainer/.gitignore", entry)
+	}
+}
+
+// --env-name-from values.
+const (
+	envNameFromBranch = "branch" // the sanitized branch name (default)
+	envNameFromDir    = "dir"    // the sanitized basename of --path
+	envNameFromCustom = "custom" // --name, which is then required
+)
+
+// resolveEnvName returns the environment name for create according to
+// --env-name-from, and validates it. branch is the branch the worktree is
+// created on and path the --path value.
+//
+// --name is taken as given with the default strategy, as it always was;
+// with "dir" it is rejected, since the two would disagree. "dir" requires
+// --path: the default worktree path is itself derived from the name.
+// Names derived from a branch or directory go through sanitizeBranchName,
+// so "feature/x" and a directory named "feature_x" yield the same name.
+func resolveEnvName(flags *createFlags, branch, path string) (string, error) {
+	var name string
+	switch flags.nameFrom {
+	case "", envNameFromBranch:
+		name = flags.name
+		if name == "" {
+			name = sanitizeBranchName(branch)
+		}
+	case envNameFromDir:
+		if flags.name != "" {
+			return "", model.NewCLIError(model.ExitGeneralError, "--name cannot be used with --env-name-from dir")
+		}
+		if path == "" {
+			return "", model.NewCLIError(model.ExitGeneralError, "--env-name-from dir requires --path")
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", model.WrapCLIError(model.ExitGeneralError, "failed to resolve worktree path", err)
+		}
+		name = sanitizeBranchName(filepath.Base(abs))
+	case envNameFromCustom:
+		if flags.name == "" {
+			return "", model.NewCLIError(model.ExitGeneralError, "--env-name-from custom requires --name")
+		}
+		name = flags.name
+	default:
+		return "", model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("invalid --env-name-from %q: must be branch, dir, or custom", flags.nameFrom))
+	}
+	if err := model.ValidateName(name); err != nil {
+		return "", model.WrapCLIError(model.ExitGeneralError, "invalid environment name", err)
+	}
+	return name, nil
+}
+
+// sanitizeBranchName converts a Git branch name to a valid environment name.
+// Replaces "/" with "-" and strips invalid characters. Names longer than
+// model.MaxNameLength are shortened with a hash suffix (see
+// model.TruncateName), so long branch names still yield distinct names.
+func sanitizeBranchName(branch string) string {
+	// Replace common branch name separators with hyphens.
+	name := strings.ReplaceAll(branch, "/", "-")
+	name = strings.ReplaceAll(name, "_", "-")
+
+	// Remove any characters that aren't alphanumeric or hyphens.
+	var result strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			result.WriteRune(r)
+		}
+	}
+	name = result.String()
+
+	// Trim leading/trailing hyphens.
+	name = strings.Trim(name, "-")
+
+	if name == "" {
+		name = "worktree"
+	}
+	return model.TruncateName(name, model.MaxNameLength)
+}
+
+// countComposeServices returns the number of services defined in the
+// devcontainer's Compose configuration. For Pattern C, this is 1.
+// For Pattern D, this is 2 or more.
+func countComposeServices(raw *devcontainer.RawDevContainer) int {
+	// The runServices field explicitly lists which services to start.
+	// If present, its length gives us the service count.
+	if len(raw.RunServices) > 0 {
+		return len(raw.RunServices)
+	}
+
+	// If runServices is not set, there's at least the primary service.
+	if raw.Service != "" {
+		return 1
+	}
+
+	return 0
+}
+
+// parseBuildArgs parses the --build-arg values ("KEY=VALUE") into a map.
+// The value may be empty or contain "=", but the key may not be empty. A
+// key given more than once takes its last value, as with docker build.
+func parseBuildArgs(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	args := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("invalid --build-arg %q: expected KEY=VALUE", v))
+		}
+		args[key] = value
+	}
+	return args, nil
+}
+
+// parseForcePorts parses the --force-port values ("CONTAINER=HOST") into a
+// map from container port to host port. Container ports must be 1-65535 and
+// host ports 1024-65535, like allocated ones; neither may be given twice.
+func parseForcePorts(values []string) (map[int]int, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	forced := make(map[int]int, len(values))
+	hosts := make(map[int]int, len(values))
+	for _, v := range values {
+		containerStr, hostStr, ok := strings.Cut(v, "=")
+		containerPort, containerErr := strconv.Atoi(containerStr)
+		hostPort, hostErr := strconv.Atoi(hostStr)
+		switch {
+		case !ok || containerErr != nil || hostErr != nil:
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("invalid --force-port %q: expected CONTAINER=HOST, e.g. 3000=4000", v))
+		case containerPort < 1 || containerPort > 65535:
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("invalid --force-port %q: container port out of range (1-65535)", v))
+		case hostPort < 1024 || hostPort > 65535:
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("invalid --force-port %q: host port out of range (1024-65535)", v))
+		}
+		if _, dup := forced[containerPort]; dup {
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("--force-port: container port %d is given more than once", containerPort))
+		}
+		if other, dup := hosts[hostPort]; dup {
+			return nil, model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("--force-port: host port %d is given for both container ports %d and %d", hostPort, other, containerPort))
+		}
+		forced[containerPort] = hostPort
+		hosts[hostPort] = containerPort
+	}
+	return forced, nil
+}
+
+// warnUnusedForcedPorts warns about --force-port container ports that the
+// configuration does not publish; they have no effect.
+func warnUnusedForcedPorts(forced map[int]int, ports []model.PortSpec) {
+	published := make(map[int]bool, len(ports))
+	for _, ps := range ports {
+		published[ps.ContainerPort] = true
+	}
+	unused := make([]int, 0, len(forced))
+	for containerPort := range forced {
+		if !published[containerPort] {
+			unused = append(unused, containerPort)
+		}
+	}
+	sort.Ints(unused)
+	for _, containerPort := range unused {
+		printWarning("--force-port: container port %d is not published by the configuration and is ignored", containerPort)
 	}
-
-	return 0
 }
 
-// determineWorktreeIndex counts existing managed environments to determine
-// the index for the new environment. Index 0 is reserved for the primary
-// worktree (main branch), so new environments start at index 1.
-func determineWorktreeIndex(ctx context.Context) (int, error) {
-	// Try to connect to Docker to count existing environments.
-	cli, err := docker.NewClient()
+// pinLocalPorts adds the ports marked requireLocalPort in portsAttributes
+// to forced, pinned to their own number, unless --force-port already pins
+// them elsewhere. forced may be nil.
+func pinLocalPorts(forced map[int]int, ports []model.PortSpec) map[int]int {
+	for _, ps := range ports {
+		if !ps.RequireLocalPort {
+			continue
+		}
+		if _, ok := forced[ps.ContainerPort]; ok {
+			continue
+		}
+		if forced == nil {
+			forced = make(map[int]int)
+		}
+		forced[ps.ContainerPort] = ps.ContainerPort
+		VerboseLog("Port %d requires the same local port (requireLocalPort); not shifting it", ps.ContainerPort)
+	}
+	return forced
+}
+
+// skipHostNetworkPorts drops the ports of containers on the host's network
+// stack: runArgs with --network=host for pattern A/B, network_mode: host
+// for a Compose service. Such a container publishes no ports, so there is
+// nothing to shift, and a rewritten appPort would only break its start.
+// Each case is reported, since the environment's ports then collide with
+// those of every other worktree.
+func skipHostNetworkPorts(raw *devcontainer.RawDevContainer, pattern model.ConfigPattern, composeServices []devcontainer.ComposeService, ports []model.PortSpec) []model.PortSpec {
+	if !pattern.IsCompose() {
+		if !devcontainer.UsesHostNetwork(raw) {
+			return ports
+		}
+		printWarning("runArgs use host networking, which precludes per-worktree port isolation; ports are not shifted")
+		return nil
+	}
+
+	hostNetwork := make(map[string]bool)
+	for _, svc := range composeServices {
+		if svc.HostNetwork {
+			hostNetwork[svc.Name] = true
+			printWarning("service %q uses host networking, which precludes per-worktree port isolation; its ports are not shifted", svc.Name)
+		}
+	}
+	if len(hostNetwork) == 0 {
+		return ports
+	}
+	var kept []model.PortSpec
+	for _, ps := range ports {
+		if !hostNetwork[ps.ServiceName] {
+			kept = append(kept, ps)
+		}
+	}
+	return kept
+}
+
+// parseComposeServicesOrWarn reads the Compose files of devcontainer.json,
+// resolving relative paths against devcontainerDir, so that the ports they
+// publish are allocated alongside forwardPorts. A file that cannot be read
+// is not fatal — docker compose reports it when the environment starts — so
+// it only produces a warning, and no Compose services are returned.
+func parseComposeServicesOrWarn(devcontainerDir string, composeFiles []string) []devcontainer.ComposeService {
+	services, err := devcontainer.ParseComposeServices(composeFilePaths(devcontainerDir, composeFiles))
 	if err != nil {
-		return 1, err
+		printWarning("could not read services from Compose files: %v", err)
+		return nil
+	}
+	return services
+}
+
+// parseComposeResourcesOrWarn reads the volumes and networks declared in the
+// Compose files, so that the override can label them. Like
+// parseComposeServicesOrWarn, a file that cannot be read only produces a
+// warning; the project's default network is still labeled.
+func parseComposeResourcesOrWarn(devcontainerDir string, composeFiles []string) devcontainer.ComposeResources {
+	resources, err := devcontainer.ParseComposeResources(composeFilePaths(devcontainerDir, composeFiles))
+	if err != nil {
+		printWarning("could not read volumes and networks from Compose files: %v", err)
+		return devcontainer.ComposeResources{Networks: []string{"default"}}
+	}
+	return resources
+}
+
+// composeFilePaths resolves the dockerComposeFile entries of
+// devcontainer.json against devcontainerDir.
+func composeFilePaths(devcontainerDir string, composeFiles []string) []string {
+	paths := make([]string, 0, len(composeFiles))
+	for _, f := range composeFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(devcontainerDir, f)
+		}
+		paths = append(paths, f)
+	}
+	return paths
+}
+
+// warnUnlistedDependencies warns about each depends_on entry of the
+// override's services that names a service outside of them (see
+// devcontainer.UnlistedDependencies). Listing the dependency in runServices
+// brings it under the override.
+func warnUnlistedDependencies(composeServices []devcontainer.ComposeService, services []string) {
+	for _, dep := range devcontainer.UnlistedDependencies(composeServices, services) {
+		printWarning("service %q depends on %q, which is not in runServices; it will start without the worktree override (labels, port shifts)",
+			dep.Service, dep.DependsOn)
+	}
+}
+
+// appendAllocatedServices adds the services that received port allocations
+// to services, the list of services the Compose override covers. A port
+// published by a service that is neither the primary service nor in
+// runServices (e.g., a DNS sidecar) must still be shifted and labeled.
+func appendAllocatedServices(services []string, allocations []model.PortAllocation) []string {
+	seen := make(map[string]bool, len(services))
+	for _, svc := range services {
+		seen[svc] = true
+	}
+	result := append([]string(nil), services...)
+	for _, pa := range allocations {
+		if !seen[pa.ServiceName] {
+			seen[pa.ServiceName] = true
+			result = append(result, pa.ServiceName)
+		}
+	}
+	return result
+}
+
+// checkReusableWorktree reports whether worktreePath is an existing worktree
+// of the repository at repoRoot that can be reused for branchName (--reuse).
+//
+// It returns false (and no error) when nothing exists at the path yet, so
+// --reuse falls back to creating the worktree. It returns an error when the
+// path is a worktree that cannot be reused: one belonging to a different
+// repository, or one checked out on a different branch (including a
+// detached HEAD), since silently switching its branch could lose work.
+// It also fails early when the branch is checked out somewhere else, which
+// `git worktree add` would refuse anyway, pointing at the existing checkout.
+func checkReusableWorktree(wm *worktree.Manager, repoRoot, worktreePath, branchName string) (bool, error) {
+	branchPath, checkedOut, err := wm.WorktreeForBranch(repoRoot, branchName)
+	if err != nil {
+		return false, model.WrapCLIError(model.ExitGitError, "failed to list worktrees", err)
+	}
+	if checkedOut && containsPath([]string{branchPath}, worktreePath) {
+		return true, nil
+	}
+
+	if _, statErr := os.Stat(worktreePath); os.IsNotExist(statErr) || !wm.IsWorktree(worktreePath) {
+		if checkedOut {
+			return false, model.NewCLIError(model.ExitGitError,
+				fmt.Sprintf("branch %q is already checked out at %s; pass --path %s to reuse it", branchName, branchPath, branchPath))
+		}
+		// Nothing there, or not a worktree (e.g., an empty directory): let
+		// `git worktree add` decide whether it can be used.
+		return false, nil
+	}
+
+	// The path must be one of this repository's worktrees, not another's.
+	// Paths are compared after resolving symlinks (e.g., /tmp on macOS).
+	paths, err := wm.ListPaths(repoRoot)
+	if err != nil {
+		return false, model.WrapCLIError(model.ExitGitError, "failed to list worktrees", err)
+	}
+	if !containsPath(paths, worktreePath) {
+		return false, model.NewCLIError(model.ExitGitError,
+			fmt.Sprintf("%s is a worktree of a different repository", worktreePath))
+	}
+
+	// The branch is not checked out at worktreePath (see above), so the
+	// existing worktree is on another branch or detached.
+	current, err := wm.GetCurrentBranch(worktreePath)
+	if err != nil {
+		return false, model.WrapCLIError(model.ExitGitError, "failed to determine the branch of the existing worktree", err)
+	}
+	return false, model.NewCLIError(model.ExitGitError,
+		fmt.Sprintf("existing worktree at %s is on branch %q, not %q", worktreePath, current, branchName))
+}
+
+// containsPath reports whether target is in paths, comparing symlink-resolved
+// absolute paths.
+func containsPath(paths []string, target string) bool {
+	resolve := func(p string) string {
+		if r, err := filepath.EvalSymlinks(p); err == nil {
+			return r
+		}
+		return filepath.Clean(p)
+	}
+	want := resolve(target)
+	for _, p := range paths {
+		if resolve(p) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// baseAuto is the --base value that selects the repository's default
+// branch instead of a literal ref.
+const baseAuto = "auto"
+
+// warnIfBaseBehind warns when the branch a new branch is based on is
+// behind its upstream, which leads to avoidable conflicts later. An empty
+// base means HEAD, i.e. the branch checked out in repoRoot. Bases that are
+// not branches with an upstream are skipped silently; the check is only a
+// hint, so its own failures are merely logged.
+func warnIfBaseBehind(wm *worktree.Manager, repoRoot, base string) {
+	if base == "" {
+		current, err := wm.GetCurrentBranch(repoRoot)
+		if err != nil || current == "HEAD" {
+			return
+		}
+		base = current
+	}
+	behind, err := wm.BehindUpstream(repoRoot, base)
+	if err != nil {
+		VerboseLog("Could not compare %s with its upstream: %v", base, err)
+		return
+	}
+	if behind > 0 {
+		printWarning("base branch %q is %d commit(s) behind its upstream; "+
+			"run `git pull` on it (or `git fetch` and use --base <remote>/<branch>) to start from the latest changes",
+			base, behind)
+	}
+}
+
+// resolveDefaultBase resolves --base auto to the repository's default
+// branch (see worktree.Manager.DefaultBranch): the local branch if it
+// exists, otherwise its origin remote-tracking branch, which is all a fresh
+// clone may have.
+func resolveDefaultBase(wm *worktree.Manager, repoRoot string) (string, error) {
+	branch, err := wm.DefaultBranch(repoRoot)
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitGitError, "cannot resolve --base auto", err)
+	}
+	if wm.BranchExists(repoRoot, "refs/heads/"+branch) {
+		return branch, nil
+	}
+	return worktree.DefaultRemote + "/" + branch, nil
+}
+
+// resolveAllocatorConfig returns the port allocation limits for create:
+// --max-environments wins over maxEnvironments in the project
+// configuration, which wins over the default.
+func resolveAllocatorConfig(flags *createFlags, projectConfig *config.ProjectConfig) (port.AllocatorConfig, error) {
+	cfg := port.DefaultAllocatorConfig()
+	source := "--max-environments"
+	switch {
+	case flags.maxEnvironments != 0:
+		cfg.MaxEnvironments = flags.maxEnvironments
+	case projectConfig.MaxEnvironments != 0:
+		cfg.MaxEnvironments = projectConfig.MaxEnvironments
+		source = "maxEnvironments in " + config.FileName
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, model.WrapCLIError(model.ExitGeneralError, "invalid "+source, err)
+	}
+	return cfg, nil
+}
+
+// listEnvironmentGroups returns the containers of existing managed
+// environments, grouped by environment name.
+func listEnvironmentGroups(ctx context.Context, dc *dockerConn) (map[string][]model.ContainerInfo, error) {
+	cli, err := dc.client()
+	if err != nil {
+		return nil, err
 	}
-	defer func() { _ = cli.Close() }()
 
 	containers, err := docker.ListManagedContainers(ctx, cli)
 	if err != nil {
-		return 1, err
+		return nil, err
 	}
+	return docker.GroupContainersByEnv(containers), nil
+}
+
+// nextWorktreeIndex returns the index for a new environment: the lowest
+// index in 1..cfg.MaxIndex() not in used (see usedWorktreeIndices). Index 0
+// is reserved for the primary worktree (main branch), so new environments
+// start at index 1, and indices freed by removed environments are reused.
+// It returns a CLIError when no index is left.
+func nextWorktreeIndex(used map[int]string, cfg port.AllocatorConfig) (int, error) {
+	for index := 1; index <= cfg.MaxIndex(); index++ {
+		if _, taken := used[index]; !taken {
+			return index, nil
+		}
+	}
+
+	names := make([]string, 0, len(used))
+	for _, name := range used {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return 0, environmentLimitError(cfg, names)
+}
+
+// environmentLimitError reports that no worktree index is left for a new
+// environment, listing the existing environments so one can be removed.
+func environmentLimitError(cfg port.AllocatorConfig, envNames []string) error {
+	msg := fmt.Sprintf("maximum of %d environments reached (existing: %s); remove one with \"loam remove <name>\"",
+		cfg.MaxEnvironments, strings.Join(envNames, ", "))
+	if cfg.MaxEnvironments < port.DefaultMaxEnvironments {
+		msg += fmt.Sprintf(" or raise --max-environments (up to %d)", port.DefaultMaxEnvironments)
+	}
+	return model.NewCLIError(model.ExitGeneralError, msg)
+}
 
+// resolveWorktreeIndex returns the worktree index for a new environment
+// named envName: an explicit --index (validated in Step 3.5) wins;
+// otherwise the lowest free index is taken (see nextWorktreeIndex). Indices
+// in use come from the labels of existing containers and from the marker
+// files of repoRoot's worktrees, which also cover environments without
+// containers. With flags.dockerOffline, or if Docker cannot be queried,
+// only the marker files are used. Reaching the environment limit is an
+// error.
+func resolveWorktreeIndex(ctx context.Context, dc *dockerConn, wm *worktree.Manager, repoRoot, envName string, flags *createFlags, cfg port.AllocatorConfig) (int, error) {
+	if flags.indexSet {
+		return flags.index, nil
+	}
+
+	used := make(map[int]string)
+	if !flags.dockerOffline {
+		groups, err := listEnvironmentGroups(ctx, dc)
+		if err != nil {
+			VerboseLog("Could not list environments from Docker, using marker files only: %v", err)
+		} else {
+			delete(groups, envName)
+			used = usedWorktreeIndices(groups)
+		}
+	}
+	markerIndices, err := markerWorktreeIndices(wm, repoRoot, envName)
+	if err != nil {
+		VerboseLog("Could not read marker files of the worktrees: %v", err)
+	}
+	for index, name := range markerIndices {
+		if _, taken := used[index]; !taken {
+			used[index] = name
+		}
+	}
+	return nextWorktreeIndex(used, cfg)
+}
+
+// markerWorktreeIndices returns the worktree indices recorded in the marker
+// files of repoRoot's worktrees, keyed by index, with the environment name
+// as the value. The marker of exclude (the environment being created) and
+// markers without an index are left out.
+func markerWorktreeIndices(wm *worktree.Manager, repoRoot, exclude string) (map[int]string, error) {
+	paths, err := wm.ListPaths(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[int]string)
+	for _, path := range paths {
+		marker, readErr := worktree.ReadMarkerFile(path)
+		if readErr != nil || marker == nil || marker.ManagedBy != "loam" || marker.Name == exclude {
+			continue
+		}
+		if index := marker.WorktreeIndex(); index != model.UnknownWorktreeIndex {
+			if _, taken := used[index]; !taken {
+				used[index] = marker.Name
+			}
+		}
+	}
+	return used, nil
+}
+
+// dockerAvailable reports whether a Docker daemon can be reached.
+func dockerAvailable(ctx context.Context, dc *dockerConn) bool {
+	cli, err := dc.client()
+	if err != nil {
+		VerboseLog("Docker not available: %v", err)
+		return false
+	}
+	if err := cli.Ping(ctx); err != nil {
+		VerboseLog("Docker not available: %v", err)
+		return false
+	}
+	return true
+}
+
+// validateRequestedIndex checks an explicit --index value: it must be within
+// 0..cfg.MaxIndex() and not already used by another environment.
+//
+// Existing indices come from the marker files of repoRoot's worktrees, as in
+// resolveWorktreeIndex, and from the loam.index label, or are inferred from
+// port labels for environments created before that label existed. If Docker
+// is unavailable, only the marker files are checked (with a verbose note);
+// offline does so without trying to connect.
+func validateRequestedIndex(ctx context.Context, dc *dockerConn, wm *worktree.Manager, repoRoot, envName string, index int, cfg port.AllocatorConfig, offline bool) error {
+	if index < 0 || index > cfg.MaxIndex() {
+		return model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("--index %d is out of range (0-%d)", index, cfg.MaxIndex()))
+	}
+
+	var used map[int]string
+	if offline {
+		VerboseLog("Checking --index against marker files only, Docker not available")
+		used = make(map[int]string)
+	} else {
+		used = dockerWorktreeIndices(ctx, dc, envName)
+	}
+	markerIndices, err := markerWorktreeIndices(wm, repoRoot, envName)
+	if err != nil {
+		VerboseLog("Could not read marker files of the worktrees: %v", err)
+	}
+	for i, name := range markerIndices {
+		if _, taken := used[i]; !taken {
+			used[i] = name
+		}
+	}
+	return checkWorktreeIndexAvailable(index, used)
+}
+
+// dockerWorktreeIndices returns the worktree indices of the environments
+// with containers other than envName (see usedWorktreeIndices). If Docker
+// cannot be queried, it notes so and returns an empty map.
+func dockerWorktreeIndices(ctx context.Context, dc *dockerConn, envName string) map[int]string {
+	cli, err := dc.client()
+	if err != nil {
+		VerboseLog("Checking --index against marker files only, Docker not available: %v", err)
+		return make(map[int]string)
+	}
+	containers, err := docker.ListManagedContainers(ctx, cli)
+	if err != nil {
+		VerboseLog("Checking --index against marker files only, could not list containers: %v", err)
+		return make(map[int]string)
+	}
 	groups := docker.GroupContainersByEnv(containers)
+	delete(groups, envName)
+	return usedWorktreeIndices(groups)
+}
 
-	// New environment gets the next index after existing ones.
-	// Minimum index is 1 (index 0 is for the primary worktree).
-	index := len(groups) + 1
-	if index > 9 {
-		return 0, fmt.Errorf("maximum of 10 environments reached (currently %d)", len(groups))
+// checkNetworkExists verifies that the --network to attach the containers
+// to exists. Unlike the --index check, it does not skip when Docker is
+// unavailable: the containers are about to be started, which needs Docker.
+func checkNetworkExists(ctx context.Context, dc *dockerConn, network string) error {
+	cli, err := dc.client()
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "failed to connect to Docker", err)
 	}
-	return index, nil
+
+	exists, err := docker.NetworkExists(ctx, cli, network)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return model.NewCLIError(model.ExitDockerNotRunning,
+			fmt.Sprintf("Docker network %q not found; create it with `docker network create %s`", network, network))
+	}
+	return nil
+}
+
+// resolveAttachNetwork returns the network of environment envName that
+// --attach-to-network joins: the default network of its Compose project.
+// The environment must exist and be a Compose environment, and the network
+// must exist, which it does once the environment has been started.
+func resolveAttachNetwork(ctx context.Context, dc *dockerConn, envName string) (string, error) {
+	cli, err := dc.client()
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitDockerNotRunning, "failed to connect to Docker", err)
+	}
+
+	env, _, err := findEnvironment(ctx, cli, envName)
+	if err != nil {
+		return "", err
+	}
+	if !env.ConfigPattern.IsCompose() {
+		return "", model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("environment %q is not a Compose environment and has no network to attach to (pattern: %s)", envName, env.ConfigPattern))
+	}
+
+	networks, err := docker.ListManagedNetworks(ctx, cli, envName)
+	if err != nil {
+		return "", model.WrapCLIError(model.ExitDockerNotRunning, "failed to list Docker networks", err)
+	}
+	network, err := composeDefaultNetwork(env, networks)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(networks, network) {
+		return network, nil
+	}
+
+	// Environments created before their networks were labeled are found by
+	// the name Compose gives the default network.
+	exists, err := docker.NetworkExists(ctx, cli, network)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", model.NewCLIError(model.ExitDockerNotRunning,
+			fmt.Sprintf("network %q of environment %q not found; start the environment with `loam start %s` first", network, envName, envName))
+	}
+	return network, nil
+}
+
+// composeDefaultNetwork picks the default network of env's Compose project
+// from networks, the networks labeled as belonging to env. Compose names it
+// "<project>_default"; if the Compose files rename it, the environment's
+// only network is taken instead. Without labeled networks, the Compose name
+// is returned for the caller to look up.
+func composeDefaultNetwork(env *model.WorktreeEnv, networks []string) (string, error) {
+	defaultNetwork := env.ComposeProjectName() + "_default"
+	switch {
+	case len(networks) == 0 || slices.Contains(networks, defaultNetwork):
+		return defaultNetwork, nil
+	case len(networks) == 1:
+		return networks[0], nil
+	}
+	return "", model.NewCLIError(model.ExitGeneralError,
+		fmt.Sprintf("environment %q has several networks (%s) and none is named %q; pick one with --network",
+			env.Name, strings.Join(networks, ", "), defaultNetwork))
+}
+
+// usedWorktreeIndices returns the worktree index of each existing
+// environment, keyed by index, with the environment name as the value.
+// Environments whose index cannot be determined are left out. Names are
+// visited in sorted order so the reported owner of a shared index is stable.
+func usedWorktreeIndices(groups map[string][]model.ContainerInfo) map[int]string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	used := make(map[int]string, len(names))
+	for _, name := range names {
+		if len(groups[name]) == 0 {
+			continue
+		}
+		idx, err := docker.WorktreeIndexFromLabels(groups[name][0].Labels)
+		if err != nil || idx == model.UnknownWorktreeIndex {
+			continue
+		}
+		if _, taken := used[idx]; !taken {
+			used[idx] = name
+		}
+	}
+	return used
+}
+
+// checkWorktreeIndexAvailable returns a CLIError if index is already used.
+func checkWorktreeIndexAvailable(index int, used map[int]string) error {
+	if owner, ok := used[index]; ok {
+		return model.NewCLIError(model.ExitPortAllocationFailed,
+			fmt.Sprintf("worktree index %d is already used by environment %q; choose a different --index", index, owner))
+	}
+	return nil
 }
 
 // loadExistingAllocations fetches port allocations from all currently
 // managed containers. This is used to prevent port collisions with
 // already-running environments.
-func loadExistingAllocations(ctx context.Context) ([]model.PortAllocation, error) {
-	cli, err := docker.NewClient()
+func loadExistingAllocations(ctx context.Context, dc *dockerConn) ([]model.PortAllocation, error) {
+	cli, err := dc.client()
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = cli.Close() }()
 
 	containers, err := docker.ListManagedContainers(ctx, cli)
 	if err != nil {
@@ -428,26 +2000,43 @@ func loadExistingAllocations(ctx context.Context) ([]model.PortAllocation, error
 	return allocs, nil
 }
 
+// loadPublishedPorts returns the host ports published by all running
+// containers, including ones not managed by loam (see
+// docker.ListAllPublishedPorts).
+func loadPublishedPorts(ctx context.Context, dc *dockerConn) ([]int, error) {
+	cli, err := dc.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return docker.ListAllPublishedPorts(ctx, cli)
+}
+
 // startContainers launches the Dev Container based on the detected pattern.
-func startContainers(ctx context.Context, pattern model.ConfigPattern, devcontainerDir string, composeFiles []string, envName string, raw *devcontainer.RawDevContainer) error {
+// composeFiles is the full Compose file chain (see composeFileChain) and
+// projectName the Compose project name; both are ignored for Pattern A/B.
+// pull is passed to Compose for Pattern C/D; for Pattern A/B, see
+// imagePullNeeded.
+func startContainers(ctx context.Context, pattern model.ConfigPattern, devcontainerDir string, composeFiles []string, projectName string, pull docker.PullPolicy, raw *devcontainer.RawDevContainer) error {
 	if pattern.IsCompose() {
 		// Pattern C/D: Use docker compose with the override file.
-		// Build the full list of compose files: originals + override.
-		allComposeFiles := make([]string, 0, len(composeFiles)+1)
-		allComposeFiles = append(allComposeFiles, composeFiles...)
-		allComposeFiles = append(allComposeFiles, "docker-compose.worktree.yml")
-
 		envVars := map[string]string{
-			"COMPOSE_PROJECT_NAME": envName,
+			"COMPOSE_PROJECT_NAME": projectName,
 		}
 
-		VerboseLog("Running docker compose up with files: %v", allComposeFiles)
-		if err := docker.ComposeUp(ctx, devcontainerDir, allComposeFiles, envVars); err != nil {
+		VerboseLog("Running docker compose up with files: %v", composeFiles)
+		if err := docker.ComposeUp(ctx, devcontainerDir, composeFiles, envVars, pull); err != nil {
 			return model.WrapCLIError(model.ExitDockerNotRunning, "failed to start Compose services", err)
 		}
 	} else {
 		// Pattern A/B: Use docker run or devcontainer CLI.
 		// For now, we use `devcontainer up` which handles building and starting.
+		if imagePullNeeded(pull, raw) {
+			VerboseLog("Pulling image %s (--pull %s)...", raw.Image, pull)
+			if err := docker.PullImage(ctx, raw.Image); err != nil {
+				return err
+			}
+		}
 		VerboseLog("Starting container for pattern %s...", pattern)
 		if err := runDevcontainerUp(ctx, filepath.Dir(devcontainerDir)); err != nil {
 			return model.WrapCLIError(model.ExitDockerNotRunning, "failed to start container", err)
@@ -456,6 +2045,30 @@ func startContainers(ctx context.Context, pattern model.ConfigPattern, devcontai
 	return nil
 }
 
+// imagePullNeeded reports whether a Pattern A/B configuration needs an
+// explicit `docker pull` before starting. Only "always" needs one: starting
+// a container already pulls missing images, and "never" cannot be enforced
+// without Compose. Pattern B builds from a Dockerfile and has no image to
+// pull.
+func imagePullNeeded(pull docker.PullPolicy, raw *devcontainer.RawDevContainer) bool {
+	return pull == docker.PullAlways && raw != nil && raw.Image != ""
+}
+
+// removeStartedContainers undoes startContainers during a create rollback.
+// It runs `docker compose down` with the same files and project name that
+// were used to start the environment, removing its containers, networks,
+// and volumes. Compose tolerates services that were never created, so this
+// is safe after a partial start.
+func removeStartedContainers(ctx context.Context, pattern model.ConfigPattern, devcontainerDir string, composeFiles []string, projectName string) error {
+	if pattern.IsCompose() {
+		envVars := map[string]string{
+			"COMPOSE_PROJECT_NAME": projectName,
+		}
+		return docker.ComposeDown(ctx, devcontainerDir, composeFiles, true, envVars)
+	}
+	return docker.ComposeDown(ctx, filepath.Dir(devcontainerDir), nil, true, nil)
+}
+
 // runDevcontainerUp runs `devcontainer up` command for Pattern A/B containers.
 // This delegates to the Dev Container CLI which handles image pulling,
 // building, and container creation.
@@ -466,11 +2079,16 @@ func runDevcontainerUp(ctx context.Context, workspaceFolder string) error {
 	VerboseLog("Using devcontainer up --workspace-folder %s", workspaceFolder)
 
 	// Try devcontainer CLI first.
-	return docker.ComposeUp(ctx, workspaceFolder, nil, nil)
+	return docker.ComposeUp(ctx, workspaceFolder, nil, nil, docker.PullDefault)
 }
 
-// printCreateResult outputs the create command results in text or JSON format.
-func printCreateResult(env *model.WorktreeEnv) {
+// printCreateResult outputs the create command results in text or JSON format,
+// or as an eval-able shell snippet when shellInit is set (see shellinit.go).
+func printCreateResult(env *model.WorktreeEnv, shellInit bool) {
+	if shellInit {
+		fmt.Print(buildShellInit(env, detectShellDialect(os.Getenv("SHELL"))))
+		return
+	}
 	if IsJSONOutput() {
 		printCreateResultJSON(env)
 	} else {
@@ -478,45 +2096,53 @@ func printCreateResult(env *model.WorktreeEnv) {
 	}
 }
 
-// printCreateResultJSON outputs the create result as structured JSON.
-func printCreateResultJSON(env *model.WorktreeEnv) {
-	type serviceJSON struct {
-		Name          string `json:"name"`
-		ContainerPort int    `json:"containerPort"`
-		HostPort      int    `json:"hostPort"`
-		Protocol      string `json:"protocol"`
-	}
-
-	type resultJSON struct {
-		Name          string        `json:"name"`
-		Branch        string        `json:"branch"`
-		WorktreePath  string        `json:"worktreePath"`
-		Status        string        `json:"status"`
-		ConfigPattern string        `json:"configPattern"`
-		Services      []serviceJSON `json:"services"`
-	}
+// createResultJSON is the JSON result of create. The generated file paths
+// are absolute, so that wrappers can hand them to VS Code, the Dev
+// Container CLI, or DevPod as they are; they and tools are omitted for
+// worktree-only environments (PatternNone).
+type createResultJSON struct {
+	Name             string                       `json:"name"`
+	Branch           string                       `json:"branch"`
+	WorktreePath     string                       `json:"worktreePath"`
+	Status           string                       `json:"status"`
+	ConfigPattern    string                       `json:"configPattern"`
+	Services         []serviceJSON                `json:"services"`
+	DevcontainerPath string                       `json:"devcontainerPath,omitempty"`
+	ComposeFiles     []string                     `json:"composeFiles,omitempty"`
+	OverridePath     string                       `json:"overridePath,omitempty"`
+	Tools            *devcontainer.ToolCompatInfo `json:"tools,omitempty"`
+}
 
-	result := resultJSON{
+// buildCreateResultJSON converts env to its create JSON result. For Compose
+// configurations, ComposeFiles is the recorded chain (see composeFileChain)
+// resolved against the directory of the generated files.
+func buildCreateResultJSON(env *model.WorktreeEnv) createResultJSON {
+	result := createResultJSON{
 		Name:          env.Name,
 		Branch:        env.Branch,
 		WorktreePath:  env.WorktreePath,
 		Status:        env.Status.String(),
 		ConfigPattern: env.ConfigPattern.String(),
-		// Initialize with an empty slice so JSON output shows [] instead of null
-		// when no services are present.
-		Services: make([]serviceJSON, 0),
+		Services:      buildServicesJSON(env.PortAllocations),
+	}
+	if env.ConfigPattern == model.PatternNone {
+		return result
 	}
 
-	for _, pa := range env.PortAllocations {
-		result.Services = append(result.Services, serviceJSON{
-			Name:          pa.ServiceName,
-			ContainerPort: pa.ContainerPort,
-			HostPort:      pa.HostPort,
-			Protocol:      pa.Protocol,
-		})
+	dir := envDevcontainerDir(env)
+	result.DevcontainerPath = filepath.Join(dir, "devcontainer.json")
+	if env.ConfigPattern.IsCompose() {
+		result.ComposeFiles = composeFilePaths(dir, env.ComposeFiles)
+		result.OverridePath = filepath.Join(dir, devcontainer.ComposeOverrideFileName)
 	}
+	tools := devcontainer.GenerateToolCompatInfo(env.WorktreePath)
+	result.Tools = &tools
+	return result
+}
 
-	data, _ := json.MarshalIndent(result, "", "  ")
+// printCreateResultJSON outputs the create result as structured JSON.
+func printCreateResultJSON(env *model.WorktreeEnv) {
+	data, _ := json.MarshalIndent(buildCreateResultJSON(env), "", "  ")
 	fmt.Println(string(data))
 }
 
@@ -525,7 +2151,7 @@ func printCreateResultJSON(env *model.WorktreeEnv) {
 // worktree info (name, branch, path) is shown — no Pattern or Services.
 func printCreateResultText(env *model.WorktreeEnv) {
 	fmt.Printf("Created worktree environment %q\n", env.Name)
-	fmt.Printf("  Branch:    %s\n", env.Branch)
+	fmt.Printf("  Branch:    %s\n", formatBranch(env.Branch))
 	fmt.Printf("  Path:      %s\n", env.WorktreePath)
 
 	// Skip Pattern and Services display for worktree-only environments.
@@ -533,7 +2159,7 @@ func printCreateResultText(env *model.WorktreeEnv) {
 		return
 	}
 
-	serviceCount := len(env.PortAllocations)
+	serviceCount := countServices(env.PortAllocations)
 	patternDesc := env.ConfigPattern.String()
 	if serviceCount > 0 {
 		patternDesc = fmt.Sprintf("%s (%d services)", patternDesc, serviceCount)
@@ -4,15 +4,26 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 
+	"github.com/mmr-tortoise/loam/internal/config"
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/docker"
 	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/port"
 	"github.com/mmr-tortoise/loam/internal/worktree"
 )
 
@@ -95,6 +106,165 @@ func TestCreateNoDevcontainer_WorktreeAndMarker(t *testing.T) {
 	assert.Equal(t, model.PatternNone, readMarker.ConfigPattern)
 }
 
+// TestRunCreate_WorktreePathTemplate verifies that without --path, the
+// worktree is created at the path rendered from the project configuration,
+// including nested parent directories. This test uses os.Chdir, so it must
+// NOT use t.Parallel().
+func TestRunCreate_WorktreePathTemplate(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, config.FileName),
+		[]byte(`{"worktreePathTemplate": "../wt/{{.Repo}}/{{.Branch}}"}`), 0644))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature/templated", &createFlags{noStart: true}))
+	})
+
+	want := filepath.Join(filepath.Dir(repoPath), "wt", filepath.Base(repoPath), "feature", "templated")
+	marker, err := worktree.ReadMarkerFile(want)
+	require.NoError(t, err, "worktree should be created at the templated path")
+	assert.Equal(t, "feature-templated", marker.Name)
+}
+
+// TestRunCreate_Repo verifies that --repo creates the environment from the
+// given repository when run from an unrelated directory, and that a path
+// outside any repository is a Git error. This test uses os.Chdir, so it
+// must NOT use t.Parallel().
+func TestRunCreate_Repo(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(t.TempDir()))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-elsewhere", &createFlags{
+			repo:    repoPath,
+			path:    worktreePath,
+			noStart: true,
+		}))
+	})
+	marker, err := worktree.ReadMarkerFile(worktreePath)
+	require.NoError(t, err)
+	assert.Equal(t, repoPath, marker.SourceRepoPath)
+	assert.True(t, worktree.NewManager().BranchExists(repoPath, "feature-elsewhere"))
+
+	err = runCreate(context.Background(), "feature-nowhere", &createFlags{
+		repo:    t.TempDir(),
+		path:    filepath.Join(t.TempDir(), "wt"),
+		noStart: true,
+	})
+	requireExitCode(t, err, model.ExitGitError)
+	assert.Contains(t, err.Error(), "--repo")
+}
+
+// TestRunCreate_BaseAuto verifies that --base auto bases the new branch on
+// the default branch rather than on the checked-out feature branch. This
+// test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_BaseAuto(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	runTestGit(t, repoPath, "branch", "-m", "main")
+	mainSHA := strings.TrimSpace(runTestGit(t, repoPath, "rev-parse", "main"))
+	runTestGit(t, repoPath, "checkout", "-q", "-b", "feature-wip")
+	runTestGit(t, repoPath, "commit", "-q", "--allow-empty", "-m", "wip")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt-auto")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "bugfix-login",
+			&createFlags{base: baseAuto, path: worktreePath, noStart: true}))
+	})
+	assert.Equal(t, mainSHA, strings.TrimSpace(runTestGit(t, worktreePath, "rev-parse", "HEAD")))
+}
+
+// TestResolveDefaultBase verifies that the local default branch is
+// preferred and that a fresh clone without it falls back to origin.
+func TestResolveDefaultBase(t *testing.T) {
+	t.Parallel()
+
+	wm := worktree.NewManager()
+	remotePath := setupTestRepo(t)
+	runTestGit(t, remotePath, "branch", "-m", "main")
+
+	base, err := resolveDefaultBase(wm, remotePath)
+	require.NoError(t, err)
+	assert.Equal(t, "main", base)
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	require.NoError(t, wm.Clone(remotePath, clone))
+	runTestGit(t, clone, "checkout", "-q", "-b", "feature-x")
+	runTestGit(t, clone, "branch", "-D", "main")
+	base, err = resolveDefaultBase(wm, clone)
+	require.NoError(t, err)
+	assert.Equal(t, "origin/main", base)
+
+	runTestGit(t, remotePath, "branch", "-m", "develop")
+	_, err = resolveDefaultBase(wm, remotePath)
+	var cliErr *model.CLIError
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitGitError, cliErr.Code)
+}
+
+// TestCreateDetached_MarkerAndLabels verifies the --detach path: the worktree
+// is created without a branch, named after the short SHA, and the empty
+// branch survives the marker file and the Docker label round-trip.
+func TestCreateDetached_MarkerAndLabels(t *testing.T) {
+	t.Parallel()
+
+	repoPath := setupTestRepo(t)
+	wm := worktree.NewManager()
+
+	envName, err := wm.ShortCommit(repoPath, "HEAD")
+	require.NoError(t, err)
+	require.NoError(t, model.ValidateName(envName), "a short SHA must be a valid environment name")
+
+	worktreePath := filepath.Join(t.TempDir(), "wt-"+envName)
+	require.NoError(t, wm.AddDetached(repoPath, worktreePath, "HEAD"))
+
+	marker := worktree.MarkerFile{
+		ManagedBy:      "loam",
+		Name:           envName,
+		SourceRepoPath: repoPath,
+		ConfigPattern:  model.PatternNone,
+		CreatedAt:      "2026-03-02T00:00:00Z",
+	}
+	require.NoError(t, worktree.WriteMarkerFile(worktreePath, marker))
+	readMarker, err := worktree.ReadMarkerFile(worktreePath)
+	require.NoError(t, err)
+	require.NotNil(t, readMarker)
+	assert.Empty(t, readMarker.Branch)
+
+	env := &model.WorktreeEnv{
+		Name:           envName,
+		WorktreePath:   worktreePath,
+		SourceRepoPath: repoPath,
+		ConfigPattern:  model.PatternImage,
+		Index:          1,
+	}
+	labels, err := docker.BuildLabels(env)
+	require.NoError(t, err)
+	parsed, err := docker.ParseLabels(labels)
+	require.NoError(t, err, "an empty branch label must still parse")
+	assert.Empty(t, parsed.Branch)
+	assert.Equal(t, "(detached)", formatBranch(parsed.Branch))
+}
+
 // TestCreateWithDevcontainer_MarkerFile verifies that when a devcontainer.json
 // exists, the marker file is updated with the actual config pattern (not "none").
 func TestCreateWithDevcontainer_MarkerFile(t *testing.T) {
@@ -328,3 +498,977 @@ func TestFindEnvironmentFromMarker_StatusByPattern(t *testing.T) {
 	assert.Equal(t, model.StatusStopped, envImage.Status,
 		"PatternImage should map to StatusStopped (best guess without Docker)")
 }
+
+// indexTestContainer builds a managed container whose port labels encode
+// the given container→host port mappings.
+func indexTestContainer(envName string, ports map[int]int) model.ContainerInfo {
+	labels := map[string]string{docker.LabelName: envName}
+	for containerPort, hostPort := range ports {
+		labels[docker.BuildPortLabel(containerPort)] = strconv.Itoa(hostPort)
+	}
+	return model.ContainerInfo{ContainerID: "id-" + envName, Labels: labels}
+}
+
+// TestUsedWorktreeIndices verifies that indices are inferred from each
+// environment's port labels, and that environments without a recognizable
+// band are ignored.
+func TestUsedWorktreeIndices(t *testing.T) {
+	groups := map[string][]model.ContainerInfo{
+		"feature-a": {indexTestContainer("feature-a", map[int]int{3000: 13000, 5432: 15432})},
+		"feature-b": {indexTestContainer("feature-b", map[int]int{3000: 33000})},
+		"overflow":  {indexTestContainer("overflow", map[int]int{9000: 49152})},
+		"no-ports":  {indexTestContainer("no-ports", nil)},
+	}
+
+	used := usedWorktreeIndices(groups)
+	assert.Equal(t, map[int]string{1: "feature-a", 3: "feature-b"}, used)
+}
+
+// TestCheckWorktreeIndexAvailable verifies the --index conflict check.
+func TestCheckWorktreeIndexAvailable(t *testing.T) {
+	used := map[int]string{1: "feature-a", 3: "feature-b"}
+
+	assert.NoError(t, checkWorktreeIndexAvailable(2, used))
+	assert.NoError(t, checkWorktreeIndexAvailable(0, nil))
+
+	err := checkWorktreeIndexAvailable(3, used)
+	var cliErr *model.CLIError
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitPortAllocationFailed, cliErr.Code)
+	assert.Contains(t, cliErr.Message, `"feature-b"`)
+}
+
+// TestImagePullNeeded verifies that Pattern A/B pulls explicitly only for
+// --pull always with an image-based configuration.
+func TestImagePullNeeded(t *testing.T) {
+	t.Parallel()
+
+	image := &devcontainer.RawDevContainer{Image: "node:20"}
+	dockerfile := &devcontainer.RawDevContainer{}
+
+	assert.True(t, imagePullNeeded(docker.PullAlways, image))
+	assert.False(t, imagePullNeeded(docker.PullAlways, dockerfile), "Dockerfile builds have no image to pull")
+	assert.False(t, imagePullNeeded(docker.PullMissing, image), "docker run pulls missing images itself")
+	assert.False(t, imagePullNeeded(docker.PullNever, image))
+	assert.False(t, imagePullNeeded(docker.PullDefault, image))
+}
+
+// TestRunPullPhase verifies that container startup runs under the
+// --pull-timeout deadline rather than the shorter --timeout one, that an
+// expired --timeout does not cancel it, and that running out of pull time is
+// reported with the flag's name.
+func TestRunPullPhase(t *testing.T) {
+	t.Parallel()
+
+	parent := context.Background()
+	setupCtx, cancel := withPhaseTimeout(parent, 10*time.Millisecond)
+	defer cancel()
+	setupDeadline, ok := setupCtx.Deadline()
+	require.True(t, ok)
+	<-setupCtx.Done()
+
+	err := runPullPhase(parent, time.Hour, func(ctx context.Context) error {
+		require.NoError(t, ctx.Err(), "the pull phase must not inherit the expired --timeout")
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.True(t, deadline.After(setupDeadline.Add(50*time.Minute)), "pull phase deadline %v", deadline)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Without --pull-timeout the phase has no deadline at all.
+	require.NoError(t, runPullPhase(parent, 0, func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+		return nil
+	}))
+
+	err = runPullPhase(parent, 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--pull-timeout 10ms")
+}
+
+// TestParseComposeServicesOrWarn verifies that relative Compose file paths
+// resolve against the .devcontainer directory and that an unreadable file
+// yields no services instead of an error.
+func TestParseComposeServicesOrWarn(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yml"),
+		[]byte("services:\n  dns:\n    ports: [\"53:53/udp\"]\n"), 0o644))
+
+	services := parseComposeServicesOrWarn(dir, []string{"docker-compose.yml"})
+	require.Len(t, services, 1)
+	assert.Equal(t, "udp", services[0].Ports[0].Protocol)
+
+	assert.Nil(t, parseComposeServicesOrWarn(dir, []string{"missing.yml"}))
+}
+
+// TestAppendAllocatedServices verifies that services with allocated ports
+// are added to the override's service list once, after the listed ones.
+func TestAppendAllocatedServices(t *testing.T) {
+	t.Parallel()
+
+	allocations := []model.PortAllocation{
+		{ServiceName: "app", ContainerPort: 3000},
+		{ServiceName: "dns", ContainerPort: 53, Protocol: "udp"},
+		{ServiceName: "dns", ContainerPort: 8080},
+	}
+	assert.Equal(t, []string{"app", "dns"}, appendAllocatedServices([]string{"app"}, allocations))
+	assert.Equal(t, []string{"app"}, appendAllocatedServices([]string{"app"}, nil))
+}
+
+// TestValidateRequestedIndex_Range verifies that out-of-range indices are
+// rejected before any Docker access.
+func TestValidateRequestedIndex_Range(t *testing.T) {
+	for _, idx := range []int{-1, port.MaxWorktreeIndex + 1} {
+		err := validateRequestedIndex(context.Background(), newDockerConn(), worktree.NewManager(), t.TempDir(), "feature-a", idx, port.DefaultAllocatorConfig(), false)
+		assert.Error(t, err, "index %d should be rejected", idx)
+	}
+
+	err := validateRequestedIndex(context.Background(), newDockerConn(), worktree.NewManager(), t.TempDir(), "feature-a", 3, port.AllocatorConfig{MaxEnvironments: 3}, false)
+	require.Error(t, err, "a lowered limit also bounds --index")
+	assert.Contains(t, err.Error(), "out of range (0-2)")
+}
+
+// TestNextWorktreeIndex verifies that the lowest free index is taken, so
+// that gaps left by pinned or removed environments are filled, and that
+// reaching the limit produces one actionable error listing the environments.
+func TestNextWorktreeIndex(t *testing.T) {
+	t.Parallel()
+
+	index, err := nextWorktreeIndex(map[int]string{}, port.DefaultAllocatorConfig())
+	require.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	index, err = nextWorktreeIndex(map[int]string{0: "main", 1: "feature-a", 3: "feature-b"}, port.DefaultAllocatorConfig())
+	require.NoError(t, err)
+	assert.Equal(t, 2, index)
+
+	_, err = nextWorktreeIndex(map[int]string{1: "feature-b", 2: "feature-a"}, port.AllocatorConfig{MaxEnvironments: 3})
+	var cliErr *model.CLIError
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitGeneralError, cliErr.Code)
+	assert.Contains(t, cliErr.Message, "maximum of 3 environments reached (existing: feature-a, feature-b)")
+	assert.Contains(t, cliErr.Message, "loam remove <name>")
+	assert.Contains(t, cliErr.Message, "raise --max-environments")
+
+	full := make(map[int]string)
+	for i := 1; i <= port.MaxWorktreeIndex; i++ {
+		full[i] = fmt.Sprintf("env-%d", i)
+	}
+	_, err = nextWorktreeIndex(full, port.DefaultAllocatorConfig())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "raise --max-environments", "the default limit cannot be raised")
+}
+
+// TestRunCreate_IndexReuse verifies that an automatic index skips an index
+// pinned with --index, that --index refuses an index already in use, and
+// that the index of a removed environment is reused. The indices are
+// recorded in the marker files, so this works for environments without
+// containers. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_IndexReuse(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, config.FileName),
+		[]byte(`{"worktreePathTemplate": "../wt-{{.Index}}"}`), 0644))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	create := func(name string, flags *createFlags) int {
+		t.Helper()
+		flags.noStart = true
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(context.Background(), name, flags))
+		})
+		env, err := findEnvironmentFromMarker(name)
+		require.NoError(t, err)
+		require.NotNil(t, env)
+		return env.Index
+	}
+
+	assert.Equal(t, 3, create("feature-pinned", &createFlags{index: 3, indexSet: true}))
+	assert.Equal(t, 1, create("feature-a", &createFlags{}))
+	assert.Equal(t, 2, create("feature-b", &createFlags{}))
+	assert.Equal(t, 4, create("feature-c", &createFlags{}), "the pinned index 3 must be skipped")
+
+	// An explicit --index is checked against the marker files too, so the
+	// index of an environment without containers cannot be taken twice.
+	captureStdout(t, func() {
+		err = runCreate(context.Background(), "feature-clash", &createFlags{index: 3, indexSet: true, noStart: true})
+	})
+	requireExitCode(t, err, model.ExitPortAllocationFailed)
+	assert.Contains(t, err.Error(), `"feature-pinned"`)
+
+	require.NoError(t, runRemove(context.Background(), "feature-a", &removeFlags{force: true}))
+	assert.Equal(t, 1, create("feature-d", &createFlags{}), "the index of the removed environment is reused")
+}
+
+// TestResolveAllocatorConfig verifies the precedence of --max-environments
+// over the project configuration, and that invalid limits are rejected.
+func TestResolveAllocatorConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := resolveAllocatorConfig(&createFlags{}, &config.ProjectConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, port.DefaultMaxEnvironments, cfg.MaxEnvironments)
+
+	cfg, err = resolveAllocatorConfig(&createFlags{}, &config.ProjectConfig{MaxEnvironments: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.MaxEnvironments)
+
+	cfg, err = resolveAllocatorConfig(&createFlags{maxEnvironments: 2}, &config.ProjectConfig{MaxEnvironments: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.MaxEnvironments)
+
+	_, err = resolveAllocatorConfig(&createFlags{maxEnvironments: 20}, &config.ProjectConfig{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --max-environments")
+
+	_, err = resolveAllocatorConfig(&createFlags{}, &config.ProjectConfig{MaxEnvironments: -1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maxEnvironments in "+config.FileName)
+}
+
+// TestCheckReusableWorktree verifies the --reuse checks against real
+// repositories: reuse on a matching branch, fallback when nothing exists,
+// and errors for a branch mismatch or another repository's worktree.
+func TestCheckReusableWorktree(t *testing.T) {
+	repoPath := setupTestRepo(t)
+	wm := worktree.NewManager()
+
+	worktreePath := filepath.Join(t.TempDir(), "wt-reuse")
+	require.NoError(t, wm.Add(repoPath, "feature-reuse", worktreePath, ""))
+
+	t.Run("existing worktree on the requested branch", func(t *testing.T) {
+		reused, err := checkReusableWorktree(wm, repoPath, worktreePath, "feature-reuse")
+		require.NoError(t, err)
+		assert.True(t, reused)
+	})
+
+	t.Run("branch mismatch", func(t *testing.T) {
+		reused, err := checkReusableWorktree(wm, repoPath, worktreePath, "feature-other")
+		require.Error(t, err)
+		assert.False(t, reused)
+
+		var cliErr *model.CLIError
+		require.ErrorAs(t, err, &cliErr)
+		assert.Equal(t, model.ExitGitError, cliErr.Code)
+		assert.Contains(t, cliErr.Message, `on branch "feature-reuse", not "feature-other"`)
+	})
+
+	t.Run("nothing at the path falls back to creation", func(t *testing.T) {
+		reused, err := checkReusableWorktree(wm, repoPath, filepath.Join(t.TempDir(), "missing"), "feature-new")
+		require.NoError(t, err)
+		assert.False(t, reused)
+	})
+
+	t.Run("branch checked out elsewhere", func(t *testing.T) {
+		_, err := checkReusableWorktree(wm, repoPath, filepath.Join(t.TempDir(), "missing"), "feature-reuse")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `branch "feature-reuse" is already checked out at`)
+		assert.Contains(t, err.Error(), "wt-reuse")
+	})
+
+	t.Run("worktree of another repository", func(t *testing.T) {
+		otherRepo := setupTestRepo(t)
+		otherPath := filepath.Join(t.TempDir(), "wt-other")
+		require.NoError(t, wm.Add(otherRepo, "feature-reuse", otherPath, ""))
+
+		_, err := checkReusableWorktree(wm, repoPath, otherPath, "feature-reuse")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "different repository")
+	})
+}
+
+// TestResolveEnvName verifies each --env-name-from strategy, that names
+// from a branch and from a directory go through the same sanitizer rules
+// (and so can collide), and that invalid combinations are rejected.
+func TestResolveEnvName(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   createFlags
+		branch  string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "default is branch", branch: "feature/auth", want: "feature-auth"},
+		{name: "branch", flags: createFlags{nameFrom: envNameFromBranch}, branch: "feature/auth", want: "feature-auth"},
+		{name: "branch with --name", flags: createFlags{nameFrom: envNameFromBranch, name: "auth"}, branch: "feature/auth", want: "auth"},
+		{name: "dir", flags: createFlags{nameFrom: envNameFromDir}, branch: "feature/auth", path: "/src/auth-work", want: "auth-work"},
+		{name: "dir collides with branch", flags: createFlags{nameFrom: envNameFromDir}, branch: "other", path: "/src/feature_auth", want: "feature-auth"},
+		{name: "dir without --path", flags: createFlags{nameFrom: envNameFromDir}, branch: "feature/auth", wantErr: "requires --path"},
+		{name: "dir with --name", flags: createFlags{nameFrom: envNameFromDir, name: "auth"}, path: "/src/auth", wantErr: "cannot be used"},
+		{name: "custom", flags: createFlags{nameFrom: envNameFromCustom, name: "auth"}, branch: "feature/auth", want: "auth"},
+		{name: "custom without --name", flags: createFlags{nameFrom: envNameFromCustom}, branch: "feature/auth", wantErr: "requires --name"},
+		{name: "custom is not sanitized", flags: createFlags{nameFrom: envNameFromCustom, name: "feature/auth"}, wantErr: "invalid environment name"},
+		{name: "unknown strategy", flags: createFlags{nameFrom: "tag"}, branch: "feature/auth", wantErr: "invalid --env-name-from"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveEnvName(&tt.flags, tt.branch, tt.path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestParseBuildArgs verifies KEY=VALUE parsing for --build-arg, including
+// empty values, values containing "=", and repeated keys.
+func TestParseBuildArgs(t *testing.T) {
+	args, err := parseBuildArgs([]string{"NODE_VERSION=20", "NODE_VERSION=22", "EMPTY=", "OPTS=a=b,c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"NODE_VERSION": "22", "EMPTY": "", "OPTS": "a=b,c"}, args)
+
+	args, err = parseBuildArgs(nil)
+	require.NoError(t, err)
+	assert.Nil(t, args)
+
+	for _, bad := range []string{"NODE_VERSION", "=22"} {
+		_, err := parseBuildArgs([]string{bad})
+		assert.Error(t, err, "%q should be rejected", bad)
+	}
+}
+
+// TestParseForcePorts verifies the CONTAINER=HOST syntax, the port ranges,
+// and that neither side may be given twice.
+func TestParseForcePorts(t *testing.T) {
+	t.Parallel()
+
+	forced, err := parseForcePorts([]string{"3000=4000", "5432=15432"})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{3000: 4000, 5432: 15432}, forced)
+
+	forced, err = parseForcePorts(nil)
+	require.NoError(t, err)
+	assert.Nil(t, forced)
+
+	for _, bad := range [][]string{
+		{"3000"},
+		{"3000:4000"},
+		{"http=4000"},
+		{"3000=4000=5000"},
+		{"0=4000"},
+		{"3000=80"},
+		{"3000=70000"},
+		{"3000=4000", "3000=4001"},
+		{"3000=4000", "3001=4000"},
+	} {
+		_, err := parseForcePorts(bad)
+		requireExitCode(t, err, model.ExitGeneralError)
+	}
+}
+
+// TestPinLocalPorts verifies that requireLocalPort ports are pinned to
+// their own number unless --force-port pins them elsewhere.
+func TestPinLocalPorts(t *testing.T) {
+	t.Parallel()
+
+	ports := []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000},
+		{ServiceName: "app", ContainerPort: 5432, RequireLocalPort: true},
+		{ServiceName: "app", ContainerPort: 6379, RequireLocalPort: true},
+	}
+
+	assert.Equal(t, map[int]int{5432: 5432, 6379: 6379}, pinLocalPorts(nil, ports))
+	assert.Equal(t, map[int]int{3000: 4000, 5432: 15432, 6379: 6379},
+		pinLocalPorts(map[int]int{3000: 4000, 5432: 15432}, ports))
+	assert.Nil(t, pinLocalPorts(nil, ports[:1]))
+}
+
+// TestComposeDefaultNetwork verifies how --attach-to-network picks the
+// network of the other environment, and that the override then attaches
+// the new environment's services to it as an external network.
+func TestComposeDefaultNetwork(t *testing.T) {
+	t.Parallel()
+
+	env := &model.WorktreeEnv{Name: "feature-api", ProjectPrefix: "acme", ConfigPattern: model.PatternComposeSingle}
+
+	tests := []struct {
+		name     string
+		networks []string
+		want     string
+		wantErr  string
+	}{
+		{"unlabeled", nil, "acme-feature-api_default", ""},
+		{"default among several", []string{"acme-feature-api_backend", "acme-feature-api_default"}, "acme-feature-api_default", ""},
+		{"renamed default", []string{"api-net"}, "api-net", ""},
+		{"ambiguous", []string{"backend", "frontend"}, "", `has several networks (backend, frontend)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := composeDefaultNetwork(env, tt.networks)
+			if tt.wantErr != "" {
+				requireExitCode(t, err, model.ExitGeneralError)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	network, err := composeDefaultNetwork(env, nil)
+	require.NoError(t, err)
+	data, err := devcontainer.GenerateComposeOverrideWithOptions("acme-feature-web", []string{"app"}, nil, nil,
+		devcontainer.ComposeOverrideOptions{ExternalNetwork: network})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "- acme-feature-api_default")
+	assert.Contains(t, string(data), "acme-feature-api_default:\n        external: true")
+}
+
+// TestRunCreate_AttachToNetwork verifies that --attach-to-network is
+// rejected together with --network and that an unknown environment is
+// reported before anything is created. This test uses os.Chdir and
+// t.Setenv, so it must NOT use t.Parallel().
+func TestRunCreate_AttachToNetwork(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	err = runCreate(context.Background(), "feature-web", &createFlags{
+		path:            filepath.Join(t.TempDir(), "wt"),
+		network:         "shared-proxy",
+		attachToNetwork: "feature-api",
+	})
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Contains(t, err.Error(), "--attach-to-network cannot be combined with --network")
+
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	err = runCreate(context.Background(), "feature-web", &createFlags{
+		path:            worktreePath,
+		noStart:         true,
+		attachToNetwork: "feature-api",
+	})
+	requireExitCode(t, err, model.ExitEnvNotFound)
+	assert.Contains(t, err.Error(), `"feature-api" not found`)
+	assert.NoDirExists(t, worktreePath, "nothing is created when the network cannot be resolved")
+}
+
+// TestSkipHostNetworkPorts verifies that host networking drops every port
+// of a pattern A/B container, but only the ports of the host-networked
+// services of a Compose project.
+func TestSkipHostNetworkPorts(t *testing.T) {
+	t.Parallel()
+
+	ports := []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000},
+		{ServiceName: "db", ContainerPort: 5432},
+	}
+
+	assert.Equal(t, ports, skipHostNetworkPorts(&devcontainer.RawDevContainer{}, model.PatternImage, nil, ports))
+	assert.Nil(t, skipHostNetworkPorts(&devcontainer.RawDevContainer{RunArgs: []string{"--network=host"}},
+		model.PatternImage, nil, ports))
+
+	services := []devcontainer.ComposeService{{Name: "app", HostNetwork: true}, {Name: "db"}}
+	assert.Equal(t, ports[1:], skipHostNetworkPorts(&devcontainer.RawDevContainer{}, model.PatternComposeMulti, services, ports))
+	assert.Equal(t, ports, skipHostNetworkPorts(&devcontainer.RawDevContainer{}, model.PatternComposeMulti, services[1:], ports))
+}
+
+// TestRunCreate_HostNetwork verifies that a host-networked pattern A
+// container gets no ports allocated and no appPort in its rewritten
+// configuration. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_HostNetwork(t *testing.T) {
+	setJSONOutput(t, true)
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{
+		"image": "node:22",
+		"runArgs": ["--network", "host"],
+		"appPort": ["3000:3000"]
+	}`), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	out := captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-host", &createFlags{
+			path:          worktreePath,
+			noStart:       true,
+			skipPortCheck: true,
+		}))
+	})
+	var result createResultJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	assert.Empty(t, result.Services)
+
+	raw, err := devcontainer.LoadConfig(filepath.Join(worktreePath, ".devcontainer", "devcontainer.json"))
+	require.NoError(t, err)
+	assert.Nil(t, raw.AppPort)
+	assert.Equal(t, []string{"--network", "host"}, raw.RunArgs[:2])
+}
+
+// TestRunCreate_ForcePort verifies that a forced port is published at the
+// given host port while the other ports are shifted as usual, and that
+// --force-port is rejected together with --no-ports. This test uses
+// os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_ForcePort(t *testing.T) {
+	setJSONOutput(t, true)
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{
+		"image": "node:22",
+		"forwardPorts": [3000, 9229]
+	}`), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-forced", &createFlags{
+			path:          filepath.Join(t.TempDir(), "wt"),
+			noStart:       true,
+			skipPortCheck: true,
+			index:         1,
+			indexSet:      true,
+			forcePorts:    []string{"3000=4000"},
+		}))
+	})
+	var result createResultJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	hostPorts := make(map[int]int)
+	for _, svc := range result.Services {
+		hostPorts[svc.ContainerPort] = svc.HostPort
+	}
+	assert.Equal(t, map[int]int{3000: 4000, 9229: 19229}, hostPorts)
+
+	err = runCreate(context.Background(), "feature-none", &createFlags{
+		path:       filepath.Join(t.TempDir(), "wt"),
+		noStart:    true,
+		noPorts:    true,
+		forcePorts: []string{"3000=4000"},
+	})
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Contains(t, err.Error(), "--no-ports")
+}
+
+// TestRunCreate_BuildArgs verifies that --build-arg values are merged into
+// build.args of the rewritten Pattern B configuration, which the
+// devcontainer CLI passes to the image build, overriding the original
+// values. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_BuildArgs(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{
+		"build": {"dockerfile": "Dockerfile", "args": {"NODE_VERSION": "20", "DEBIAN": "bookworm"}}
+	}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "Dockerfile"), []byte("FROM node\n"), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-args", &createFlags{
+			path:      worktreePath,
+			noStart:   true,
+			buildArgs: []string{"NODE_VERSION=22", "EXTRA=1"},
+		}))
+	})
+
+	raw, err := devcontainer.LoadConfig(filepath.Join(worktreePath, ".devcontainer", "devcontainer.json"))
+	require.NoError(t, err)
+	require.NotNil(t, raw.Build)
+	assert.Equal(t, "Dockerfile", raw.Build.Dockerfile)
+	assert.Equal(t, map[string]string{"NODE_VERSION": "22", "DEBIAN": "bookworm", "EXTRA": "1"}, raw.Build.Args)
+}
+
+// TestRunCreate_BaseImage verifies that --base-image replaces the image of
+// the rewritten Pattern A configuration, leaving the original untouched,
+// and that it is rejected for a Compose configuration, whose worktree is
+// then rolled back. This test uses os.Chdir, so it must NOT use
+// t.Parallel().
+func TestRunCreate_BaseImage(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{"image": "node:20"}`), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-image", &createFlags{
+			path:      worktreePath,
+			noStart:   true,
+			baseImage: "node:22-bookworm",
+		}))
+	})
+
+	raw, err := devcontainer.LoadConfig(filepath.Join(worktreePath, ".devcontainer", "devcontainer.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "node:22-bookworm", raw.Image)
+	original, err := devcontainer.LoadConfig(filepath.Join(dcDir, "devcontainer.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "node:20", original.Image)
+
+	composeRepo := setupComposeRepo(t)
+	require.NoError(t, os.Chdir(composeRepo))
+	composePath := filepath.Join(t.TempDir(), "wt-compose")
+	captureStdout(t, func() {
+		err = runCreate(context.Background(), "feature-compose", &createFlags{
+			path:              composePath,
+			noStart:           true,
+			baseImage:         "node:22",
+			rollbackOnFailure: true,
+		})
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--base-image only applies to image-based configurations")
+	assert.NoDirExists(t, composePath)
+}
+
+// TestCreateEnv_SingleDockerClient verifies that the steps of one create
+// share a single Docker client, opened once and closed once, also when
+// create fails after consulting Docker several times. The daemon is made
+// unreachable, so starting the container fails and the create is rolled
+// back. This test uses os.Chdir and t.Setenv, so it must NOT use
+// t.Parallel().
+func TestCreateEnv_SingleDockerClient(t *testing.T) {
+	setJSONOutput(t, false)
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{"image": "node:20"}`), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	opened, closed := 0, 0
+	dc := &dockerConn{
+		connect: func() (*docker.Client, error) {
+			opened++
+			return docker.NewClient()
+		},
+		closeClient: func(c *docker.Client) error {
+			closed++
+			return c.Close()
+		},
+	}
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		err = createEnv(context.Background(), "feature-conn", &createFlags{
+			path:              worktreePath,
+			indexSet:          true,
+			index:             2,
+			rollbackOnFailure: true,
+		}, dc)
+		dc.close()
+		dc.close()
+	})
+	require.Error(t, err)
+	assert.NoDirExists(t, worktreePath)
+	assert.Equal(t, 1, opened)
+	assert.Equal(t, 1, closed)
+}
+
+// TestRunCreate_ComposeFileLabel verifies that create records the Compose
+// file chain, with the --compose-file files after the generated override,
+// in the labels of the override. This test uses os.Chdir, so it must NOT
+// use t.Parallel().
+func TestRunCreate_ComposeFileLabel(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+
+	extra := filepath.Join(t.TempDir(), "debug.yml")
+	require.NoError(t, os.WriteFile(extra, []byte("services:\n  app:\n    cap_add: [SYS_PTRACE]\n"), 0o644))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-debug", &createFlags{
+			path:         worktreePath,
+			noStart:      true,
+			composeFiles: []string{extra},
+		}))
+	})
+
+	override, err := os.ReadFile(filepath.Join(worktreePath, ".devcontainer", devcontainer.ComposeOverrideFileName))
+	require.NoError(t, err)
+	chain := strings.Join([]string{"docker-compose.yml", devcontainer.ComposeOverrideFileName, extra}, string(os.PathListSeparator))
+	assert.Contains(t, string(override), docker.LabelComposeFiles+": "+chain)
+}
+
+// TestRunCreate_GitignoreGeneratedFiles verifies that the Compose override
+// is added to .devcontainer/.gitignore exactly once across repeated creates
+// on the same worktree, that it no longer shows up in `git status`, and
+// that --no-gitignore leaves the file alone. This test uses os.Chdir, so it
+// must NOT use t.Parallel().
+func TestRunCreate_GitignoreGeneratedFiles(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	wm := worktree.NewManager()
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	gitignorePath := filepath.Join(worktreePath, ".devcontainer", ".gitignore")
+
+	for cycle := 0; cycle < 2; cycle++ {
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(t.Context(), "feature-ignore",
+				&createFlags{path: worktreePath, reuse: true, noStart: true}), "cycle %d", cycle)
+		})
+	}
+
+	content, err := os.ReadFile(gitignorePath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), devcontainer.ComposeOverrideFileName+"\n"))
+	assert.NotContains(t, string(content), "devcontainer.json", "a tracked devcontainer.json is not listed")
+
+	changed, err := wm.ChangedFiles(worktreePath)
+	require.NoError(t, err)
+	for _, file := range changed {
+		assert.NotContains(t, file, devcontainer.ComposeOverrideFileName)
+		assert.NotContains(t, file, ".gitignore")
+	}
+
+	// With --no-gitignore, a fresh worktree gets no .gitignore.
+	otherPath := filepath.Join(t.TempDir(), "wt-other")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature-no-ignore",
+			&createFlags{path: otherPath, noStart: true, noGitignore: true}))
+	})
+	assert.NoFileExists(t, filepath.Join(otherPath, ".devcontainer", ".gitignore"))
+}
+
+// TestRunCreate_NoStartWithoutDocker verifies that --no-start works with no
+// Docker daemon, and that the worktree index is then derived from the
+// marker files of the repository's worktrees, so a second environment gets
+// the next port band instead of sharing index 1. This test uses os.Chdir
+// and t.Setenv, so it must NOT use t.Parallel().
+func TestRunCreate_NoStartWithoutDocker(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	for i, name := range []string{"feature-one", "feature-two"} {
+		worktreePath := filepath.Join(t.TempDir(), name)
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(t.Context(), name, &createFlags{path: worktreePath, noStart: true}))
+		})
+
+		override, err := os.ReadFile(filepath.Join(worktreePath, ".devcontainer", devcontainer.ComposeOverrideFileName))
+		require.NoError(t, err)
+		assert.Contains(t, string(override), fmt.Sprintf("%s: \"%d\"", docker.LabelIndex, i+1))
+	}
+}
+
+// TestRunCreate_JSONGeneratedPaths verifies that the JSON result of create
+// points at the generated devcontainer.json, the Compose chain, and the
+// override, both in the worktree and with --config-dir, and that every
+// path exists. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_JSONGeneratedPaths(t *testing.T) {
+	setJSONOutput(t, true)
+	repoDir := setupComposeRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	for _, withConfigDir := range []bool{false, true} {
+		worktreePath := filepath.Join(t.TempDir(), "wt")
+		generatedDir := filepath.Join(worktreePath, ".devcontainer")
+		flags := &createFlags{path: worktreePath, noStart: true}
+		if withConfigDir {
+			generatedDir = filepath.Join(t.TempDir(), "configs")
+			flags.configDir = generatedDir
+		}
+
+		out := captureStdout(t, func() {
+			require.NoError(t, runCreate(t.Context(), "feature-paths", flags))
+		})
+		var result createResultJSON
+		require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+
+		assert.Equal(t, filepath.Join(generatedDir, "devcontainer.json"), result.DevcontainerPath)
+		assert.Equal(t, filepath.Join(generatedDir, devcontainer.ComposeOverrideFileName), result.OverridePath)
+		assert.Equal(t, []string{
+			filepath.Join(worktreePath, ".devcontainer", "docker-compose.yml"),
+			result.OverridePath,
+		}, result.ComposeFiles)
+		for _, path := range append([]string{result.DevcontainerPath}, result.ComposeFiles...) {
+			assert.FileExists(t, path)
+		}
+		require.NotNil(t, result.Tools)
+		assert.Equal(t, "devcontainer up --workspace-folder "+worktreePath, result.Tools.DevContainerCLI)
+
+		require.NoError(t, worktree.NewManager().Remove(repoDir, worktreePath, true))
+	}
+}
+
+// TestBuildCreateResultJSON_WorktreeOnly verifies that a worktree-only
+// environment reports no generated files or tool commands.
+func TestBuildCreateResultJSON_WorktreeOnly(t *testing.T) {
+	data, err := json.Marshal(buildCreateResultJSON(&model.WorktreeEnv{
+		Name:          "docs",
+		Branch:        "docs",
+		WorktreePath:  "/tmp/docs",
+		Status:        model.StatusNoContainer,
+		ConfigPattern: model.PatternNone,
+	}))
+	require.NoError(t, err)
+	for _, key := range []string{"devcontainerPath", "composeFiles", "overridePath", "tools"} {
+		assert.NotContains(t, string(data), `"`+key+`"`)
+	}
+}
+
+// TestRunCreate_NewBranchOnly verifies that --new-branch-only fails with a
+// Git error, before creating anything, when the branch already exists, and
+// creates the environment for a new branch. This test uses os.Chdir, so it
+// must NOT use t.Parallel().
+func TestRunCreate_NewBranchOnly(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupTestRepo(t)
+	runTestGit(t, repoDir, "branch", "feature-stale")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	stalePath := filepath.Join(t.TempDir(), "stale")
+	err = runCreate(t.Context(), "feature-stale", &createFlags{path: stalePath, noStart: true, newBranchOnly: true})
+	requireExitCode(t, err, model.ExitGitError)
+	assert.Contains(t, err.Error(), "already exists")
+	assert.NoDirExists(t, stalePath)
+
+	freshPath := filepath.Join(t.TempDir(), "fresh")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature-fresh", &createFlags{path: freshPath, noStart: true, newBranchOnly: true}))
+	})
+	assert.DirExists(t, freshPath)
+	assert.True(t, worktree.NewManager().BranchExists(repoDir, "feature-fresh"))
+}
+
+// TestResolveProjectPrefix verifies the Compose project name prefix: the
+// repository default, an explicit value, "none", and the conflicts and
+// invalid values that are rejected.
+func TestResolveProjectPrefix(t *testing.T) {
+	t.Parallel()
+
+	const repoRoot = "/home/dev/src/api"
+	tests := []struct {
+		name    string
+		flags   createFlags
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: model.DefaultProjectPrefix(repoRoot)},
+		{name: "explicit", flags: createFlags{projectPrefix: "acme"}, want: "acme"},
+		{name: "none", flags: createFlags{projectPrefix: projectPrefixNone}, want: ""},
+		{name: "project name", flags: createFlags{projectName: "acme-auth"}, want: ""},
+		{name: "project name with prefix", flags: createFlags{projectName: "acme-auth", projectPrefix: "acme"}, wantErr: true},
+		{name: "invalid", flags: createFlags{projectPrefix: "Acme Corp"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := resolveProjectPrefix(&tt.flags, repoRoot)
+			if tt.wantErr {
+				requireExitCode(t, err, model.ExitGeneralError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestRunCreate_ProjectPrefixAvoidsCollisions verifies that environments of
+// the same name in two repositories get different Compose project names by
+// default. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_ProjectPrefixAvoidsCollisions(t *testing.T) {
+	setJSONOutput(t, false)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+
+	var names []string
+	for range 2 {
+		repoDir := setupComposeRepo(t)
+		require.NoError(t, os.Chdir(repoDir))
+
+		worktreePath := filepath.Join(t.TempDir(), "wt")
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(t.Context(), "feature-auth", &createFlags{path: worktreePath, noStart: true}))
+		})
+		data, err := os.ReadFile(filepath.Join(worktreePath, ".devcontainer", devcontainer.ComposeOverrideFileName))
+		require.NoError(t, err)
+		var override struct {
+			Name string `yaml:"name"`
+		}
+		require.NoError(t, yaml.Unmarshal(data, &override))
+		assert.True(t, strings.HasSuffix(override.Name, "-feature-auth"), override.Name)
+		names = append(names, override.Name)
+	}
+	assert.NotEqual(t, names[0], names[1])
+}
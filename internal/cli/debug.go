@@ -0,0 +1,156 @@
+// Package cli — debug.go implements the hidden "loam debug" commands.
+//
+// These commands dump internal state for troubleshooting and are not part
+// of the documented interface; their output format may change at any time.
+//
+// "loam debug inspect <name>" prints the environment as reconstructed from
+// its labels next to the raw material it was reconstructed from: each
+// container's full label map and its Docker inspect result. Corrupted or
+// hand-edited labels are otherwise hard to see, because the normal commands
+// only show what parsing made of them — or fail outright.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/spf13/cobra"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// NewDebugCommand creates the hidden "debug" cobra command, which groups the
+// troubleshooting subcommands. It is called from NewRootCommand.
+func NewDebugCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "debug",
+		Short:  "Troubleshooting commands",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+	}
+	cmd.AddCommand(newDebugInspectCommand())
+	return cmd
+}
+
+// newDebugInspectCommand creates the "debug inspect" subcommand.
+func newDebugInspectCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "inspect <name>",
+		Short: "Dump an environment with its raw labels and Docker inspect data",
+		Long: `Print the reconstructed worktree environment together with each
+container's raw labels and Docker inspect result, as JSON.
+
+Parsing errors do not stop the dump: if the labels cannot be turned into an
+environment, the error is reported in "envError" and the raw data is still
+printed.
+
+Examples:
+  loam debug inspect feature-auth`,
+
+		Args: cobra.ExactArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDebugInspect(cmd.Context(), args[0])
+		},
+	}
+}
+
+// debugInspectJSON is the output of "debug inspect".
+type debugInspectJSON struct {
+	// Env is the environment as the other commands see it; nil if it
+	// could not be reconstructed.
+	Env *model.WorktreeEnv `json:"env"`
+
+	// EnvError is the error from reconstructing Env, if any.
+	EnvError string `json:"envError,omitempty"`
+
+	Containers []debugContainerJSON `json:"containers"`
+}
+
+// debugContainerJSON is the raw data of one container in "debug inspect".
+type debugContainerJSON struct {
+	ContainerID   string               `json:"containerId"`
+	ContainerName string               `json:"containerName"`
+	Labels        map[string]string    `json:"labels"`
+	Inspect       *types.ContainerJSON `json:"inspect,omitempty"`
+	InspectError  string               `json:"inspectError,omitempty"`
+}
+
+// inspectFunc returns the Docker inspect result of a container. It is
+// *client.Client's ContainerInspect; tests substitute a fake.
+type inspectFunc func(ctx context.Context, containerID string) (types.ContainerJSON, error)
+
+// runDebugInspect is the main logic function for "debug inspect".
+func runDebugInspect(ctx context.Context, envName string) error {
+	// The raw data lives in Docker, so Docker is mandatory here.
+	cli, err := docker.NewClient()
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "Docker is required for debug inspect but is not available", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	containers, err := docker.ListManagedContainers(ctx, cli)
+	if err != nil {
+		return err
+	}
+	containers = docker.GroupContainersByEnv(containers)[envName]
+
+	if len(containers) == 0 {
+		// Without containers there is nothing raw to show, but a marker-only
+		// environment is still worth dumping.
+		env, markerErr := findEnvironmentFromMarker(envName)
+		if markerErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "failed to read marker files", markerErr)
+		}
+		if env == nil {
+			return model.NewCLIError(model.ExitEnvNotFound,
+				fmt.Sprintf("worktree environment %q not found", envName))
+		}
+		printDebugInspect(&debugInspectJSON{Env: env, Containers: []debugContainerJSON{}})
+		return nil
+	}
+
+	printDebugInspect(buildDebugInspect(ctx, cli.Inner().ContainerInspect, envName, containers))
+	return nil
+}
+
+// buildDebugInspect reconstructs the environment from containers and
+// collects each container's labels and inspect result. Failures are
+// recorded in the result rather than returned, so that one broken
+// container does not hide the rest.
+func buildDebugInspect(ctx context.Context, inspect inspectFunc, envName string, containers []model.ContainerInfo) *debugInspectJSON {
+	result := &debugInspectJSON{Containers: make([]debugContainerJSON, 0, len(containers))}
+
+	env, err := docker.BuildWorktreeEnv(envName, containers)
+	if err != nil {
+		result.EnvError = err.Error()
+	} else {
+		result.Env = env
+	}
+
+	for _, c := range containers {
+		entry := debugContainerJSON{
+			ContainerID:   c.ContainerID,
+			ContainerName: c.ContainerName,
+			Labels:        c.Labels,
+		}
+		info, inspectErr := inspect(ctx, c.ContainerID)
+		if inspectErr != nil {
+			entry.InspectError = inspectErr.Error()
+		} else {
+			entry.Inspect = &info
+		}
+		result.Containers = append(result.Containers, entry)
+	}
+	return result
+}
+
+// printDebugInspect prints the dump as indented JSON. It is always JSON,
+// regardless of --output, since the raw inspect data has no text form.
+func printDebugInspect(result *debugInspectJSON) {
+	data, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(data))
+}
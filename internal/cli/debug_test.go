@@ -0,0 +1,101 @@
+// Package cli — debug_test.go contains unit tests for "loam debug inspect".
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// fakeInspect returns an inspect result carrying the container's ID and
+// labels, and fails for IDs in failing.
+func fakeInspect(labels map[string]map[string]string, failing ...string) inspectFunc {
+	return func(_ context.Context, containerID string) (types.ContainerJSON, error) {
+		for _, id := range failing {
+			if id == containerID {
+				return types.ContainerJSON{}, errors.New("no such container")
+			}
+		}
+		return types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: containerID},
+			Config:            &container.Config{Labels: labels[containerID]},
+		}, nil
+	}
+}
+
+// TestBuildDebugInspect verifies that the dump contains the reconstructed
+// environment and every container's raw labels, including labels that the
+// environment model does not carry.
+func TestBuildDebugInspect(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:           "feature-auth",
+		Branch:         "feature/auth",
+		WorktreePath:   "/tmp/feature-auth",
+		SourceRepoPath: "/tmp/repo",
+		ConfigPattern:  model.PatternImage,
+		CreatedAt:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Index:          1,
+	}
+	labels, err := docker.BuildLabels(env)
+	require.NoError(t, err)
+	labels["com.example.extra"] = "kept"
+	containers := []model.ContainerInfo{{ContainerID: "c1", ContainerName: "app", Status: "running", Labels: labels}}
+
+	result := buildDebugInspect(context.Background(), fakeInspect(map[string]map[string]string{"c1": labels}), "feature-auth", containers)
+	require.Empty(t, result.EnvError)
+	require.NotNil(t, result.Env)
+	assert.Equal(t, "feature/auth", result.Env.Branch)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	var decoded struct {
+		Containers []struct {
+			Labels  map[string]string `json:"labels"`
+			Inspect struct {
+				ID     string `json:"Id"`
+				Config struct {
+					Labels map[string]string `json:"Labels"`
+				} `json:"Config"`
+			} `json:"inspect"`
+		} `json:"containers"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Len(t, decoded.Containers, 1)
+	assert.Equal(t, labels, decoded.Containers[0].Labels)
+	assert.Equal(t, "c1", decoded.Containers[0].Inspect.ID)
+	assert.Equal(t, "kept", decoded.Containers[0].Inspect.Config.Labels["com.example.extra"])
+}
+
+// TestBuildDebugInspect_CorruptLabels verifies that labels that cannot be
+// parsed are reported in envError while the raw data is still dumped, and
+// that an inspect failure is recorded per container.
+func TestBuildDebugInspect_CorruptLabels(t *testing.T) {
+	labels := map[string]string{
+		docker.LabelManagedBy: docker.ManagedByValue,
+		docker.LabelName:      "feature-auth",
+		docker.LabelCreatedAt: "yesterday",
+	}
+	containers := []model.ContainerInfo{
+		{ContainerID: "c1", ContainerName: "app", Labels: labels},
+		{ContainerID: "c2", ContainerName: "db", Labels: labels},
+	}
+
+	result := buildDebugInspect(context.Background(), fakeInspect(nil, "c2"), "feature-auth", containers)
+	assert.Nil(t, result.Env)
+	assert.Contains(t, result.EnvError, "missing required Docker labels")
+	require.Len(t, result.Containers, 2)
+	assert.Equal(t, "yesterday", result.Containers[0].Labels[docker.LabelCreatedAt])
+	assert.NotNil(t, result.Containers[0].Inspect)
+	assert.Nil(t, result.Containers[1].Inspect)
+	assert.Equal(t, "no such container", result.Containers[1].InspectError)
+}
@@ -0,0 +1,120 @@
+// Package cli — diskspace.go implements the disk space pre-flight of
+// "loam create".
+//
+// create copies the configuration's .devcontainer directory into the new
+// worktree. A build context there can be large, and a copy that runs out of
+// space halfway leaves a broken worktree behind, so create first compares
+// the size of the directory with the space available where the worktree
+// goes. --skip-space-check turns the check off.
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// diskSpaceHeadroom is the space that should remain free after the copy.
+// Less than this is not an error, but the worktree's own checkout and any
+// build run in it are likely to fill the disk, so create warns.
+const diskSpaceHeadroom = 256 << 20 // 256 MiB
+
+// freeDiskFunc returns the bytes available to the current user on the file
+// system holding path. freeDiskBytes is the production implementation.
+type freeDiskFunc func(path string) (uint64, error)
+
+// dirSize returns the total size of the regular files under dir. Symbolic
+// links are not followed, matching devcontainer.CopyDevContainerDir, which
+// does not copy them.
+func dirSize(dir string) (uint64, error) {
+	var total uint64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.Mode().IsRegular() {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// nearestExistingDir returns path or its closest ancestor that exists, so
+// that free space can be queried before the worktree's parent directories
+// are created.
+func nearestExistingDir(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// checkDiskSpace decides whether need bytes fit into free bytes. It returns
+// an error if they do not, and a warning if they do but less than
+// diskSpaceHeadroom would remain.
+func checkDiskSpace(need, free uint64) (string, error) {
+	if free < need {
+		return "", model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("not enough disk space for the worktree: .devcontainer needs %s, %s available (use --skip-space-check to create it anyway)",
+				formatBytes(need), formatBytes(free)))
+	}
+	if free-need < diskSpaceHeadroom {
+		return fmt.Sprintf("low disk space for the worktree: %s available, %s of it needed for .devcontainer",
+			formatBytes(free), formatBytes(need)), nil
+	}
+	return "", nil
+}
+
+// checkWorktreeSpace runs the pre-flight for copying srcDir (the
+// configuration's .devcontainer directory) into a worktree at
+// worktreePath. Failures to measure either side are only logged: the check
+// is a safeguard, not a requirement for creating an environment.
+func checkWorktreeSpace(srcDir, worktreePath string, free freeDiskFunc) error {
+	need, err := dirSize(srcDir)
+	if err != nil {
+		VerboseLog("Skipping disk space check: cannot measure %s: %v", srcDir, err)
+		return nil
+	}
+	target := nearestExistingDir(filepath.Dir(worktreePath))
+	available, err := free(target)
+	if err != nil {
+		VerboseLog("Skipping disk space check: cannot query free space at %s: %v", target, err)
+		return nil
+	}
+	VerboseLog("Disk space: .devcontainer needs %s, %s available at %s", formatBytes(need), formatBytes(available), target)
+
+	warning, err := checkDiskSpace(need, available)
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		printWarning("%s", warning)
+	}
+	return nil
+}
+
+// formatBytes renders n in binary units with one decimal (e.g., "1.5 GiB").
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
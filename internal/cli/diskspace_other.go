@@ -0,0 +1,14 @@
+//go:build !darwin && !freebsd && !linux && !windows
+
+package cli
+
+import (
+	"errors"
+	"runtime"
+)
+
+// freeDiskBytes is not implemented on this platform; the disk space check
+// is skipped.
+func freeDiskBytes(string) (uint64, error) {
+	return 0, errors.New("free disk space is not available on " + runtime.GOOS)
+}
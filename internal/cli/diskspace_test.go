@@ -0,0 +1,100 @@
+// Package cli — diskspace_test.go contains unit tests for the disk space
+// pre-flight of "loam create".
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// TestDirSize verifies that the sizes of the regular files in a tree are
+// summed and that symbolic links are not counted.
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devcontainer.json"), make([]byte, 100), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "context", "assets"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "context", "assets", "blob.bin"), make([]byte, 4096), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "context"), filepath.Join(dir, "link")))
+
+	size, err := dirSize(dir)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4196), size)
+
+	_, err = dirSize(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+// TestCheckDiskSpace verifies the decision: an error when the directory
+// does not fit, a warning when less than the headroom would remain, and
+// nothing otherwise.
+func TestCheckDiskSpace(t *testing.T) {
+	tests := []struct {
+		name        string
+		need, free  uint64
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "plenty of space", need: 1 << 20, free: 10 << 30},
+		{name: "exactly the headroom left", need: 1 << 20, free: 1<<20 + diskSpaceHeadroom},
+		{name: "fits but low", need: 1 << 20, free: 2 << 20, wantWarning: true},
+		{name: "exact fit", need: 1 << 20, free: 1 << 20, wantWarning: true},
+		{name: "does not fit", need: 2 << 20, free: 1 << 20, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, err := checkDiskSpace(tt.need, tt.free)
+			if tt.wantErr {
+				requireExitCode(t, err, model.ExitGeneralError)
+				assert.Contains(t, err.Error(), "--skip-space-check")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWarning, warning != "", warning)
+		})
+	}
+}
+
+// TestCheckWorktreeSpace verifies that free space is queried at the nearest
+// existing ancestor of the worktree, that a shortage aborts, and that a
+// failed query skips the check.
+func TestCheckWorktreeSpace(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "Dockerfile"), make([]byte, 2048), 0o644))
+	parent := t.TempDir()
+	worktreePath := filepath.Join(parent, "not", "yet", "there", "feature-auth")
+
+	var queried string
+	free := func(path string) (uint64, error) {
+		queried = path
+		return 1024, nil
+	}
+	err := checkWorktreeSpace(src, worktreePath, free)
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Equal(t, parent, queried)
+
+	failing := func(string) (uint64, error) { return 0, errors.New("unsupported") }
+	assert.NoError(t, checkWorktreeSpace(src, worktreePath, failing))
+}
+
+// TestFormatBytes verifies the rendering of sizes in binary units.
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "256.0 MiB", formatBytes(diskSpaceHeadroom))
+	assert.Equal(t, "2.0 GiB", formatBytes(2<<30))
+}
+
+// TestFreeDiskBytes verifies that the platform helper reports some space
+// for an existing directory.
+func TestFreeDiskBytes(t *testing.T) {
+	free, err := freeDiskBytes(t.TempDir())
+	require.NoError(t, err)
+	assert.Positive(t, free)
+}
@@ -0,0 +1,16 @@
+//go:build darwin || freebsd || linux
+
+package cli
+
+import "syscall"
+
+// freeDiskBytes returns the bytes available to unprivileged users on the
+// file system holding path.
+func freeDiskBytes(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	// The field types differ between platforms, hence the conversions.
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,48 @@
+// Package cli — dockerconn.go implements the Docker connection shared by
+// the steps of one create run.
+//
+// Several steps of create consult Docker (the index, existing allocations,
+// published ports, the --network check). Rather than each opening a client
+// of its own, they share one dockerConn, which connects on first use and is
+// closed once when create returns, on error paths as well.
+package cli
+
+import "github.com/mmr-tortoise/loam/internal/docker"
+
+// dockerConn is a lazily opened Docker client. The zero value is not
+// usable; see newDockerConn.
+type dockerConn struct {
+	// connect opens the client (docker.NewClient in production) and
+	// closeClient closes it; tests replace them to count calls.
+	connect     func() (*docker.Client, error)
+	closeClient func(*docker.Client) error
+
+	cli    *docker.Client
+	err    error
+	opened bool
+}
+
+// newDockerConn returns a dockerConn that opens its client with
+// docker.NewClient.
+func newDockerConn() *dockerConn {
+	return &dockerConn{connect: docker.NewClient, closeClient: (*docker.Client).Close}
+}
+
+// client returns the shared client, opening it on the first call. A failure
+// to open is remembered and returned on every call, so it is not retried.
+func (c *dockerConn) client() (*docker.Client, error) {
+	if !c.opened {
+		c.opened = true
+		c.cli, c.err = c.connect()
+	}
+	return c.cli, c.err
+}
+
+// close closes the client if it was opened. It is safe to call more than
+// once.
+func (c *dockerConn) close() {
+	if c.cli != nil {
+		_ = c.closeClient(c.cli)
+		c.cli = nil
+	}
+}
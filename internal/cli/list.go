@@ -5,9 +5,15 @@
 // worktree discovery, and Docker container labels for live container state.
 // This allows listing environments even when Docker is unavailable.
 //
-// Environments are presented as a text table or JSON array, depending on
-// the --json flag. An optional --status flag allows filtering by lifecycle
-// state (running, stopped, orphaned, no-container, or all).
+// Environments are presented as a text table, JSON, or YAML, depending on
+// the --output flag. Several flags narrow or arrange the result: --status
+// keeps one lifecycle state (running, starting, stopped, orphaned,
+// no-container, unhealthy, or all), --older-than and --newer-than filter
+// by the age of the environment to find forgotten ones, --limit caps the
+// number of environments shown, and --group-by repo sections the output by
+// source repository. --detailed checks container health, and --no-docker
+// lists the repository's worktrees from Git alone. A summary of the
+// matching environments follows the table unless --no-summary is given.
 package cli
 
 import (
@@ -15,6 +21,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -31,10 +39,44 @@ import (
 // These are bound to cobra flags in NewListCommand.
 type listFlags struct {
 	// status filters environments by their lifecycle state.
-	// Valid values: "running", "stopped", "orphaned", "no-container", "all" (default).
+	// Valid values: "running", "starting", "stopped", "orphaned", "no-container",
+	// "unhealthy", "all" (default).
 	status string
+
+	// detailed inspects running containers for their healthcheck status,
+	// so that environments failing a healthcheck show as "unhealthy".
+	detailed bool
+
+	// groupBy selects how environments are grouped in the output.
+	// Valid values: "" (flat list, default) and "repo" (by source repository).
+	groupBy string
+
+	// limit caps the number of environments shown; 0 shows all of them.
+	limit int
+
+	// failIfEmpty makes list exit with ExitEnvNotFound when no environment
+	// matches, so scripts can test "is anything running?".
+	failIfEmpty bool
+
+	// noDocker lists the worktrees of the current repository from Git
+	// alone, without contacting Docker; their status is "unknown".
+	noDocker bool
+
+	// noSummary omits the summary (counts by status, ports allocated) from
+	// the output.
+	noSummary bool
+
+	// olderThan and newerThan filter environments by the time since they
+	// were created, as durations accepted by parseAge (e.g., "7d", "12h").
+	// Empty means no limit.
+	olderThan string
+	newerThan string
 }
 
+// listGroupByRepo is the --group-by value that groups environments by
+// their source repository path.
+const listGroupByRepo = "repo"
+
 // NewListCommand creates the "list" cobra command.
 // It is called from NewRootCommand to register as a subcommand.
 func NewListCommand() *cobra.Command {
@@ -46,12 +88,23 @@ func NewListCommand() *cobra.Command {
 		Long: `List all managed worktree environments and their status.
 
 Each environment is shown with its name, branch, lifecycle status,
-service count, and allocated host ports.
+worktree index (port band), service count, and allocated host ports.
 
 Examples:
   loam list
   loam list --status running
-  loam list --json`,
+  loam list --detailed
+  loam list --group-by repo
+  loam list --status running --limit 10
+  loam list --status running --fail-if-empty
+  loam list --older-than 7d
+  loam list --status orphaned --older-than 1d
+  loam list --no-docker
+  loam list --output json
+  loam list --output yaml`,
+
+		// list renders the same structs as JSON or YAML (see printYAML).
+		Annotations: map[string]string{annotationYAMLOutput: "true"},
 
 		// No positional arguments are required for the list command.
 		Args: cobra.NoArgs,
@@ -64,7 +117,23 @@ Examples:
 
 	// Register the --status flag with a default value of "all".
 	cmd.Flags().StringVar(&flags.status, "status", "all",
-		"Filter by status: running, stopped, orphaned, no-container, all (default: all)")
+		"Filter by status: running, starting, stopped, orphaned, no-container, unhealthy, unknown, all (default: all)")
+	cmd.Flags().BoolVar(&flags.detailed, "detailed", false,
+		"Inspect container healthchecks and show environments failing them as unhealthy (one Docker call per running container)")
+	cmd.Flags().StringVar(&flags.groupBy, "group-by", "",
+		"Group environments in the output: repo (default: flat list)")
+	cmd.Flags().IntVar(&flags.limit, "limit", 0,
+		"Show at most N environments, followed by a count of the rest (default: 0, show all)")
+	cmd.Flags().BoolVar(&flags.failIfEmpty, "fail-if-empty", false,
+		fmt.Sprintf("Exit with code %d if no environment matches (the empty result is still printed)", model.ExitEnvNotFound))
+	cmd.Flags().BoolVar(&flags.noDocker, "no-docker", false,
+		"List the current repository's worktrees from Git only, without container state")
+	cmd.Flags().BoolVar(&flags.noSummary, "no-summary", false,
+		"Omit the summary of environments by status and allocated ports")
+	cmd.Flags().StringVar(&flags.olderThan, "older-than", "",
+		"Only show environments created longer ago than this (e.g., 7d, 12h, 30m)")
+	cmd.Flags().StringVar(&flags.newerThan, "newer-than", "",
+		"Only show environments created more recently than this (e.g., 1h)")
 
 	return cmd
 }
@@ -79,9 +148,22 @@ func runList(ctx context.Context, flags *listFlags) error {
 	if statusFilter != "all" {
 		if _, err := model.ParseWorktreeStatus(statusFilter); err != nil {
 			return model.WrapCLIError(model.ExitGeneralError,
-				fmt.Sprintf("invalid status filter %q: valid values are running, stopped, orphaned, no-container, all", statusFilter), nil)
+				fmt.Sprintf("invalid status filter %q: valid values are running, starting, stopped, orphaned, no-container, unhealthy, unknown, all", statusFilter), nil)
 		}
 	}
+	if flags.groupBy != "" && flags.groupBy != listGroupByRepo {
+		return model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("invalid --group-by value %q: valid value is %q", flags.groupBy, listGroupByRepo))
+	}
+	if flags.limit < 0 {
+		return model.NewCLIError(model.ExitGeneralError, "--limit must not be negative")
+	}
+	if flags.noDocker && flags.detailed {
+		return model.NewCLIError(model.ExitGeneralError, "--detailed cannot be used with --no-docker")
+	}
+	if _, err := parseAgeFilter(flags); err != nil {
+		return err
+	}
 
 	// Step 2: Discover environments from marker files (local filesystem).
 	// Get the repository root so we can enumerate all worktrees.
@@ -96,6 +178,15 @@ func runList(ctx context.Context, flags *listFlags) error {
 		return model.WrapCLIError(model.ExitGitError, "not inside a Git repository", err)
 	}
 
+	// With --no-docker, Git is the only source (see listGitWorktrees).
+	if flags.noDocker {
+		envs, err := listGitWorktrees(wm, repoRoot)
+		if err != nil {
+			return model.WrapCLIError(model.ExitGitError, "failed to list worktrees", err)
+		}
+		return printFilteredList(wm, flags, envs)
+	}
+
 	// Scan all worktree paths for marker files.
 	// Build a map of envName → WorktreeEnv from marker data.
 	markerEnvs := make(map[string]*model.WorktreeEnv)
@@ -156,6 +247,7 @@ func runList(ctx context.Context, flags *listFlags) error {
 				Status:         status,
 				ConfigPattern:  configPattern,
 				CreatedAt:      createdAt,
+				Index:          marker.WorktreeIndex(),
 			}
 			markerEnvs[marker.Name] = env
 		}
@@ -189,6 +281,9 @@ func runList(ctx context.Context, flags *listFlags) error {
 				}
 				dockerEnvs[envName] = env
 			}
+			if checkListHealth(flags) {
+				applyListHealth(ctx, cli, dockerEnvs)
+			}
 		}
 	}
 
@@ -213,6 +308,13 @@ func runList(ctx context.Context, flags *listFlags) error {
 		envs = append(envs, env)
 	}
 
+	return printFilteredList(wm, flags, envs)
+}
+
+// printFilteredList sorts envs by name, applies --status and --limit, and
+// prints the result (Steps 5-7 of runList).
+func printFilteredList(wm *worktree.Manager, flags *listFlags, envs []*model.WorktreeEnv) error {
+	statusFilter := flags.status
 	sort.Slice(envs, func(i, j int) bool {
 		return envs[i].Name < envs[j].Name
 	})
@@ -228,87 +330,548 @@ func runList(ctx context.Context, flags *listFlags) error {
 		envs = filteredEnvs
 	}
 
-	// Step 7: Output results in the appropriate format.
-	printListResult(envs)
-	return nil
+	// Step 6.1: Apply --older-than and --newer-than (validated by runList).
+	age, err := parseAgeFilter(flags)
+	if err != nil {
+		return err
+	}
+	envs = age.filter(envs, time.Now())
+
+	// Step 6.2: Apply --limit after sorting and filtering, remembering how
+	// many environments matched so the output can report the rest. The
+	// summary also covers all of them.
+	total := len(envs)
+	var summary *listSummary
+	if !flags.noSummary {
+		summary = summarizeEnvs(envs)
+	}
+	envs = limitEnvs(envs, flags.limit)
+
+	// Step 6.5: Look up remote URLs for structured output. The text table
+	// has no column for them, so the git calls are skipped there.
+	if IsJSONOutput() || IsYAMLOutput() {
+		fillRemoteURLs(wm, envs)
+	}
+
+	// Step 7: Output results in the appropriate format. Without Docker,
+	// the text table shows paths instead of the container columns.
+	switch {
+	case flags.groupBy == listGroupByRepo:
+		printListResultByRepo(groupEnvsByRepo(envs), total, summary)
+	case flags.noDocker && !IsJSONOutput() && !IsYAMLOutput():
+		printGitWorktreesText(envs)
+		printListRemainder(len(envs), total)
+		printListSummaryText(summary)
+	default:
+		printListResult(envs, total, summary)
+	}
+	return checkListNotEmpty(flags, total)
 }
 
-// printListResult outputs the list of environments in text or JSON format,
-// depending on the global --json flag.
-func printListResult(envs []*model.WorktreeEnv) {
-	if IsJSONOutput() {
-		printListResultJSON(envs)
-	} else {
+// listGitWorktrees returns an environment for each linked worktree of the
+// repository at repoRoot, for list --no-docker. A worktree with a loam
+// marker is named and described by it; any other worktree is named after
+// its directory. Container state is not looked up, so the status is
+// StatusUnknown and the index is unknown.
+func listGitWorktrees(wm *worktree.Manager, repoRoot string) ([]*model.WorktreeEnv, error) {
+	worktrees, err := wm.ListWorktrees(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]*model.WorktreeEnv, 0, len(worktrees))
+	for _, wt := range worktrees {
+		env := &model.WorktreeEnv{
+			Name:           filepath.Base(wt.Path),
+			Branch:         strings.TrimPrefix(wt.Branch, "refs/heads/"),
+			WorktreePath:   wt.Path,
+			SourceRepoPath: repoRoot,
+			Status:         model.StatusUnknown,
+			ConfigPattern:  model.PatternNone,
+			Index:          model.UnknownWorktreeIndex,
+		}
+		marker, readErr := worktree.ReadMarkerFile(wt.Path)
+		if readErr != nil {
+			VerboseLog("Warning: could not read marker at %s: %v", wt.Path, readErr)
+		}
+		if marker != nil && marker.ManagedBy == "loam" && marker.Name != "" {
+			env.Name = marker.Name
+			env.ConfigPattern = marker.ConfigPattern
+			if createdAt, parseErr := time.Parse(time.RFC3339, marker.CreatedAt); parseErr == nil {
+				env.CreatedAt = createdAt
+			}
+			if marker.SourceRepoPath != "" {
+				env.SourceRepoPath = marker.SourceRepoPath
+			}
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+// printGitWorktreesText prints the list --no-docker table, which has the
+// worktree path in place of the container columns.
+func printGitWorktreesText(envs []*model.WorktreeEnv) {
+	if len(envs) == 0 {
+		fmt.Println("No worktrees found.")
+		return
+	}
+
+	fmt.Printf("%-20s %-20s %-10s %s\n", "NAME", "BRANCH", "STATUS", "PATH")
+	for _, env := range envs {
+		fmt.Printf("%-20s %-20s %-10s %s\n", env.Name, formatBranch(env.Branch), env.Status.String(), env.WorktreePath)
+	}
+}
+
+// ageFilter is the parsed form of --older-than and --newer-than. A zero
+// duration means no limit on that side.
+type ageFilter struct {
+	olderThan time.Duration
+	newerThan time.Duration
+}
+
+// parseAgeFilter parses the age flags of list.
+func parseAgeFilter(flags *listFlags) (ageFilter, error) {
+	var f ageFilter
+	for _, opt := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"--older-than", flags.olderThan, &f.olderThan},
+		{"--newer-than", flags.newerThan, &f.newerThan},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		d, err := parseAge(opt.value)
+		if err != nil {
+			return ageFilter{}, model.WrapCLIError(model.ExitGeneralError, "invalid "+opt.name, err)
+		}
+		*opt.dst = d
+	}
+	return f, nil
+}
+
+// filter returns the environments of envs whose age at now is within the
+// limits. An environment with an unknown creation time (e.g., from a
+// marker file with a malformed timestamp) cannot be placed and is dropped
+// whenever a limit is set.
+func (f ageFilter) filter(envs []*model.WorktreeEnv, now time.Time) []*model.WorktreeEnv {
+	if f.olderThan == 0 && f.newerThan == 0 {
+		return envs
+	}
+	kept := make([]*model.WorktreeEnv, 0, len(envs))
+	for _, env := range envs {
+		if env.CreatedAt.IsZero() {
+			continue
+		}
+		age := now.Sub(env.CreatedAt)
+		if f.olderThan != 0 && age <= f.olderThan {
+			continue
+		}
+		if f.newerThan != 0 && age >= f.newerThan {
+			continue
+		}
+		kept = append(kept, env)
+	}
+	return kept
+}
+
+// ageDaysPattern matches a number of days in a duration, such as the "7d"
+// in "7d" or the "1.5d" in "1.5d6h".
+var ageDaysPattern = regexp.MustCompile(`(\d+(?:\.\d*)?|\.\d+)d`)
+
+// parseAge parses a positive duration like time.ParseDuration, adding the
+// unit "d" for days of 24 hours, which age limits are usually given in
+// (e.g., "7d", "1d12h", "30m"). No unit of time.ParseDuration contains a
+// "d", so days are converted to hours before the string is handed to it.
+func parseAge(s string) (time.Duration, error) {
+	var convErr error
+	hours := ageDaysPattern.ReplaceAllStringFunc(s, func(days string) string {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(days, "d"), 64)
+		if err != nil {
+			convErr = err
+		}
+		return strconv.FormatFloat(n*24, 'f', -1, 64) + "h"
+	})
+	if convErr != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, convErr)
+	}
+	d, err := time.ParseDuration(hours)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (use a number with a unit: d, h, m, s)", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", s)
+	}
+	return d, nil
+}
+
+// checkListHealth reports whether list inspects container health: with
+// --detailed, and for --status unhealthy, which could match nothing
+// otherwise.
+func checkListHealth(flags *listFlags) bool {
+	return flags.detailed || flags.status == model.StatusUnhealthy.String()
+}
+
+// applyListHealth marks running environments whose containers fail their
+// healthcheck as unhealthy (see docker.ApplyHealth). An environment that
+// cannot be inspected keeps its status.
+func applyListHealth(ctx context.Context, cli *docker.Client, envs map[string]*model.WorktreeEnv) {
+	for name, env := range envs {
+		if err := docker.ApplyHealth(ctx, cli, env); err != nil {
+			VerboseLog("Warning: could not check health of environment %q: %v", name, err)
+		}
+	}
+}
+
+// checkListNotEmpty implements --fail-if-empty: it returns an
+// ExitEnvNotFound error when total, the number of environments matching
+// the filters, is zero. The result has already been printed, so JSON and
+// YAML consumers still get a valid (empty) document on stdout.
+func checkListNotEmpty(flags *listFlags, total int) error {
+	if !flags.failIfEmpty || total > 0 {
+		return nil
+	}
+	if flags.status != "" && flags.status != "all" {
+		return model.NewCLIError(model.ExitEnvNotFound,
+			fmt.Sprintf("no environments with status %q found", flags.status))
+	}
+	return model.NewCLIError(model.ExitEnvNotFound, "no environments found")
+}
+
+// limitEnvs returns the first limit environments, or all of them when
+// limit is 0 or not smaller than the number of environments.
+func limitEnvs(envs []*model.WorktreeEnv, limit int) []*model.WorktreeEnv {
+	if limit <= 0 || limit >= len(envs) {
+		return envs
+	}
+	return envs[:limit]
+}
+
+// repoEnvGroup is a set of environments that share a source repository.
+type repoEnvGroup struct {
+	SourceRepo string
+	Envs       []*model.WorktreeEnv
+}
+
+// groupEnvsByRepo buckets environments by SourceRepoPath.
+// Groups are sorted by repository path; within a group, environments keep
+// their input order (already sorted by name in runList).
+func groupEnvsByRepo(envs []*model.WorktreeEnv) []repoEnvGroup {
+	index := make(map[string]int)
+	var groups []repoEnvGroup
+
+	for _, env := range envs {
+		i, ok := index[env.SourceRepoPath]
+		if !ok {
+			i = len(groups)
+			index[env.SourceRepoPath] = i
+			groups = append(groups, repoEnvGroup{SourceRepo: env.SourceRepoPath})
+		}
+		groups[i].Envs = append(groups[i].Envs, env)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].SourceRepo < groups[j].SourceRepo
+	})
+	return groups
+}
+
+// fillRemoteURLs sets RemoteURL on each environment from the origin remote
+// of its source repository. Environments usually share a handful of source
+// repositories, so each repository is queried only once. Lookup failures
+// (e.g., the repository was moved) are logged and leave the URL empty.
+func fillRemoteURLs(wm *worktree.Manager, envs []*model.WorktreeEnv) {
+	cache := make(map[string]string)
+	for _, env := range envs {
+		repoPath := env.SourceRepoPath
+		if repoPath == "" {
+			repoPath = env.WorktreePath
+		}
+		url, ok := cache[repoPath]
+		if !ok {
+			var err error
+			url, err = wm.GetRemoteURL(repoPath, worktree.DefaultRemote)
+			if err != nil {
+				VerboseLog("Warning: could not get remote URL for %s: %v", repoPath, err)
+			}
+			cache[repoPath] = url
+		}
+		env.RemoteURL = url
+	}
+}
+
+// printListResult outputs the list of environments in text, JSON, or YAML
+// format, depending on the global --output flag. total is the number of
+// matching environments before --limit; it exceeds len(envs) when the list
+// was truncated. summary is omitted when nil (--no-summary).
+func printListResult(envs []*model.WorktreeEnv, total int, summary *listSummary) {
+	switch {
+	case IsJSONOutput():
+		printListResultJSON(envs, total, summary)
+	case IsYAMLOutput():
+		printYAML(buildListResult(envs, total, summary))
+	default:
 		printListResultText(envs)
+		printListRemainder(len(envs), total)
+		printListSummaryText(summary)
+	}
+}
+
+// listSummary counts the environments matching a list by status, along
+// with the host ports allocated to them. The yaml tags reuse the JSON keys.
+type listSummary struct {
+	Running        int `json:"running" yaml:"running"`
+	Starting       int `json:"starting" yaml:"starting"`
+	Unhealthy      int `json:"unhealthy" yaml:"unhealthy"`
+	Stopped        int `json:"stopped" yaml:"stopped"`
+	Orphaned       int `json:"orphaned" yaml:"orphaned"`
+	NoContainer    int `json:"noContainer" yaml:"noContainer"`
+	Unknown        int `json:"unknown" yaml:"unknown"`
+	Total          int `json:"total" yaml:"total"`
+	PortsAllocated int `json:"portsAllocated" yaml:"portsAllocated"`
+}
+
+// summarizeEnvs computes the summary of envs.
+func summarizeEnvs(envs []*model.WorktreeEnv) *listSummary {
+	summary := &listSummary{Total: len(envs)}
+	for _, env := range envs {
+		switch env.Status {
+		case model.StatusRunning:
+			summary.Running++
+		case model.StatusStarting:
+			summary.Starting++
+		case model.StatusUnhealthy:
+			summary.Unhealthy++
+		case model.StatusStopped:
+			summary.Stopped++
+		case model.StatusOrphaned:
+			summary.Orphaned++
+		case model.StatusNoContainer:
+			summary.NoContainer++
+		case model.StatusUnknown:
+			summary.Unknown++
+		}
+		summary.PortsAllocated += len(env.PortAllocations)
+	}
+	return summary
+}
+
+// printListSummaryText prints the footer line of the text output, e.g.
+// "3 running, 1 stopped, 4 total, 9 ports allocated". Statuses without
+// environments are left out. Nothing is printed for a nil summary or an
+// empty list.
+func printListSummaryText(summary *listSummary) {
+	if summary == nil || summary.Total == 0 {
+		return
 	}
+
+	var parts []string
+	for _, c := range []struct {
+		count  int
+		status model.WorktreeStatus
+	}{
+		{summary.Running, model.StatusRunning},
+		{summary.Starting, model.StatusStarting},
+		{summary.Unhealthy, model.StatusUnhealthy},
+		{summary.Stopped, model.StatusStopped},
+		{summary.Orphaned, model.StatusOrphaned},
+		{summary.NoContainer, model.StatusNoContainer},
+		{summary.Unknown, model.StatusUnknown},
+	} {
+		if c.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.count, c.status))
+		}
+	}
+	ports := "ports"
+	if summary.PortsAllocated == 1 {
+		ports = "port"
+	}
+	parts = append(parts, fmt.Sprintf("%d total", summary.Total), fmt.Sprintf("%d %s allocated", summary.PortsAllocated, ports))
+	fmt.Printf("\n%s\n", strings.Join(parts, ", "))
 }
 
 // listEnvJSON is the JSON output structure for a single environment
 // in the list command. It mirrors the CLI contracts specification.
+// The yaml tags reuse the JSON keys so --output yaml has the same schema.
 type listEnvJSON struct {
-	Name          string            `json:"name"`
-	Branch        string            `json:"branch"`
-	Status        string            `json:"status"`
-	WorktreePath  string            `json:"worktreePath"`
-	ConfigPattern string            `json:"configPattern"`
-	Services      []listServiceJSON `json:"services"`
+	Name          string        `json:"name" yaml:"name"`
+	Branch        string        `json:"branch" yaml:"branch"`
+	Status        string        `json:"status" yaml:"status"`
+	WorktreePath  string        `json:"worktreePath" yaml:"worktreePath"`
+	ConfigPattern string        `json:"configPattern" yaml:"configPattern"`
+	Index         *int          `json:"index,omitempty" yaml:"index,omitempty"` // nil when unknown (marker-only)
+	RemoteURL     string        `json:"remoteUrl,omitempty" yaml:"remoteUrl,omitempty"`
+	Services      []serviceJSON `json:"services" yaml:"services"`
+
+	// UnboundPorts are the allocated host ports that Docker did not
+	// publish although the environment is running (see docker.UnboundPorts).
+	UnboundPorts []int `json:"unboundPorts,omitempty" yaml:"unboundPorts,omitempty"`
 }
 
-// listServiceJSON is the JSON output structure for a service within
-// an environment in the list command.
-type listServiceJSON struct {
-	Name          string `json:"name"`
-	ContainerPort int    `json:"containerPort"`
-	HostPort      int    `json:"hostPort"`
+// listResultJSON is the top-level structure of the flat list output.
+// The top-level key is "environments" containing an array of environment
+// objects; "total" counts all matching environments, including those cut
+// off by --limit, and "summary" breaks them down.
+type listResultJSON struct {
+	Environments []listEnvJSON `json:"environments" yaml:"environments"`
+	Total        int           `json:"total" yaml:"total"`
+	Summary      *listSummary  `json:"summary,omitempty" yaml:"summary,omitempty"`
 }
 
-// printListResultJSON outputs the environment list as structured JSON.
-// The top-level key is "environments" containing an array of environment objects.
-func printListResultJSON(envs []*model.WorktreeEnv) {
-	type resultJSON struct {
-		Environments []listEnvJSON `json:"environments"`
-	}
-
-	result := resultJSON{
+// buildListResult converts environments into the flat list output structure,
+// shared by the JSON and YAML renderers.
+func buildListResult(envs []*model.WorktreeEnv, total int, summary *listSummary) listResultJSON {
+	result := listResultJSON{
 		// Use an empty slice instead of nil to ensure JSON output shows []
 		// instead of null when no environments are found.
 		Environments: make([]listEnvJSON, 0, len(envs)),
+		Total:        total,
+		Summary:      summary,
 	}
 
 	for _, env := range envs {
-		entry := listEnvJSON{
-			Name:          env.Name,
-			Branch:        env.Branch,
-			Status:        env.Status.String(),
-			WorktreePath:  env.WorktreePath,
-			ConfigPattern: env.ConfigPattern.String(),
-			Services:      make([]listServiceJSON, 0, len(env.PortAllocations)),
-		}
+		result.Environments = append(result.Environments, buildListEnvJSON(env))
+	}
+	return result
+}
 
-		for _, pa := range env.PortAllocations {
-			entry.Services = append(entry.Services, listServiceJSON{
-				Name:          pa.ServiceName,
-				ContainerPort: pa.ContainerPort,
-				HostPort:      pa.HostPort,
-			})
+// printListResultJSON outputs the environment list as structured JSON.
+func printListResultJSON(envs []*model.WorktreeEnv, total int, summary *listSummary) {
+	// MarshalIndent produces human-readable JSON with 2-space indentation.
+	data, _ := json.MarshalIndent(buildListResult(envs, total, summary), "", "  ")
+	fmt.Println(string(data))
+}
+
+// buildListEnvJSON converts a WorktreeEnv into its list JSON representation.
+// It is shared by the flat and grouped JSON outputs.
+func buildListEnvJSON(env *model.WorktreeEnv) listEnvJSON {
+	entry := listEnvJSON{
+		Name:          env.Name,
+		Branch:        env.Branch,
+		Status:        env.Status.String(),
+		WorktreePath:  env.WorktreePath,
+		ConfigPattern: env.ConfigPattern.String(),
+		RemoteURL:     env.RemoteURL,
+		Services:      buildServicesJSON(env.PortAllocations),
+	}
+	for _, pa := range docker.UnboundPorts(env) {
+		entry.UnboundPorts = append(entry.UnboundPorts, pa.HostPort)
+	}
+	if env.Index != model.UnknownWorktreeIndex {
+		index := env.Index
+		entry.Index = &index
+	}
+
+	return entry
+}
+
+// printListResultByRepo outputs environments grouped by source repository
+// in text, JSON, or YAML format, depending on the global --output flag.
+// total and summary have the same meaning as in printListResult.
+func printListResultByRepo(groups []repoEnvGroup, total int, summary *listSummary) {
+	switch {
+	case IsJSONOutput():
+		printListResultByRepoJSON(groups, total, summary)
+	case IsYAMLOutput():
+		printYAML(buildListResultByRepo(groups, total, summary))
+	default:
+		printListResultByRepoText(groups)
+		shown := 0
+		for _, g := range groups {
+			shown += len(g.Envs)
 		}
+		printListRemainder(shown, total)
+		printListSummaryText(summary)
+	}
+}
 
-		result.Environments = append(result.Environments, entry)
+// printListRemainder prints the "... and M more" line that follows a text
+// listing truncated by --limit. Nothing is printed when all were shown.
+func printListRemainder(shown, total int) {
+	if total > shown {
+		fmt.Printf("... and %d more\n", total-shown)
 	}
+}
 
-	// MarshalIndent produces human-readable JSON with 2-space indentation.
-	data, _ := json.MarshalIndent(result, "", "  ")
+// listRepoJSON holds one source repository and the environments created from it.
+type listRepoJSON struct {
+	SourceRepo   string        `json:"sourceRepo" yaml:"sourceRepo"`
+	Environments []listEnvJSON `json:"environments" yaml:"environments"`
+}
+
+// listByRepoResultJSON is the top-level structure of the grouped output.
+// The top-level key is "repos", each entry holding the source repository
+// path and the environments created from it.
+type listByRepoResultJSON struct {
+	Repos   []listRepoJSON `json:"repos" yaml:"repos"`
+	Total   int            `json:"total" yaml:"total"`
+	Summary *listSummary   `json:"summary,omitempty" yaml:"summary,omitempty"`
+}
+
+// buildListResultByRepo converts grouped environments into the grouped
+// output structure, shared by the JSON and YAML renderers.
+func buildListResultByRepo(groups []repoEnvGroup, total int, summary *listSummary) listByRepoResultJSON {
+	result := listByRepoResultJSON{
+		Repos:   make([]listRepoJSON, 0, len(groups)),
+		Total:   total,
+		Summary: summary,
+	}
+
+	for _, g := range groups {
+		repo := listRepoJSON{
+			SourceRepo:   g.SourceRepo,
+			Environments: make([]listEnvJSON, 0, len(g.Envs)),
+		}
+		for _, env := range g.Envs {
+			repo.Environments = append(repo.Environments, buildListEnvJSON(env))
+		}
+		result.Repos = append(result.Repos, repo)
+	}
+	return result
+}
+
+// printListResultByRepoJSON outputs grouped environments as structured JSON.
+func printListResultByRepoJSON(groups []repoEnvGroup, total int, summary *listSummary) {
+	data, _ := json.MarshalIndent(buildListResultByRepo(groups, total, summary), "", "  ")
 	fmt.Println(string(data))
 }
 
+// printListResultByRepoText outputs one table per source repository, each
+// preceded by the repository path as a section header.
+func printListResultByRepoText(groups []repoEnvGroup) {
+	if len(groups) == 0 {
+		fmt.Println("No worktree environments found.")
+		return
+	}
+
+	for i, g := range groups {
+		// A blank line separates consecutive sections.
+		if i > 0 {
+			fmt.Println()
+		}
+		header := g.SourceRepo
+		if header == "" {
+			header = "(unknown repository)"
+		}
+		fmt.Printf("%s\n", header)
+		printListResultText(g.Envs)
+	}
+}
+
 // printListResultText outputs the environment list as a human-readable
 // text table with aligned columns.
 //
 // The table format is:
 //
-//	NAME           BRANCH          STATUS    SERVICES  PORTS
-//	feature-auth   feature/auth    running   3         13000,15432,16379
-//	bugfix-login   bugfix/login    stopped   1         -
+//	NAME           BRANCH          STATUS    INDEX  SERVICES  PORTS
+//	feature-auth   feature/auth    running   1      3         13000,15432!,16379
+//	bugfix-login   bugfix/login    stopped   -      1         -
+//
+// A port marked with "!" is allocated but not published by Docker (see
+// docker.UnboundPorts); a legend follows the table when there is one.
 func printListResultText(envs []*model.WorktreeEnv) {
 	if len(envs) == 0 {
 		fmt.Println("No worktree environments found.")
@@ -316,22 +879,87 @@ func printListResultText(envs []*model.WorktreeEnv) {
 	}
 
 	// Print header row.
-	fmt.Printf("%-20s %-20s %-10s %-10s %s\n",
-		"NAME", "BRANCH", "STATUS", "SERVICES", "PORTS")
+	fmt.Printf("%-20s %-20s %-10s %-6s %-10s %s\n",
+		"NAME", "BRANCH", "STATUS", "INDEX", "SERVICES", "PORTS")
 
+	anyUnbound := false
 	for _, env := range envs {
-		serviceCount := len(env.PortAllocations)
-		portsStr := FormatPortsList(env.PortAllocations)
+		serviceCount := countServices(env.PortAllocations)
+		unbound := docker.UnboundPorts(env)
+		portsStr := formatPortsWithUnbound(env.PortAllocations, unbound)
+		if len(unbound) > 0 {
+			anyUnbound = true
+		}
 
 		// Print one row per environment with fixed-width columns.
-		fmt.Printf("%-20s %-20s %-10s %-10d %s\n",
+		fmt.Printf("%-20s %-20s %-10s %-6s %-10d %s\n",
 			env.Name,
-			env.Branch,
+			formatBranch(env.Branch),
 			env.Status.String(),
+			formatWorktreeIndex(env.Index),
 			serviceCount,
 			portsStr,
 		)
 	}
+	if anyUnbound {
+		fmt.Println("\n! allocated, but not published by Docker (the container may have failed to bind it)")
+	}
+}
+
+// formatBranch renders a branch name for text output, using "(detached)"
+// for environments created with `create --detach`, which have no branch.
+func formatBranch(branch string) string {
+	if branch == "" {
+		return "(detached)"
+	}
+	return branch
+}
+
+// formatWorktreeIndex renders a worktree index for table output,
+// using "-" when the index is unknown.
+func formatWorktreeIndex(index int) string {
+	if index == model.UnknownWorktreeIndex {
+		return "-"
+	}
+	return strconv.Itoa(index)
+}
+
+// countServices returns the number of services that own the allocated
+// ports. Ports whose service is not known (environments created before the
+// loam.port-service labels) are counted one each, as they used to be.
+func countServices(allocations []model.PortAllocation) int {
+	seen := make(map[string]bool)
+	count := 0
+	for _, pa := range allocations {
+		if pa.ServiceName == "" {
+			count++
+			continue
+		}
+		if !seen[pa.ServiceName] {
+			seen[pa.ServiceName] = true
+			count++
+		}
+	}
+	return count
+}
+
+// formatPortsWithUnbound is FormatPortsList with "!" appended to the host
+// ports of the unbound allocations.
+func formatPortsWithUnbound(allocations, unbound []model.PortAllocation) string {
+	if len(unbound) == 0 {
+		return FormatPortsList(allocations)
+	}
+	marked := make(map[int]bool, len(unbound))
+	for _, pa := range unbound {
+		marked[pa.HostPort] = true
+	}
+	ports := strings.Split(FormatPortsList(allocations), ",")
+	for i, p := range ports {
+		if n, err := strconv.Atoi(p); err == nil && marked[n] {
+			ports[i] = p + "!"
+		}
+	}
+	return strings.Join(ports, ",")
 }
 
 // FormatPortsList converts a slice of PortAllocations into a comma-separated
@@ -6,13 +6,420 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/docker"
 	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/port"
+	"github.com/mmr-tortoise/loam/internal/worktree"
 )
 
+// captureStdout runs fn and returns everything it wrote to os.Stdout.
+// The print* helpers write directly to stdout, so tests swap os.Stdout for
+// the write end of a pipe while fn runs. Tests using this helper must not
+// call t.Parallel, since os.Stdout is process-wide.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	orig := os.Stdout
+	os.Stdout = w
+	// defer restores stdout even if fn panics.
+	defer func() { os.Stdout = orig }()
+
+	fn()
+	require.NoError(t, w.Close())
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+// setJSONOutput selects JSON (or text) output for the duration of a test.
+func setJSONOutput(t *testing.T, enabled bool) {
+	t.Helper()
+	format := outputText
+	if enabled {
+		format = outputJSON
+	}
+	setOutputFormat(t, format)
+}
+
+// setOutputFormat sets the global --output format for the duration of a test.
+func setOutputFormat(t *testing.T, format string) {
+	t.Helper()
+	orig := outputFormat
+	outputFormat = format
+	t.Cleanup(func() { outputFormat = orig })
+}
+
+// groupedTestEnvs returns environments from two repositories, sorted by name
+// as runList would produce them.
+func groupedTestEnvs() []*model.WorktreeEnv {
+	return []*model.WorktreeEnv{
+		{Name: "api-auth", Branch: "auth", SourceRepoPath: "/src/zeta", Status: model.StatusRunning},
+		{Name: "web-login", Branch: "login", SourceRepoPath: "/src/alpha", Status: model.StatusStopped},
+		{Name: "web-nav", Branch: "nav", SourceRepoPath: "/src/alpha", Status: model.StatusRunning},
+	}
+}
+
+// TestGroupEnvsByRepo verifies bucketing by source repository, with groups
+// sorted by path and environments keeping their input order.
+func TestGroupEnvsByRepo(t *testing.T) {
+	groups := groupEnvsByRepo(groupedTestEnvs())
+
+	require.Len(t, groups, 2)
+	assert.Equal(t, "/src/alpha", groups[0].SourceRepo)
+	require.Len(t, groups[0].Envs, 2)
+	assert.Equal(t, "web-login", groups[0].Envs[0].Name)
+	assert.Equal(t, "web-nav", groups[0].Envs[1].Name)
+	assert.Equal(t, "/src/zeta", groups[1].SourceRepo)
+	assert.Len(t, groups[1].Envs, 1)
+
+	assert.Empty(t, groupEnvsByRepo(nil))
+}
+
+// TestPrintListResultByRepoJSON verifies the nested "repos" JSON shape.
+func TestPrintListResultByRepoJSON(t *testing.T) {
+	setJSONOutput(t, true)
+
+	out := captureStdout(t, func() {
+		printListResultByRepo(groupEnvsByRepo(groupedTestEnvs()), 3, nil)
+	})
+
+	var result struct {
+		Repos []struct {
+			SourceRepo   string        `json:"sourceRepo"`
+			Environments []listEnvJSON `json:"environments"`
+		} `json:"repos"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	require.Len(t, result.Repos, 2)
+	assert.Equal(t, "/src/alpha", result.Repos[0].SourceRepo)
+	assert.Len(t, result.Repos[0].Environments, 2)
+	assert.Equal(t, "api-auth", result.Repos[1].Environments[0].Name)
+	assert.Equal(t, "running", result.Repos[1].Environments[0].Status)
+
+	// An empty result still emits an array, not null.
+	out = captureStdout(t, func() { printListResultByRepo(nil, 0, nil) })
+	assert.Contains(t, out, `"repos": []`)
+	assert.Contains(t, out, `"total": 0`)
+}
+
+// TestPrintListResult_YAML verifies the YAML rendering of a representative
+// environment: same keys as the JSON output, 2-space indentation.
+func TestPrintListResult_YAML(t *testing.T) {
+	setOutputFormat(t, outputYAML)
+
+	envs := []*model.WorktreeEnv{{
+		Name:          "feature-auth",
+		Branch:        "feature/auth",
+		Status:        model.StatusRunning,
+		WorktreePath:  "/src/myproject-feature-auth",
+		ConfigPattern: model.PatternComposeSingle,
+		Index:         1,
+		PortAllocations: []model.PortAllocation{
+			{ServiceName: "app", ContainerPort: 3000, HostPort: 13000},
+		},
+	}}
+
+	out := captureStdout(t, func() { printListResult(envs, 1, nil) })
+
+	want := `environments:
+  - name: feature-auth
+    branch: feature/auth
+    status: running
+    worktreePath: /src/myproject-feature-auth
+    configPattern: compose-single
+    index: 1
+    services:
+      - name: app
+        containerPort: 3000
+        hostPort: 13000
+        protocol: tcp
+total: 1
+`
+	assert.Equal(t, want, out)
+
+	// An empty result still emits an empty list, not null.
+	out = captureStdout(t, func() { printListResult(nil, 0, nil) })
+	assert.Equal(t, "environments: []\ntotal: 0\n", out)
+}
+
+// TestPrintListResultByRepo_YAML verifies the grouped YAML shape.
+func TestPrintListResultByRepo_YAML(t *testing.T) {
+	setOutputFormat(t, outputYAML)
+
+	out := captureStdout(t, func() {
+		printListResultByRepo(groupEnvsByRepo(groupedTestEnvs()), 3, nil)
+	})
+
+	assert.True(t, strings.HasPrefix(out, "repos:\n  - sourceRepo: /src/alpha\n    environments:\n"), out)
+	assert.Contains(t, out, "  - sourceRepo: /src/zeta\n")
+}
+
+// TestPrintListResultByRepoText verifies that each repository gets a header
+// followed by its own table.
+func TestPrintListResultByRepoText(t *testing.T) {
+	setJSONOutput(t, false)
+
+	out := captureStdout(t, func() {
+		printListResultByRepo(groupEnvsByRepo(groupedTestEnvs()), 3, nil)
+	})
+
+	alpha := strings.Index(out, "/src/alpha\n")
+	zeta := strings.Index(out, "/src/zeta\n")
+	webNav := strings.Index(out, "web-nav")
+	apiAuth := strings.Index(out, "api-auth")
+
+	require.True(t, alpha >= 0 && zeta >= 0, "both repository headers should be printed:\n%s", out)
+	assert.Less(t, alpha, webNav, "alpha environments follow the alpha header")
+	assert.Less(t, webNav, zeta, "zeta header follows the alpha section")
+	assert.Less(t, zeta, apiAuth, "zeta environments follow the zeta header")
+}
+
+// TestLimitEnvs verifies that --limit keeps the first N environments and
+// that 0 or a limit past the end keeps them all.
+func TestLimitEnvs(t *testing.T) {
+	t.Parallel()
+
+	envs := groupedTestEnvs()
+	assert.Len(t, limitEnvs(envs, 0), 3)
+	assert.Len(t, limitEnvs(envs, 5), 3)
+
+	limited := limitEnvs(envs, 2)
+	require.Len(t, limited, 2)
+	assert.Equal(t, "api-auth", limited[0].Name)
+	assert.Equal(t, "web-login", limited[1].Name)
+}
+
+// TestPrintListResult_Limit verifies the "... and M more" line in text
+// output and the total count in JSON output for a truncated list.
+func TestPrintListResult_Limit(t *testing.T) {
+	envs := limitEnvs(groupedTestEnvs(), 2)
+
+	setJSONOutput(t, false)
+	out := captureStdout(t, func() { printListResult(envs, 3, nil) })
+	assert.NotContains(t, out, "web-nav")
+	assert.True(t, strings.HasSuffix(out, "... and 1 more\n"), out)
+
+	out = captureStdout(t, func() { printListResultByRepo(groupEnvsByRepo(envs), 3, nil) })
+	assert.True(t, strings.HasSuffix(out, "... and 1 more\n"), out)
+
+	// Nothing is appended when the list is complete.
+	out = captureStdout(t, func() { printListResult(envs, 2, nil) })
+	assert.NotContains(t, out, "more")
+
+	setJSONOutput(t, true)
+	out = captureStdout(t, func() { printListResult(envs, 3, nil) })
+	var result listResultJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Len(t, result.Environments, 2)
+	assert.Equal(t, 3, result.Total)
+}
+
+// summaryTestEnvs returns environments in every status, with 3 ports
+// allocated in total.
+func summaryTestEnvs() []*model.WorktreeEnv {
+	ports := func(n int) []model.PortAllocation { return make([]model.PortAllocation, n) }
+	return []*model.WorktreeEnv{
+		{Name: "a", Status: model.StatusRunning, PortAllocations: ports(2)},
+		{Name: "b", Status: model.StatusRunning},
+		{Name: "c", Status: model.StatusStopped, PortAllocations: ports(1)},
+		{Name: "d", Status: model.StatusOrphaned},
+		{Name: "e", Status: model.StatusOrphaned},
+		{Name: "f", Status: model.StatusNoContainer},
+	}
+}
+
+// TestSummarizeEnvs verifies the counts by status and of allocated ports.
+func TestSummarizeEnvs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, &listSummary{
+		Running: 2, Stopped: 1, Orphaned: 2, NoContainer: 1, Total: 6, PortsAllocated: 3,
+	}, summarizeEnvs(summaryTestEnvs()))
+	assert.Equal(t, &listSummary{}, summarizeEnvs(nil))
+}
+
+// TestPrintListResult_Summary verifies the text footer, which leaves out
+// statuses without environments, and the summary object in JSON output,
+// and that a nil summary (--no-summary) prints neither.
+func TestPrintListResult_Summary(t *testing.T) {
+	envs := summaryTestEnvs()
+	summary := summarizeEnvs(envs)
+
+	setJSONOutput(t, false)
+	out := captureStdout(t, func() { printListResult(envs, len(envs), summary) })
+	assert.True(t, strings.HasSuffix(out, "\n2 running, 1 stopped, 2 orphaned, 1 no-container, 6 total, 3 ports allocated\n"), out)
+
+	out = captureStdout(t, func() { printListResult(envs, len(envs), nil) })
+	assert.NotContains(t, out, "total")
+
+	out = captureStdout(t, func() { printListResult(nil, 0, summarizeEnvs(nil)) })
+	assert.Equal(t, "No worktree environments found.\n", out)
+
+	setJSONOutput(t, true)
+	out = captureStdout(t, func() { printListResult(envs, len(envs), summary) })
+	var result struct {
+		Summary map[string]int `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, map[string]int{
+		"running": 2, "starting": 0, "unhealthy": 0, "stopped": 1, "orphaned": 2, "noContainer": 1,
+		"unknown": 0, "total": 6, "portsAllocated": 3,
+	}, result.Summary)
+
+	out = captureStdout(t, func() { printListResult(envs, len(envs), nil) })
+	assert.NotContains(t, out, "summary")
+}
+
+// TestCheckListNotEmpty verifies the --fail-if-empty exit code: an empty
+// (filtered) result fails with ExitEnvNotFound, anything else succeeds.
+func TestCheckListNotEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkListNotEmpty(&listFlags{status: "all"}, 0), "without the flag, empty is fine")
+	assert.NoError(t, checkListNotEmpty(&listFlags{status: "all", failIfEmpty: true}, 2))
+
+	var cliErr *model.CLIError
+	err := checkListNotEmpty(&listFlags{status: "all", failIfEmpty: true}, 0)
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitEnvNotFound, cliErr.Code)
+	assert.Equal(t, "no environments found", cliErr.Message)
+
+	err = checkListNotEmpty(&listFlags{status: "running", failIfEmpty: true}, 0)
+	require.ErrorAs(t, err, &cliErr)
+	assert.Equal(t, model.ExitEnvNotFound, cliErr.Code)
+	assert.Contains(t, cliErr.Message, `status "running"`)
+}
+
+// TestRunList_FailIfEmpty runs list with --fail-if-empty in a repository
+// with a marker-only environment, which must succeed and print it. The
+// empty case is covered by TestCheckListNotEmpty, since the environments
+// on a developer's Docker host would make it nondeterministic here. This
+// test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunList_FailIfEmpty(t *testing.T) {
+	setJSONOutput(t, true)
+
+	repoPath := setupTestRepo(t)
+	wm := worktree.NewManager()
+	worktreePath := filepath.Join(t.TempDir(), "wt-list")
+	require.NoError(t, wm.Add(repoPath, "feature-list", worktreePath, ""))
+	require.NoError(t, worktree.WriteMarkerFile(worktreePath, worktree.MarkerFile{
+		ManagedBy:      "loam",
+		Name:           "feature-list-unique-env",
+		Branch:         "feature-list",
+		SourceRepoPath: repoPath,
+		ConfigPattern:  model.PatternNone,
+		CreatedAt:      "2026-03-02T00:00:00Z",
+	}))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runList(context.Background(), &listFlags{status: "no-container", failIfEmpty: true}))
+	})
+	assert.Contains(t, out, "feature-list-unique-env")
+}
+
+// TestRunList_NoDocker verifies that --no-docker lists every linked
+// worktree from Git, named by its marker if it has one and by its
+// directory otherwise, with status unknown and its path. Docker is made
+// unreachable so that the test shows no daemon is needed. This test uses
+// os.Chdir and t.Setenv, so it must NOT use t.Parallel().
+func TestRunList_NoDocker(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	repoPath := setupTestRepo(t)
+	wm := worktree.NewManager()
+	managedPath := filepath.Join(t.TempDir(), "wt-managed")
+	require.NoError(t, wm.Add(repoPath, "feature/managed", managedPath, ""))
+	require.NoError(t, worktree.WriteMarkerFile(managedPath, worktree.MarkerFile{
+		ManagedBy:      "loam",
+		Name:           "feature-managed",
+		Branch:         "feature/managed",
+		SourceRepoPath: repoPath,
+		ConfigPattern:  model.PatternImage,
+		CreatedAt:      "2026-03-02T00:00:00Z",
+	}))
+	plainPath := filepath.Join(t.TempDir(), "wt-plain")
+	require.NoError(t, wm.Add(repoPath, "plain", plainPath, ""))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	setJSONOutput(t, false)
+	out := captureStdout(t, func() {
+		require.NoError(t, runList(context.Background(), &listFlags{status: "all", noDocker: true}))
+	})
+	assert.Contains(t, out, "PATH")
+	assert.Regexp(t, `feature-managed\s+feature/managed\s+unknown\s+`+regexp.QuoteMeta(managedPath), out)
+	assert.Regexp(t, `wt-plain\s+plain\s+unknown\s+`+regexp.QuoteMeta(plainPath), out)
+
+	setJSONOutput(t, true)
+	out = captureStdout(t, func() {
+		require.NoError(t, runList(context.Background(), &listFlags{status: "unknown", noDocker: true}))
+	})
+	var result listResultJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	require.Len(t, result.Environments, 2)
+	assert.Equal(t, "feature-managed", result.Environments[0].Name)
+	assert.Equal(t, "image", result.Environments[0].ConfigPattern)
+	assert.Equal(t, "unknown", result.Environments[1].Status)
+	assert.Nil(t, result.Environments[1].Index)
+
+	err = runList(context.Background(), &listFlags{status: "all", noDocker: true, detailed: true})
+	require.Error(t, err)
+}
+
+// TestFillRemoteURLs verifies that environments get their source repository's
+// origin URL, and that repositories without a remote leave it empty.
+func TestFillRemoteURLs(t *testing.T) {
+	t.Parallel()
+
+	withRemote := setupTestRepo(t)
+	runTestGit(t, withRemote, "remote", "add", "origin", "https://example.com/acme/app.git")
+	withoutRemote := setupTestRepo(t)
+
+	envs := []*model.WorktreeEnv{
+		{Name: "a", SourceRepoPath: withRemote},
+		{Name: "b", SourceRepoPath: withRemote},
+		{Name: "c", SourceRepoPath: withoutRemote},
+		{Name: "d", SourceRepoPath: t.TempDir()}, // not a repository: logged, left empty
+	}
+	fillRemoteURLs(worktree.NewManager(), envs)
+
+	assert.Equal(t, "https://example.com/acme/app.git", envs[0].RemoteURL)
+	assert.Equal(t, "https://example.com/acme/app.git", envs[1].RemoteURL)
+	assert.Empty(t, envs[2].RemoteURL)
+	assert.Empty(t, envs[3].RemoteURL)
+
+	entry := buildListEnvJSON(envs[0])
+	assert.Equal(t, "https://example.com/acme/app.git", entry.RemoteURL)
+}
+
 // TestFormatPortsList verifies that FormatPortsList correctly converts
 // a slice of PortAllocations into a comma-separated string of host ports.
 func TestFormatPortsList(t *testing.T) {
@@ -144,4 +551,212 @@ func TestSanitizeBranchName(t *testing.T) {
 			assert.Equal(t, tt.want, got)
 		})
 	}
+
+	t.Run("long branch names are truncated and stay distinct", func(t *testing.T) {
+		prefix := "feature/" + strings.Repeat("very-long-description-", 4)
+		a := sanitizeBranchName(prefix + "one")
+		b := sanitizeBranchName(prefix + "two")
+		assert.LessOrEqual(t, len(a), model.MaxNameLength)
+		assert.NotEqual(t, a, b)
+		assert.NoError(t, model.ValidateName(a))
+		assert.NoError(t, model.ValidateName(b))
+	})
+}
+
+// TestListForwardOnlyServices creates the labels of a Compose environment
+// whose ports are declared only in forwardPorts, some qualified with
+// another service, and verifies that the environment reconstructed from
+// them shows each port under its owning service and counts services, not
+// ports.
+func TestListForwardOnlyServices(t *testing.T) {
+	setJSONOutput(t, false)
+	raw := &devcontainer.RawDevContainer{
+		Service:      "app",
+		ForwardPorts: []interface{}{float64(3000), float64(9229), "db:5432", "redis:6379"},
+	}
+	specs, err := devcontainer.ExtractPorts(raw, raw.Service)
+	require.NoError(t, err)
+	scanner := port.NewScanner()
+	scanner.SetSkipProbe(true)
+	allocations, err := port.NewAllocator(scanner).AllocatePorts(specs, 1)
+	require.NoError(t, err)
+
+	original := &model.WorktreeEnv{
+		Name:            "feature-auth",
+		Branch:          "feature/auth",
+		WorktreePath:    t.TempDir(),
+		SourceRepoPath:  "/tmp/repo",
+		ConfigPattern:   model.PatternComposeMulti,
+		PortAllocations: allocations,
+		CreatedAt:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Index:           1,
+	}
+	labels, err := docker.BuildLabels(original)
+	require.NoError(t, err)
+	env, err := docker.BuildWorktreeEnv("feature-auth", []model.ContainerInfo{
+		{ContainerID: "c1", ContainerName: "app", ServiceName: "app", Status: "running", Labels: labels},
+		{ContainerID: "c2", ContainerName: "db", ServiceName: "db", Status: "running", Labels: labels},
+	})
+	require.NoError(t, err)
+
+	owners := make(map[int]string)
+	for _, s := range buildServicesJSON(env.PortAllocations) {
+		owners[s.ContainerPort] = s.Name
+	}
+	assert.Equal(t, map[int]string{3000: "app", 9229: "app", 5432: "db", 6379: "redis"}, owners)
+	assert.Equal(t, 3, countServices(env.PortAllocations))
+
+	out := captureStdout(t, func() { printListResultText([]*model.WorktreeEnv{env}) })
+	assert.Regexp(t, `feature-auth\s+feature/auth\s+running\s+1\s+3\s+13000,15432,16379,19229`, out)
+}
+
+// TestPrintListResultText_UnboundPorts verifies that an allocated port
+// that the running container does not publish is marked, with a legend.
+func TestPrintListResultText_UnboundPorts(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:   "feature-auth",
+		Branch: "feature/auth",
+		Status: model.StatusRunning,
+		Index:  1,
+		PortAllocations: []model.PortAllocation{
+			{ServiceName: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+			{ServiceName: "app", ContainerPort: 9229, HostPort: 19229, Protocol: "tcp"},
+		},
+		Containers: []model.ContainerInfo{{ServiceName: "app", Status: "running", Ports: []model.PublishedPort{
+			{HostPort: 13000, ContainerPort: 3000, Protocol: "tcp"},
+		}}},
+	}
+
+	out := captureStdout(t, func() { printListResultText([]*model.WorktreeEnv{env}) })
+	assert.Contains(t, out, "13000,19229!")
+	assert.Contains(t, out, "! allocated, but not published by Docker")
+	assert.Equal(t, []int{19229}, buildListEnvJSON(env).UnboundPorts)
+
+	env.Containers[0].Ports = append(env.Containers[0].Ports, model.PublishedPort{HostPort: 19229, ContainerPort: 9229, Protocol: "tcp"})
+	out = captureStdout(t, func() { printListResultText([]*model.WorktreeEnv{env}) })
+	assert.Contains(t, out, "13000,19229\n")
+	assert.NotContains(t, out, "!")
+	assert.Empty(t, buildListEnvJSON(env).UnboundPorts)
+}
+
+// TestCountServices verifies that ports are grouped by service, and that
+// ports without a known service are counted one each.
+func TestCountServices(t *testing.T) {
+	assert.Equal(t, 0, countServices(nil))
+	assert.Equal(t, 2, countServices([]model.PortAllocation{
+		{ServiceName: "app", ContainerPort: 3000},
+		{ServiceName: "app", ContainerPort: 9229},
+		{ServiceName: "db", ContainerPort: 5432},
+	}))
+	assert.Equal(t, 2, countServices([]model.PortAllocation{{ContainerPort: 3000}, {ContainerPort: 5432}}))
+}
+
+// TestParseAge verifies the day suffix on top of time.ParseDuration, and
+// that zero, negative, and unitless durations are rejected.
+func TestParseAge(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		"1.5d":  36 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"12h":   12 * time.Hour,
+		"30m":   30 * time.Minute,
+		"90s":   90 * time.Second,
+	}
+	for in, want := range valid {
+		got, err := parseAge(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "7", "0d", "-1d", "d", "7days", "1w"} {
+		_, err := parseAge(in)
+		assert.Error(t, err, in)
+	}
+}
+
+// TestAgeFilter verifies --older-than and --newer-than on their own and
+// combined, and that environments without a creation time are dropped
+// once a limit is set.
+func TestAgeFilter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	envs := []*model.WorktreeEnv{
+		{Name: "fresh", CreatedAt: now.Add(-30 * time.Minute)},
+		{Name: "day-old", CreatedAt: now.Add(-36 * time.Hour)},
+		{Name: "stale", CreatedAt: now.Add(-10 * 24 * time.Hour)},
+		{Name: "unknown"},
+	}
+	names := func(envs []*model.WorktreeEnv) []string {
+		var out []string
+		for _, env := range envs {
+			out = append(out, env.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter ageFilter
+		want   []string
+	}{
+		{name: "no limits", want: []string{"fresh", "day-old", "stale", "unknown"}},
+		{name: "older than 7d", filter: ageFilter{olderThan: 7 * 24 * time.Hour}, want: []string{"stale"}},
+		{name: "newer than 1h", filter: ageFilter{newerThan: time.Hour}, want: []string{"fresh"}},
+		{name: "between 1d and 7d", filter: ageFilter{olderThan: 24 * time.Hour, newerThan: 7 * 24 * time.Hour}, want: []string{"day-old"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, names(tt.filter.filter(envs, now)))
+		})
+	}
+
+	_, err := parseAgeFilter(&listFlags{olderThan: "soon"})
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Contains(t, err.Error(), "--older-than")
+}
+
+// TestRunList_OlderThan combines --status and --older-than on marker-only
+// environments: an old marker matches, a new one does not. This test uses
+// os.Chdir and t.Setenv, so it must NOT use t.Parallel().
+func TestRunList_OlderThan(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+	setJSONOutput(t, true)
+
+	repoPath := setupTestRepo(t)
+	wm := worktree.NewManager()
+	for name, createdAt := range map[string]time.Time{
+		"feature-old": time.Now().Add(-30 * 24 * time.Hour),
+		"feature-new": time.Now().Add(-time.Minute),
+	} {
+		worktreePath := filepath.Join(t.TempDir(), name)
+		require.NoError(t, wm.Add(repoPath, name, worktreePath, ""))
+		require.NoError(t, worktree.WriteMarkerFile(worktreePath, worktree.MarkerFile{
+			ManagedBy:      "loam",
+			Name:           name,
+			Branch:         name,
+			SourceRepoPath: repoPath,
+			ConfigPattern:  model.PatternNone,
+			CreatedAt:      createdAt.UTC().Format(time.RFC3339),
+		}))
+	}
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runList(context.Background(), &listFlags{status: "no-container", olderThan: "7d"}))
+	})
+	var result listResultJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.Len(t, result.Environments, 1)
+	assert.Equal(t, "feature-old", result.Environments[0].Name)
+
+	err = runList(context.Background(), &listFlags{status: "all", newerThan: "7"})
+	requireExitCode(t, err, model.ExitGeneralError)
 }
@@ -0,0 +1,104 @@
+// Package cli — postcreate.go implements "loam create --post-create".
+//
+// A post-create hook is an arbitrary host-side command run after an
+// environment was created successfully, e.g. to open an editor or to seed a
+// database. It is given by --post-create or by the postCreateHook key of
+// the project configuration, runs through the platform shell in the new
+// worktree, and sees the environment in its variables:
+//
+//	WORKTREE_NAME  environment name
+//	WORKTREE_PATH  absolute worktree path
+//	WORKTREE_PORT  host port of the primary service (only when one is allocated)
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/mmr-tortoise/loam/internal/config"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// resolvePostCreateHook returns the post-create command to run, or "" for
+// none. --post-create wins over the project configuration.
+func resolvePostCreateHook(flags *createFlags, projectConfig *config.ProjectConfig) string {
+	if flags.postCreate != "" {
+		return flags.postCreate
+	}
+	return projectConfig.PostCreateHook
+}
+
+// postCreateEnv returns the environment of the hook process: the current
+// environment plus the WORKTREE_* variables for env. service is the primary
+// Compose service from devcontainer.json, if any.
+func postCreateEnv(env *model.WorktreeEnv, service string) []string {
+	vars := append(os.Environ(),
+		"WORKTREE_NAME="+env.Name,
+		"WORKTREE_PATH="+env.WorktreePath,
+	)
+	if pa, ok := primaryPortAllocation(env.PortAllocations, service); ok {
+		vars = append(vars, "WORKTREE_PORT="+strconv.Itoa(pa.HostPort))
+	}
+	return vars
+}
+
+// primaryPortAllocation picks the allocation WORKTREE_PORT reports: the
+// lowest container port of service, or of any service if service is empty
+// or publishes nothing. Pattern A/B environments have a single service.
+func primaryPortAllocation(allocations []model.PortAllocation, service string) (model.PortAllocation, bool) {
+	if service != "" {
+		var own []model.PortAllocation
+		for _, pa := range allocations {
+			if pa.ServiceName == service {
+				own = append(own, pa)
+			}
+		}
+		if len(own) > 0 {
+			allocations = own
+		}
+	}
+	if len(allocations) == 0 {
+		return model.PortAllocation{}, false
+	}
+
+	best := allocations[0]
+	for _, pa := range allocations[1:] {
+		if pa.ContainerPort < best.ContainerPort {
+			best = pa
+		}
+	}
+	return best, true
+}
+
+// runPostCreateHook runs command through the platform shell with the
+// worktree as its working directory. Its output is streamed to out (see
+// tailLogsOutput), its errors to stderr. The environment is complete by
+// then, so a failing hook is reported but nothing is rolled back.
+func runPostCreateHook(ctx context.Context, command string, env *model.WorktreeEnv, service string, out io.Writer) error {
+	if command == "" {
+		return nil
+	}
+
+	name, args := "sh", []string{"-c", command}
+	if runtime.GOOS == "windows" {
+		name, args = "cmd", []string{"/C", command}
+	}
+
+	VerboseLog("Running post-create hook: %s", command)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = env.WorktreePath
+	cmd.Env = postCreateEnv(env, service)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return model.WrapCLIError(model.ExitGeneralError,
+			fmt.Sprintf("post-create hook failed (environment %q was created)", env.Name), err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package cli — postcreate_test.go contains unit tests for
+// "loam create --post-create".
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/config"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// skipWithoutSh skips tests that run hooks through sh.
+func skipWithoutSh(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("post-create hooks run through cmd on Windows")
+	}
+}
+
+// TestResolvePostCreateHook verifies that --post-create wins over the
+// project configuration.
+func TestResolvePostCreateHook(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.ProjectConfig{PostCreateHook: "make seed"}
+	assert.Equal(t, "code .", resolvePostCreateHook(&createFlags{postCreate: "code ."}, cfg))
+	assert.Equal(t, "make seed", resolvePostCreateHook(&createFlags{}, cfg))
+	assert.Empty(t, resolvePostCreateHook(&createFlags{}, &config.ProjectConfig{}))
+}
+
+// TestPostCreateEnv verifies the WORKTREE_* variables, including the choice
+// of the primary service's port.
+func TestPostCreateEnv(t *testing.T) {
+	t.Parallel()
+
+	env := &model.WorktreeEnv{
+		Name:         "feature-auth",
+		WorktreePath: "/tmp/wt/feature-auth",
+		PortAllocations: []model.PortAllocation{
+			{ServiceName: "db", ContainerPort: 5432, HostPort: 15432},
+			{ServiceName: "app", ContainerPort: 9229, HostPort: 19229},
+			{ServiceName: "app", ContainerPort: 3000, HostPort: 13000},
+		},
+	}
+
+	lookup := func(vars []string, key string) (string, bool) {
+		for _, kv := range vars {
+			if value, ok := strings.CutPrefix(kv, key+"="); ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+
+	vars := postCreateEnv(env, "app")
+	name, _ := lookup(vars, "WORKTREE_NAME")
+	path, _ := lookup(vars, "WORKTREE_PATH")
+	port, _ := lookup(vars, "WORKTREE_PORT")
+	assert.Equal(t, "feature-auth", name)
+	assert.Equal(t, "/tmp/wt/feature-auth", path)
+	assert.Equal(t, "13000", port, "lowest container port of the primary service")
+	_, inherited := lookup(vars, "PATH")
+	assert.True(t, inherited, "the current environment is passed on")
+
+	port, _ = lookup(postCreateEnv(env, "worker"), "WORKTREE_PORT")
+	assert.Equal(t, "13000", port, "a service without ports falls back to all allocations")
+
+	_, ok := lookup(postCreateEnv(&model.WorktreeEnv{Name: "docs"}, ""), "WORKTREE_PORT")
+	assert.False(t, ok, "WORKTREE_PORT is only set when a port is allocated")
+}
+
+// TestRunPostCreateHook verifies that the hook runs in the worktree with the
+// variables set, that its output is streamed, and that a failing hook is a
+// general error.
+func TestRunPostCreateHook(t *testing.T) {
+	skipWithoutSh(t)
+	t.Parallel()
+
+	dir := t.TempDir()
+	env := &model.WorktreeEnv{
+		Name:            "feature-auth",
+		WorktreePath:    dir,
+		PortAllocations: []model.PortAllocation{{ServiceName: "app", ContainerPort: 3000, HostPort: 13000}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, runPostCreateHook(context.Background(),
+		`echo "$WORKTREE_NAME $WORKTREE_PORT"; pwd > cwd.txt`, env, "app", &out))
+	assert.Equal(t, "feature-auth 13000\n", out.String())
+
+	cwd, err := os.ReadFile(filepath.Join(dir, "cwd.txt"))
+	require.NoError(t, err)
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, resolved, strings.TrimSpace(string(cwd)))
+
+	err = runPostCreateHook(context.Background(), "exit 3", env, "app", &out)
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Contains(t, err.Error(), "feature-auth")
+
+	require.NoError(t, runPostCreateHook(context.Background(), "", env, "app", &out), "no hook is not an error")
+}
+
+// TestRunCreate_PostCreateHook verifies that create runs the postCreateHook
+// of the project configuration after creating the worktree. This test uses
+// os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_PostCreateHook(t *testing.T) {
+	skipWithoutSh(t)
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, config.FileName),
+		[]byte(`{"postCreateHook": "echo \"$WORKTREE_NAME\" > hook.txt"}`), 0644))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-hook", &createFlags{path: worktreePath, noStart: true}))
+	})
+
+	data, err := os.ReadFile(filepath.Join(worktreePath, "hook.txt"))
+	require.NoError(t, err, "the hook should run in the new worktree")
+	assert.Equal(t, "feature-hook\n", string(data))
+}
@@ -0,0 +1,165 @@
+// Package cli — printconfig.go implements "loam create --print-config".
+//
+// --print-config runs the configuration steps of create — finding and
+// parsing devcontainer.json, detecting the pattern, allocating ports, and
+// rewriting — in memory, and prints the files create would generate
+// instead of creating the worktree and containers. The output is meant for
+// review and diffing: the rewritten devcontainer.json for pattern A/B, and
+// for Compose configurations that file followed by the override YAML as a
+// second YAML document ("---"), since JSON is valid YAML.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/port"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// configPreview holds what createEnv has resolved from its flags by the
+// time --print-config takes over, before any side effects.
+type configPreview struct {
+	repoRoot      string
+	envName       string
+	branchName    string
+	worktreePath  string
+	worktreeIndex int // model.UnknownWorktreeIndex if not resolved yet
+
+	projectPrefix     string
+	buildArgs         map[string]string
+	forcedPorts       map[int]int
+	extraComposeFiles []string
+	configDir         string
+	allocCfg          port.AllocatorConfig
+}
+
+// printConfigJSON is the --output json form of --print-config.
+type printConfigJSON struct {
+	ConfigPattern   string          `json:"configPattern"`
+	Devcontainer    json.RawMessage `json:"devcontainer"`
+	ComposeOverride string          `json:"composeOverride,omitempty"`
+}
+
+// printEnvConfig renders the configuration files of the environment
+// described by p and prints them to stdout. Nothing is written: Compose
+// files are referenced as configured rather than resolved against the
+// worktree (see devcontainer.ResolveComposeFiles), which only differs for
+// files given by absolute path.
+func printEnvConfig(ctx context.Context, dc *dockerConn, wm *worktree.Manager, flags *createFlags, p configPreview) error {
+	devcontainerPath, err := devcontainer.FindDevContainerJSON(p.repoRoot)
+	if err != nil {
+		return err
+	}
+	if devcontainerPath == "" {
+		return model.NewCLIError(model.ExitDevContainerNotFound,
+			fmt.Sprintf("no devcontainer.json found in %s; there is no configuration to print", p.repoRoot))
+	}
+
+	rawConfig, err := devcontainer.LoadConfig(devcontainerPath)
+	if err != nil {
+		return err
+	}
+	rawJSON, err := os.ReadFile(devcontainerPath)
+	if err != nil {
+		return model.WrapCLIError(model.ExitDevContainerNotFound, "failed to read devcontainer.json", err)
+	}
+
+	pattern, composeFiles, configDir, err := detectConfigPattern(rawConfig, flags, p.buildArgs, p.extraComposeFiles, p.configDir)
+	if err != nil {
+		return err
+	}
+
+	worktreeIndex := p.worktreeIndex
+	if worktreeIndex == model.UnknownWorktreeIndex {
+		worktreeIndex, err = resolveWorktreeIndex(ctx, dc, wm, p.repoRoot, p.envName, flags, p.allocCfg)
+		if err != nil {
+			return err
+		}
+	}
+	composeServices, portAllocations, err := allocateEnvPorts(ctx, dc, devcontainerPath, rawConfig, pattern, composeFiles,
+		p.envName, worktreeIndex, flags, p.allocCfg, p.forcedPorts)
+	if err != nil {
+		return err
+	}
+
+	env := &model.WorktreeEnv{
+		Name:            p.envName,
+		Branch:          p.branchName,
+		WorktreePath:    p.worktreePath,
+		SourceRepoPath:  p.repoRoot,
+		Status:          model.StatusRunning,
+		ConfigPattern:   pattern,
+		PortAllocations: portAllocations,
+		CreatedAt:       time.Now().UTC(),
+		Index:           worktreeIndex,
+		ProjectName:     flags.projectName,
+		ShutdownAction:  rawConfig.ShutdownAction,
+	}
+
+	var rewrittenJSON, overrideData []byte
+	if pattern.IsCompose() {
+		env.ProjectPrefix = p.projectPrefix
+		srcDevcontainerDir := filepath.Dir(devcontainerPath)
+		resources := parseComposeResourcesOrWarn(srcDevcontainerDir, composeFiles)
+
+		overrideRef := devcontainer.ComposeOverrideFileName
+		if configDir != "" {
+			composeFiles = composeFilePaths(filepath.Join(p.worktreePath, ".devcontainer"), composeFiles)
+			overrideRef = filepath.Join(configDir, devcontainer.ComposeOverrideFileName)
+			env.ConfigDir = configDir
+		}
+		env.ComposeFiles = composeFileChain(composeFiles, overrideRef, p.extraComposeFiles)
+
+		labels, labelErr := docker.BuildLabels(env)
+		if labelErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "invalid environment", labelErr)
+		}
+		overrideData, err = generateComposeOverride(env, rawConfig, composeServices, labels, flags, resources)
+		if err != nil {
+			return err
+		}
+		rewrittenJSON, err = rewriteComposeConfig(rawJSON, p.envName, composeFiles, overrideRef, flags)
+		if err != nil {
+			return err
+		}
+	} else {
+		labels, labelErr := docker.BuildLabels(env)
+		if labelErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, "invalid environment", labelErr)
+		}
+		rewrittenJSON, err = rewriteImageConfig(rawJSON, env, labels, flags, p.buildArgs)
+		if err != nil {
+			return err
+		}
+	}
+
+	printRenderedConfig(pattern, rewrittenJSON, overrideData)
+	return nil
+}
+
+// printRenderedConfig outputs the rendered files in text or JSON format.
+func printRenderedConfig(pattern model.ConfigPattern, rewrittenJSON, overrideData []byte) {
+	if IsJSONOutput() {
+		data, _ := json.MarshalIndent(printConfigJSON{
+			ConfigPattern:   string(pattern),
+			Devcontainer:    json.RawMessage(rewrittenJSON),
+			ComposeOverride: string(overrideData),
+		}, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Print(string(rewrittenJSON))
+	if len(overrideData) > 0 {
+		fmt.Println("---")
+		fmt.Print(string(overrideData))
+	}
+}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// TestRunCreate_PrintConfig verifies that --print-config prints the
+// rewritten devcontainer.json of a pattern A configuration as valid JSON,
+// with the shifted ports, and creates neither the worktree nor the branch.
+// This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_PrintConfig(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(`{
+		// Comments are fine in the source.
+		"image": "node:22",
+		"appPort": ["3000:3000"]
+	}`), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	out := captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-print", &createFlags{
+			path:          worktreePath,
+			printConfig:   true,
+			skipPortCheck: true,
+			index:         2,
+			indexSet:      true,
+		}))
+	})
+
+	var config map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &config), out)
+	assert.Equal(t, "node:22", config["image"])
+	assert.Equal(t, []interface{}{"23000:3000"}, config["appPort"])
+
+	assert.NoDirExists(t, worktreePath)
+	assert.False(t, worktree.NewManager().BranchExists(repoPath, "feature-print"))
+}
+
+// TestRunCreate_PrintConfigCompose verifies that --print-config prints the
+// devcontainer.json and the override of a Compose configuration as two YAML
+// documents, and a single object with --output json, without writing the
+// files. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_PrintConfigCompose(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupComposeRepo(t)
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	flags := func() *createFlags {
+		return &createFlags{path: worktreePath, printConfig: true, skipPortCheck: true, index: 1, indexSet: true}
+	}
+	out := captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-print", flags()))
+	})
+
+	config, override, found := strings.Cut(out, "\n---\n")
+	require.True(t, found, out)
+	var rewritten map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(config), &rewritten), config)
+	assert.Equal(t, []interface{}{"docker-compose.yml", "docker-compose.worktree.yml"}, rewritten["dockerComposeFile"])
+
+	var parsed struct {
+		Services map[string]struct {
+			Ports []string `yaml:"ports"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(override), &parsed), override)
+	assert.Equal(t, []string{"13000:3000"}, parsed.Services["app"].Ports)
+	assert.NoDirExists(t, worktreePath)
+
+	setJSONOutput(t, true)
+	out = captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature-print", flags()))
+	})
+	var result printConfigJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	assert.Equal(t, string(model.PatternComposeSingle), result.ConfigPattern)
+	assert.Contains(t, string(result.Devcontainer), "docker-compose.worktree.yml")
+	assert.Contains(t, result.ComposeOverride, "13000:3000")
+	assert.NoDirExists(t, worktreePath)
+}
+
+// TestRunCreate_PrintConfigErrors verifies that --print-config fails
+// without a devcontainer.json and together with --clone-url. This test uses
+// os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_PrintConfigErrors(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	err = runCreate(context.Background(), "feature-print", &createFlags{path: worktreePath, printConfig: true})
+	requireExitCode(t, err, model.ExitDevContainerNotFound)
+	assert.NoDirExists(t, worktreePath)
+
+	err = runCreate(context.Background(), "feature-print", &createFlags{printConfig: true, cloneURL: "https://example.com/repo.git"})
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Contains(t, err.Error(), "--clone-url")
+}
+
+// TestRunCreate_PrintConfigUsers verifies that --remote-user and
+// --container-user set the fields of a pattern A configuration, and for a
+// Compose configuration set remoteUser and the user of the primary service
+// in the override. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_PrintConfigUsers(t *testing.T) {
+	setJSONOutput(t, true)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+
+	render := func(flags *createFlags) printConfigJSON {
+		t.Helper()
+		flags.path = filepath.Join(t.TempDir(), "wt")
+		flags.printConfig = true
+		flags.skipPortCheck = true
+		out := captureStdout(t, func() {
+			require.NoError(t, runCreate(context.Background(), "feature-user", flags))
+		})
+		var result printConfigJSON
+		require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+		return result
+	}
+
+	repoPath := setupTestRepo(t)
+	dcDir := filepath.Join(repoPath, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"),
+		[]byte(`{"image": "node:22", "remoteUser": "node"}`), 0o644))
+	runTestGit(t, repoPath, "add", ".devcontainer")
+	runTestGit(t, repoPath, "commit", "-q", "-m", "add devcontainer")
+	require.NoError(t, os.Chdir(repoPath))
+
+	var config map[string]interface{}
+	require.NoError(t, json.Unmarshal(render(&createFlags{containerUser: "1000:1000"}).Devcontainer, &config))
+	assert.Equal(t, "node", config["remoteUser"], "the original remoteUser is kept")
+	assert.Equal(t, "1000:1000", config["containerUser"])
+
+	config = nil
+	require.NoError(t, json.Unmarshal(render(&createFlags{remoteUser: "dev"}).Devcontainer, &config))
+	assert.Equal(t, "dev", config["remoteUser"])
+	assert.NotContains(t, config, "containerUser")
+
+	require.NoError(t, os.Chdir(setupComposeRepo(t)))
+	result := render(&createFlags{remoteUser: "dev", containerUser: "1000:1000"})
+	config = nil
+	require.NoError(t, json.Unmarshal(result.Devcontainer, &config))
+	assert.Equal(t, "dev", config["remoteUser"])
+	assert.NotContains(t, config, "containerUser", "Compose services get user: in the override instead")
+
+	var override struct {
+		Services map[string]struct {
+			User string `yaml:"user"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(result.ComposeOverride), &override))
+	assert.Equal(t, "1000:1000", override.Services["app"].User)
+}
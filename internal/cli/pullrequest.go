@@ -0,0 +1,98 @@
+// Package cli — pullrequest.go implements GitHub pull request support for
+// "loam create --from-pr".
+//
+// The PR head commit is always fetched with plain Git from the well-known
+// "pull/<N>/head" ref that GitHub maintains on the base repository. This
+// works for PRs from forks as well, and needs nothing beyond a GitHub remote.
+//
+// When the GitHub CLI (gh) is installed, it is used only to look up PR
+// metadata (title, head branch, head repository) for display. A missing or
+// unauthenticated gh degrades gracefully: the lookup is skipped and the
+// fetch proceeds without metadata.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// pullRequestRemote is the Git remote that PR refs are fetched from.
+const pullRequestRemote = "origin"
+
+// pullRequestInfo holds the subset of `gh pr view --json` output that loam uses.
+type pullRequestInfo struct {
+	Number            int    `json:"number"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	HeadRefName       string `json:"headRefName"`
+	IsCrossRepository bool   `json:"isCrossRepository"`
+
+	// HeadRepositoryOwner is only meaningful for PRs opened from a fork.
+	HeadRepositoryOwner struct {
+		Login string `json:"login"`
+	} `json:"headRepositoryOwner"`
+}
+
+// pullRequestFields lists the JSON fields requested from `gh pr view`.
+// It must stay in sync with the json tags on pullRequestInfo.
+const pullRequestFields = "number,title,url,headRefName,isCrossRepository,headRepositoryOwner"
+
+// pullRequestRef returns the remote ref holding the head commit of PR number.
+func pullRequestRef(number int) string {
+	return fmt.Sprintf("pull/%d/head", number)
+}
+
+// pullRequestBranch returns the default local branch name for PR number.
+// A dedicated "pr-<N>" branch avoids clobbering a local branch that happens
+// to share the PR's head branch name (e.g., "main" on a fork).
+func pullRequestBranch(number int) string {
+	return fmt.Sprintf("pr-%d", number)
+}
+
+// lookupPullRequest queries the GitHub CLI for metadata about PR number.
+// repoRoot is used as the working directory so gh resolves the right repository.
+//
+// Returns an error if gh is not installed or the query fails; callers should
+// treat this as non-fatal since the PR can still be fetched via Git.
+func lookupPullRequest(ctx context.Context, repoRoot string, number int) (*pullRequestInfo, error) {
+	// exec.LookPath searches $PATH for the binary, like a shell would.
+	ghPath, err := exec.LookPath("gh")
+	if err != nil {
+		return nil, fmt.Errorf("GitHub CLI (gh) not found in PATH: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, ghPath, "pr", "view", strconv.Itoa(number), "--json", pullRequestFields)
+	cmd.Dir = repoRoot
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gh pr view %d failed: %w", number, err)
+	}
+
+	return parsePullRequestInfo(out)
+}
+
+// parsePullRequestInfo decodes the JSON emitted by `gh pr view --json`.
+// It is split out from lookupPullRequest so it can be tested without gh.
+func parsePullRequestInfo(data []byte) (*pullRequestInfo, error) {
+	var info pullRequestInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse gh output: %w", err)
+	}
+	if info.HeadRefName == "" {
+		return nil, fmt.Errorf("gh output is missing headRefName")
+	}
+	return &info, nil
+}
+
+// headLabel returns a human-readable "owner:branch" (fork) or "branch"
+// description of the PR head, mirroring how GitHub displays it.
+func (p *pullRequestInfo) headLabel() string {
+	if p.IsCrossRepository && p.HeadRepositoryOwner.Login != "" {
+		return p.HeadRepositoryOwner.Login + ":" + p.HeadRefName
+	}
+	return p.HeadRefName
+}
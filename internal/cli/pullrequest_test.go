@@ -0,0 +1,92 @@
+// Package cli — pullrequest_test.go contains unit tests for the
+// "create --from-pr" helpers. None of these tests invoke gh or the network.
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParsePullRequestInfo verifies decoding of `gh pr view --json` output
+// for both same-repository and fork PRs.
+func TestParsePullRequestInfo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("same repository", func(t *testing.T) {
+		t.Parallel()
+		data := []byte(`{"number":12,"title":"Fix login","url":"https://github.com/o/r/pull/12",
+			"headRefName":"fix/login","isCrossRepository":false,"headRepositoryOwner":{"login":"o"}}`)
+
+		info, err := parsePullRequestInfo(data)
+		require.NoError(t, err)
+		assert.Equal(t, 12, info.Number)
+		assert.Equal(t, "fix/login", info.headLabel())
+	})
+
+	t.Run("fork", func(t *testing.T) {
+		t.Parallel()
+		data := []byte(`{"number":7,"headRefName":"main","isCrossRepository":true,"headRepositoryOwner":{"login":"alice"}}`)
+
+		info, err := parsePullRequestInfo(data)
+		require.NoError(t, err)
+		assert.Equal(t, "alice:main", info.headLabel())
+	})
+
+	t.Run("missing head branch", func(t *testing.T) {
+		t.Parallel()
+		_, err := parsePullRequestInfo([]byte(`{"number":7}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		t.Parallel()
+		_, err := parsePullRequestInfo([]byte(`not json`))
+		assert.Error(t, err)
+	})
+}
+
+// TestResolveCreateBranch verifies how the branch name is derived from the
+// positional argument, --from-pr and --detach.
+func TestResolveCreateBranch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       []string
+		flags      createFlags
+		want       string
+		wantCommit string
+		wantErr    bool
+	}{
+		{name: "branch argument", args: []string{"feature-auth"}, want: "feature-auth"},
+		{name: "missing branch argument", args: nil, wantErr: true},
+		{name: "from-pr default branch", flags: createFlags{fromPR: 123}, want: "pr-123"},
+		{name: "from-pr with branch override", args: []string{"review-123"}, flags: createFlags{fromPR: 123}, want: "review-123"},
+		{name: "from-pr with base", flags: createFlags{fromPR: 123, base: "main"}, wantErr: true},
+		{name: "negative PR number", flags: createFlags{fromPR: -1}, wantErr: true},
+		{name: "detach at commit", args: []string{"v1.2.0"}, flags: createFlags{detach: true}, wantCommit: "v1.2.0"},
+		{name: "detach defaults to HEAD", flags: createFlags{detach: true}, wantCommit: "HEAD"},
+		{name: "detach with from-pr", flags: createFlags{detach: true, fromPR: 1}, wantErr: true},
+		{name: "detach with base", args: []string{"v1"}, flags: createFlags{detach: true, base: "main"}, wantErr: true},
+		{name: "detach with reuse", flags: createFlags{detach: true, reuse: true}, wantErr: true},
+		{name: "detach with new-branch-only", flags: createFlags{detach: true, newBranchOnly: true}, wantErr: true},
+		{name: "new-branch-only with reuse", args: []string{"feature-auth"}, flags: createFlags{newBranchOnly: true, reuse: true}, wantErr: true},
+		{name: "new-branch-only", args: []string{"feature-auth"}, flags: createFlags{newBranchOnly: true}, want: "feature-auth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := resolveCreateBranch(tt.args, &tt.flags)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCommit, tt.flags.commit)
+		})
+	}
+}
@@ -11,19 +11,33 @@
 // By default, the command prompts for confirmation before proceeding.
 // The --force flag skips the confirmation prompt. The --keep-worktree flag
 // preserves the Git worktree directory while still removing containers.
+// The --keep-containers flag does the opposite: the worktree is removed and
+// the containers are left behind, orphaned, until a later remove of the
+// same environment reclaims them.
+//
+// With --all, every managed environment is removed one after another,
+// including environments without containers, which are found through their
+// marker files.
+// Given how destructive that is, the user must type a confirmation word
+// (or pass --yes), and environments whose worktrees have uncommitted
+// changes are skipped unless --force is given.
 package cli
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/mmr-tortoise/loam/internal/config"
+	"github.com/mmr-tortoise/loam/internal/devcontainer"
 	"github.com/mmr-tortoise/loam/internal/docker"
 	"github.com/mmr-tortoise/loam/internal/model"
 	"github.com/mmr-tortoise/loam/internal/worktree"
@@ -37,6 +51,21 @@ type removeFlags struct {
 	// keepWorktree preserves the Git worktree directory when true.
 	// Only Docker containers and resources are removed.
 	keepWorktree bool
+
+	// keepContainers leaves the Docker containers and resources in place
+	// and only removes the Git worktree. The environment is then orphaned.
+	keepContainers bool
+
+	// all removes every managed environment instead of a named one.
+	// With all, force means "also remove worktrees with uncommitted changes".
+	all bool
+
+	// repo limits --all to environments created from this repository.
+	repo string
+
+	// cleanEmptyParents removes the directories left empty by removing a
+	// worktree (see cleanEmptyParents).
+	cleanEmptyParents bool
 }
 
 // NewRemoveCommand creates the "remove" cobra command.
@@ -45,31 +74,65 @@ func NewRemoveCommand() *cobra.Command {
 	flags := &removeFlags{}
 
 	cmd := &cobra.Command{
-		Use:   "remove <name>",
+		Use:   "remove <name> | --all",
 		Short: "Remove a worktree environment",
 		Long: `Remove a worktree environment, including all Docker containers and resources.
 
 By default, the Git worktree directory is also removed. Use --keep-worktree
 to preserve the directory while removing only the Docker resources.
 
-Unless --force is specified, the command prompts for confirmation.
+Use --keep-containers to remove only the worktree. The containers are left
+as they are and the environment is listed as orphaned; running remove on it
+again removes the containers.
+
+Unless --force or --yes is specified, the command prompts for confirmation.
+Without a terminal on stdin, it refuses to proceed unless --force or --yes
+is given.
+
+With --all, every managed environment is removed sequentially, including
+environments without containers, found through the marker files of the
+repository's worktrees (Docker is not needed for those). You are asked
+to type the repository name (or "all" when several repositories are
+affected) unless --yes is given. Environments whose worktrees have
+uncommitted changes are skipped unless --force is given.
 
 Examples:
   loam remove feature-auth
   loam remove --force feature-auth
-  loam remove --keep-worktree feature-auth`,
+  loam remove --keep-worktree feature-auth
+  loam remove --keep-containers feature-auth
+  loam remove --clean-empty-parents feature-auth
+  loam remove --all
+  loam remove --all --repo . --yes`,
 
-		// Exactly one positional argument (environment name) is required.
-		Args: cobra.ExactArgs(1),
+		// Either one environment name or --all is required (validated in RunE).
+		Args: cobra.MaximumNArgs(1),
 
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateBulkArgs(args, flags.all, flags.repo); err != nil {
+				return err
+			}
+			if flags.keepWorktree && flags.keepContainers {
+				return model.NewCLIError(model.ExitGeneralError,
+					"--keep-worktree and --keep-containers cannot be used together: nothing would be removed")
+			}
+			if flags.all {
+				return runRemoveAll(cmd.Context(), flags)
+			}
 			return runRemove(cmd.Context(), args[0], flags)
 		},
 	}
 
 	// Register command-specific flags.
-	cmd.Flags().BoolVarP(&flags.force, "force", "f", false, "Remove without confirmation")
+	cmd.Flags().BoolVarP(&flags.force, "force", "f", false,
+		"Remove without confirmation; with --all, also remove worktrees with uncommitted changes")
 	cmd.Flags().BoolVar(&flags.keepWorktree, "keep-worktree", false, "Keep Git worktree directory")
+	cmd.Flags().BoolVar(&flags.keepContainers, "keep-containers", false,
+		"Keep the Docker containers and remove only the worktree (the environment becomes orphaned)")
+	cmd.Flags().BoolVar(&flags.all, "all", false, "Remove all managed worktree environments")
+	cmd.Flags().StringVar(&flags.repo, "repo", "", "With --all, only remove environments created from this repository")
+	cmd.Flags().BoolVar(&flags.cleanEmptyParents, "clean-empty-parents", false,
+		"Also remove parent directories of the worktree that are left empty")
 
 	return cmd
 }
@@ -98,59 +161,118 @@ func runRemove(ctx context.Context, envName string, flags *removeFlags) error {
 
 	VerboseLog("Found environment %q with %d containers", envName, len(containers))
 
-	// Step 3: Prompt for confirmation unless --force is specified.
+	// Step 3: Prompt for confirmation unless --force or --yes is specified.
 	if !flags.force {
-		confirmed, err := promptConfirmation(envName, len(containers), env.WorktreePath, flags.keepWorktree)
-		if err != nil {
-			return model.WrapCLIError(model.ExitGeneralError, "failed to read user input", err)
-		}
-		if !confirmed {
-			return model.NewCLIError(model.ExitUserCancelled, "operation cancelled by user")
+		summary := removeSummary(envName, len(containers), env.WorktreePath, flags)
+		if err := newStdinPrompt().yesNo(summary, "Continue?"); err != nil {
+			return err
 		}
 	}
 
+	// Steps 4-5: Remove Docker resources and the Git worktree.
+	worktreeRemoved, err := removeEnvironment(ctx, cli, env, containers, flags)
+	if err != nil {
+		return err
+	}
+	if worktreeRemoved && flags.cleanEmptyParents {
+		cleanEmptyParents(env)
+	}
+
+	// Step 6: Output the result.
+	removedCount := len(containers)
+	if flags.keepContainers {
+		removedCount = 0
+	}
+	printRemoveResult(envName, removedCount, env.WorktreePath, worktreeRemoved)
+	return nil
+}
+
+// removeEnvironment removes the Docker resources of one environment,
+// unless flags.keepContainers is set, and its Git worktree, unless
+// flags.keepWorktree is set. It is shared by the single-environment and
+// --all code paths and reports whether the worktree was removed. cli may be
+// nil for PatternNone environments and with flags.keepContainers.
+func removeEnvironment(ctx context.Context, cli *docker.Client, env *model.WorktreeEnv, containers []model.ContainerInfo, flags *removeFlags) (bool, error) {
+	envName := env.Name
+	keepWorktree := flags.keepWorktree
+
 	// Step 4: Remove Docker containers and resources (skip for PatternNone).
 	// PatternNone environments have no containers to remove — only the
-	// Git worktree cleanup in Step 5 is needed.
-	if env.ConfigPattern.RequiresDocker() {
+	// Git worktree cleanup in Step 5 is needed. Kept containers keep their
+	// labels, so the environment is still found, as orphaned, once the
+	// worktree is gone.
+	switch {
+	case flags.keepContainers && env.ConfigPattern.RequiresDocker():
+		VerboseLog("Keeping %d container(s) of environment %q (--keep-containers)", len(containers), envName)
+	case env.ConfigPattern.RequiresDocker():
+		if notice := shutdownActionNotice(env, "removing"); notice != "" {
+			printWarning("%s", notice)
+		}
+
 		// Guard against nil Docker client for non-None patterns.
 		// If Docker is not available but the environment requires containers,
 		// return a clear error instead of proceeding to panic on Docker SDK calls.
 		if cli == nil {
-			return model.WrapCLIError(model.ExitDockerNotRunning,
+			return false, model.WrapCLIError(model.ExitDockerNotRunning,
 				fmt.Sprintf("Docker is required to remove environment %q (pattern: %s) but is not available",
 					envName, env.ConfigPattern), nil)
 		}
 
-		if env.ConfigPattern.IsCompose() {
+		devcontainerDir := envDevcontainerDir(env)
+		_, statErr := os.Stat(devcontainerDir)
+		if env.ConfigPattern.IsCompose() && statErr == nil {
 			// Pattern C/D: Use docker compose down with volume removal.
 			// This removes containers, networks, and named volumes in one operation.
 			VerboseLog("Running docker compose down for environment %q...", envName)
 
-			devcontainerDir := filepath.Join(env.WorktreePath, ".devcontainer")
-			if err := docker.ComposeDown(ctx, devcontainerDir, nil, true); err != nil {
-				return model.WrapCLIError(model.ExitGeneralError,
+			envVars := map[string]string{
+				"COMPOSE_PROJECT_NAME": env.ComposeProjectName(),
+			}
+			if err := docker.ComposeDown(ctx, devcontainerDir, envComposeFiles(env, nil), true, envVars); err != nil {
+				return false, model.WrapCLIError(model.ExitGeneralError,
 					fmt.Sprintf("failed to remove environment %q containers", envName), err)
 			}
+			removeLabeledResources(ctx, cli, envName)
 		} else {
 			// Pattern A/B: Stop and remove each container individually.
+			// An orphaned Compose environment takes this path too: its
+			// Compose files went with the worktree, so compose down cannot
+			// run, and the labeled networks and volumes are removed after
+			// the containers instead.
 			VerboseLog("Removing %d container(s) for environment %q...", len(containers), envName)
 			for _, c := range containers {
 				VerboseLog("Removing container %s (%s)...", c.ContainerName, c.ContainerID[:12])
 				// Use force=true to handle containers that might still be running.
 				if err := docker.RemoveContainer(ctx, cli, c.ContainerID, true); err != nil {
-					return model.WrapCLIError(model.ExitGeneralError,
+					return false, model.WrapCLIError(model.ExitGeneralError,
 						fmt.Sprintf("failed to remove container %q", c.ContainerName), err)
 				}
 			}
+			if env.ConfigPattern.IsCompose() {
+				removeLabeledResources(ctx, cli, envName)
+			}
 		}
-	} else {
+	default:
 		VerboseLog("No containers to remove for environment %q (PatternNone)", envName)
 	}
 
-	// Step 5: Optionally remove the Git worktree.
+	// Step 5: Optionally remove the Git worktree. A kept worktree still
+	// holds the files create generated for the containers, which would
+	// otherwise point a later "create --reuse" at stale ports and labels.
+	// Files generated into a --config-dir are outside the worktree and are
+	// removed either way.
 	worktreeRemoved := false
-	if !flags.keepWorktree {
+	switch {
+	case env.ConfigDir != "":
+		if err := removeConfigDir(env.ConfigDir); err != nil {
+			printWarning("could not clean up generated files in %s: %v", env.ConfigDir, err)
+		}
+	case keepWorktree && env.ConfigPattern.RequiresDocker():
+		if err := cleanupGeneratedFiles(worktree.NewManager(), env.WorktreePath); err != nil {
+			printWarning("could not clean up generated files in %s: %v", env.WorktreePath, err)
+		}
+	}
+	if !keepWorktree {
 		VerboseLog("Removing Git worktree at %s...", env.WorktreePath)
 		wm := worktree.NewManager()
 
@@ -163,7 +285,7 @@ func runRemove(ctx context.Context, envName string, flags *removeFlags) error {
 
 			// If the worktree directory still exists, report the git error.
 			if _, statErr := os.Stat(env.WorktreePath); statErr == nil {
-				return model.WrapCLIError(model.ExitGitError,
+				return false, model.WrapCLIError(model.ExitGitError,
 					fmt.Sprintf("failed to remove Git worktree at %s", env.WorktreePath), err)
 			}
 			// Directory already gone — the worktree was likely already removed manually.
@@ -172,38 +294,337 @@ func runRemove(ctx context.Context, envName string, flags *removeFlags) error {
 		}
 	}
 
-	// Step 6: Output the result.
-	printRemoveResult(envName, len(containers), env.WorktreePath, worktreeRemoved)
-	return nil
+	return worktreeRemoved, nil
 }
 
-// promptConfirmation asks the user to confirm the remove operation.
-// It reads a single line from stdin and checks for "y" or "yes".
-// Returns true if the user confirmed, false otherwise.
-func promptConfirmation(envName string, containerCount int, worktreePath string, keepWorktree bool) (bool, error) {
-	fmt.Printf("About to remove worktree environment %q:\n", envName)
-	fmt.Printf("  - %d container(s) will be removed\n", containerCount)
-	if !keepWorktree {
-		fmt.Printf("  - Git worktree at %s will be removed\n", worktreePath)
+// cleanEmptyParents removes the parent directories of env's removed
+// worktree that are now empty, up to the boundary chosen by
+// worktree.EmptyParentsBoundary. Failures only produce a warning: the
+// environment itself is gone at this point.
+func cleanEmptyParents(env *model.WorktreeEnv) {
+	tmpl := ""
+	if cfg, err := config.Load(env.SourceRepoPath); err != nil {
+		VerboseLog("Ignoring %s of %s: %v", config.FileName, env.SourceRepoPath, err)
+	} else {
+		tmpl = cfg.WorktreePathTemplate
 	}
-	fmt.Print("\nContinue? [y/N] ")
 
-	// Read a line from stdin. bufio.Scanner handles different line endings
-	// across platforms (LF on Unix, CRLF on Windows).
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
-		return answer == "y" || answer == "yes", nil
+	boundary := worktree.EmptyParentsBoundary(env.WorktreePath, env.SourceRepoPath, tmpl)
+	removed, err := worktree.RemoveEmptyParents(env.WorktreePath, boundary)
+	for _, dir := range removed {
+		VerboseLog("Removed empty directory %s", dir)
+	}
+	if err != nil {
+		printWarning("could not remove empty parent directories of %s: %v", env.WorktreePath, err)
 	}
+}
 
-	// If stdin is closed or an error occurred, treat it as "no".
-	if err := scanner.Err(); err != nil {
-		return false, err
+// removeLabeledResources removes the networks and volumes labeled as
+// belonging to envName that docker compose down left behind. The containers
+// are gone at this point, so a failure only produces a warning.
+func removeLabeledResources(ctx context.Context, cli *docker.Client, envName string) {
+	networks, err := docker.RemoveManagedNetworks(ctx, cli, envName)
+	if err != nil {
+		printWarning("could not remove all networks of environment %q: %v", envName, err)
+	}
+	for _, name := range networks {
+		VerboseLog("Removed leftover network %s", name)
+	}
+
+	volumes, err := docker.RemoveManagedVolumes(ctx, cli, envName)
+	if err != nil {
+		printWarning("could not remove all volumes of environment %q: %v", envName, err)
+	}
+	for _, name := range volumes {
+		VerboseLog("Removed leftover volume %s", name)
+	}
+}
+
+// runRemoveAll removes every managed environment (optionally limited to one
+// repository), one at a time, after confirmation.
+//
+// Environments are found from Docker container labels and, like
+// findEnvironment does for a single name, from marker files, so that
+// environments without containers (created with --no-start, PatternNone, or
+// whose containers were pruned) are removed too. Without Docker, only the
+// marker-only environments are found.
+//
+// Environments are removed sequentially rather than through the concurrent
+// bulk worker pool: removal is irreversible, and a strictly ordered progress
+// log makes it clear what has already happened if the run is interrupted.
+func runRemoveAll(ctx context.Context, flags *removeFlags) error {
+	wm := worktree.NewManager()
+	repoFilter, err := resolveRepoFilter(flags.repo)
+	if err != nil {
+		return err
+	}
+
+	// Docker is optional: a failure leaves cli nil, so that removing an
+	// environment that needs it fails with a clear error of its own.
+	var targets []bulkTarget
+	cli, err := docker.NewClient()
+	if err == nil {
+		targets, err = listBulkTargets(ctx, cli, flags.repo)
+		if err != nil {
+			_ = cli.Close()
+			cli = nil
+		}
+	}
+	if err != nil {
+		VerboseLog("Warning: Docker not available, removing marker-only environments: %v", err)
+	} else {
+		defer func() { _ = cli.Close() }()
+	}
+	targets = addMarkerTargets(wm, targets, repoFilter)
+
+	targets = skipDirtyTargets(targets, flags.force, func(env *model.WorktreeEnv) (bool, error) {
+		return hasUserChanges(wm, env.WorktreePath, env.SourceRepoPath)
+	})
+	if len(targets) == 0 {
+		return printBulkResult("removed", nil)
+	}
+
+	if err := confirmRemoveAll(newStdinPrompt(), targets, removeAllConfirmWord(targets)); err != nil {
+		return err
+	}
+
+	done := 0
+	results := runBulk(ctx, targets, 1, func(ctx context.Context, t bulkTarget) error {
+		done++
+		fmt.Fprintf(os.Stderr, "[%d/%d] Removing environment %q...\n", done, len(targets), t.env.Name)
+		worktreeRemoved, err := removeEnvironment(ctx, cli, t.env, t.containers, flags)
+		if worktreeRemoved && flags.cleanEmptyParents {
+			cleanEmptyParents(t.env)
+		}
+		return err
+	})
+	return printBulkResult("removed", results)
+}
+
+// addMarkerTargets returns targets plus the environments found only through
+// marker files. The worktrees of repoFilter are scanned, or without a filter
+// those of the current repository and of the source repositories of
+// targets. A marker whose environment is already in targets is skipped, as
+// is one from another repository than repoFilter. The result is sorted by
+// environment name.
+func addMarkerTargets(wm *worktree.Manager, targets []bulkTarget, repoFilter string) []bulkTarget {
+	var repos []string
+	if repoFilter != "" {
+		repos = append(repos, repoFilter)
+	} else {
+		if cwd, err := os.Getwd(); err == nil {
+			if root, rootErr := wm.GetRepoRoot(cwd); rootErr == nil {
+				repos = append(repos, filepath.Clean(root))
+			}
+		}
+		for _, t := range targets {
+			if t.env.SourceRepoPath != "" {
+				repos = append(repos, filepath.Clean(t.env.SourceRepoPath))
+			}
+		}
+	}
+	sort.Strings(repos)
+	repos = slices.Compact(repos)
+
+	known := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		known[t.env.Name] = true
+	}
+	for _, repo := range repos {
+		paths, err := wm.ListPaths(repo)
+		if err != nil {
+			VerboseLog("Warning: could not list worktrees of %s: %v", repo, err)
+			continue
+		}
+		for _, path := range paths {
+			marker, readErr := worktree.ReadMarkerFile(path)
+			if readErr != nil || marker == nil || marker.ManagedBy != "loam" || marker.Name == "" || known[marker.Name] {
+				continue
+			}
+			env, ok := envFromMarker(marker, path)
+			if !ok || (repoFilter != "" && filepath.Clean(env.SourceRepoPath) != repoFilter) {
+				continue
+			}
+			known[env.Name] = true
+			targets = append(targets, bulkTarget{env: env})
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].env.Name < targets[j].env.Name
+	})
+	return targets
+}
+
+// skipDirtyTargets drops environments whose worktrees have uncommitted
+// changes, printing a warning for each, unless force is set. An environment
+// whose worktree cannot be checked is skipped as well, erring on the side
+// of not losing work. isDirty is hasUserChanges in production.
+func skipDirtyTargets(targets []bulkTarget, force bool, isDirty func(env *model.WorktreeEnv) (bool, error)) []bulkTarget {
+	if force {
+		return targets
 	}
 
+	kept := make([]bulkTarget, 0, len(targets))
+	for _, t := range targets {
+		dirty, err := isDirty(t.env)
+		switch {
+		case err != nil:
+			printWarning("skipping %q: could not check %s for uncommitted changes: %v",
+				t.env.Name, t.env.WorktreePath, err)
+		case dirty:
+			printWarning("skipping %q: worktree at %s has uncommitted changes (use --force to remove it anyway)",
+				t.env.Name, t.env.WorktreePath)
+		default:
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// hasUserChanges reports whether the worktree at worktreePath has
+// uncommitted changes other than the files loam itself writes there: the
+// marker file, the rewritten .devcontainer/devcontainer.json, and the
+// Compose override. Other files under .devcontainer count as changes unless
+// they are identical to the file at the same path in sourceRepoPath, i.e.
+// the copy create made from the main checkout; a user's edit to a
+// Dockerfile or script is not lost silently. A missing worktree (an
+// orphaned environment) has nothing to lose.
+func hasUserChanges(wm *worktree.Manager, worktreePath, sourceRepoPath string) (bool, error) {
+	if _, err := os.Stat(worktreePath); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	files, err := wm.ChangedFiles(worktreePath)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range files {
+		switch f {
+		case worktree.MarkerFileName,
+			".devcontainer/devcontainer.json",
+			".devcontainer/" + devcontainer.ComposeOverrideFileName:
+			continue
+		}
+		if strings.HasPrefix(f, ".devcontainer/") && sourceRepoPath != "" &&
+			sameFileContent(filepath.Join(worktreePath, f), filepath.Join(sourceRepoPath, f)) {
+			continue
+		}
+		return true, nil
+	}
 	return false, nil
 }
 
+// sameFileContent reports whether the files at a and b both exist and have
+// the same content.
+func sameFileContent(a, b string) bool {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(dataA, dataB)
+}
+
+// removeAllConfirmWord returns the word the user must type to confirm
+// remove --all: the name of the source repository when all targets share
+// one, and "all" when several repositories are affected.
+func removeAllConfirmWord(targets []bulkTarget) string {
+	repo := ""
+	for _, t := range targets {
+		switch {
+		case repo == "":
+			repo = t.env.SourceRepoPath
+		case repo != t.env.SourceRepoPath:
+			return "all"
+		}
+	}
+	if repo == "" {
+		return "all"
+	}
+	return filepath.Base(repo)
+}
+
+// confirmRemoveAll lists the environments about to be removed and asks the
+// user to type expected (see confirmPrompt.typed).
+func confirmRemoveAll(p confirmPrompt, targets []bulkTarget, expected string) error {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "About to remove %d worktree environment(s), including their containers, volumes, and worktrees:\n", len(targets))
+	for _, t := range targets {
+		fmt.Fprintf(&summary, "  - %s (%s)\n", t.env.Name, t.env.WorktreePath)
+	}
+	summary.WriteString("\n")
+	return p.typed(summary.String(), expected)
+}
+
+// cleanupGeneratedFiles undoes the changes create made to the
+// .devcontainer directory of a worktree that is kept: the Compose override
+// file is deleted, and the rewritten devcontainer.json is restored from
+// HEAD, or deleted if it is not tracked (it was copied from the main
+// checkout). Missing files are not an error.
+//
+// devcontainer.json is only restored or deleted while it still matches the
+// checksum create recorded in the marker file. A file edited since (or one
+// without a recorded checksum) is left alone with a warning, so that the
+// user's edits are not thrown away.
+func cleanupGeneratedFiles(wm *worktree.Manager, worktreePath string) error {
+	devcontainerDir := filepath.Join(worktreePath, ".devcontainer")
+
+	overridePath := filepath.Join(devcontainerDir, devcontainer.ComposeOverrideFileName)
+	if err := os.Remove(overridePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	configPath := filepath.Join(devcontainerDir, "devcontainer.json")
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	marker, err := worktree.ReadMarkerFile(worktreePath)
+	if err != nil {
+		return err
+	}
+	if marker == nil || marker.ConfigChecksum != worktree.ConfigChecksum(data) {
+		printWarning("%s was changed after create and is left as it is; it may still hold the ports and labels of the removed containers",
+			configPath)
+		return nil
+	}
+
+	restored, err := wm.RestoreFile(worktreePath, filepath.Join(".devcontainer", "devcontainer.json"))
+	if err != nil {
+		return err
+	}
+	if restored {
+		VerboseLog("Restored %s from HEAD", configPath)
+		return nil
+	}
+	VerboseLog("Removing untracked %s", configPath)
+	return os.Remove(configPath)
+}
+
+// removeSummary describes what removing environment envName does, for
+// the confirmation prompt.
+func removeSummary(envName string, containerCount int, worktreePath string, flags *removeFlags) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "About to remove worktree environment %q:\n", envName)
+	if flags.keepContainers {
+		fmt.Fprintf(&b, "  - %d container(s) will be kept and become orphaned\n", containerCount)
+	} else {
+		fmt.Fprintf(&b, "  - %d container(s) will be removed\n", containerCount)
+	}
+	if !flags.keepWorktree {
+		fmt.Fprintf(&b, "  - Git worktree at %s will be removed\n", worktreePath)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
 // printRemoveResult outputs the remove command result in text or JSON format.
 func printRemoveResult(envName string, containerCount int, worktreePath string, worktreeRemoved bool) {
 	if IsJSONOutput() {
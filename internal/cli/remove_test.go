@@ -0,0 +1,396 @@
+// Package cli — remove_test.go contains unit tests for the safety checks
+// behind "loam remove --all" (the typed confirmation and the skipping of
+// worktrees with uncommitted changes) and for the cleanup of generated
+// files with --keep-worktree and of empty parent directories, and for the
+// containers kept with --keep-containers. Docker is not needed.
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/config"
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// TestConfirmRemoveAll verifies that only the exact confirmation word
+// (ignoring surrounding spaces) confirms removal.
+func TestConfirmRemoveAll(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"exact word", "myrepo\n", true},
+		{"surrounding spaces", "  myrepo  \n", true},
+		{"no trailing newline", "myrepo", true},
+		{"yes is not enough", "y\n", false},
+		{"wrong case", "MyRepo\n", false},
+		{"empty line", "\n", false},
+		{"end of input", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out strings.Builder
+			p := confirmPrompt{in: strings.NewReader(tt.input), out: &out, interactive: true}
+			err := confirmRemoveAll(p, makeBulkTargets(2), "myrepo")
+			if tt.want {
+				require.NoError(t, err)
+			} else {
+				requireExitCode(t, err, model.ExitUserCancelled)
+			}
+			assert.Contains(t, out.String(), "About to remove 2 worktree environment(s)")
+			assert.Contains(t, out.String(), "  - env-1")
+			assert.Contains(t, out.String(), `Type "myrepo" to confirm`)
+		})
+	}
+}
+
+// TestRemoveAllConfirmWord verifies that the repository name is required
+// when all targets share one repository, and "all" otherwise.
+func TestRemoveAllConfirmWord(t *testing.T) {
+	t.Parallel()
+
+	target := func(repo string) bulkTarget {
+		return bulkTarget{env: &model.WorktreeEnv{SourceRepoPath: repo}}
+	}
+
+	assert.Equal(t, "myrepo", removeAllConfirmWord([]bulkTarget{target("/src/myrepo"), target("/src/myrepo")}))
+	assert.Equal(t, "all", removeAllConfirmWord([]bulkTarget{target("/src/myrepo"), target("/src/other")}))
+	assert.Equal(t, "all", removeAllConfirmWord([]bulkTarget{target("")}), "unknown repository")
+}
+
+// TestSkipDirtyTargets verifies that dirty and uncheckable worktrees are
+// skipped unless force is set, with a stub standing in for the Git check.
+func TestSkipDirtyTargets(t *testing.T) {
+	t.Parallel()
+
+	targets := makeBulkTargets(4)
+	for i, tgt := range targets {
+		tgt.env.WorktreePath = tgt.env.Name
+		targets[i] = tgt
+	}
+	isDirty := func(env *model.WorktreeEnv) (bool, error) {
+		switch env.WorktreePath {
+		case "env-1":
+			return true, nil
+		case "env-2":
+			return false, errors.New("not a git repository")
+		}
+		return false, nil
+	}
+
+	kept := skipDirtyTargets(targets, false, isDirty)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "env-0", kept[0].env.Name)
+	assert.Equal(t, "env-3", kept[1].env.Name)
+
+	assert.Len(t, skipDirtyTargets(targets, true, isDirty), 4, "--force keeps every environment")
+}
+
+// TestHasUserChanges verifies that files written by loam itself do not make
+// a worktree dirty, while user changes do, including edits to other files
+// under .devcontainer.
+func TestHasUserChanges(t *testing.T) {
+	t.Parallel()
+
+	sourceDir := setupTestRepo(t)
+	repoDir := setupTestRepo(t)
+	wm := worktree.NewManager()
+
+	// A freshly created environment contains the marker file, a rewritten
+	// devcontainer.json, the Compose override, and the rest of the
+	// .devcontainer directory copied from the main checkout.
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, worktree.MarkerFileName), []byte("{}"), 0o644))
+	for _, dir := range []string{repoDir, sourceDir} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".devcontainer", "Dockerfile"), []byte("FROM node:22\n"), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".devcontainer", "devcontainer.json"), []byte("{}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".devcontainer", "docker-compose.worktree.yml"), []byte("name: x\n"), 0o644))
+
+	dirty, err := hasUserChanges(wm, repoDir, sourceDir)
+	require.NoError(t, err)
+	assert.False(t, dirty, "generated and copied files must be ignored")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".devcontainer", "Dockerfile"), []byte("FROM node:20\n"), 0o644))
+	dirty, err = hasUserChanges(wm, repoDir, sourceDir)
+	require.NoError(t, err)
+	assert.True(t, dirty, "an edited Dockerfile is a user change")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".devcontainer", "Dockerfile"), []byte("FROM node:22\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "notes.txt"), []byte("wip"), 0o644))
+	dirty, err = hasUserChanges(wm, repoDir, sourceDir)
+	require.NoError(t, err)
+	assert.True(t, dirty)
+
+	dirty, err = hasUserChanges(wm, filepath.Join(repoDir, "missing"), sourceDir)
+	require.NoError(t, err)
+	assert.False(t, dirty, "a missing worktree has nothing to lose")
+}
+
+// setupComposeRepo returns a test repository with a committed Compose
+// devcontainer configuration.
+func setupComposeRepo(t *testing.T) string {
+	t.Helper()
+	repoDir := setupTestRepo(t)
+	dcDir := filepath.Join(repoDir, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"),
+		[]byte(`{"dockerComposeFile": "docker-compose.yml", "service": "app", "forwardPorts": [3000]}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "docker-compose.yml"),
+		[]byte("services:\n  app:\n    image: node\n"), 0o644))
+	runTestGit(t, repoDir, "add", ".devcontainer")
+	runTestGit(t, repoDir, "commit", "-q", "-m", "add devcontainer")
+	return repoDir
+}
+
+// TestCreateRemoveKeepWorktreeCycles runs create --reuse and the
+// remove --keep-worktree cleanup several times on the same worktree, and
+// verifies that the generated files are cleaned up each time and that the
+// override is never referenced twice. This test uses os.Chdir, so it must
+// NOT use t.Parallel().
+func TestCreateRemoveKeepWorktreeCycles(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+	original, err := os.ReadFile(filepath.Join(repoDir, ".devcontainer", "devcontainer.json"))
+	require.NoError(t, err)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	wm := worktree.NewManager()
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	configPath := filepath.Join(worktreePath, ".devcontainer", "devcontainer.json")
+	overridePath := filepath.Join(worktreePath, ".devcontainer", "docker-compose.worktree.yml")
+
+	for cycle := 0; cycle < 3; cycle++ {
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(t.Context(), "feature-cycle",
+				&createFlags{path: worktreePath, reuse: true, noStart: true}), "cycle %d", cycle)
+		})
+		rewritten, err := os.ReadFile(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(rewritten), "docker-compose.worktree.yml"),
+			"cycle %d: the override must be referenced exactly once", cycle)
+		require.FileExists(t, overridePath)
+
+		require.NoError(t, cleanupGeneratedFiles(wm, worktreePath))
+		assert.NoFileExists(t, overridePath)
+		restored, err := os.ReadFile(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, string(original), string(restored), "cycle %d: devcontainer.json must be restored", cycle)
+	}
+}
+
+// TestCleanupGeneratedFiles_Untracked verifies that an untracked
+// devcontainer.json (copied from the main checkout) is removed, and that
+// cleaning up a worktree without generated files is a no-op.
+func TestCleanupGeneratedFiles_Untracked(t *testing.T) {
+	t.Parallel()
+
+	repoDir := setupTestRepo(t)
+	wm := worktree.NewManager()
+	require.NoError(t, cleanupGeneratedFiles(wm, repoDir))
+
+	dcDir := filepath.Join(repoDir, ".devcontainer")
+	require.NoError(t, os.MkdirAll(dcDir, 0o755))
+	config := []byte(`{"name": "x"}`)
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), config, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dcDir, "docker-compose.worktree.yml"), []byte("name: x\n"), 0o644))
+	require.NoError(t, worktree.WriteMarkerFile(repoDir, worktree.MarkerFile{
+		ManagedBy: "loam", Name: "x", ConfigChecksum: worktree.ConfigChecksum(config),
+	}))
+
+	require.NoError(t, cleanupGeneratedFiles(wm, repoDir))
+	assert.NoFileExists(t, filepath.Join(dcDir, "devcontainer.json"))
+	assert.NoFileExists(t, filepath.Join(dcDir, "docker-compose.worktree.yml"))
+}
+
+// TestCleanupGeneratedFiles_UserEdit verifies that a devcontainer.json
+// edited after create survives remove --keep-worktree, while the Compose
+// override is still deleted. This test uses os.Chdir, so it must NOT use
+// t.Parallel().
+func TestCleanupGeneratedFiles_UserEdit(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature-edit", &createFlags{path: worktreePath, noStart: true}))
+	})
+	configPath := filepath.Join(worktreePath, ".devcontainer", "devcontainer.json")
+	rewritten, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	edited := strings.Replace(string(rewritten), "{", `{"postCreateCommand": "npm ci",`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(edited), 0o644))
+
+	require.NoError(t, cleanupGeneratedFiles(worktree.NewManager(), worktreePath))
+	kept, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, edited, string(kept), "the user's edit must survive")
+	assert.NoFileExists(t, filepath.Join(worktreePath, ".devcontainer", "docker-compose.worktree.yml"))
+}
+
+// TestRunRemove_CleanEmptyParents creates a worktree at a nested path from
+// a worktreePathTemplate and verifies that remove --clean-empty-parents
+// removes the directories left empty, but not the template's root. This
+// test uses os.Chdir and t.Setenv, so it must NOT use t.Parallel().
+func TestRunRemove_CleanEmptyParents(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+	setJSONOutput(t, false)
+	repoDir := setupTestRepo(t)
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, config.FileName),
+		[]byte(`{"worktreePathTemplate": "`+filepath.ToSlash(root)+`/{{.Repo}}/{{.Branch}}"}`), 0o644))
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature/nested/auth", &createFlags{name: "nested", noStart: true}))
+	})
+	worktreePath := filepath.Join(root, filepath.Base(repoDir), "feature", "nested", "auth")
+	require.DirExists(t, worktreePath)
+
+	captureStdout(t, func() {
+		require.NoError(t, runRemove(t.Context(), "nested", &removeFlags{force: true, cleanEmptyParents: true}))
+	})
+	assert.NoDirExists(t, worktreePath)
+	assert.NoDirExists(t, filepath.Join(root, filepath.Base(repoDir)))
+	assert.DirExists(t, root)
+}
+
+// TestRunRemoveAll_MarkerOnly verifies that remove --all finds and removes
+// environments that exist only as marker files, without Docker, that a
+// worktree with an edited .devcontainer/Dockerfile is skipped, and that
+// --repo leaves the environments of other repositories alone. This test
+// uses os.Chdir and t.Setenv, so it must NOT use t.Parallel().
+func TestRunRemoveAll_MarkerOnly(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+	setJSONOutput(t, false)
+	setAssumeYes(t, true)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+
+	create := func(repoDir, name string) string {
+		t.Helper()
+		require.NoError(t, os.Chdir(repoDir))
+		worktreePath := filepath.Join(t.TempDir(), name)
+		captureStdout(t, func() {
+			require.NoError(t, runCreate(t.Context(), name, &createFlags{path: worktreePath, noStart: true}))
+		})
+		return worktreePath
+	}
+	otherRepo := setupTestRepo(t)
+	otherPath := create(otherRepo, "feature-other")
+	repoDir := setupTestRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".devcontainer"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".devcontainer", "Dockerfile"), []byte("FROM node:22\n"), 0o644))
+	runTestGit(t, repoDir, "add", ".devcontainer")
+	runTestGit(t, repoDir, "commit", "-q", "-m", "add Dockerfile")
+	first := create(repoDir, "feature-a")
+	second := create(repoDir, "feature-b")
+	dirty := create(repoDir, "feature-dirty")
+	require.NoError(t, os.WriteFile(filepath.Join(dirty, ".devcontainer", "Dockerfile"), []byte("FROM node:20\n"), 0o644))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runRemoveAll(t.Context(), &removeFlags{all: true, repo: repoDir}))
+	})
+	assert.Contains(t, out, "2 of 2 environment(s) removed")
+	assert.NoDirExists(t, first)
+	assert.NoDirExists(t, second)
+	assert.DirExists(t, dirty, "an edited .devcontainer/Dockerfile must be kept without --force")
+	assert.DirExists(t, otherPath, "--repo must limit removal to repoDir")
+}
+
+// TestRemoveEnvironment_KeepContainers runs the kept-containers lifecycle:
+// remove --keep-containers deletes the worktree without touching Docker,
+// and the containers, whose labels still describe the environment, are then
+// reported as orphaned. This test uses os.Chdir, so it must NOT use
+// t.Parallel().
+func TestRemoveEnvironment_KeepContainers(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupComposeRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature-kept", &createFlags{path: worktreePath, noStart: true}))
+	})
+
+	env := &model.WorktreeEnv{
+		Name:           "feature-kept",
+		Branch:         "feature-kept",
+		WorktreePath:   worktreePath,
+		SourceRepoPath: repoDir,
+		Status:         model.StatusRunning,
+		ConfigPattern:  model.PatternComposeSingle,
+		CreatedAt:      time.Now(),
+		Index:          1,
+	}
+	labels, err := docker.BuildLabels(env)
+	require.NoError(t, err)
+	containers := []model.ContainerInfo{{
+		ContainerID:   "0123456789abcdef",
+		ContainerName: "feature-kept-app-1",
+		ServiceName:   "app",
+		Status:        "running",
+		Labels:        labels,
+	}}
+
+	// Before: the worktree exists, so the environment is running.
+	before, err := docker.BuildWorktreeEnv(env.Name, containers)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRunning, before.Status)
+
+	// A nil Docker client proves that the containers are left alone.
+	removed, err := removeEnvironment(t.Context(), nil, env, containers, &removeFlags{keepContainers: true})
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.NoDirExists(t, worktreePath)
+
+	after, err := docker.BuildWorktreeEnv(env.Name, containers)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusOrphaned, after.Status)
+}
+
+// TestRemoveSummary_KeepContainers verifies that the confirmation prompt
+// says the containers are kept when --keep-containers is given.
+func TestRemoveSummary_KeepContainers(t *testing.T) {
+	summary := removeSummary("feature-auth", 2, "/tmp/wt", &removeFlags{keepContainers: true})
+	assert.Contains(t, summary, "2 container(s) will be kept and become orphaned")
+	assert.Contains(t, summary, "Git worktree at /tmp/wt will be removed")
+
+	summary = removeSummary("feature-auth", 2, "/tmp/wt", &removeFlags{keepWorktree: true})
+	assert.Contains(t, summary, "2 container(s) will be removed")
+	assert.NotContains(t, summary, "Git worktree")
+}
@@ -0,0 +1,161 @@
+// Package cli — repair.go implements the "loam repair" command.
+//
+// A linked worktree and its repository point at each other: the worktree's
+// .git file names an administrative directory in the repository's .git,
+// and that directory records where the worktree is. Moving either side
+// breaks the link, and Git, and with it create, list, and the container
+// commands, no longer recognize the worktree. repair runs
+// `git worktree repair` to restore the links and reports the state of each
+// worktree afterwards.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// repairFlags holds the flag values for the repair command.
+type repairFlags struct {
+	repo string // --repo: the repository whose worktrees to repair (default: current directory)
+}
+
+// repairedWorktree is the state of one worktree after the repair.
+type repairedWorktree struct {
+	Path   string `json:"path"`
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewRepairCommand creates the "repair" cobra command.
+// It is called from NewRootCommand to register as a subcommand.
+func NewRepairCommand() *cobra.Command {
+	flags := &repairFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "repair [<worktree-path>...]",
+		Short: "Repair the Git links of moved worktrees",
+		Long: `Repair the links between a repository and its worktrees with
+"git worktree repair".
+
+Without arguments, every worktree registered in the repository is pointed
+back at it, which is what is needed after the repository itself was moved.
+Worktrees that were moved must be given by their new paths.
+
+Run it in the main repository, or pass --repo: a worktree whose link is
+broken is no longer recognized as part of the repository. Exits with
+code 5 when a worktree is still broken afterwards.
+
+Examples:
+  loam repair
+  loam repair ~/worktrees/myrepo/feature-auth
+  loam repair --repo ~/src/myrepo`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRepair(args, flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.repo, "repo", "", "Repository whose worktrees to repair (default: current directory)")
+
+	return cmd
+}
+
+// runRepair is the main logic function for the repair command.
+func runRepair(paths []string, flags *repairFlags) error {
+	repo := flags.repo
+	if repo == "" {
+		repo = "."
+	}
+	absRepo, err := filepath.Abs(repo)
+	if err != nil {
+		return model.WrapCLIError(model.ExitGeneralError, fmt.Sprintf("invalid --repo path %q", repo), err)
+	}
+
+	wm := worktree.NewManager()
+	repoRoot, err := wm.GetMainRepoRoot(absRepo)
+	if err != nil {
+		return model.WrapCLIError(model.ExitGitError,
+			fmt.Sprintf("%s is not inside a Git repository (run repair in the main repository or pass --repo)", absRepo), err)
+	}
+
+	// git resolves relative paths against the repository, not the
+	// directory the user ran loam in.
+	absPaths := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, absErr := filepath.Abs(p)
+		if absErr != nil {
+			return model.WrapCLIError(model.ExitGeneralError, fmt.Sprintf("invalid worktree path %q", p), absErr)
+		}
+		absPaths = append(absPaths, abs)
+	}
+
+	VerboseLog("Running git worktree repair in %s...", repoRoot)
+	repairErr := wm.Repair(repoRoot, absPaths...)
+	if repairErr != nil {
+		VerboseLog("git worktree repair: %v", repairErr)
+	}
+
+	// Without explicit paths, report every registered worktree.
+	if len(absPaths) == 0 {
+		worktrees, listErr := wm.ListWorktrees(repoRoot)
+		if listErr != nil {
+			return model.WrapCLIError(model.ExitGitError, "failed to list worktrees", listErr)
+		}
+		for _, wt := range worktrees {
+			absPaths = append(absPaths, wt.Path)
+		}
+	}
+
+	results := make([]repairedWorktree, 0, len(absPaths))
+	broken := 0
+	for _, p := range absPaths {
+		valid, reason := wm.WorktreeState(p)
+		if !valid {
+			broken++
+		}
+		results = append(results, repairedWorktree{Path: p, Valid: valid, Reason: reason})
+	}
+
+	printRepairResult(repoRoot, results)
+
+	switch {
+	case broken > 0:
+		return model.WrapCLIError(model.ExitGitError,
+			fmt.Sprintf("%d worktree(s) are still broken", broken), repairErr)
+	case repairErr != nil:
+		return model.WrapCLIError(model.ExitGitError, "git worktree repair failed", repairErr)
+	}
+	return nil
+}
+
+// printRepairResult outputs the state of the worktrees after the repair in
+// text or JSON format.
+func printRepairResult(repoRoot string, results []repairedWorktree) {
+	if IsJSONOutput() {
+		data, _ := json.MarshalIndent(struct {
+			Repository string             `json:"repository"`
+			Worktrees  []repairedWorktree `json:"worktrees"`
+		}{repoRoot, results}, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Repaired worktree links of %s\n", repoRoot)
+	if len(results) == 0 {
+		fmt.Println("  No worktrees registered.")
+		return
+	}
+	for _, r := range results {
+		if r.Valid {
+			fmt.Printf("  ok      %s\n", r.Path)
+		} else {
+			fmt.Printf("  broken  %s: %s\n", r.Path, r.Reason)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package cli — repair_test.go contains unit tests for "loam repair".
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// TestRunRepair_MovedRepository moves a repository away from its worktree
+// and verifies that repair --repo points the worktree back at it and
+// reports it as valid.
+func TestRunRepair_MovedRepository(t *testing.T) {
+	setJSONOutput(t, true)
+	repoDir := setupTestRepo(t)
+	wm := worktree.NewManager()
+	worktreePath := filepath.Join(t.TempDir(), "feature-auth")
+	require.NoError(t, wm.Add(repoDir, "feature-auth", worktreePath, ""))
+
+	movedRepo := filepath.Join(t.TempDir(), "moved")
+	require.NoError(t, os.Rename(repoDir, movedRepo))
+	valid, _ := wm.WorktreeState(worktreePath)
+	require.False(t, valid)
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runRepair(nil, &repairFlags{repo: movedRepo}))
+	})
+	var result struct {
+		Worktrees []repairedWorktree `json:"worktrees"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result), out)
+	require.Len(t, result.Worktrees, 1)
+	assert.True(t, result.Worktrees[0].Valid, result.Worktrees[0].Reason)
+	assert.True(t, wm.IsWorktree(worktreePath))
+}
+
+// TestRunRepair_StillBroken verifies that a path that cannot be repaired is
+// reported and fails with a Git error.
+func TestRunRepair_StillBroken(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupTestRepo(t)
+	notAWorktree := t.TempDir()
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runRepair([]string{notAWorktree}, &repairFlags{repo: repoDir})
+	})
+	requireExitCode(t, err, model.ExitGitError)
+	assert.Contains(t, out, "broken  "+notAWorktree)
+}
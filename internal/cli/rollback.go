@@ -0,0 +1,81 @@
+// Package cli — rollback.go implements the cleanup stack used by
+// "loam create" to undo a partially created environment.
+//
+// runCreate has side effects spread across many steps: a branch and a Git
+// worktree are created, files are written into the worktree, and containers
+// are started. When a later step fails (most often container startup), the
+// user would otherwise be left with a half-built environment. Each step
+// that creates something pushes an undo action onto a createRollback, and
+// on failure the actions run in reverse order.
+package cli
+
+import (
+	"fmt"
+	"io"
+)
+
+// rollbackStep is one undoable side effect of create.
+type rollbackStep struct {
+	// what describes the resource, e.g. `Git worktree at /path`.
+	what string
+
+	// undo removes the resource. nil means the resource is never removed by
+	// a rollback (e.g., a worktree reused with --reuse), and it is always
+	// reported as left in place.
+	undo func() error
+}
+
+// createRollback records the side effects of a create run so that they can
+// be undone if the run fails. The zero value is an empty stack.
+type createRollback struct {
+	steps []rollbackStep
+}
+
+// push records a side effect. undo may be nil for resources that must be
+// left in place.
+func (r *createRollback) push(what string, undo func() error) {
+	r.steps = append(r.steps, rollbackStep{what: what, undo: undo})
+}
+
+// commit marks the run as successful: the recorded side effects are kept,
+// and a later run does nothing.
+func (r *createRollback) commit() {
+	r.steps = nil
+}
+
+// run undoes the recorded side effects in reverse order (containers before
+// the worktree holding their configuration, the worktree before its branch)
+// and reports each outcome to out. With enabled false, nothing is undone
+// and every resource is reported as left in place. A failing undo does not
+// stop the rollback; the resource is reported as left in place with the
+// reason.
+//
+// It prints nothing when no side effects were recorded, which is the case
+// for failures before the worktree was created.
+func (r *createRollback) run(enabled bool, out io.Writer) {
+	if len(r.steps) == 0 {
+		return
+	}
+
+	if !enabled {
+		_, _ = fmt.Fprintln(out, "Create failed; leaving the partially created environment in place (--rollback-on-failure=false):")
+		for i := len(r.steps) - 1; i >= 0; i-- {
+			_, _ = fmt.Fprintf(out, "  left in place: %s\n", r.steps[i].what)
+		}
+		return
+	}
+
+	_, _ = fmt.Fprintln(out, "Create failed; rolling back:")
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+		if step.undo == nil {
+			_, _ = fmt.Fprintf(out, "  left in place: %s\n", step.what)
+			continue
+		}
+		if err := step.undo(); err != nil {
+			_, _ = fmt.Fprintf(out, "  left in place: %s (rollback failed: %v)\n", step.what, err)
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "  removed: %s\n", step.what)
+	}
+}
@@ -0,0 +1,162 @@
+// Package cli — rollback_test.go contains tests for the create rollback:
+// the cleanup stack itself, runCreate undoing its side effects when a step
+// after worktree creation fails, and a failed worktree add not leaving its
+// branch behind.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// TestCreateRollback_SimulatedStartupFailure records the steps of a create
+// run up to a container start that fails, and verifies that the rollback
+// removes the containers, worktree, and branch in reverse order.
+func TestCreateRollback_SimulatedStartupFailure(t *testing.T) {
+	t.Parallel()
+
+	repoDir := setupTestRepo(t)
+	wm := worktree.NewManager()
+	worktreePath := filepath.Join(t.TempDir(), "wt")
+
+	var undone []string
+	rb := &createRollback{}
+	require.NoError(t, wm.Add(repoDir, "feature-x", worktreePath, ""))
+	rb.push(`branch "feature-x"`, func() error {
+		undone = append(undone, "branch")
+		return wm.DeleteBranch(repoDir, "feature-x")
+	})
+	rb.push("Git worktree at "+worktreePath, func() error {
+		undone = append(undone, "worktree")
+		return wm.Remove(repoDir, worktreePath, true)
+	})
+	rb.push(`containers of environment "feature-x"`, func() error {
+		undone = append(undone, "containers")
+		return nil
+	})
+
+	var out strings.Builder
+	rb.run(true, &out)
+
+	assert.Equal(t, []string{"containers", "worktree", "branch"}, undone, "steps must be undone in reverse order")
+	assert.NoDirExists(t, worktreePath)
+	assert.False(t, wm.BranchExists(repoDir, "feature-x"))
+	assert.Contains(t, out.String(), "removed: Git worktree at "+worktreePath)
+	assert.Contains(t, out.String(), `removed: branch "feature-x"`)
+}
+
+// TestCreateRollback_Reporting verifies the report for failed undos, steps
+// without an undo, a disabled rollback, and an empty stack.
+func TestCreateRollback_Reporting(t *testing.T) {
+	t.Parallel()
+
+	t.Run("failed undo and kept resource", func(t *testing.T) {
+		t.Parallel()
+		rb := &createRollback{}
+		rb.push("existing worktree at /wt (reused)", nil)
+		rb.push(`containers of environment "x"`, func() error { return errors.New("daemon gone") })
+
+		var out strings.Builder
+		rb.run(true, &out)
+		assert.Contains(t, out.String(), `left in place: containers of environment "x" (rollback failed: daemon gone)`)
+		assert.Contains(t, out.String(), "left in place: existing worktree at /wt (reused)")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		called := false
+		rb := &createRollback{}
+		rb.push("Git worktree at /wt", func() error { called = true; return nil })
+
+		var out strings.Builder
+		rb.run(false, &out)
+		assert.False(t, called, "nothing may be undone when rollback is disabled")
+		assert.Contains(t, out.String(), "--rollback-on-failure=false")
+		assert.Contains(t, out.String(), "left in place: Git worktree at /wt")
+	})
+
+	t.Run("nothing recorded", func(t *testing.T) {
+		t.Parallel()
+		var out strings.Builder
+		(&createRollback{}).run(true, &out)
+		assert.Empty(t, out.String())
+	})
+}
+
+// TestRunCreate_RollbackOnFailure runs create against a repository whose
+// devcontainer.json is malformed, so the run fails after the worktree was
+// created. This test uses os.Chdir, so it must NOT use t.Parallel().
+func TestRunCreate_RollbackOnFailure(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("rollback=%v", enabled), func(t *testing.T) {
+			repoDir := setupTestRepo(t)
+			require.NoError(t, os.MkdirAll(filepath.Join(repoDir, ".devcontainer"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(repoDir, ".devcontainer", "devcontainer.json"),
+				[]byte(`{"image": `), 0o644))
+
+			// Save and restore cwd to avoid affecting other tests.
+			origDir, err := os.Getwd()
+			require.NoError(t, err)
+			defer func() { _ = os.Chdir(origDir) }()
+			require.NoError(t, os.Chdir(repoDir))
+
+			worktreePath := filepath.Join(t.TempDir(), "wt")
+			flags := &createFlags{path: worktreePath, noStart: true, rollbackOnFailure: enabled}
+			require.Error(t, runCreate(t.Context(), "feature-broken", flags))
+
+			wm := worktree.NewManager()
+			if enabled {
+				assert.NoDirExists(t, worktreePath, "the worktree must be rolled back")
+				assert.False(t, wm.BranchExists(repoDir, "feature-broken"), "the new branch must be rolled back")
+			} else {
+				assert.DirExists(t, worktreePath, "the worktree must be left in place")
+				assert.True(t, wm.BranchExists(repoDir, "feature-broken"))
+			}
+		})
+	}
+}
+
+// TestRunCreate_FailedAddKeepsNoBranch runs create against real git with a
+// destination that is in the way: a non-empty directory, which is refused
+// before git runs, and a file, which git refuses only after creating the
+// branch. Neither may leave the new branch behind, so that a retry with the
+// same name succeeds. This test uses os.Chdir, so it must NOT use
+// t.Parallel().
+func TestRunCreate_FailedAddKeepsNoBranch(t *testing.T) {
+	setJSONOutput(t, false)
+	repoDir := setupTestRepo(t)
+
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoDir))
+
+	wm := worktree.NewManager()
+	busyDir := filepath.Join(t.TempDir(), "wt")
+	require.NoError(t, os.Mkdir(busyDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(busyDir, "notes.txt"), []byte("mine"), 0o644))
+	busyFile := filepath.Join(t.TempDir(), "wt-file")
+	require.NoError(t, os.WriteFile(busyFile, []byte("mine"), 0o644))
+
+	for _, path := range []string{busyDir, busyFile} {
+		err := runCreate(t.Context(), "feature-blocked", &createFlags{path: path, noStart: true, rollbackOnFailure: true})
+		requireExitCode(t, err, model.ExitGitError)
+		assert.False(t, wm.BranchExists(repoDir, "feature-blocked"), "%s: the new branch must not be left behind", path)
+	}
+	assert.FileExists(t, filepath.Join(busyDir, "notes.txt"))
+
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(t.Context(), "feature-blocked",
+			&createFlags{path: filepath.Join(t.TempDir(), "wt"), noStart: true, rollbackOnFailure: true}))
+	})
+}
@@ -1,6 +1,6 @@
 // Package cli implements the cobra-based CLI commands for loam.
 //
-// Each subcommand (create, list, start, stop, remove) is defined in its own
+// Each subcommand (create, list, start, stop, remove, audit) is defined in its own
 // file within this package. This file defines the root command that serves as
 // the parent for all subcommands and handles global flags.
 package cli
@@ -9,9 +9,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
+	"github.com/mmr-tortoise/loam/internal/cmdlog"
+	"github.com/mmr-tortoise/loam/internal/docker"
 	"github.com/mmr-tortoise/loam/internal/model"
 )
 
@@ -19,16 +23,36 @@ import (
 // These are bound to cobra persistent flags on the root command,
 // which makes them available to every subcommand automatically.
 var (
-	// jsonOutput controls whether command output is formatted as JSON.
-	// When true, all output uses structured JSON format for machine consumption.
-	// When false (default), output uses human-readable text format.
-	jsonOutput bool
+	// outputFormat selects how command output is rendered: outputText
+	// (default, human-readable), outputJSON, or outputYAML. It is bound to
+	// --output and normalized by resolveOutputFormat before a command runs.
+	outputFormat = outputText
+
+	// jsonAlias is bound to the deprecated --json flag, which is kept as an
+	// alias for --output json so existing scripts keep working.
+	jsonAlias bool
 
 	// verbose enables detailed logging output for debugging.
 	// When true, additional information about operations is printed to stderr.
 	verbose bool
+
+	// assumeYes answers confirmation prompts of destructive commands in
+	// advance (see confirmPrompt).
+	assumeYes bool
+)
+
+// Supported --output formats.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
 )
 
+// annotationYAMLOutput marks commands that can render --output yaml.
+// It is set in a command's Annotations map; other commands reject yaml
+// instead of silently falling back to a different format.
+const annotationYAMLOutput = "loam/yaml-output"
+
 // version, commit, and date are set at build time via ldflags.
 // They are injected from the main package to display version information.
 var (
@@ -64,9 +88,22 @@ zero port collisions between environments.`,
 		SilenceUsage: true,
 
 		// SilenceErrors prevents cobra from printing errors automatically.
-		// We format errors ourselves (text or JSON based on --json flag).
+		// We format errors ourselves (text or JSON based on --output).
 		SilenceErrors: true,
 
+		// PersistentPreRunE runs before every subcommand, so global flags
+		// are applied once here rather than in each command.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// In verbose mode, show docker compose output (e.g., image pull
+			// progress) as it happens instead of only on failure.
+			// The git and docker commands loam runs are echoed as well.
+			if verbose {
+				docker.ComposeProgress = os.Stderr
+				cmdlog.Output = os.Stderr
+			}
+			return resolveOutputFormat(cmd)
+		},
+
 		// Version is displayed when --version flag is used.
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", Version, Commit, Date),
 	}
@@ -74,8 +111,14 @@ zero port collisions between environments.`,
 	// PersistentFlags are inherited by all subcommands. This is the cobra
 	// mechanism for global flags — any flag defined here is automatically
 	// available in every subcommand without re-declaration.
-	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json, or yaml")
+	rootCmd.PersistentFlags().BoolVar(&jsonAlias, "json", false, "Output in JSON format")
+	// MarkDeprecated hides --json from help and prints a notice to stderr
+	// when it is used; the flag itself keeps working.
+	_ = rootCmd.PersistentFlags().MarkDeprecated("json", "use --output json instead")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false,
+		"Answer confirmation prompts with yes (required when stdin is not a terminal)")
 
 	// Register subcommands. Each subcommand is defined in its own file
 	// (create.go, list.go, etc.) and returns a *cobra.Command.
@@ -84,6 +127,10 @@ zero port collisions between environments.`,
 	rootCmd.AddCommand(NewStopCommand())
 	rootCmd.AddCommand(NewStartCommand())
 	rootCmd.AddCommand(NewRemoveCommand())
+	rootCmd.AddCommand(NewSwitchCommand())
+	rootCmd.AddCommand(NewRepairCommand())
+	rootCmd.AddCommand(NewAuditCommand())
+	rootCmd.AddCommand(NewDebugCommand())
 
 	return rootCmd
 }
@@ -110,10 +157,41 @@ func Execute(rootCmd *cobra.Command) {
 	}
 }
 
+// resolveOutputFormat validates --output, folds the deprecated --json alias
+// into it, and rejects yaml for commands that cannot render it.
+func resolveOutputFormat(cmd *cobra.Command) error {
+	outputFormat = strings.ToLower(outputFormat)
+
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML:
+	default:
+		format := outputFormat
+		outputFormat = outputText
+		return model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("invalid --output value %q (valid: text, json, yaml)", format))
+	}
+
+	if jsonAlias {
+		if cmd.Flags().Changed("output") && outputFormat != outputJSON {
+			return model.NewCLIError(model.ExitGeneralError,
+				fmt.Sprintf("--json conflicts with --output %s", outputFormat))
+		}
+		outputFormat = outputJSON
+	}
+
+	if outputFormat == outputYAML && cmd.Annotations[annotationYAMLOutput] != "true" {
+		outputFormat = outputText
+		return model.NewCLIError(model.ExitGeneralError,
+			fmt.Sprintf("--output yaml is not supported by the %s command", cmd.Name()))
+	}
+
+	return nil
+}
+
 // printError outputs an error message in the appropriate format
-// (JSON or text) based on the --json global flag.
+// (JSON or text) based on the --output global flag.
 func printError(message string, underlying error) {
-	if jsonOutput {
+	if IsJSONOutput() {
 		// JSON error format matches the CLI contracts specification.
 		errObj := map[string]interface{}{
 			"error": map[string]interface{}{
@@ -149,8 +227,32 @@ func VerboseLog(format string, args ...interface{}) {
 	}
 }
 
-// IsJSONOutput returns whether the --json flag is set.
-// Subcommands use this to decide their output format.
+// printWarning prints a warning to stderr in both text and JSON modes.
+// Using stderr keeps stdout parseable in JSON and YAML modes.
+func printWarning(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// IsJSONOutput returns whether JSON output was selected (--output json
+// or the deprecated --json). Subcommands use this to decide their output format.
 func IsJSONOutput() bool {
-	return jsonOutput
+	return outputFormat == outputJSON
+}
+
+// IsYAMLOutput returns whether --output yaml was selected.
+func IsYAMLOutput() bool {
+	return outputFormat == outputYAML
+}
+
+// printYAML writes v to stdout as YAML with 2-space indentation, matching
+// the indentation of the JSON output. Output structs carry yaml tags with
+// the same keys as their json tags so both formats share one schema.
+func printYAML(v interface{}) {
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	// Encoding plain structs, maps, and slices cannot fail.
+	_ = enc.Encode(v)
+	_ = enc.Close()
+	fmt.Print(b.String())
 }
@@ -0,0 +1,67 @@
+// Package cli — root_test.go contains unit tests for the global flag
+// handling defined on the root command.
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseOutputFlags builds a root command with a yaml-capable and a
+// text/json-only subcommand, parses args, and resolves the output format
+// for the selected subcommand.
+func parseOutputFlags(t *testing.T, args ...string) error {
+	t.Helper()
+
+	setOutputFormat(t, outputText)
+	origAlias := jsonAlias
+	t.Cleanup(func() { jsonAlias = origAlias })
+	jsonAlias = false
+
+	root := NewRootCommand()
+	root.AddCommand(
+		&cobra.Command{Use: "yamlcmd", Annotations: map[string]string{annotationYAMLOutput: "true"}},
+		&cobra.Command{Use: "plaincmd"},
+	)
+
+	cmd, rest, err := root.Find(args)
+	require.NoError(t, err)
+	require.NoError(t, cmd.ParseFlags(rest))
+	return resolveOutputFormat(cmd)
+}
+
+// TestResolveOutputFormat verifies --output validation and the deprecated
+// --json alias. Not parallel: the output format is a package-level global.
+func TestResolveOutputFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{name: "default is text", args: []string{"plaincmd"}, want: outputText},
+		{name: "json", args: []string{"plaincmd", "--output", "json"}, want: outputJSON},
+		{name: "shorthand and case-insensitive", args: []string{"yamlcmd", "-o", "YAML"}, want: outputYAML},
+		{name: "deprecated --json alias", args: []string{"plaincmd", "--json"}, want: outputJSON},
+		{name: "--json agrees with --output json", args: []string{"plaincmd", "--json", "-o", "json"}, want: outputJSON},
+		{name: "--json conflicts with --output yaml", args: []string{"yamlcmd", "--json", "-o", "yaml"}, wantErr: "conflicts"},
+		{name: "unknown format", args: []string{"plaincmd", "-o", "xml"}, wantErr: "invalid --output"},
+		{name: "yaml on unsupported command", args: []string{"plaincmd", "-o", "yaml"}, wantErr: "not supported by the plaincmd command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseOutputFlags(t, tt.args...)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, outputFormat)
+		})
+	}
+}
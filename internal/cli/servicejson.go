@@ -0,0 +1,41 @@
+// Package cli — servicejson.go defines how port allocations appear in
+// machine-readable output.
+//
+// create, start, and list all report an environment's services. They share
+// serviceJSON so that a consumer parsing more than one of them sees the same
+// fields for the same allocation.
+package cli
+
+import "github.com/mmr-tortoise/loam/internal/model"
+
+// serviceJSON is the JSON/YAML representation of one port allocation.
+type serviceJSON struct {
+	Name          string `json:"name" yaml:"name"`
+	ContainerPort int    `json:"containerPort" yaml:"containerPort"`
+	HostPort      int    `json:"hostPort" yaml:"hostPort"`
+	Protocol      string `json:"protocol" yaml:"protocol"`
+	Label         string `json:"label,omitempty" yaml:"label,omitempty"`
+	HostIP        string `json:"hostIp,omitempty" yaml:"hostIp,omitempty"`
+}
+
+// buildServicesJSON converts port allocations into their output form. It
+// never returns nil, so an environment without ports is rendered as []
+// rather than null. An empty protocol is reported as "tcp", its default.
+func buildServicesJSON(allocations []model.PortAllocation) []serviceJSON {
+	services := make([]serviceJSON, 0, len(allocations))
+	for _, pa := range allocations {
+		protocol := pa.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		services = append(services, serviceJSON{
+			Name:          pa.ServiceName,
+			ContainerPort: pa.ContainerPort,
+			HostPort:      pa.HostPort,
+			Protocol:      protocol,
+			Label:         pa.Label,
+			HostIP:        pa.HostIP,
+		})
+	}
+	return services
+}
@@ -0,0 +1,68 @@
+// Package cli — servicejson_test.go contains unit tests for the shared
+// service output of create, start, and list.
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// TestBuildServicesJSON verifies that every allocation field is carried
+// over, that an empty protocol is reported as tcp, and that optional fields
+// are omitted from the JSON when unset.
+func TestBuildServicesJSON(t *testing.T) {
+	assert.NotNil(t, buildServicesJSON(nil))
+
+	services := buildServicesJSON([]model.PortAllocation{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp", Label: "Web", HostIP: "127.0.0.1"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 10053},
+	})
+	require.Len(t, services, 2)
+	assert.Equal(t, serviceJSON{Name: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp", Label: "Web", HostIP: "127.0.0.1"}, services[0])
+	assert.Equal(t, "tcp", services[1].Protocol)
+
+	data, err := json.Marshal(services[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"dns","containerPort":53,"hostPort":10053,"protocol":"tcp"}`, string(data))
+}
+
+// TestServicesJSON_CreateListParity verifies that create --json and
+// list --json report the same services for the same environment.
+func TestServicesJSON_CreateListParity(t *testing.T) {
+	setJSONOutput(t, true)
+	env := &model.WorktreeEnv{
+		Name:          "feature-auth",
+		Branch:        "feature/auth",
+		WorktreePath:  "/tmp/feature-auth",
+		Status:        model.StatusRunning,
+		ConfigPattern: model.PatternImage,
+		PortAllocations: []model.PortAllocation{
+			{ServiceName: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp", Label: "Web", HostIP: "127.0.0.1"},
+			{ServiceName: "app", ContainerPort: 5353, HostPort: 15353, Protocol: "udp"},
+		},
+	}
+
+	var created struct {
+		Services []map[string]any `json:"services"`
+	}
+	out := captureStdout(t, func() { printCreateResultJSON(env) })
+	require.NoError(t, json.Unmarshal([]byte(out), &created))
+
+	var listed struct {
+		Services []map[string]any `json:"services"`
+	}
+	data, err := json.Marshal(buildListEnvJSON(env))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &listed))
+
+	require.Len(t, created.Services, 2)
+	assert.Equal(t, created.Services, listed.Services)
+	assert.Equal(t, "127.0.0.1", listed.Services[0]["hostIp"])
+	assert.Equal(t, "Web", listed.Services[0]["label"])
+	assert.NotContains(t, listed.Services[1], "hostIp")
+}
@@ -0,0 +1,95 @@
+// Package cli — shellinit.go implements "loam create --shell-init".
+//
+// A child process cannot change its parent shell's working directory, so
+// instead of printing the usual summary, --shell-init prints shell commands
+// for the caller to evaluate:
+//
+//	eval "$(loam create feature-x --shell-init)"
+//
+// The snippet changes into the new worktree and exports variables describing
+// the environment. The syntax is chosen from $SHELL: fish gets fish syntax,
+// every other shell gets POSIX sh syntax (which bash and zsh accept).
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// Supported --shell-init dialects.
+const (
+	shellPOSIX = "posix"
+	shellFish  = "fish"
+)
+
+// detectShellDialect maps the value of $SHELL (e.g., "/usr/bin/fish") to a
+// snippet dialect. Unknown or empty values fall back to POSIX.
+func detectShellDialect(shellPath string) string {
+	if filepath.Base(shellPath) == "fish" {
+		return shellFish
+	}
+	return shellPOSIX
+}
+
+// buildShellInit returns the eval-able snippet for env in the given dialect.
+//
+// Exported variables:
+//   - LOAM_ENV: environment name
+//   - LOAM_WORKTREE: absolute worktree path
+//   - LOAM_INDEX: worktree index (only when known)
+//   - LOAM_PORT_<containerPort>: allocated host port, one per allocation
+func buildShellInit(env *model.WorktreeEnv, dialect string) string {
+	vars := [][2]string{
+		{"LOAM_ENV", env.Name},
+		{"LOAM_WORKTREE", env.WorktreePath},
+	}
+	if env.Index != model.UnknownWorktreeIndex {
+		vars = append(vars, [2]string{"LOAM_INDEX", strconv.Itoa(env.Index)})
+	}
+
+	// Sort port variables by container port so the output is stable.
+	ports := append([]model.PortAllocation(nil), env.PortAllocations...)
+	sort.Slice(ports, func(i, j int) bool { return ports[i].ContainerPort < ports[j].ContainerPort })
+	for _, pa := range ports {
+		vars = append(vars, [2]string{
+			fmt.Sprintf("LOAM_PORT_%d", pa.ContainerPort),
+			strconv.Itoa(pa.HostPort),
+		})
+	}
+
+	var b strings.Builder
+	quote := quotePOSIX
+	if dialect == shellFish {
+		quote = quoteFish
+	}
+
+	fmt.Fprintf(&b, "cd %s\n", quote(env.WorktreePath))
+	for _, kv := range vars {
+		if dialect == shellFish {
+			fmt.Fprintf(&b, "set -gx %s %s\n", kv[0], quote(kv[1]))
+		} else {
+			fmt.Fprintf(&b, "export %s=%s\n", kv[0], quote(kv[1]))
+		}
+	}
+	return b.String()
+}
+
+// quotePOSIX wraps s in single quotes for POSIX shells. Inside single quotes
+// nothing is special except the quote itself, which is written by closing
+// the quote, adding an escaped quote (\'), and reopening the quote.
+func quotePOSIX(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFish wraps s in single quotes for fish, where backslash and the
+// single quote are escaped with a backslash inside single quotes.
+func quoteFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "'", `\'`)
+	return "'" + s + "'"
+}
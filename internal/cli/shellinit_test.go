@@ -0,0 +1,95 @@
+// Package cli — shellinit_test.go contains unit tests for the snippet
+// emitted by "loam create --shell-init".
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// shellInitTestEnv returns an environment with a path that needs quoting.
+func shellInitTestEnv() *model.WorktreeEnv {
+	return &model.WorktreeEnv{
+		Name:         "feature-x",
+		WorktreePath: "/home/dev/it's my repo-feature-x",
+		Index:        2,
+		PortAllocations: []model.PortAllocation{
+			{ContainerPort: 5432, HostPort: 25432},
+			{ContainerPort: 3000, HostPort: 23000},
+		},
+	}
+}
+
+// TestDetectShellDialect verifies $SHELL detection.
+func TestDetectShellDialect(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, shellFish, detectShellDialect("/usr/local/bin/fish"))
+	assert.Equal(t, shellPOSIX, detectShellDialect("/bin/bash"))
+	assert.Equal(t, shellPOSIX, detectShellDialect("/bin/zsh"))
+	assert.Equal(t, shellPOSIX, detectShellDialect(""), "unset $SHELL falls back to POSIX")
+}
+
+// TestBuildShellInit_POSIX verifies the exact POSIX snippet, including
+// quoting of a path containing a single quote and stable port ordering.
+func TestBuildShellInit_POSIX(t *testing.T) {
+	t.Parallel()
+
+	want := `cd '/home/dev/it'\''s my repo-feature-x'
+export LOAM_ENV='feature-x'
+export LOAM_WORKTREE='/home/dev/it'\''s my repo-feature-x'
+export LOAM_INDEX='2'
+export LOAM_PORT_3000='23000'
+export LOAM_PORT_5432='25432'
+`
+	assert.Equal(t, want, buildShellInit(shellInitTestEnv(), shellPOSIX))
+}
+
+// TestBuildShellInit_Fish verifies fish syntax and quoting.
+func TestBuildShellInit_Fish(t *testing.T) {
+	t.Parallel()
+
+	got := buildShellInit(shellInitTestEnv(), shellFish)
+	assert.True(t, strings.HasPrefix(got, `cd '/home/dev/it\'s my repo-feature-x'`+"\n"), got)
+	assert.Contains(t, got, "set -gx LOAM_ENV 'feature-x'\n")
+	assert.Contains(t, got, "set -gx LOAM_PORT_3000 '23000'\n")
+	assert.NotContains(t, got, "export ")
+}
+
+// TestBuildShellInit_UnknownIndex verifies LOAM_INDEX is omitted when the
+// index is unknown (e.g., worktree-only environments).
+func TestBuildShellInit_UnknownIndex(t *testing.T) {
+	t.Parallel()
+
+	env := &model.WorktreeEnv{Name: "docs", WorktreePath: "/tmp/docs", Index: model.UnknownWorktreeIndex}
+	got := buildShellInit(env, shellPOSIX)
+	assert.NotContains(t, got, "LOAM_INDEX")
+	assert.Equal(t, "cd '/tmp/docs'\nexport LOAM_ENV='docs'\nexport LOAM_WORKTREE='/tmp/docs'\n", got)
+}
+
+// TestBuildShellInit_EvalInSh verifies that the POSIX snippet actually
+// evaluates in sh and round-trips the quoted values.
+func TestBuildShellInit_EvalInSh(t *testing.T) {
+	t.Parallel()
+
+	shPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	env := shellInitTestEnv()
+	env.WorktreePath = t.TempDir() + "/it's here"
+	require.NoError(t, os.MkdirAll(env.WorktreePath, 0o755))
+
+	script := buildShellInit(env, shellPOSIX) + `printf '%s|%s' "$PWD" "$LOAM_PORT_3000"`
+	out, err := exec.Command(shPath, "-c", script).CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.True(t, strings.HasSuffix(string(out), "it's here|23000"), string(out))
+}
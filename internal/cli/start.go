@@ -15,7 +15,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 
@@ -25,11 +24,22 @@ import (
 	"github.com/mmr-tortoise/loam/internal/port"
 )
 
+// startFlags holds the flag values for the start command.
+// These are bound to cobra flags in NewStartCommand.
+type startFlags struct {
+	all  bool   // --all: start every stopped environment
+	repo string // --repo: with --all, only environments from this repository
+
+	composeFiles []string // --compose-file: extra Compose files applied after the recorded ones
+}
+
 // NewStartCommand creates the "start" cobra command.
 // It is called from NewRootCommand to register as a subcommand.
 func NewStartCommand() *cobra.Command {
+	flags := &startFlags{}
+
 	cmd := &cobra.Command{
-		Use:   "start <name>",
+		Use:   "start <name> | --all",
 		Short: "Start a stopped worktree environment",
 		Long: `Start all containers in a previously stopped worktree environment.
 
@@ -37,25 +47,47 @@ Before starting, the command verifies that all allocated host ports
 are still available. If any port conflict is detected, the command
 exits with code 4 and reports which ports are in use.
 
+With --all, every stopped environment is started concurrently. Failures
+(including port conflicts) are reported per environment and do not
+prevent the others from starting.
+
 Examples:
   loam start feature-auth
-  loam start --json feature-auth`,
+  loam start --output json feature-auth
+  loam start --all
+  loam start --all --repo ~/src/myproject
+  loam start --compose-file ./debug-tools.yml feature-auth`,
 
-		// Exactly one positional argument (environment name) is required.
-		Args: cobra.ExactArgs(1),
+		// Either one environment name or --all is required (validated in RunE).
+		Args: cobra.MaximumNArgs(1),
 
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStart(cmd.Context(), args[0])
+			if err := validateBulkArgs(args, flags.all, flags.repo); err != nil {
+				return err
+			}
+			extra, err := resolveExtraComposeFiles(flags.composeFiles)
+			if err != nil {
+				return err
+			}
+			if flags.all {
+				return runStartAll(cmd.Context(), flags.repo, extra)
+			}
+			return runStart(cmd.Context(), args[0], extra)
 		},
 	}
 
+	cmd.Flags().BoolVar(&flags.all, "all", false, "Start all stopped worktree environments")
+	cmd.Flags().StringVar(&flags.repo, "repo", "", "With --all, only start environments created from this repository")
+	cmd.Flags().StringArrayVar(&flags.composeFiles, "compose-file", nil,
+		"Extra Compose file applied after the environment's own files for Compose environments (repeatable)")
+
 	return cmd
 }
 
 // runStart is the main logic function for the start command.
 // It finds the named environment, checks port availability, and starts
-// all containers.
-func runStart(ctx context.Context, envName string) error {
+// all containers. extraComposeFiles are the resolved --compose-file paths.
+func runStart(ctx context.Context, envName string, extraComposeFiles []string) error {
 	// Step 1: Try to connect to Docker daemon.
 	// Docker may not be needed for PatternNone environments, so connection
 	// failure is deferred until we know the pattern.
@@ -114,6 +146,45 @@ func runStart(ctx context.Context, envName string) error {
 				envName, env.ConfigPattern), nil)
 	}
 
+	// Steps 3-4: Verify ports and start containers.
+	if err := startEnvironment(ctx, cli, env, containers, extraComposeFiles); err != nil {
+		return err
+	}
+
+	// Step 5: Output the result with service details.
+	printStartResult(env)
+	return nil
+}
+
+// runStartAll starts every stopped environment (optionally limited to one
+// repository) and reports per-environment results.
+func runStartAll(ctx context.Context, repo string, extraComposeFiles []string) error {
+	// Bulk discovery relies on container labels, so Docker is mandatory here.
+	cli, err := docker.NewClient()
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "Docker is required for --all but is not available", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	targets, err := listBulkTargets(ctx, cli, repo, model.StatusStopped)
+	if err != nil {
+		return err
+	}
+	VerboseLog("Starting %d stopped environment(s)...", len(targets))
+
+	results := runBulk(ctx, targets, bulkWorkers, func(ctx context.Context, t bulkTarget) error {
+		return startEnvironment(ctx, cli, t.env, t.containers, extraComposeFiles)
+	})
+	return printBulkResult("started", results)
+}
+
+// startEnvironment verifies port availability and starts the containers of a
+// single environment. It is shared by the single-environment and --all paths.
+// extraComposeFiles are applied after the environment's Compose files and
+// are ignored for non-Compose environments.
+func startEnvironment(ctx context.Context, cli *docker.Client, env *model.WorktreeEnv, containers []model.ContainerInfo, extraComposeFiles []string) error {
+	envName := env.Name
+
 	// Step 3: Verify port availability before starting.
 	// This prevents starting containers that would fail to bind ports or
 	// silently shadow other services already using those ports.
@@ -136,11 +207,12 @@ func runStart(ctx context.Context, envName string) error {
 		// Compose handles service dependency ordering and network creation.
 		VerboseLog("Starting Compose environment %q...", envName)
 
-		devcontainerDir := filepath.Join(env.WorktreePath, ".devcontainer")
+		devcontainerDir := envDevcontainerDir(env)
 		envVars := map[string]string{
-			"COMPOSE_PROJECT_NAME": envName,
+			"COMPOSE_PROJECT_NAME": env.ComposeProjectName(),
 		}
-		if err := docker.ComposeUp(ctx, devcontainerDir, nil, envVars); err != nil {
+		composeFiles := envComposeFiles(env, extraComposeFiles)
+		if err := docker.ComposeUp(ctx, devcontainerDir, composeFiles, envVars, docker.PullDefault); err != nil {
 			return model.WrapCLIError(model.ExitGeneralError,
 				fmt.Sprintf("failed to start environment %q", envName), err)
 		}
@@ -156,8 +228,6 @@ func runStart(ctx context.Context, envName string) error {
 		}
 	}
 
-	// Step 5: Output the result with service details.
-	printStartResult(env)
 	return nil
 }
 
@@ -172,12 +242,6 @@ func printStartResult(env *model.WorktreeEnv) {
 
 // printStartResultJSON outputs the start result as structured JSON.
 func printStartResultJSON(env *model.WorktreeEnv) {
-	type serviceJSON struct {
-		Name          string `json:"name"`
-		ContainerPort int    `json:"containerPort"`
-		HostPort      int    `json:"hostPort"`
-	}
-
 	type resultJSON struct {
 		Name     string        `json:"name"`
 		Action   string        `json:"action"`
@@ -187,15 +251,7 @@ func printStartResultJSON(env *model.WorktreeEnv) {
 	result := resultJSON{
 		Name:     env.Name,
 		Action:   "started",
-		Services: make([]serviceJSON, 0, len(env.PortAllocations)),
-	}
-
-	for _, pa := range env.PortAllocations {
-		result.Services = append(result.Services, serviceJSON{
-			Name:          pa.ServiceName,
-			ContainerPort: pa.ContainerPort,
-			HostPort:      pa.HostPort,
-		})
+		Services: buildServicesJSON(env.PortAllocations),
 	}
 
 	data, _ := json.MarshalIndent(result, "", "  ")
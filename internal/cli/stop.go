@@ -14,7 +14,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -24,11 +23,31 @@ import (
 	"github.com/mmr-tortoise/loam/internal/worktree"
 )
 
+// stopFlags holds the flag values for the stop command.
+// These are bound to cobra flags in NewStopCommand.
+type stopFlags struct {
+	all  bool   // --all: stop every running environment
+	repo string // --repo: with --all, only environments from this repository
+
+	timeout    int  // --timeout: seconds to wait before killing the containers
+	timeoutSet bool // true if --timeout was given; 0 is a valid timeout, so a sentinel won't do
+}
+
+// stopTimeout returns the --timeout value, or nil to keep Docker's default.
+func (f *stopFlags) stopTimeout() *int {
+	if !f.timeoutSet {
+		return nil
+	}
+	return &f.timeout
+}
+
 // NewStopCommand creates the "stop" cobra command.
 // It is called from NewRootCommand to register as a subcommand.
 func NewStopCommand() *cobra.Command {
+	flags := &stopFlags{}
+
 	cmd := &cobra.Command{
-		Use:   "stop <name>",
+		Use:   "stop <name> | --all",
 		Short: "Stop a worktree environment",
 		Long: `Stop all containers in the specified worktree environment.
 
@@ -36,25 +55,49 @@ The environment's containers are gracefully stopped but not removed.
 Data and configuration are preserved, and the environment can be
 restarted later with the "start" command.
 
+With --all, every running environment is stopped concurrently. Failures
+are reported per environment and do not prevent the others from stopping.
+
+--timeout sets how many seconds the containers get to shut down before
+they are killed (default: the container's stop timeout, usually 10).
+
 Examples:
   loam stop feature-auth
-  loam stop --json feature-auth`,
+  loam stop --output json feature-auth
+  loam stop --all
+  loam stop --all --repo .
+  loam stop --timeout 60 feature-auth`,
 
-		// Exactly one positional argument (environment name) is required.
-		Args: cobra.ExactArgs(1),
+		// Either one environment name or --all is required (validated in RunE).
+		Args: cobra.MaximumNArgs(1),
 
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStop(cmd.Context(), args[0])
+			if err := validateBulkArgs(args, flags.all, flags.repo); err != nil {
+				return err
+			}
+			flags.timeoutSet = cmd.Flags().Changed("timeout")
+			if flags.timeout < 0 {
+				return model.NewCLIError(model.ExitGeneralError, "--timeout must not be negative")
+			}
+			if flags.all {
+				return runStopAll(cmd.Context(), flags)
+			}
+			return runStop(cmd.Context(), args[0], flags)
 		},
 	}
 
+	cmd.Flags().BoolVar(&flags.all, "all", false, "Stop all running or starting worktree environments")
+	cmd.Flags().StringVar(&flags.repo, "repo", "", "With --all, only stop environments created from this repository")
+	cmd.Flags().IntVar(&flags.timeout, "timeout", 0,
+		"Seconds to wait for the containers to stop before killing them (default: Docker's, usually 10)")
+
 	return cmd
 }
 
 // runStop is the main logic function for the stop command.
 // It finds the named environment, determines the appropriate stop strategy
 // (Compose vs. individual containers), and executes the stop operation.
-func runStop(ctx context.Context, envName string) error {
+func runStop(ctx context.Context, envName string, flags *stopFlags) error {
 	// Step 1: Try to connect to Docker daemon.
 	// Docker may not be needed for PatternNone environments.
 	cli, err := docker.NewClient()
@@ -92,34 +135,95 @@ func runStop(ctx context.Context, envName string) error {
 	}
 
 	// Step 3: Stop containers based on the configuration pattern.
+	if err := stopEnvironment(ctx, cli, env, containers, flags.stopTimeout()); err != nil {
+		return err
+	}
+
+	// Step 4: Output the result.
+	printStopResult(envName, len(containers))
+	return nil
+}
+
+// runStopAll stops every running or starting environment (optionally limited to one
+// repository) and reports per-environment results.
+func runStopAll(ctx context.Context, flags *stopFlags) error {
+	// Bulk discovery relies on container labels, so Docker is mandatory here.
+	cli, err := docker.NewClient()
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "Docker is required for --all but is not available", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	// Environments still starting have containers up, so they are stopped too.
+	targets, err := listBulkTargets(ctx, cli, flags.repo, model.StatusRunning, model.StatusStarting)
+	if err != nil {
+		return err
+	}
+	VerboseLog("Stopping %d running environment(s)...", len(targets))
+
+	results := runBulk(ctx, targets, bulkWorkers, func(ctx context.Context, t bulkTarget) error {
+		return stopEnvironment(ctx, cli, t.env, t.containers, flags.stopTimeout())
+	})
+	return printBulkResult("stopped", results)
+}
+
+// stopEnvironment stops the containers of a single environment using the
+// strategy appropriate for its configuration pattern. It is shared by the
+// single-environment and --all code paths. timeout is the --timeout in
+// seconds, or nil for Docker's default.
+func stopEnvironment(ctx context.Context, cli *docker.Client, env *model.WorktreeEnv, containers []model.ContainerInfo, timeout *int) error {
+	if notice := shutdownActionNotice(env, "stopping"); notice != "" {
+		printWarning("%s", notice)
+	}
+
 	if env.ConfigPattern.IsCompose() {
 		// Pattern C/D: Use docker compose stop for coordinated shutdown.
-		// Compose handles service dependency ordering during stop.
-		VerboseLog("Stopping Compose environment %q...", envName)
-
-		// The devcontainer directory is at <worktreePath>/.devcontainer
-		devcontainerDir := filepath.Join(env.WorktreePath, ".devcontainer")
-		if err := docker.ComposeStop(ctx, devcontainerDir, nil); err != nil {
-			return model.WrapCLIError(model.ExitGeneralError,
-				fmt.Sprintf("failed to stop environment %q", envName), err)
+		// Compose handles service dependency ordering during stop. No
+		// services are named, so the whole project is stopped, as
+		// shutdownAction "stopCompose" (the Compose default) asks.
+		VerboseLog("Stopping Compose environment %q...", env.Name)
+
+		// <worktreePath>/.devcontainer, or the --config-dir of create.
+		devcontainerDir := envDevcontainerDir(env)
+		envVars := map[string]string{
+			"COMPOSE_PROJECT_NAME": env.ComposeProjectName(),
 		}
-	} else {
-		// Pattern A/B: Stop each container individually via Docker SDK.
-		VerboseLog("Stopping %d container(s) for environment %q...", len(containers), envName)
-		for _, c := range containers {
-			VerboseLog("Stopping container %s (%s)...", c.ContainerName, c.ContainerID[:12])
-			if err := docker.StopContainer(ctx, cli, c.ContainerID); err != nil {
-				return model.WrapCLIError(model.ExitGeneralError,
-					fmt.Sprintf("failed to stop container %q", c.ContainerName), err)
-			}
+		if err := docker.ComposeStop(ctx, devcontainerDir, envComposeFiles(env, nil), envVars, timeout); err != nil {
+			return model.WrapCLIError(model.ExitGeneralError,
+				fmt.Sprintf("failed to stop environment %q", env.Name), err)
 		}
+		return nil
 	}
 
-	// Step 4: Output the result.
-	printStopResult(envName, len(containers))
+	// Pattern A/B: Stop each container individually via Docker SDK.
+	VerboseLog("Stopping %d container(s) for environment %q...", len(containers), env.Name)
+	for _, c := range containers {
+		VerboseLog("Stopping container %s (%s)...", c.ContainerName, c.ContainerID[:12])
+		if err := docker.StopContainer(ctx, cli, c.ContainerID, timeout); err != nil {
+			return model.WrapCLIError(model.ExitGeneralError,
+				fmt.Sprintf("failed to stop container %q", c.ContainerName), err)
+		}
+	}
 	return nil
 }
 
+// shutdownActionNotice returns the warning to print before stopping or
+// removing env (verb is "stopping" or "removing") given its recorded
+// shutdownAction, or "" if there is nothing to point out. "none" means the
+// user did not want the container shut down automatically, which an
+// explicit command overrides; "stopCompose" only has a meaning for Compose
+// configurations.
+func shutdownActionNotice(env *model.WorktreeEnv, verb string) string {
+	switch {
+	case env.ShutdownAction == model.ShutdownActionNone:
+		return fmt.Sprintf("environment %q sets shutdownAction \"none\" (no automatic shutdown); %s it as requested", env.Name, verb)
+	case env.ShutdownAction == model.ShutdownActionStopCompose && !env.ConfigPattern.IsCompose():
+		return fmt.Sprintf("environment %q sets shutdownAction \"stopCompose\", which only applies to Compose configurations; ignored", env.Name)
+	default:
+		return ""
+	}
+}
+
 // printStopResult outputs the stop command result in text or JSON format.
 func printStopResult(envName string, containerCount int) {
 	if IsJSONOutput() {
@@ -230,42 +334,49 @@ func findEnvironmentFromMarker(envName string) (*model.WorktreeEnv, error) {
 		}
 
 		// Found a matching marker — build a WorktreeEnv from it.
-		createdAt, parseErr := time.Parse(time.RFC3339, marker.CreatedAt)
-		if parseErr != nil {
-			VerboseLog("Warning: could not parse createdAt %q in marker at %s: %v", marker.CreatedAt, wtPath, parseErr)
+		if env, ok := envFromMarker(marker, wtPath); ok {
+			return env, nil
 		}
+	}
 
-		// Use config pattern from marker directly (typed as model.ConfigPattern).
-		// Skip markers with invalid config patterns instead of silently falling
-		// back to PatternNone, which could mask data corruption.
-		configPattern := marker.ConfigPattern
-		if !configPattern.IsValid() {
-			VerboseLog("Warning: ignoring marker at %s for %q due to invalid configPattern %q", wtPath, envName, marker.ConfigPattern)
-			continue
-		}
+	return nil, nil
+}
 
-		// Determine status heuristically based on config pattern.
-		// Without Docker, we cannot know the actual container state, so:
-		// - PatternNone → StatusNoContainer (no containers exist)
-		// - Any other pattern → StatusStopped (best guess; containers may
-		//   actually be running or removed, but "stopped" is the safest
-		//   assumption for marker-only lookup without Docker).
-		status := model.StatusNoContainer
-		if configPattern != model.PatternNone {
-			status = model.StatusStopped
-		}
+// envFromMarker builds a WorktreeEnv from the marker file of the worktree at
+// wtPath. It returns false for a marker with an invalid config pattern,
+// which is skipped instead of silently falling back to PatternNone, as that
+// could mask data corruption.
+func envFromMarker(marker *worktree.MarkerFile, wtPath string) (*model.WorktreeEnv, bool) {
+	createdAt, parseErr := time.Parse(time.RFC3339, marker.CreatedAt)
+	if parseErr != nil {
+		VerboseLog("Warning: could not parse createdAt %q in marker at %s: %v", marker.CreatedAt, wtPath, parseErr)
+	}
 
-		env := &model.WorktreeEnv{
-			Name:           marker.Name,
-			Branch:         marker.Branch,
-			WorktreePath:   wtPath,
-			SourceRepoPath: marker.SourceRepoPath,
-			Status:         status,
-			ConfigPattern:  configPattern,
-			CreatedAt:      createdAt,
-		}
-		return env, nil
+	configPattern := marker.ConfigPattern
+	if !configPattern.IsValid() {
+		VerboseLog("Warning: ignoring marker at %s for %q due to invalid configPattern %q", wtPath, marker.Name, marker.ConfigPattern)
+		return nil, false
 	}
 
-	return nil, nil
+	// Determine status heuristically based on config pattern.
+	// Without Docker, we cannot know the actual container state, so:
+	// - PatternNone → StatusNoContainer (no containers exist)
+	// - Any other pattern → StatusStopped (best guess; containers may
+	//   actually be running or removed, but "stopped" is the safest
+	//   assumption for marker-only lookup without Docker).
+	status := model.StatusNoContainer
+	if configPattern != model.PatternNone {
+		status = model.StatusStopped
+	}
+
+	return &model.WorktreeEnv{
+		Name:           marker.Name,
+		Branch:         marker.Branch,
+		WorktreePath:   wtPath,
+		SourceRepoPath: marker.SourceRepoPath,
+		Status:         status,
+		ConfigPattern:  configPattern,
+		CreatedAt:      createdAt,
+		Index:          marker.WorktreeIndex(),
+	}, true
 }
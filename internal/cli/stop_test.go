@@ -0,0 +1,65 @@
+// Package cli — stop_test.go contains unit tests for the stop command's
+// handling of devcontainer.json's shutdownAction and of --timeout.
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// TestShutdownActionNotice verifies which recorded shutdownAction values
+// produce a warning before stop and remove.
+func TestShutdownActionNotice(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		action  string
+		pattern model.ConfigPattern
+		want    string
+	}{
+		{"unset", "", model.PatternComposeMulti, ""},
+		{"none", model.ShutdownActionNone, model.PatternImage, "no automatic shutdown"},
+		{"none on Compose", model.ShutdownActionNone, model.PatternComposeSingle, "no automatic shutdown"},
+		{"stopCompose on Compose", model.ShutdownActionStopCompose, model.PatternComposeMulti, ""},
+		{"stopCompose on image", model.ShutdownActionStopCompose, model.PatternImage, "only applies to Compose"},
+		{"stopContainer", model.ShutdownActionStopContainer, model.PatternDockerfile, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			env := &model.WorktreeEnv{Name: "feature-auth", ConfigPattern: tt.pattern, ShutdownAction: tt.action}
+			got := shutdownActionNotice(env, "stopping")
+			if tt.want == "" {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Contains(t, got, tt.want)
+			assert.Contains(t, got, `"feature-auth"`)
+		})
+	}
+}
+
+// TestStopTimeout verifies that --timeout is only passed on when given,
+// including an explicit 0, and that a negative value is rejected before
+// Docker is contacted.
+func TestStopTimeout(t *testing.T) {
+	assert.Nil(t, (&stopFlags{timeout: 0}).stopTimeout())
+
+	timeout := (&stopFlags{timeout: 0, timeoutSet: true}).stopTimeout()
+	require.NotNil(t, timeout)
+	assert.Equal(t, 0, *timeout)
+
+	cmd := NewStopCommand()
+	cmd.SetArgs([]string{"--timeout", "-5", "feature-auth"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	err := cmd.Execute()
+	requireExitCode(t, err, model.ExitGeneralError)
+	assert.Contains(t, err.Error(), "--timeout")
+}
@@ -0,0 +1,162 @@
+// Package cli — switch.go implements the "loam switch" command.
+//
+// switch resolves an environment name to its worktree path and prints it,
+// for use in shell functions and scripts:
+//
+//	cd "$(loam switch feature-auth)"
+//
+// With --shell-init it prints the same eval-able snippet as
+// "loam create --shell-init" (see shellinit.go), which changes into the
+// worktree and exports the environment's LOAM_* variables.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// switchFlags holds the flag values for the switch command.
+// These are bound to cobra flags in NewSwitchCommand.
+type switchFlags struct {
+	shellInit bool // --shell-init: print an eval-able cd/export snippet instead of the path
+}
+
+// NewSwitchCommand creates the "switch" cobra command.
+// It is called from NewRootCommand to register as a subcommand.
+func NewSwitchCommand() *cobra.Command {
+	flags := &switchFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "switch <name>",
+		Short: "Print the worktree path of an environment",
+		Long: `Print the worktree path of a worktree environment.
+
+The environment is looked up by name from container labels or marker
+files. If no environment has that name, it is taken as a branch name and
+the worktree that has the branch checked out is used.
+
+A command cannot change the directory of the shell that runs it, so use
+the output with cd, or pass --shell-init and evaluate the result.
+
+Examples:
+  cd "$(loam switch feature-auth)"
+  eval "$(loam switch --shell-init feature-auth)"
+  loam switch --output json feature-auth`,
+
+		// switch renders its result as JSON or YAML (see printYAML).
+		Annotations: map[string]string{annotationYAMLOutput: "true"},
+
+		Args: cobra.ExactArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSwitch(cmd.Context(), args[0], flags)
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.shellInit, "shell-init", false,
+		"Print shell commands (cd, exports) for eval instead of the path")
+
+	return cmd
+}
+
+// runSwitch is the main logic function for the switch command.
+func runSwitch(ctx context.Context, name string, flags *switchFlags) error {
+	if flags.shellInit && (IsJSONOutput() || IsYAMLOutput()) {
+		return model.NewCLIError(model.ExitGeneralError, "--shell-init cannot be used with --output json or yaml")
+	}
+
+	// Docker is optional: marker files and Git still resolve the path.
+	cli, err := docker.NewClient()
+	if err != nil {
+		VerboseLog("Warning: Docker not available: %v", err)
+		cli = nil
+	} else {
+		defer func() { _ = cli.Close() }()
+	}
+
+	env, err := resolveSwitchEnv(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+
+	printSwitchResult(env, flags.shellInit)
+	return nil
+}
+
+// resolveSwitchEnv finds the environment to switch to. name is looked up as
+// an environment name first (see findEnvironment). If there is no such
+// environment, it is taken as a branch name, and the worktree with that
+// branch checked out is returned as an environment with only Name, Branch,
+// and WorktreePath set. Anything else is ExitEnvNotFound.
+func resolveSwitchEnv(ctx context.Context, cli *docker.Client, name string) (*model.WorktreeEnv, error) {
+	env, _, err := findEnvironment(ctx, cli, name)
+	if err == nil {
+		return env, nil
+	}
+	var cliErr *model.CLIError
+	if !errors.As(err, &cliErr) || cliErr.Code != model.ExitEnvNotFound {
+		return nil, err
+	}
+
+	wm := worktree.NewManager()
+	cwd, cwdErr := os.Getwd()
+	if cwdErr != nil {
+		return nil, err
+	}
+	repoRoot, repoErr := wm.GetRepoRoot(cwd)
+	if repoErr != nil {
+		return nil, err
+	}
+	path, found, wtErr := wm.WorktreeForBranch(repoRoot, name)
+	if wtErr != nil || !found {
+		return nil, err
+	}
+
+	VerboseLog("No environment named %q; using the worktree of branch %q", name, name)
+	return &model.WorktreeEnv{
+		Name:         name,
+		Branch:       name,
+		WorktreePath: path,
+		Index:        model.UnknownWorktreeIndex,
+	}, nil
+}
+
+// printSwitchResult outputs the worktree path of env: the bare path as text,
+// an object in JSON or YAML, or an eval-able snippet when shellInit is set.
+func printSwitchResult(env *model.WorktreeEnv, shellInit bool) {
+	if shellInit {
+		fmt.Print(buildShellInit(env, detectShellDialect(os.Getenv("SHELL"))))
+		return
+	}
+
+	result := switchResultJSON{
+		Name:         env.Name,
+		Branch:       env.Branch,
+		WorktreePath: env.WorktreePath,
+	}
+	switch {
+	case IsJSONOutput():
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	case IsYAMLOutput():
+		printYAML(result)
+	default:
+		fmt.Println(env.WorktreePath)
+	}
+}
+
+// switchResultJSON is the JSON/YAML output of the switch command.
+type switchResultJSON struct {
+	Name         string `json:"name" yaml:"name"`
+	Branch       string `json:"branch" yaml:"branch"`
+	WorktreePath string `json:"worktreePath" yaml:"worktreePath"`
+}
@@ -0,0 +1,102 @@
+// Package cli — switch_test.go contains unit tests for "loam switch".
+// Lookups run without a Docker client, so environments are found through
+// marker files and branches through Git.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/worktree"
+)
+
+// TestResolveSwitchEnv verifies that an environment is found by name, that
+// an unmanaged worktree is found by its branch, and that unknown names fail
+// with ExitEnvNotFound. This test uses os.Chdir, so it must NOT use
+// t.Parallel().
+func TestResolveSwitchEnv(t *testing.T) {
+	setJSONOutput(t, false)
+
+	repoPath := setupTestRepo(t)
+	origDir, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(origDir) }()
+	require.NoError(t, os.Chdir(repoPath))
+
+	managedPath := filepath.Join(t.TempDir(), "managed")
+	captureStdout(t, func() {
+		require.NoError(t, runCreate(context.Background(), "feature/auth", &createFlags{path: managedPath, noStart: true}))
+	})
+
+	plainPath := filepath.Join(t.TempDir(), "plain")
+	require.NoError(t, worktree.NewManager().Add(repoPath, "feature/plain", plainPath, ""))
+
+	t.Run("environment name", func(t *testing.T) {
+		env, err := resolveSwitchEnv(context.Background(), nil, "feature-auth")
+		require.NoError(t, err)
+		assert.Equal(t, managedPath, env.WorktreePath)
+		assert.Equal(t, "feature/auth", env.Branch)
+	})
+
+	t.Run("branch name", func(t *testing.T) {
+		env, err := resolveSwitchEnv(context.Background(), nil, "feature/plain")
+		require.NoError(t, err)
+		resolved, err := filepath.EvalSymlinks(plainPath)
+		require.NoError(t, err)
+		got, err := filepath.EvalSymlinks(env.WorktreePath)
+		require.NoError(t, err)
+		assert.Equal(t, resolved, got)
+		assert.Equal(t, model.UnknownWorktreeIndex, env.Index)
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		_, err := resolveSwitchEnv(context.Background(), nil, "nope")
+		var cliErr *model.CLIError
+		require.True(t, errors.As(err, &cliErr))
+		assert.Equal(t, model.ExitEnvNotFound, cliErr.Code)
+	})
+}
+
+// TestPrintSwitchResult verifies the three output forms: the bare path, the
+// JSON object, and the eval-able cd snippet.
+func TestPrintSwitchResult(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:         "feature-auth",
+		Branch:       "feature/auth",
+		WorktreePath: "/home/dev/my project-feature-auth",
+		Index:        2,
+	}
+
+	setJSONOutput(t, false)
+	out := captureStdout(t, func() { printSwitchResult(env, false) })
+	assert.Equal(t, env.WorktreePath+"\n", out)
+
+	t.Setenv("SHELL", "/bin/bash")
+	out = captureStdout(t, func() { printSwitchResult(env, true) })
+	assert.True(t, strings.HasPrefix(out, "cd '/home/dev/my project-feature-auth'\n"), out)
+	assert.Contains(t, out, "export LOAM_INDEX='2'\n")
+
+	setJSONOutput(t, true)
+	out = captureStdout(t, func() { printSwitchResult(env, false) })
+	var result switchResultJSON
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, switchResultJSON{Name: "feature-auth", Branch: "feature/auth", WorktreePath: env.WorktreePath}, result)
+}
+
+// TestRunSwitch_ShellInitWithJSON verifies that --shell-init is rejected
+// with machine-readable output, like create --shell-init.
+func TestRunSwitch_ShellInitWithJSON(t *testing.T) {
+	setJSONOutput(t, true)
+	err := runSwitch(context.Background(), "feature-auth", &switchFlags{shellInit: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--shell-init")
+}
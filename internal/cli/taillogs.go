@@ -0,0 +1,107 @@
+// Package cli — taillogs.go implements "loam create --tail-on-start".
+//
+// After a successful start, --tail-on-start follows the logs of the
+// environment's primary container, so that watching the service boot does
+// not need a second command. Ctrl-C stops following and returns normally;
+// the containers keep running.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/mmr-tortoise/loam/internal/docker"
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// logFollower follows the logs of the primary container of an environment,
+// writing them to out, until interrupted. followEnvLogs is the production
+// implementation; tests substitute a fake.
+type logFollower func(ctx context.Context, envName, service string, out io.Writer) error
+
+// shouldTailLogs reports whether create follows logs after printing its
+// result. There is nothing to follow without started containers.
+func shouldTailLogs(flags *createFlags, pattern model.ConfigPattern) bool {
+	return flags.tailOnStart && !flags.noStart && pattern.RequiresDocker()
+}
+
+// tailLogsOutput returns where followed logs are written. With JSON or YAML
+// output, or --shell-init, stdout carries the machine-readable result and
+// the logs go to stderr instead.
+func tailLogsOutput(flags *createFlags) io.Writer {
+	if flags.shellInit || IsJSONOutput() || IsYAMLOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// tailCreatedEnv follows the logs of a newly created environment if
+// --tail-on-start applies (see shouldTailLogs). service is the primary
+// Compose service from devcontainer.json, if any.
+func tailCreatedEnv(ctx context.Context, env *model.WorktreeEnv, service string, flags *createFlags, follow logFollower) error {
+	if !shouldTailLogs(flags, env.ConfigPattern) {
+		if flags.tailOnStart {
+			VerboseLog("Not following logs: no containers were started")
+		}
+		return nil
+	}
+	return follow(ctx, env.Name, service, tailLogsOutput(flags))
+}
+
+// followEnvLogs finds the primary container of envName and follows its logs
+// until the container exits or the user presses Ctrl-C. An interrupt is not
+// an error: it is the normal way to stop following.
+func followEnvLogs(ctx context.Context, envName, service string, out io.Writer) error {
+	cli, err := docker.NewClient()
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "failed to connect to Docker", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	containers, err := docker.ListManagedContainers(ctx, cli)
+	if err != nil {
+		return model.WrapCLIError(model.ExitDockerNotRunning, "failed to list containers", err)
+	}
+	c, ok := primaryContainer(docker.GroupContainersByEnv(containers)[envName], service)
+	if !ok {
+		return model.NewCLIError(model.ExitEnvNotFound,
+			fmt.Sprintf("no containers found for environment %q to follow logs of", envName))
+	}
+
+	// Catch Ctrl-C so that it ends the log stream instead of the process.
+	// The terminal also delivers it to `docker logs`, which exits as well.
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Following logs of %s (Ctrl-C to stop; the containers keep running)...\n", c.ContainerName)
+	err = docker.FollowContainerLogs(sigCtx, c.ContainerID, out, out)
+	if sigCtx.Err() != nil && ctx.Err() == nil {
+		return nil
+	}
+	return err
+}
+
+// primaryContainer picks the container whose logs --tail-on-start follows:
+// the one running service (the devcontainer.json "service" for Compose
+// configurations), or else the first container by name. Pattern A/B
+// environments have a single container.
+func primaryContainer(containers []model.ContainerInfo, service string) (model.ContainerInfo, bool) {
+	if len(containers) == 0 {
+		return model.ContainerInfo{}, false
+	}
+	if service != "" {
+		for _, c := range containers {
+			if c.ServiceName == service {
+				return c, true
+			}
+		}
+	}
+
+	sorted := append([]model.ContainerInfo(nil), containers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ContainerName < sorted[j].ContainerName })
+	return sorted[0], true
+}
@@ -0,0 +1,78 @@
+// Package cli — taillogs_test.go contains unit tests for
+// "loam create --tail-on-start". A fake follower stands in for Docker.
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// TestTailCreatedEnv verifies that --tail-on-start triggers the log-follow
+// path with the environment and service, and that it is skipped without
+// the flag, with --no-start, and for worktree-only environments.
+func TestTailCreatedEnv(t *testing.T) {
+	setJSONOutput(t, false)
+
+	env := &model.WorktreeEnv{Name: "feature-auth", ConfigPattern: model.PatternComposeMulti}
+
+	var calls []string
+	follow := func(_ context.Context, envName, service string, out io.Writer) error {
+		calls = append(calls, envName+"/"+service)
+		assert.Equal(t, os.Stdout, out, "text output follows logs on stdout")
+		return nil
+	}
+
+	require.NoError(t, tailCreatedEnv(context.Background(), env, "app", &createFlags{tailOnStart: true}, follow))
+	assert.Equal(t, []string{"feature-auth/app"}, calls)
+
+	calls = nil
+	require.NoError(t, tailCreatedEnv(context.Background(), env, "app", &createFlags{}, follow))
+	require.NoError(t, tailCreatedEnv(context.Background(), env, "app", &createFlags{tailOnStart: true, noStart: true}, follow))
+	none := &model.WorktreeEnv{Name: "docs", ConfigPattern: model.PatternNone}
+	require.NoError(t, tailCreatedEnv(context.Background(), none, "", &createFlags{tailOnStart: true}, follow))
+	assert.Empty(t, calls, "logs must only be followed after containers were started")
+}
+
+// TestTailLogsOutput verifies that machine-readable output keeps logs off
+// stdout.
+func TestTailLogsOutput(t *testing.T) {
+	setJSONOutput(t, true)
+	assert.Equal(t, os.Stderr, tailLogsOutput(&createFlags{}))
+
+	setJSONOutput(t, false)
+	assert.Equal(t, os.Stderr, tailLogsOutput(&createFlags{shellInit: true}))
+	assert.Equal(t, os.Stdout, tailLogsOutput(&createFlags{}))
+}
+
+// TestPrimaryContainer verifies that the devcontainer.json service wins and
+// that the first container by name is the fallback.
+func TestPrimaryContainer(t *testing.T) {
+	t.Parallel()
+
+	containers := []model.ContainerInfo{
+		{ContainerID: "2", ContainerName: "feature-db-1", ServiceName: "db"},
+		{ContainerID: "1", ContainerName: "feature-app-1", ServiceName: "app"},
+	}
+
+	c, ok := primaryContainer(containers, "db")
+	require.True(t, ok)
+	assert.Equal(t, "2", c.ContainerID)
+
+	c, ok = primaryContainer(containers, "")
+	require.True(t, ok)
+	assert.Equal(t, "1", c.ContainerID, "first by name")
+
+	c, ok = primaryContainer(containers, "missing")
+	require.True(t, ok)
+	assert.Equal(t, "1", c.ContainerID)
+
+	_, ok = primaryContainer(nil, "app")
+	assert.False(t, ok)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tidwall/jsonc"
+)
+
+// FileName is the name of the project configuration file, looked up at the
+// root of the source repository.
+const FileName = ".loam.json"
+
+// ProjectConfig holds the settings of a .loam.json file. The zero value
+// means "no configuration": every field falls back to the built-in default.
+type ProjectConfig struct {
+	// WorktreePathTemplate is a text/template for the worktree directory
+	// used when create is run without --path, e.g.
+	// "~/worktrees/{{.Repo}}/{{.Branch}}". See worktree.RenderPathTemplate
+	// for the available fields. Empty means the default sibling directory
+	// "../<repo>-<name>". Environment variables are expanded (see Load).
+	WorktreePathTemplate string `json:"worktreePathTemplate,omitempty"`
+
+	// MaxEnvironments limits the number of concurrent environments (and
+	// thus worktree indices) for create. Zero means the built-in default
+	// (port.DefaultMaxEnvironments); create --max-environments overrides it.
+	MaxEnvironments int `json:"maxEnvironments,omitempty"`
+
+	// PostCreateHook is a host command run in the new worktree after a
+	// successful create; create --post-create overrides it. It is run by
+	// the shell, so unlike the other settings it is not expanded by Load:
+	// references to $WORKTREE_PORT and the like are left to the shell.
+	PostCreateHook string `json:"postCreateHook,omitempty"`
+}
+
+// Load reads the project configuration of the repository at repoRoot.
+// It returns an empty configuration if the file does not exist. Unknown
+// keys are rejected so that a misspelled setting does not go unnoticed.
+//
+// References to environment variables ($VAR or ${VAR}) in string settings
+// are expanded, so that a file shared by a team can point at per-user
+// locations; see expandEnv.
+func Load(repoRoot string) (*ProjectConfig, error) {
+	path := filepath.Join(repoRoot, FileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &ProjectConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	cfg := &ProjectConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonc.ToJSON(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	cfg.WorktreePathTemplate = expandEnv(cfg.WorktreePathTemplate)
+	return cfg, nil
+}
+
+// expandEnv replaces $VAR and ${VAR} in s with the value of the environment
+// variable, like os.ExpandEnv; unset variables expand to "". Unlike
+// os.ExpandEnv, "$$" stands for a literal "$", which is how a template
+// variable such as {{$x}} is written.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return os.Getenv(name)
+	})
+}
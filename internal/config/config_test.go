@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes content as the project configuration of a new
+// temporary repository root and returns the root.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0o644))
+	return dir
+}
+
+// TestLoad_Missing verifies that a repository without a configuration file
+// gets the zero configuration.
+func TestLoad_Missing(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, &ProjectConfig{}, cfg)
+}
+
+// TestLoad_JSONC verifies that comments and trailing commas are accepted.
+func TestLoad_JSONC(t *testing.T) {
+	t.Parallel()
+
+	dir := writeConfig(t, `{
+  // Keep worktrees out of the parent directory.
+  "worktreePathTemplate": "~/worktrees/{{.Repo}}/{{.Branch}}",
+  "maxEnvironments": 4,
+}`)
+
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "~/worktrees/{{.Repo}}/{{.Branch}}", cfg.WorktreePathTemplate)
+	assert.Equal(t, 4, cfg.MaxEnvironments)
+}
+
+// TestLoad_Invalid verifies that malformed files and unknown keys are
+// reported with the file path.
+func TestLoad_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for name, content := range map[string]string{
+		"malformed":   `{"worktreePathTemplate": `,
+		"unknown key": `{"worktreePathTemplte": "x"}`,
+		"wrong type":  `{"worktreePathTemplate": 3}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load(writeConfig(t, content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), FileName)
+		})
+	}
+}
+
+// TestLoad_ExpandEnv verifies that environment variables in the worktree
+// path template are expanded and that "$$" stays a literal "$", while the
+// post-create hook is left to the shell. This test uses t.Setenv, so it
+// must NOT use t.Parallel().
+func TestLoad_ExpandEnv(t *testing.T) {
+	t.Setenv("LOAM_TEST_WORKTREES", "/srv/worktrees")
+	t.Setenv("LOAM_TEST_USER", "alice")
+
+	dir := writeConfig(t, `{
+  "worktreePathTemplate": "${LOAM_TEST_WORKTREES}/$LOAM_TEST_USER/{{.Repo}}/{{.Branch}}",
+  "maxEnvironments": 4,
+  "postCreateHook": "open http://localhost:$WORKTREE_PORT/$LOAM_TEST_USER"
+}`)
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/worktrees/alice/{{.Repo}}/{{.Branch}}", cfg.WorktreePathTemplate)
+	assert.Equal(t, 4, cfg.MaxEnvironments)
+	assert.Equal(t, "open http://localhost:$WORKTREE_PORT/$LOAM_TEST_USER", cfg.PostCreateHook)
+}
+
+// TestExpandEnv covers literal dollars and unset variables.
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("LOAM_TEST_HOME", "/home/alice")
+	t.Setenv("LOAM_TEST_UNSET", "")
+
+	tests := []struct {
+		in, want string
+	}{
+		{"$LOAM_TEST_HOME/wt", "/home/alice/wt"},
+		{"${LOAM_TEST_HOME}-wt", "/home/alice-wt"},
+		{"cost$$5", "cost$5"},
+		{"{{$$x := .Repo}}{{$$x}}", "{{$x := .Repo}}{{$x}}"},
+		{"$$LOAM_TEST_HOME", "$LOAM_TEST_HOME"},
+		{"${LOAM_TEST_UNSET}/wt", "/wt"},
+		{"~/worktrees/{{.Repo}}", "~/worktrees/{{.Repo}}"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, expandEnv(tt.in), tt.in)
+	}
+}
@@ -0,0 +1,8 @@
+// Package config loads the optional per-repository loam configuration.
+//
+// The configuration lives in a .loam.json file at the root of the source
+// repository and holds team-wide defaults, so that every developer creates
+// environments the same way without repeating flags. The file accepts
+// JSONC (comments and trailing commas), like devcontainer.json. A missing
+// file is not an error; every setting has a built-in default.
+package config
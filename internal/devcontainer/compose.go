@@ -20,13 +20,18 @@ package devcontainer
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 
 	"github.com/mmr-tortoise/loam/internal/model"
-	"github.com/tidwall/jsonc"
 	"gopkg.in/yaml.v3"
 )
 
+// ComposeOverrideFileName is the name of the generated Compose override
+// file, written next to the rewritten devcontainer.json in the worktree.
+const ComposeOverrideFileName = "docker-compose.worktree.yml"
+
 // composeOverride represents the structure of the generated docker-compose
 // override YAML file. This struct is used for YAML serialization via the
 // yaml.v3 library.
@@ -35,6 +40,7 @@ import (
 //   - A top-level `name` that sets COMPOSE_PROJECT_NAME for isolation
 //   - Per-service port mappings with shifted host ports
 //   - Per-service worktree management labels
+//   - Worktree labels on the project's networks and volumes
 type composeOverride struct {
 	// Name sets the Compose project name. Docker Compose uses this to prefix
 	// container names, network names, and volume names, providing automatic
@@ -44,6 +50,22 @@ type composeOverride struct {
 	// Services maps service names to their override configurations.
 	// Each service gets its shifted ports and worktree labels.
 	Services map[string]composeServiceOverride `yaml:"services"`
+
+	// Networks labels the networks the project creates, and declares the
+	// external network joined with create --network.
+	Networks map[string]composeResourceOverride `yaml:"networks,omitempty"`
+
+	// Volumes labels the volumes the project creates.
+	Volumes map[string]composeResourceOverride `yaml:"volumes,omitempty"`
+}
+
+// composeResourceOverride is the override of a top-level network or volume:
+// either worktree labels for one the project creates, or `external: true`
+// for one managed outside the project (Compose neither creates nor removes
+// it, and rejects labels on it).
+type composeResourceOverride struct {
+	External bool              `yaml:"external,omitempty"`
+	Labels   map[string]string `yaml:"labels,omitempty"`
 }
 
 // composeServiceOverride represents the override configuration for a single
@@ -52,13 +74,45 @@ type composeOverride struct {
 type composeServiceOverride struct {
 	// Ports lists the port mappings in "hostPort:containerPort" format.
 	// This REPLACES the service's port list from the base Compose file.
-	// Only present for services that have port allocations.
-	Ports []string `yaml:"ports,omitempty"`
+	// Only present for services that have port allocations, or as an
+	// explicit reset when ports are disabled.
+	Ports composePorts `yaml:"ports,omitempty"`
 
 	// Labels contains worktree management labels applied to the service's
 	// containers. These labels enable container discovery and metadata
 	// reconstruction from Docker API queries.
 	Labels map[string]string `yaml:"labels"`
+
+	// Networks lists the networks the service joins when an external
+	// network is attached. "default" is listed first so that the services
+	// of the environment keep reaching each other by name.
+	Networks []string `yaml:"networks,omitempty"`
+
+	// User overrides the user the service's processes run as
+	// (create --container-user).
+	User string `yaml:"user,omitempty"`
+}
+
+// composePorts is the ports list of a service override. When reset is set it
+// marshals to `!reset []`, which tells Docker Compose (2.24.4 and later) to
+// drop the ports inherited from the base Compose file instead of merging.
+type composePorts struct {
+	mappings []string
+	reset    bool
+}
+
+// IsZero lets yaml.v3 omit the field (omitempty) when there is nothing to
+// publish and nothing to reset.
+func (p composePorts) IsZero() bool {
+	return !p.reset && len(p.mappings) == 0
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (p composePorts) MarshalYAML() (interface{}, error) {
+	if p.reset {
+		return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!reset", Style: yaml.FlowStyle}, nil
+	}
+	return p.mappings, nil
 }
 
 // GenerateComposeOverride creates a docker-compose override YAML that applies
@@ -77,13 +131,54 @@ type composeServiceOverride struct {
 //     to ensure every container in the environment can be discovered via labels.
 //
 // Parameters:
-//   - envName: the worktree environment name, used as the Compose project name
+//   - projectName: the Compose project name (the environment name unless
+//     overridden with create --project-name)
 //   - services: list of ALL service names defined in the Compose file(s)
 //   - portAllocations: the shifted port assignments for this worktree
 //   - labels: worktree management labels to apply to all services
 //
 // Returns the YAML bytes with a header comment, or an error if serialization fails.
-func GenerateComposeOverride(envName string, services []string, portAllocations []model.PortAllocation, labels map[string]string) ([]byte, error) {
+func GenerateComposeOverride(projectName string, services []string, portAllocations []model.PortAllocation, labels map[string]string) ([]byte, error) {
+	return GenerateComposeOverrideWithOptions(projectName, services, portAllocations, labels, ComposeOverrideOptions{})
+}
+
+// GenerateComposeOverrideWithoutPorts creates a Compose override that applies
+// the project name and labels but publishes no host ports (create --no-ports).
+// Every service's ports are reset, so ports declared in the base Compose file
+// are not published either.
+func GenerateComposeOverrideWithoutPorts(projectName string, services []string, labels map[string]string) ([]byte, error) {
+	return GenerateComposeOverrideWithOptions(projectName, services, nil, labels, ComposeOverrideOptions{ResetPorts: true})
+}
+
+// ComposeOverrideOptions holds the optional parts of a generated Compose
+// override. The zero value generates the same override as
+// GenerateComposeOverride.
+type ComposeOverrideOptions struct {
+	// ResetPorts clears the ports inherited from the base Compose file
+	// (create --no-ports); see GenerateComposeOverrideWithoutPorts.
+	ResetPorts bool
+
+	// ExternalNetwork is an existing Docker network that every service
+	// joins in addition to the project's default network (create
+	// --network). It is declared as external, so Compose neither creates
+	// nor removes it.
+	ExternalNetwork string
+
+	// Resources are the volumes and networks the project creates (see
+	// ParseComposeResources). Each is labeled with ResourceLabels, so that
+	// it can be found by label once its containers are gone. Nothing is
+	// labeled when ResourceLabels is empty.
+	Resources      ComposeResources
+	ResourceLabels map[string]string
+
+	// ServiceUsers sets the user of the named services (create
+	// --container-user, which applies to the primary service).
+	ServiceUsers map[string]string
+}
+
+// GenerateComposeOverrideWithOptions implements GenerateComposeOverride and
+// GenerateComposeOverrideWithoutPorts, and additionally applies opts.
+func GenerateComposeOverrideWithOptions(projectName string, services []string, portAllocations []model.PortAllocation, labels map[string]string, opts ComposeOverrideOptions) ([]byte, error) {
 	// Build a mapping from service name to its port allocations for quick lookup.
 	// A single service may have multiple port allocations (e.g., app → [3000, 8080]).
 	servicePorts := make(map[string][]model.PortAllocation)
@@ -93,7 +188,7 @@ func GenerateComposeOverride(envName string, services []string, portAllocations
 
 	// Build the override structure with all services.
 	override := composeOverride{
-		Name:     envName,
+		Name:     projectName,
 		Services: make(map[string]composeServiceOverride),
 	}
 
@@ -117,14 +212,39 @@ func GenerateComposeOverride(envName string, services []string, portAllocations
 		// Add port mappings if this service has any allocated ports.
 		if ports, ok := servicePorts[svc]; ok {
 			for _, pa := range ports {
-				// Use the standard Docker port mapping format: "hostPort:containerPort".
-				svcOverride.Ports = append(svcOverride.Ports, fmt.Sprintf("%d:%d", pa.HostPort, pa.ContainerPort))
+				// Use the standard Docker port mapping format:
+				// "hostPort:containerPort", with a "/udp" suffix for UDP.
+				mapping := fmt.Sprintf("%d:%d", pa.HostPort, pa.ContainerPort)
+				if pa.Protocol == "udp" {
+					mapping += "/udp"
+				}
+				svcOverride.Ports.mappings = append(svcOverride.Ports.mappings, mapping)
 			}
 		}
+		svcOverride.Ports.reset = opts.ResetPorts
+		svcOverride.User = opts.ServiceUsers[svc]
+		if opts.ExternalNetwork != "" {
+			svcOverride.Networks = []string{"default", opts.ExternalNetwork}
+		}
 
 		override.Services[svc] = svcOverride
 	}
 
+	// Empty maps are omitted from the YAML (omitempty).
+	override.Networks = make(map[string]composeResourceOverride)
+	override.Volumes = make(map[string]composeResourceOverride)
+	if len(opts.ResourceLabels) > 0 {
+		for _, name := range opts.Resources.Networks {
+			override.Networks[name] = composeResourceOverride{Labels: opts.ResourceLabels}
+		}
+		for _, name := range opts.Resources.Volumes {
+			override.Volumes[name] = composeResourceOverride{Labels: opts.ResourceLabels}
+		}
+	}
+	if opts.ExternalNetwork != "" {
+		override.Networks[opts.ExternalNetwork] = composeResourceOverride{External: true}
+	}
+
 	// Serialize to YAML with the yaml.v3 library.
 	yamlBytes, err := yaml.Marshal(&override)
 	if err != nil {
@@ -135,8 +255,8 @@ func GenerateComposeOverride(envName string, services []string, portAllocations
 	// against manual edits. This is important because the file is auto-generated
 	// and will be overwritten on each `create` or `start` command.
 	header := fmt.Sprintf(
-		"# Auto-generated by loam for environment %q\n# DO NOT EDIT - this file is regenerated on each create/start\n",
-		envName,
+		"# Auto-generated by loam for Compose project %q\n# DO NOT EDIT - this file is regenerated on each create/start\n",
+		projectName,
 	)
 
 	return []byte(header + string(yamlBytes)), nil
@@ -161,7 +281,8 @@ func WriteComposeOverride(outputPath string, data []byte) error {
 // RewriteComposeConfig takes the raw bytes of a devcontainer.json file (with
 // JSONC comments) and rewrites it for Compose patterns by:
 //  1. Updating the `name` field to the worktree environment name
-//  2. Appending the override YAML path to the `dockerComposeFile` array
+//  2. Replacing the `dockerComposeFile` entries with composeFiles, when given
+//  3. Appending the override YAML path to the `dockerComposeFile` array
 //
 // This function is used for Pattern C and D configurations. Unlike RewriteConfig
 // (for Pattern A/B), it does NOT modify runArgs, appPort, or portsAttributes,
@@ -174,23 +295,33 @@ func WriteComposeOverride(outputPath string, data []byte) error {
 // Parameters:
 //   - rawJSON: the original devcontainer.json file contents (may include JSONC comments)
 //   - envName: the worktree environment name
+//   - composeFiles: the Compose file paths to write, as returned by
+//     ResolveComposeFiles; nil keeps the original entries unchanged
 //   - overrideYAMLPath: the relative path to the generated override YAML file
 //     (relative to the devcontainer.json location, e.g., "docker-compose.worktree.yml")
 //
 // Returns the modified JSON bytes, or an error if parsing/serialization fails.
-func RewriteComposeConfig(rawJSON []byte, envName, overrideYAMLPath string) ([]byte, error) {
+func RewriteComposeConfig(rawJSON []byte, envName string, composeFiles []string, overrideYAMLPath string) ([]byte, error) {
 	// Strip JSONC comments and parse into a generic map.
 	// Same approach as RewriteConfig — we use a map to preserve unknown fields.
-	cleanJSON := jsonc.ToJSON(rawJSON)
-
 	var configMap map[string]interface{}
-	if err := json.Unmarshal(cleanJSON, &configMap); err != nil {
+	if err := unmarshalJSONC(rawJSON, &configMap); err != nil {
 		return nil, fmt.Errorf("failed to parse devcontainer.json for compose rewriting: %w", err)
 	}
 
 	// Update the container/environment name.
 	configMap["name"] = envName
 
+	// Replace the Compose file paths with the worktree-relative ones so that
+	// tools opening the worktree resolve the same files loam starts.
+	if composeFiles != nil {
+		files := make([]interface{}, 0, len(composeFiles))
+		for _, f := range composeFiles {
+			files = append(files, f)
+		}
+		configMap["dockerComposeFile"] = files
+	}
+
 	// Append the override YAML path to the dockerComposeFile array.
 	// The dockerComposeFile field can be either a string or an array of strings.
 	// We normalize it to an array and append the override path.
@@ -216,8 +347,10 @@ func RewriteComposeConfig(rawJSON []byte, envName, overrideYAMLPath string) ([]b
 //   - An array of strings: ["docker-compose.yml", "docker-compose.override.yml"]
 //
 // This function handles both cases and always returns an array.
-// If the override path is already in the array (e.g., from a previous run),
-// it is NOT added again to avoid duplicates.
+// If the override path is already in the array (e.g., when the input is a
+// devcontainer.json rewritten by a previous run), the existing entry is
+// dropped and the override is appended once, so it stays last. Entries are
+// compared as cleaned paths, so "./docker-compose.worktree.yml" matches too.
 func appendComposeFile(existing interface{}, overridePath string) []interface{} {
 	var files []interface{}
 
@@ -233,17 +366,94 @@ func appendComposeFile(existing interface{}, overridePath string) []interface{}
 		files = []interface{}{}
 	}
 
-	// Check if the override path is already present to prevent duplicates.
-	// This can happen if the user runs `create` multiple times on the same worktree.
+	// Drop earlier references to the override to prevent duplicates.
+	want := filepath.Clean(overridePath)
+	result := make([]interface{}, 0, len(files)+1)
 	for _, f := range files {
-		if s, ok := f.(string); ok && s == overridePath {
-			// Already present — return the array unchanged.
-			return files
+		if s, ok := f.(string); ok && filepath.Clean(s) == want {
+			continue
 		}
+		result = append(result, f)
 	}
 
 	// Append the override path as the last entry.
 	// Being last is important: Docker Compose processes files in order,
 	// and the override must come after the base file to take effect.
-	return append(files, overridePath)
+	return append(result, overridePath)
+}
+
+// ResolveComposeFiles maps the dockerComposeFile entries of the original
+// devcontainer.json onto the worktree, returning paths that are valid
+// relative to the copied .devcontainer directory (dstDir).
+//
+// The spec resolves Compose file paths relative to devcontainer.json, but
+// only the .devcontainer directory is copied into the worktree, so entries
+// that point elsewhere need attention:
+//   - Inside .devcontainer: returned relative to it (absolute paths are
+//     normalized to relative ones), since the file was copied along.
+//   - Elsewhere in the repository (e.g., "../docker-compose.yml"): the path
+//     is re-targeted at the same file in the worktree checkout. If the file is
+//     not there (e.g., it is untracked), it is copied from the main checkout.
+//     Existing files are never overwritten.
+//   - Outside the repository: returned as an absolute path to the original
+//     file, which is shared by all worktrees.
+func ResolveComposeFiles(srcDir, dstDir, repoRoot, worktreeRoot string, files []string) ([]string, error) {
+	resolved := make([]string, 0, len(files))
+
+	for _, file := range files {
+		srcPath := file
+		if !filepath.IsAbs(srcPath) {
+			srcPath = filepath.Join(srcDir, file)
+		}
+		srcPath = filepath.Clean(srcPath)
+
+		var dstPath string
+		switch {
+		case isWithinDir(srcPath, srcDir):
+			rel, err := filepath.Rel(srcDir, srcPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute relative path for %s: %w", file, err)
+			}
+			dstPath = filepath.Join(dstDir, rel)
+
+		case isWithinDir(srcPath, repoRoot):
+			rel, err := filepath.Rel(repoRoot, srcPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute relative path for %s: %w", file, err)
+			}
+			dstPath = filepath.Join(worktreeRoot, rel)
+			if err := copyComposeFileIfMissing(srcPath, dstPath); err != nil {
+				return nil, err
+			}
+
+		default:
+			resolved = append(resolved, srcPath)
+			continue
+		}
+
+		rel, err := filepath.Rel(dstDir, dstPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute relative path for %s: %w", file, err)
+		}
+		resolved = append(resolved, rel)
+	}
+
+	return resolved, nil
+}
+
+// copyComposeFileIfMissing copies a Compose file from the main checkout to
+// the worktree unless the worktree already has it (the usual case for
+// tracked files). A missing source is left for docker compose to report.
+func copyComposeFileIfMissing(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dst, err)
+	}
+	return copyFile(src, dst, info.Mode().Perm())
 }
@@ -2,6 +2,8 @@ package devcontainer
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -70,6 +72,26 @@ func TestGenerateComposeOverride_SingleService(t *testing.T) {
 		"name label should be present")
 }
 
+// TestGenerateComposeOverride_ProjectName verifies that a --project-name
+// override becomes the top-level name while labels keep the environment name.
+func TestGenerateComposeOverride_ProjectName(t *testing.T) {
+	labels := map[string]string{"loam.name": "feature-auth"}
+
+	result, err := GenerateComposeOverride("acme-auth", []string{"app"}, nil, labels)
+	require.NoError(t, err)
+
+	var override struct {
+		Name     string `yaml:"name"`
+		Services map[string]struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal(result, &override))
+	assert.Equal(t, "acme-auth", override.Name)
+	assert.Equal(t, "feature-auth", override.Services["app"].Labels["loam.name"])
+	assert.Contains(t, string(result), `Compose project "acme-auth"`)
+}
+
 // TestGenerateComposeOverride_MultiService verifies the Compose override YAML
 // for a multi-service configuration (Pattern D) with three services: app, db,
 // and redis. Each service should have its own port mappings and all services
@@ -217,6 +239,142 @@ func TestGenerateComposeOverride_ServiceWithoutPorts(t *testing.T) {
 		"worker service should still have labels")
 }
 
+// TestGenerateComposeOverride_UDPPorts verifies that UDP allocations are
+// published with a "/udp" suffix, while TCP mappings stay unsuffixed.
+func TestGenerateComposeOverride_UDPPorts(t *testing.T) {
+	portAllocations := []model.PortAllocation{
+		{ServiceName: "dns", ContainerPort: 8080, HostPort: 18080, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 10053, Protocol: "udp"},
+	}
+
+	result, err := GenerateComposeOverride("feature-dns", []string{"dns"}, portAllocations, nil)
+	require.NoError(t, err)
+
+	var override struct {
+		Services map[string]struct {
+			Ports []string `yaml:"ports"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal(result, &override))
+	assert.Equal(t, []string{"18080:8080", "10053:53/udp"}, override.Services["dns"].Ports)
+}
+
+// TestGenerateComposeOverride_ExternalNetwork verifies the create --network
+// case for Pattern C/D: the network is declared external at the top level,
+// and every service joins it alongside the project's default network.
+func TestGenerateComposeOverride_ExternalNetwork(t *testing.T) {
+	result, err := GenerateComposeOverrideWithOptions("feature-auth", []string{"app", "db"}, nil, nil,
+		ComposeOverrideOptions{ExternalNetwork: "shared-proxy"})
+	require.NoError(t, err)
+
+	var override struct {
+		Services map[string]struct {
+			Networks []string `yaml:"networks"`
+		} `yaml:"services"`
+		Networks map[string]struct {
+			External bool `yaml:"external"`
+		} `yaml:"networks"`
+	}
+	require.NoError(t, yaml.Unmarshal(result, &override))
+	assert.True(t, override.Networks["shared-proxy"].External)
+	assert.Equal(t, []string{"default", "shared-proxy"}, override.Services["app"].Networks)
+	assert.Equal(t, []string{"default", "shared-proxy"}, override.Services["db"].Networks)
+
+	// Without the option, no networks are emitted at all.
+	result, err = GenerateComposeOverride("feature-auth", []string{"app"}, nil, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, string(result), "networks")
+}
+
+// TestGenerateComposeOverride_ServiceUsers verifies the create
+// --container-user case for Pattern C/D: only the named service gets a
+// user, and no user is emitted without the option.
+func TestGenerateComposeOverride_ServiceUsers(t *testing.T) {
+	result, err := GenerateComposeOverrideWithOptions("feature-auth", []string{"app", "db"}, nil, nil,
+		ComposeOverrideOptions{ServiceUsers: map[string]string{"app": "1000:1000"}})
+	require.NoError(t, err)
+
+	var override struct {
+		Services map[string]struct {
+			User string `yaml:"user"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal(result, &override))
+	assert.Equal(t, "1000:1000", override.Services["app"].User)
+	assert.Empty(t, override.Services["db"].User)
+
+	result, err = GenerateComposeOverride("feature-auth", []string{"app"}, nil, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, string(result), "user:")
+}
+
+// TestGenerateComposeOverride_ResourceLabels verifies that the networks and
+// volumes created by the project carry the resource labels, so they can be
+// found with the managed-by filter, and that the external network joined
+// with create --network is declared without labels.
+func TestGenerateComposeOverride_ResourceLabels(t *testing.T) {
+	resourceLabels := map[string]string{
+		"loam.managed-by": "loam",
+		"loam.name":       "feature-auth",
+	}
+
+	result, err := GenerateComposeOverrideWithOptions("feature-auth", []string{"app"}, nil, nil, ComposeOverrideOptions{
+		ExternalNetwork: "shared-proxy",
+		Resources:       ComposeResources{Volumes: []string{"pgdata"}, Networks: []string{"backend", "default"}},
+		ResourceLabels:  resourceLabels,
+	})
+	require.NoError(t, err)
+
+	type resource struct {
+		External bool              `yaml:"external"`
+		Labels   map[string]string `yaml:"labels"`
+	}
+	var override struct {
+		Networks map[string]resource `yaml:"networks"`
+		Volumes  map[string]resource `yaml:"volumes"`
+	}
+	require.NoError(t, yaml.Unmarshal(result, &override))
+
+	assert.Equal(t, resourceLabels, override.Volumes["pgdata"].Labels)
+	assert.Equal(t, resourceLabels, override.Networks["default"].Labels)
+	assert.Equal(t, resourceLabels, override.Networks["backend"].Labels)
+	assert.Equal(t, resource{External: true}, override.Networks["shared-proxy"])
+
+	// Without resource labels, volumes are not mentioned at all.
+	result, err = GenerateComposeOverrideWithOptions("feature-auth", []string{"app"}, nil, nil, ComposeOverrideOptions{
+		Resources: ComposeResources{Volumes: []string{"pgdata"}, Networks: []string{"default"}},
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, string(result), "volumes")
+	assert.NotContains(t, string(result), "networks")
+}
+
+// TestGenerateComposeOverrideWithoutPorts verifies the create --no-ports case
+// for Pattern C/D: every service resets its inherited ports with `!reset []`
+// and publishes none, while labels and the project name are still applied.
+func TestGenerateComposeOverrideWithoutPorts(t *testing.T) {
+	labels := map[string]string{"loam.name": "backend-only"}
+
+	result, err := GenerateComposeOverrideWithoutPorts("backend-only", []string{"app", "db"}, labels)
+	require.NoError(t, err)
+
+	out := string(result)
+	assert.Equal(t, 2, strings.Count(out, "ports: !reset []"), "each service resets its ports:\n%s", out)
+	assert.NotRegexp(t, `\d+:\d+`, out, "no host port mapping may be emitted")
+
+	var override struct {
+		Name     string `yaml:"name"`
+		Services map[string]struct {
+			Ports  []string          `yaml:"ports"`
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal(result, &override))
+	assert.Equal(t, "backend-only", override.Name)
+	assert.Empty(t, override.Services["db"].Ports)
+	assert.Equal(t, "backend-only", override.Services["db"].Labels["loam.name"])
+}
+
 // --- RewriteComposeConfig tests ---
 
 // TestRewriteComposeConfig verifies that the devcontainer.json is correctly
@@ -233,7 +391,7 @@ func TestRewriteComposeConfig(t *testing.T) {
 	}`)
 
 	// Act
-	result, err := RewriteComposeConfig(rawJSON, "feature-compose", "docker-compose.worktree.yml")
+	result, err := RewriteComposeConfig(rawJSON, "feature-compose", nil, "docker-compose.worktree.yml")
 	require.NoError(t, err)
 
 	var resultMap map[string]interface{}
@@ -271,7 +429,7 @@ func TestRewriteComposeConfig_ArrayDockerComposeFile(t *testing.T) {
 		"service": "app"
 	}`)
 
-	result, err := RewriteComposeConfig(rawJSON, "multi-env", "docker-compose.worktree.yml")
+	result, err := RewriteComposeConfig(rawJSON, "multi-env", nil, "docker-compose.worktree.yml")
 	require.NoError(t, err)
 
 	var resultMap map[string]interface{}
@@ -298,7 +456,7 @@ func TestRewriteComposeConfig_DuplicateOverridePrevented(t *testing.T) {
 		"service": "app"
 	}`)
 
-	result, err := RewriteComposeConfig(rawJSON, "dup-test", "docker-compose.worktree.yml")
+	result, err := RewriteComposeConfig(rawJSON, "dup-test", nil, "docker-compose.worktree.yml")
 	require.NoError(t, err)
 
 	var resultMap map[string]interface{}
@@ -322,6 +480,23 @@ func TestRewriteComposeConfig_DuplicateOverridePrevented(t *testing.T) {
 		"override path should appear exactly once")
 }
 
+// TestRewriteComposeConfig_OverrideVariantsDeduplicated verifies that
+// override references spelled differently ("./...") or not in last place
+// are collapsed into a single, final entry.
+func TestRewriteComposeConfig_OverrideVariantsDeduplicated(t *testing.T) {
+	rawJSON := []byte(`{
+		"dockerComposeFile": ["./docker-compose.worktree.yml", "docker-compose.yml", "docker-compose.worktree.yml"],
+		"service": "app"
+	}`)
+
+	result, err := RewriteComposeConfig(rawJSON, "dup-test", nil, ComposeOverrideFileName)
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, []interface{}{"docker-compose.yml", ComposeOverrideFileName}, resultMap["dockerComposeFile"])
+}
+
 // TestRewriteComposeConfig_PreservesJSONCComments verifies that JSONC comments
 // in the input are stripped cleanly and the output is valid JSON.
 func TestRewriteComposeConfig_PreservesJSONCComments(t *testing.T) {
@@ -333,7 +508,7 @@ func TestRewriteComposeConfig_PreservesJSONCComments(t *testing.T) {
 		"service": "app"
 	}`)
 
-	result, err := RewriteComposeConfig(rawJSON, "jsonc-env", "docker-compose.worktree.yml")
+	result, err := RewriteComposeConfig(rawJSON, "jsonc-env", nil, "docker-compose.worktree.yml")
 	require.NoError(t, err)
 
 	// The output should be valid JSON (no comments).
@@ -343,3 +518,92 @@ func TestRewriteComposeConfig_PreservesJSONCComments(t *testing.T) {
 
 	assert.Equal(t, "jsonc-env", resultMap["name"])
 }
+
+// --- ResolveComposeFiles tests ---
+
+// TestResolveComposeFiles_ParentDirectory verifies that a config using
+// dockerComposeFile "../docker-compose.yml" (a Compose file at the repository
+// root) resolves to the worktree's copy, and that an untracked file is copied
+// over so `docker compose` can start it from the worktree.
+func TestResolveComposeFiles_ParentDirectory(t *testing.T) {
+	repoRoot := t.TempDir()
+	worktreeRoot := t.TempDir()
+	srcDir := filepath.Join(repoRoot, ".devcontainer")
+	dstDir := filepath.Join(worktreeRoot, ".devcontainer")
+	require.NoError(t, os.MkdirAll(srcDir, 0o755))
+	require.NoError(t, os.MkdirAll(dstDir, 0o755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "devcontainer.json"),
+		[]byte(`{"name": "app", "dockerComposeFile": "../docker-compose.yml", "service": "app"}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repoRoot, "docker-compose.yml"),
+		[]byte("services:\n  app:\n    image: alpine\n"), 0o644))
+
+	raw, err := LoadConfig(filepath.Join(srcDir, "devcontainer.json"))
+	require.NoError(t, err)
+
+	files, err := ResolveComposeFiles(srcDir, dstDir, repoRoot, worktreeRoot, GetComposeFiles(raw))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("..", "docker-compose.yml")}, files)
+
+	// The path must resolve from the worktree's .devcontainer, which is the
+	// directory docker compose runs in.
+	data, err := os.ReadFile(filepath.Join(dstDir, files[0]))
+	require.NoError(t, err, "the Compose file must exist in the worktree")
+	assert.Contains(t, string(data), "image: alpine")
+
+	// The rewritten devcontainer.json references the same files.
+	rawJSON, err := os.ReadFile(filepath.Join(srcDir, "devcontainer.json"))
+	require.NoError(t, err)
+	result, err := RewriteComposeConfig(rawJSON, "feature", files, "docker-compose.worktree.yml")
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, []interface{}{filepath.Join("..", "docker-compose.yml"), "docker-compose.worktree.yml"},
+		resultMap["dockerComposeFile"])
+}
+
+// TestResolveComposeFiles_KeepsWorktreeCopy verifies that a Compose file
+// already present in the worktree (a tracked file) is not overwritten with
+// the main checkout's version.
+func TestResolveComposeFiles_KeepsWorktreeCopy(t *testing.T) {
+	repoRoot := t.TempDir()
+	worktreeRoot := t.TempDir()
+	srcDir := filepath.Join(repoRoot, ".devcontainer")
+	dstDir := filepath.Join(worktreeRoot, ".devcontainer")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoRoot, "docker-compose.yml"), []byte("main"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(worktreeRoot, "docker-compose.yml"), []byte("branch"), 0o644))
+
+	_, err := ResolveComposeFiles(srcDir, dstDir, repoRoot, worktreeRoot, []string{"../docker-compose.yml"})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(worktreeRoot, "docker-compose.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "branch", string(data))
+}
+
+// TestResolveComposeFiles_AbsolutePaths verifies that absolute paths are
+// normalized: paths into the repository become worktree-relative, and paths
+// outside the repository are kept as-is.
+func TestResolveComposeFiles_AbsolutePaths(t *testing.T) {
+	repoRoot := t.TempDir()
+	worktreeRoot := t.TempDir()
+	sharedDir := t.TempDir()
+	srcDir := filepath.Join(repoRoot, ".devcontainer")
+	dstDir := filepath.Join(worktreeRoot, ".devcontainer")
+
+	files, err := ResolveComposeFiles(srcDir, dstDir, repoRoot, worktreeRoot, []string{
+		filepath.Join(srcDir, "docker-compose.yml"),
+		filepath.Join(repoRoot, "compose", "db.yml"),
+		filepath.Join(sharedDir, "shared.yml"),
+		"docker-compose.dev.yml",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"docker-compose.yml",
+		filepath.Join("..", "compose", "db.yml"),
+		filepath.Join(sharedDir, "shared.yml"),
+		"docker-compose.dev.yml",
+	}, files)
+}
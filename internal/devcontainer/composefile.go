@@ -0,0 +1,349 @@
+// composefile.go reads the Compose files referenced by devcontainer.json.
+//
+// devcontainer.json only lists the ports a developer wants forwarded, while
+// the Compose files declare what is actually published on the host. A port
+// published in a Compose file (e.g., "53:53/udp" for a DNS service) collides
+// across worktrees just like a forwarded one, so its service, container
+// port, and protocol are extracted here and fed into port allocation.
+package devcontainer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+)
+
+// ComposeService is the subset of a Compose service definition loam uses.
+type ComposeService struct {
+	// Name is the service name (the key under "services").
+	Name string
+
+	// Ports are the ports the service publishes on the host. Only entries
+	// with an explicit host port are included: container-only entries
+	// ("3000") get an ephemeral host port and cannot collide.
+	Ports []model.PortSpec
+
+	// DependsOn are the services this service depends on (depends_on),
+	// sorted by name.
+	DependsOn []string
+
+	// HostNetwork reports whether the service shares the host's network
+	// stack (network_mode: host). Its ports are then not mapped at all.
+	HostNetwork bool
+}
+
+// ServiceDependency is a depends_on entry: Service depends on DependsOn.
+type ServiceDependency struct {
+	Service   string
+	DependsOn string
+}
+
+// composeFile is the part of a Compose file that ParseComposeServices reads.
+// Ports entries are decoded loosely because they may be numbers, short
+// syntax strings ("8080:80/udp"), or long syntax mappings. depends_on is
+// likewise either a list of names or a mapping of names to conditions.
+type composeFile struct {
+	Services map[string]struct {
+		Ports       []interface{} `yaml:"ports"`
+		DependsOn   interface{}   `yaml:"depends_on"`
+		NetworkMode string        `yaml:"network_mode"`
+	} `yaml:"services"`
+
+	// Volumes and Networks are the top-level definitions, read by
+	// ParseComposeResources. A definition may be empty (null).
+	Volumes  map[string]interface{} `yaml:"volumes"`
+	Networks map[string]interface{} `yaml:"networks"`
+}
+
+// ComposeResources are the volumes and networks a Compose project creates.
+type ComposeResources struct {
+	// Volumes are the names of the top-level volumes that are not external.
+	Volumes []string
+
+	// Networks are the names of the top-level networks that are not
+	// external, including Compose's implicit "default" network unless the
+	// files declare it external.
+	Networks []string
+}
+
+// ParseComposeServices reads the given Compose files and returns their
+// services sorted by name. As in Compose, a service defined in several files
+// is merged: its published ports and dependencies are the union of all
+// files' entries, and the last file that sets network_mode wins.
+//
+// Port entries that cannot be interpreted statically — port ranges
+// ("8000-8005:8000-8005") and variable interpolation ("${PORT}:80") — are
+// skipped rather than reported, since Compose itself validates the files
+// when the environment starts.
+func ParseComposeServices(paths []string) ([]ComposeService, error) {
+	ports := make(map[string][]model.PortSpec)
+	deps := make(map[string]map[string]bool)
+	hostNetwork := make(map[string]bool)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Compose file: %w", err)
+		}
+
+		var file composeFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse Compose file %s: %w", path, err)
+		}
+
+		for name, svc := range file.Services {
+			if _, ok := ports[name]; !ok {
+				ports[name] = nil
+			}
+			for _, entry := range svc.Ports {
+				if ps := parseComposePort(entry, name); ps != nil {
+					ports[name] = append(ports[name], *ps)
+				}
+			}
+			if svc.NetworkMode != "" {
+				hostNetwork[name] = svc.NetworkMode == "host"
+			}
+			for _, dep := range composeDependsOn(svc.DependsOn) {
+				if deps[name] == nil {
+					deps[name] = make(map[string]bool)
+				}
+				deps[name][dep] = true
+			}
+		}
+	}
+
+	services := make([]ComposeService, 0, len(ports))
+	for name, specs := range ports {
+		var dependsOn []string
+		for dep := range deps[name] {
+			dependsOn = append(dependsOn, dep)
+		}
+		sort.Strings(dependsOn)
+		services = append(services, ComposeService{
+			Name:        name,
+			Ports:       dedupePortSpecs(specs),
+			DependsOn:   dependsOn,
+			HostNetwork: hostNetwork[name],
+		})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// composeDependsOn returns the service names of a depends_on value, which
+// is either a list ("depends_on: [db]") or a mapping with conditions
+// ("depends_on: {db: {condition: service_healthy}}").
+func composeDependsOn(v interface{}) []string {
+	var names []string
+	switch deps := v.(type) {
+	case []interface{}:
+		for _, d := range deps {
+			if name, ok := d.(string); ok {
+				names = append(names, name)
+			}
+		}
+	case map[string]interface{}:
+		for name := range deps {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// UnlistedDependencies returns the dependencies of the services in run on
+// services that are not in run, sorted by service and then dependency.
+//
+// Compose still starts such a dependency, but only the services in run are
+// configured by the worktree override, so the dependency runs without the
+// override's labels and port shifts, and may collide with another
+// worktree's. A dependency on a service not defined in any Compose file is
+// reported too; Compose refuses to start the project then.
+func UnlistedDependencies(services []ComposeService, run []string) []ServiceDependency {
+	inRun := make(map[string]bool, len(run))
+	for _, name := range run {
+		inRun[name] = true
+	}
+
+	var missing []ServiceDependency
+	for _, svc := range services {
+		if !inRun[svc.Name] {
+			continue
+		}
+		for _, dep := range svc.DependsOn {
+			if !inRun[dep] {
+				missing = append(missing, ServiceDependency{Service: svc.Name, DependsOn: dep})
+			}
+		}
+	}
+	return missing
+}
+
+// ParseComposeResources reads the given Compose files and returns the
+// volumes and networks the project creates, sorted by name. Resources
+// declared external are skipped: they are created and owned outside the
+// project, and Compose rejects other attributes on them.
+func ParseComposeResources(paths []string) (ComposeResources, error) {
+	// Both map a resource name to whether it is external.
+	volumes := make(map[string]bool)
+	networks := map[string]bool{"default": false}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ComposeResources{}, fmt.Errorf("failed to read Compose file: %w", err)
+		}
+
+		var file composeFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return ComposeResources{}, fmt.Errorf("failed to parse Compose file %s: %w", path, err)
+		}
+
+		// As with services, later files extend earlier ones, so a resource
+		// declared external anywhere stays external.
+		for name, def := range file.Volumes {
+			volumes[name] = volumes[name] || isExternalResource(def)
+		}
+		for name, def := range file.Networks {
+			networks[name] = networks[name] || isExternalResource(def)
+		}
+	}
+
+	return ComposeResources{Volumes: ownedResourceNames(volumes), Networks: ownedResourceNames(networks)}, nil
+}
+
+// isExternalResource reports whether a top-level volume or network
+// definition is external: "external: true", or the legacy
+// "external: {name: ...}" form.
+func isExternalResource(def interface{}) bool {
+	m, ok := def.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch v := m["external"].(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// ownedResourceNames returns the sorted names of the resources that are not
+// external.
+func ownedResourceNames(resources map[string]bool) []string {
+	var names []string
+	for name, external := range resources {
+		if !external {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseComposePort interprets one entry of a service's ports list. It
+// returns nil for entries without a published host port and for entries
+// that cannot be interpreted (see ParseComposeServices).
+func parseComposePort(entry interface{}, service string) *model.PortSpec {
+	switch v := entry.(type) {
+	case string:
+		return parseComposePortString(v, service)
+	case map[string]interface{}:
+		// Long syntax: {target: 80, published: 8080, protocol: udp}.
+		target, ok := composePortNumber(v["target"])
+		if !ok {
+			return nil
+		}
+		published, ok := composePortNumber(v["published"])
+		if !ok {
+			return nil
+		}
+		protocol, _ := v["protocol"].(string)
+		return newComposePortSpec(service, target, published, protocol)
+	default:
+		// A bare number is a container-only port.
+		return nil
+	}
+}
+
+// parseComposePortString parses the short syntax
+// "[[host_ip:]published:]target[/protocol]", e.g. "127.0.0.1:5432:5432" or
+// "53:53/udp". The host IP may be a bracketed IPv6 address.
+func parseComposePortString(s, service string) *model.PortSpec {
+	protocol := ""
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		s, protocol = s[:i], s[i+1:]
+	}
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil
+	}
+	target, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return nil
+	}
+
+	published := s[:i]
+	if j := strings.LastIndex(published, ":"); j >= 0 {
+		published = published[j+1:]
+	}
+	hostPort, err := strconv.Atoi(published)
+	if err != nil {
+		return nil
+	}
+	return newComposePortSpec(service, target, hostPort, protocol)
+}
+
+// composePortNumber converts a long syntax port value, which YAML decodes
+// as an int or, when quoted, a string.
+func composePortNumber(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case string:
+		port, err := strconv.Atoi(n)
+		return port, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// newComposePortSpec builds a PortSpec, rejecting out-of-range ports and
+// protocols other than tcp and udp (e.g., sctp, which loam cannot probe).
+func newComposePortSpec(service string, target, published int, protocol string) *model.PortSpec {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return nil
+	}
+	if target < 1 || target > 65535 || published < 1 || published > 65535 {
+		return nil
+	}
+	return &model.PortSpec{
+		ServiceName:   service,
+		ContainerPort: target,
+		HostPort:      published,
+		Protocol:      protocol,
+	}
+}
+
+// MergeComposePorts adds the ports published by Compose services to ports
+// extracted from devcontainer.json (see ExtractPorts). A port declared in
+// both places is returned once, keeping the devcontainer.json entry's
+// position and label.
+func MergeComposePorts(ports []model.PortSpec, services []ComposeService) []model.PortSpec {
+	merged := append([]model.PortSpec(nil), ports...)
+	for _, svc := range services {
+		merged = append(merged, svc.Ports...)
+	}
+	return dedupePortSpecs(merged)
+}
@@ -0,0 +1,284 @@
+package devcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeComposeFile writes content to name in dir and returns its path.
+func writeComposeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// TestParseComposeServices_TCPAndUDP verifies that a service publishing both
+// a tcp and a udp port yields a PortSpec for each, with the protocol kept.
+func TestParseComposeServices_TCPAndUDP(t *testing.T) {
+	t.Parallel()
+
+	path := writeComposeFile(t, t.TempDir(), "docker-compose.yml", `
+services:
+  dns:
+    image: coredns/coredns
+    ports:
+      - "8080:8080"
+      - "53:53/udp"
+  app:
+    image: node
+`)
+
+	services, err := ParseComposeServices([]string{path})
+	require.NoError(t, err)
+
+	require.Len(t, services, 2)
+	assert.Equal(t, "app", services[0].Name, "services are sorted by name")
+	assert.Empty(t, services[0].Ports)
+	assert.Equal(t, "dns", services[1].Name)
+	assert.Equal(t, []model.PortSpec{
+		{ServiceName: "dns", ContainerPort: 8080, HostPort: 8080, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 53, Protocol: "udp"},
+	}, services[1].Ports)
+}
+
+// TestParseComposeServices_PortSyntaxes verifies the short and long port
+// syntaxes, and that entries without a published port or that cannot be
+// interpreted statically are skipped.
+func TestParseComposeServices_PortSyntaxes(t *testing.T) {
+	t.Parallel()
+
+	path := writeComposeFile(t, t.TempDir(), "docker-compose.yml", `
+services:
+  app:
+    ports:
+      - "127.0.0.1:5433:5432"
+      - "[::1]:6380:6379"
+      - 3000
+      - "9229"
+      - "8000-8005:8000-8005"
+      - "${WEB_PORT}:80"
+      - "7000:7000/sctp"
+      - target: 1812
+        published: "11812"
+        protocol: udp
+      - target: 4000
+`)
+
+	services, err := ParseComposeServices([]string{path})
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	assert.Equal(t, []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 5432, HostPort: 5433, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 6379, HostPort: 6380, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 1812, HostPort: 11812, Protocol: "udp"},
+	}, services[0].Ports)
+}
+
+// TestParseComposeServices_MultipleFiles verifies that a service defined in
+// several files gets the union of their ports, without duplicates.
+func TestParseComposeServices_MultipleFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := writeComposeFile(t, dir, "docker-compose.yml", `
+services:
+  app:
+    ports: ["3000:3000"]
+`)
+	extra := writeComposeFile(t, dir, "docker-compose.dev.yml", `
+services:
+  app:
+    ports: ["3000:3000", "9229:9229"]
+  db:
+    ports: ["5432:5432"]
+`)
+
+	services, err := ParseComposeServices([]string{base, extra})
+	require.NoError(t, err)
+	require.Len(t, services, 2)
+	assert.Len(t, services[0].Ports, 2, "app: 3000 once, plus 9229")
+	assert.Equal(t, "db", services[1].Name)
+}
+
+// TestParseComposeServices_DependsOn verifies that both depends_on forms
+// are read and merged across files.
+func TestParseComposeServices_DependsOn(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := writeComposeFile(t, dir, "docker-compose.yml", `
+services:
+  app:
+    depends_on: [db]
+  db: {}
+  cache: {}
+`)
+	extra := writeComposeFile(t, dir, "docker-compose.dev.yml", `
+services:
+  app:
+    depends_on:
+      cache:
+        condition: service_started
+      db:
+        condition: service_healthy
+`)
+
+	services, err := ParseComposeServices([]string{base, extra})
+	require.NoError(t, err)
+	require.Len(t, services, 3)
+	assert.Equal(t, "app", services[0].Name)
+	assert.Equal(t, []string{"cache", "db"}, services[0].DependsOn)
+	assert.Empty(t, services[2].DependsOn)
+}
+
+// TestParseComposeServices_HostNetwork verifies that network_mode: host is
+// reported per service and that a later file's network_mode wins.
+func TestParseComposeServices_HostNetwork(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := writeComposeFile(t, dir, "docker-compose.yml", `
+services:
+  app:
+    image: node
+    network_mode: host
+  db:
+    image: postgres
+    network_mode: host
+  cache:
+    image: redis
+    ports:
+      - "6379:6379"
+`)
+	override := writeComposeFile(t, dir, "docker-compose.override.yml", `
+services:
+  db:
+    network_mode: bridge
+`)
+
+	services, err := ParseComposeServices([]string{base, override})
+	require.NoError(t, err)
+
+	hostNetwork := make(map[string]bool)
+	for _, svc := range services {
+		hostNetwork[svc.Name] = svc.HostNetwork
+	}
+	assert.Equal(t, map[string]bool{"app": true, "cache": false, "db": false}, hostNetwork)
+}
+
+// TestUnlistedDependencies verifies that a dependency outside the run set
+// is reported, including one on an undefined service, while dependencies
+// of services outside the run set and within it are not.
+func TestUnlistedDependencies(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := writeComposeFile(t, dir, "docker-compose.yml", `
+services:
+  app:
+    depends_on: [db, queue]
+  worker:
+    depends_on: [db]
+  db: {}
+  web:
+    depends_on: [app]
+`)
+	services, err := ParseComposeServices([]string{path})
+	require.NoError(t, err)
+
+	assert.Equal(t, []ServiceDependency{
+		{Service: "app", DependsOn: "db"},
+		{Service: "app", DependsOn: "queue"},
+	}, UnlistedDependencies(services, []string{"app", "web"}))
+
+	assert.Equal(t, []ServiceDependency{{Service: "app", DependsOn: "queue"}},
+		UnlistedDependencies(services, []string{"app", "db"}))
+}
+
+// TestParseComposeServices_Errors verifies that missing and malformed files
+// are reported.
+func TestParseComposeServices_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	_, err := ParseComposeServices([]string{filepath.Join(dir, "missing.yml")})
+	assert.Error(t, err)
+
+	bad := writeComposeFile(t, dir, "bad.yml", "services: [")
+	_, err = ParseComposeServices([]string{bad})
+	assert.Error(t, err)
+}
+
+// TestParseComposeResources verifies that the volumes and networks created
+// by the project are returned across files, that external ones are skipped
+// in both syntaxes, and that the implicit default network is included.
+func TestParseComposeResources(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := writeComposeFile(t, dir, "docker-compose.yml", `
+services:
+  db:
+    image: postgres
+volumes:
+  pgdata:
+  cache: {}
+  shared:
+    external: true
+networks:
+  backend:
+  proxy:
+    external:
+      name: traefik
+`)
+	extra := writeComposeFile(t, dir, "docker-compose.extra.yml", `
+volumes:
+  cache:
+    external: true
+  logs:
+`)
+
+	resources, err := ParseComposeResources([]string{base, extra})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logs", "pgdata"}, resources.Volumes, "cache becomes external in the second file")
+	assert.Equal(t, []string{"backend", "default"}, resources.Networks)
+
+	// A default network declared external is not the project's to label.
+	external := writeComposeFile(t, dir, "docker-compose.net.yml", `
+networks:
+  default:
+    external: true
+`)
+	resources, err = ParseComposeResources([]string{external})
+	require.NoError(t, err)
+	assert.Empty(t, resources.Networks)
+	assert.Empty(t, resources.Volumes)
+}
+
+// TestMergeComposePorts verifies that Compose ports are appended to the
+// devcontainer.json ports and that a port declared in both is kept once,
+// with its devcontainer.json label.
+func TestMergeComposePorts(t *testing.T) {
+	t.Parallel()
+
+	ports := []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp", Label: "Web"},
+	}
+	services := []ComposeService{
+		{Name: "app", Ports: []model.PortSpec{{ServiceName: "app", ContainerPort: 3000, HostPort: 3000, Protocol: "tcp"}}},
+		{Name: "dns", Ports: []model.PortSpec{{ServiceName: "dns", ContainerPort: 53, HostPort: 53, Protocol: "udp"}}},
+	}
+
+	merged := MergeComposePorts(ports, services)
+	assert.Equal(t, []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 3000, Protocol: "tcp", Label: "Web"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 53, Protocol: "udp"},
+	}, merged)
+	assert.Len(t, ports, 1, "the input slice must not be modified")
+}
@@ -12,7 +12,6 @@
 package devcontainer
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -20,7 +19,6 @@ import (
 	"strings"
 
 	"github.com/mmr-tortoise/loam/internal/model"
-	"github.com/tidwall/jsonc"
 )
 
 // RawDevContainer represents the raw JSON structure of a devcontainer.json file.
@@ -106,15 +104,26 @@ type PortAttribute struct {
 
 	// OnAutoForward controls the IDE's behavior when the port is detected.
 	// Common values: "notify", "openBrowser", "silent", "ignore".
+	// ExtractPorts skips ports set to "ignore".
 	OnAutoForward string `json:"onAutoForward,omitempty"`
+
+	// RequireLocalPort asks for the port to be forwarded to the same port
+	// number on the host. ExtractPorts marks such ports, and create pins
+	// them instead of shifting them.
+	RequireLocalPort bool `json:"requireLocalPort,omitempty"`
 }
 
+// onAutoForwardIgnore is the PortAttribute.OnAutoForward value that keeps a
+// port from being forwarded at all.
+const onAutoForwardIgnore = "ignore"
+
 // LoadConfig reads a devcontainer.json file, strips JSONC comments, and
 // parses it into a RawDevContainer struct.
 //
 // The function uses github.com/tidwall/jsonc to handle JSONC (JSON with
 // Comments) format, which is common in devcontainer.json files. After
-// stripping comments, it uses the standard encoding/json for parsing.
+// stripping comments and a leading UTF-8 byte order mark, it uses the
+// standard encoding/json for parsing (see unmarshalJSONC).
 //
 // Returns a CLIError with ExitDevContainerNotFound if the file does not exist.
 func LoadConfig(devcontainerPath string) (*RawDevContainer, error) {
@@ -132,16 +141,15 @@ func LoadConfig(devcontainerPath string) (*RawDevContainer, error) {
 		return nil, fmt.Errorf("failed to read devcontainer.json: %w", err)
 	}
 
-	// Strip JSONC comments (// and /* */) and trailing commas before parsing.
-	// The devcontainer.json spec officially supports JSONC, so real-world
-	// files frequently contain comments.
-	cleanJSON := jsonc.ToJSON(data)
-
-	// Parse the cleaned JSON into our struct. encoding/json silently ignores
-	// fields not defined in the struct, which is the desired behavior since
-	// we only care about a subset of devcontainer.json fields.
+	// Strip a byte order mark, JSONC comments (// and /* */), and trailing
+	// commas before parsing. The devcontainer.json spec officially supports
+	// JSONC, so real-world files frequently contain comments.
+	//
+	// encoding/json silently ignores fields not defined in the struct, which
+	// is the desired behavior since we only care about a subset of
+	// devcontainer.json fields.
 	var raw RawDevContainer
-	if err := json.Unmarshal(cleanJSON, &raw); err != nil {
+	if err := unmarshalJSONC(data, &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse devcontainer.json at %s: %w", devcontainerPath, err)
 	}
 
@@ -190,13 +198,20 @@ func DetectPattern(raw *RawDevContainer, composeServiceCount int) model.ConfigPa
 //
 // Port sources in devcontainer.json:
 //   - forwardPorts: array of int or "service:port" strings
-//   - appPort: string "host:container", int, or array of these
-//   - portsAttributes: only provides metadata (labels), not port definitions
+//   - appPort: string "host:container" (either side may be a range such
+//     as "3000-3005"), int, or array of these
+//   - portsAttributes: only provides metadata, not port definitions: labels,
+//     requireLocalPort, and onAutoForward "ignore", which drops the port so
+//     that it does not take up a host port
 //
 // The defaultServiceName parameter is used as the ServiceName for ports
 // that don't specify a service (e.g., plain integers in forwardPorts).
 // For Compose patterns, this is typically the primary service name.
-func ExtractPorts(raw *RawDevContainer, defaultServiceName string) []model.PortSpec {
+//
+// A port listed in more than one field (e.g., 3000 in both forwardPorts and
+// appPort) is returned once; see dedupePortSpecs. appPort ranges are
+// expanded into single ports; a malformed range is an error.
+func ExtractPorts(raw *RawDevContainer, defaultServiceName string) ([]model.PortSpec, error) {
 	var ports []model.PortSpec
 
 	// Step 1: Parse forwardPorts.
@@ -228,21 +243,72 @@ func ExtractPorts(raw *RawDevContainer, defaultServiceName string) []model.PortS
 	//   - Single int: just the container port
 	//   - Single string: "hostPort:containerPort"
 	//   - Array of ints or strings
-	ports = append(ports, parseAppPort(raw.AppPort, defaultServiceName)...)
+	appPorts, err := parseAppPort(raw.AppPort, defaultServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ports = append(ports, appPorts...)
 
-	// Step 3: Enrich ports with labels from portsAttributes.
-	// portsAttributes is keyed by port number (as string) and provides
-	// display metadata. We match each port's ContainerPort against the keys.
+	// Step 3: Enrich ports with metadata from portsAttributes.
+	// portsAttributes is keyed by port number (as string). We match each
+	// port's ContainerPort against the keys; ignored ports are dropped.
 	if raw.PortsAttributes != nil {
-		for i := range ports {
-			portKey := strconv.Itoa(ports[i].ContainerPort)
-			if attr, ok := raw.PortsAttributes[portKey]; ok {
-				ports[i].Label = attr.Label
+		kept := ports[:0]
+		for _, ps := range ports {
+			attr, ok := raw.PortsAttributes[strconv.Itoa(ps.ContainerPort)]
+			if ok && attr.OnAutoForward == onAutoForwardIgnore {
+				continue
+			}
+			if ok {
+				ps.Label = attr.Label
+				ps.RequireLocalPort = attr.RequireLocalPort
 			}
+			kept = append(kept, ps)
+		}
+		ports = kept
+	}
+
+	// Step 4: Collapse duplicates so each port gets a single allocation.
+	return dedupePortSpecs(ports), nil
+}
+
+// dedupePortSpecs merges PortSpecs that share the same service, container
+// port, and protocol, keeping the first occurrence's position. Metadata is
+// combined so the richer entry wins: a non-zero HostPort (from an appPort
+// "host:container" mapping) or a non-empty Label fills in a missing one,
+// and RequireLocalPort is kept if any entry sets it.
+func dedupePortSpecs(ports []model.PortSpec) []model.PortSpec {
+	type portKey struct {
+		service       string
+		containerPort int
+		protocol      string
+	}
+
+	result := make([]model.PortSpec, 0, len(ports))
+	index := make(map[portKey]int, len(ports))
+
+	for _, ps := range ports {
+		key := portKey{service: ps.ServiceName, containerPort: ps.ContainerPort, protocol: ps.Protocol}
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(result)
+			result = append(result, ps)
+			continue
+		}
+
+		if result[i].HostPort == 0 {
+			result[i].HostPort = ps.HostPort
 		}
+		if result[i].Label == "" {
+			result[i].Label = ps.Label
+		}
+		if result[i].HostIP == "" {
+			result[i].HostIP = ps.HostIP
+		}
+		result[i].RequireLocalPort = result[i].RequireLocalPort || ps.RequireLocalPort
 	}
 
-	return ports
+	return result
 }
 
 // parseServicePort parses a "service:port" string into a PortSpec.
@@ -276,15 +342,23 @@ func parseServicePort(s, defaultServiceName string) *model.PortSpec {
 	}
 }
 
+// maxAppPortRange is the largest number of ports an appPort range may
+// span. Each port of a range is allocated on its own, so a typo such as
+// "3000-30000" would otherwise claim thousands of host ports.
+const maxAppPortRange = 100
+
 // parseAppPort handles the various formats of the appPort field.
 // appPort can be:
 //   - nil: no ports defined
 //   - float64: a single container port number (JSON number → float64 in interface{})
-//   - string: "hostPort:containerPort" mapping
+//   - string: "hostPort:containerPort" or "ip:hostPort:containerPort" mapping
 //   - []interface{}: an array of the above types
-func parseAppPort(appPort interface{}, defaultServiceName string) []model.PortSpec {
+//
+// Malformed entries are skipped, except for malformed port ranges, which
+// are reported (see parseAppPortString).
+func parseAppPort(appPort interface{}, defaultServiceName string) ([]model.PortSpec, error) {
 	if appPort == nil {
-		return nil
+		return nil, nil
 	}
 
 	var ports []model.PortSpec
@@ -299,10 +373,11 @@ func parseAppPort(appPort interface{}, defaultServiceName string) []model.PortSp
 		})
 	case string:
 		// Single "hostPort:containerPort" string.
-		ps := parseAppPortString(v, defaultServiceName)
-		if ps != nil {
-			ports = append(ports, *ps)
+		specs, err := parseAppPortString(v, defaultServiceName)
+		if err != nil {
+			return nil, err
 		}
+		ports = append(ports, specs...)
 	case []interface{}:
 		// Array of ports — each element can be a number or a string.
 		for _, item := range v {
@@ -314,50 +389,106 @@ func parseAppPort(appPort interface{}, defaultServiceName string) []model.PortSp
 					Protocol:      "tcp",
 				})
 			case string:
-				ps := parseAppPortString(iv, defaultServiceName)
-				if ps != nil {
-					ports = append(ports, *ps)
+				specs, err := parseAppPortString(iv, defaultServiceName)
+				if err != nil {
+					return nil, err
 				}
+				ports = append(ports, specs...)
 			}
 		}
 	}
 
-	return ports
+	return ports, nil
 }
 
 // parseAppPortString parses a single appPort string entry.
-// Format: "hostPort:containerPort" or just "containerPort".
-func parseAppPortString(s, defaultServiceName string) *model.PortSpec {
-	parts := strings.SplitN(s, ":", 2)
+// Format: "ip:hostPort:containerPort", "hostPort:containerPort", or just
+// "containerPort". The ip may be a bracketed IPv6 address ("[::1]"); it is
+// kept as written in PortSpec.HostIP.
+//
+// Either port may be a range ("3000-3005"). A range is expanded into one
+// spec per port, so that each port is shifted and labeled like any other;
+// "13000-13002:3000-3002" yields 13000:3000, 13001:3001, and 13002:3002.
+// Both sides must then span the same number of ports. Malformed ranges are
+// an error; other malformed entries yield no spec, as before ranges were
+// supported.
+func parseAppPortString(s, defaultServiceName string) ([]model.PortSpec, error) {
+	containerPart, host, hostIP := s, "", ""
+	// Split from the right: an IPv6 bind address contains colons itself.
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		containerPart, host = s[i+1:], s[:i]
+		if j := strings.LastIndex(host, ":"); j >= 0 {
+			host, hostIP = host[j+1:], host[:j]
+			if hostIP == "" {
+				return nil, nil
+			}
+		}
+	}
 
-	if len(parts) == 2 {
-		// "hostPort:containerPort" format.
-		hostPort, err := strconv.Atoi(parts[0])
+	containerStart, containerCount, err := parsePortRange(containerPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid appPort %q: %w", s, err)
+	}
+	if containerCount == 0 {
+		return nil, nil
+	}
+	hostStart, hostCount := 0, containerCount
+	if host != "" {
+		hostStart, hostCount, err = parsePortRange(host)
 		if err != nil {
-			return nil
+			return nil, fmt.Errorf("invalid appPort %q: %w", s, err)
 		}
-		containerPort, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return nil
+		if hostCount == 0 {
+			return nil, nil
 		}
-		return &model.PortSpec{
+	}
+	if hostCount != containerCount {
+		return nil, fmt.Errorf("invalid appPort %q: host and container ranges span %d and %d ports", s, hostCount, containerCount)
+	}
+
+	specs := make([]model.PortSpec, 0, containerCount)
+	for k := 0; k < containerCount; k++ {
+		spec := model.PortSpec{
 			ServiceName:   defaultServiceName,
-			ContainerPort: containerPort,
-			HostPort:      hostPort,
+			ContainerPort: containerStart + k,
 			Protocol:      "tcp",
+			HostIP:        hostIP,
+		}
+		if host != "" {
+			spec.HostPort = hostStart + k
 		}
+		specs = append(specs, spec)
 	}
+	return specs, nil
+}
 
-	// Single port number as string.
-	port, err := strconv.Atoi(s)
+// parsePortRange parses a port ("3000") or a port range ("3000-3005") and
+// returns its first port and the number of ports it spans. A value that is
+// not a number yields a count of zero and no error; only values that are
+// recognizably ranges but malformed (reversed, out of bounds, too large)
+// are errors.
+func parsePortRange(s string) (start, count int, err error) {
+	first, last, isRange := strings.Cut(s, "-")
+	start, err = strconv.Atoi(first)
 	if err != nil {
-		return nil
+		return 0, 0, nil
 	}
-	return &model.PortSpec{
-		ServiceName:   defaultServiceName,
-		ContainerPort: port,
-		Protocol:      "tcp",
+	if !isRange {
+		return start, 1, nil
+	}
+	end, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
 	}
+	switch {
+	case start < 1 || end > 65535:
+		return 0, 0, fmt.Errorf("port range %q is outside 1-65535", s)
+	case start > end:
+		return 0, 0, fmt.Errorf("port range %q ends before it starts", s)
+	case end-start+1 > maxAppPortRange:
+		return 0, 0, fmt.Errorf("port range %q spans more than %d ports", s, maxAppPortRange)
+	}
+	return start, end - start + 1, nil
 }
 
 // GetComposeFiles extracts and normalizes the dockerComposeFile field
@@ -392,6 +523,23 @@ func GetComposeFiles(raw *RawDevContainer) []string {
 	}
 }
 
+// UsesHostNetwork reports whether runArgs put the container on the host's
+// network stack ("--network=host", "--net=host", or the value as a separate
+// argument). Published ports do not apply to such a container.
+func UsesHostNetwork(raw *RawDevContainer) bool {
+	for i, arg := range raw.RunArgs {
+		switch {
+		case arg == "--network" || arg == "--net":
+			if i+1 < len(raw.RunArgs) && raw.RunArgs[i+1] == "host" {
+				return true
+			}
+		case arg == "--network=host" || arg == "--net=host":
+			return true
+		}
+	}
+	return false
+}
+
 // FindDevContainerJSON searches for devcontainer.json in the standard
 // locations within a project directory.
 //
@@ -187,6 +187,65 @@ func TestLoadConfig_NotFound(t *testing.T) {
 	assert.Equal(t, model.ExitDevContainerNotFound, cliErr.Code)
 }
 
+// TestLoadConfig_BOM verifies that a devcontainer.json saved with a UTF-8
+// byte order mark is parsed like one without.
+func TestLoadConfig_BOM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	content := "\xEF\xBB\xBF{\n  // saved by an editor that writes a BOM\n  \"name\": \"app\",\n  \"image\": \"node:20\"\n}\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	raw, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "app", raw.Name)
+	assert.Equal(t, "node:20", raw.Image)
+}
+
+// TestLoadConfig_TrailingComma verifies that trailing commas, which JSONC
+// allows, are tolerated in objects and arrays.
+func TestLoadConfig_TrailingComma(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	content := "{\n  \"image\": \"node:20\",\n  \"forwardPorts\": [3000, 8080,],\n}\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	raw, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "node:20", raw.Image)
+	assert.Len(t, raw.ForwardPorts, 2)
+}
+
+// TestLoadConfig_InvalidJSONPosition verifies that a syntax error names the
+// line, column, and byte offset in the file as written, counting comments
+// and a byte order mark.
+func TestLoadConfig_InvalidJSONPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing comma",
+			content: "{\n  // comment\n  \"image\": \"node:20\"\n  \"name\": \"app\"\n}\n",
+			want:    "line 4, column 3 (byte offset 38)",
+		},
+		{
+			name:    "trailing garbage with BOM",
+			content: "\xEF\xBB\xBF{\"image\": \"node:20\"}\n}\n",
+			want:    "line 2, column 1 (byte offset 24)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "devcontainer.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			_, err := LoadConfig(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), path)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
 // --- DetectPattern tests ---
 
 // TestDetectPattern_Image verifies that a configuration with no dockerComposeFile
@@ -249,7 +308,8 @@ func TestExtractPorts_ForwardPorts(t *testing.T) {
 		},
 	}
 
-	ports := ExtractPorts(raw, "app")
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
 
 	require.Len(t, ports, 3)
 
@@ -278,7 +338,8 @@ func TestExtractPorts_AppPort(t *testing.T) {
 		},
 	}
 
-	ports := ExtractPorts(raw, "app")
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
 
 	require.Len(t, ports, 2)
 
@@ -294,6 +355,70 @@ func TestExtractPorts_AppPort(t *testing.T) {
 	assert.Equal(t, 8080, ports[1].HostPort)
 }
 
+// TestExtractPorts_AppPortBindAddress verifies that the three-part
+// "ip:hostPort:containerPort" form keeps its bind address, including a
+// bracketed IPv6 address, and that a missing address is rejected.
+func TestExtractPorts_AppPortBindAddress(t *testing.T) {
+	raw := &RawDevContainer{
+		AppPort: []interface{}{"127.0.0.1:3000:3000", "0.0.0.0:8080:80", "[::1]:9229:9229", ":5000:5000"},
+	}
+
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
+
+	require.Len(t, ports, 3)
+	assert.Equal(t, model.PortSpec{ServiceName: "app", ContainerPort: 3000, HostPort: 3000, Protocol: "tcp", HostIP: "127.0.0.1"}, ports[0])
+	assert.Equal(t, model.PortSpec{ServiceName: "app", ContainerPort: 80, HostPort: 8080, Protocol: "tcp", HostIP: "0.0.0.0"}, ports[1])
+	assert.Equal(t, "[::1]", ports[2].HostIP)
+	assert.Equal(t, 9229, ports[2].HostPort)
+}
+
+// TestExtractPorts_AppPortRange verifies that a range on both sides is
+// expanded into one spec per port, pairing host and container ports in
+// order, and that a container-only range and a bind address are kept.
+func TestExtractPorts_AppPortRange(t *testing.T) {
+	raw := &RawDevContainer{
+		AppPort: []interface{}{"3000-3002:3000-3002", "127.0.0.1:18000-18001:8000-8001", "9000-9001"},
+	}
+
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 3000, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 3001, HostPort: 3001, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 3002, HostPort: 3002, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 8000, HostPort: 18000, Protocol: "tcp", HostIP: "127.0.0.1"},
+		{ServiceName: "app", ContainerPort: 8001, HostPort: 18001, Protocol: "tcp", HostIP: "127.0.0.1"},
+		{ServiceName: "app", ContainerPort: 9000, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 9001, Protocol: "tcp"},
+	}, ports)
+}
+
+// TestExtractPorts_AppPortRangeErrors verifies that malformed ranges are
+// reported instead of being dropped.
+func TestExtractPorts_AppPortRangeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		appPort interface{}
+		want    string
+	}{
+		{name: "mismatched lengths", appPort: "3000-3005:3000-3002", want: "span 6 and 3 ports"},
+		{name: "range to single port", appPort: []interface{}{"8000-8010:80"}, want: "span 11 and 1 ports"},
+		{name: "reversed", appPort: "3005-3000:3005-3000", want: "ends before it starts"},
+		{name: "out of bounds", appPort: "65530-65540", want: "outside 1-65535"},
+		{name: "too large", appPort: "3000-4000:3000-4000", want: "more than"},
+		{name: "not a number", appPort: "3000-abc", want: "invalid port range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractPorts(&RawDevContainer{AppPort: tt.appPort}, "app")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
 // TestExtractPorts_WithLabels verifies that portsAttributes labels are
 // correctly applied to extracted ports.
 func TestExtractPorts_WithLabels(t *testing.T) {
@@ -305,13 +430,77 @@ func TestExtractPorts_WithLabels(t *testing.T) {
 		},
 	}
 
-	ports := ExtractPorts(raw, "app")
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
 
 	require.Len(t, ports, 2)
 	assert.Equal(t, "Application", ports[0].Label)
 	assert.Equal(t, "API Server", ports[1].Label)
 }
 
+// TestExtractPorts_PortsAttributesBehavior verifies that ports set to
+// onAutoForward "ignore" are left out, wherever they are listed, and that
+// requireLocalPort is carried over.
+func TestExtractPorts_PortsAttributesBehavior(t *testing.T) {
+	raw := &RawDevContainer{
+		ForwardPorts: []interface{}{float64(3000), float64(9229), float64(5432)},
+		AppPort:      []interface{}{"9229:9229"},
+		PortsAttributes: map[string]PortAttribute{
+			"9229": {Label: "Debugger", OnAutoForward: "ignore"},
+			"5432": {Label: "Database", RequireLocalPort: true},
+		},
+	}
+
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 5432, Protocol: "tcp", Label: "Database", RequireLocalPort: true},
+	}, ports, "the ignored port must not be allocated")
+}
+
+// TestExtractPorts_DeduplicatesOverlappingFields verifies that a port listed
+// in both forwardPorts and appPort yields a single spec that keeps the host
+// port from appPort and the label from portsAttributes.
+func TestExtractPorts_DeduplicatesOverlappingFields(t *testing.T) {
+	raw := &RawDevContainer{
+		ForwardPorts: []interface{}{float64(3000), "db:5432", float64(8080)},
+		AppPort:      []interface{}{"3001:3000"},
+		PortsAttributes: map[string]PortAttribute{
+			"3000": {Label: "Application"},
+		},
+	}
+
+	ports, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
+
+	require.Len(t, ports, 3, "3000 appears in forwardPorts and appPort but must be allocated once")
+
+	assert.Equal(t, model.PortSpec{
+		ServiceName: "app", ContainerPort: 3000, HostPort: 3001, Protocol: "tcp", Label: "Application",
+	}, ports[0], "the merged spec keeps the appPort host port and the label")
+	assert.Equal(t, 5432, ports[1].ContainerPort)
+	assert.Equal(t, 8080, ports[2].ContainerPort, "first-seen order is preserved")
+}
+
+// TestDedupePortSpecs verifies the merge rules directly: distinct services or
+// protocols are not duplicates, and existing metadata is never overwritten.
+func TestDedupePortSpecs(t *testing.T) {
+	ports := dedupePortSpecs([]model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp", Label: "First"},
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp", HostPort: 3000, Label: "Second"},
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "udp"},
+		{ServiceName: "web", ContainerPort: 3000, Protocol: "tcp"},
+	})
+
+	require.Len(t, ports, 3)
+	assert.Equal(t, 3000, ports[0].HostPort)
+	assert.Equal(t, "First", ports[0].Label, "an existing label is not replaced")
+	assert.Equal(t, "udp", ports[1].Protocol)
+	assert.Equal(t, "web", ports[2].ServiceName)
+}
+
 // --- GetComposeFiles tests ---
 
 // TestGetComposeFiles_String verifies that a single string dockerComposeFile
@@ -351,6 +540,30 @@ func TestGetComposeFiles_Nil(t *testing.T) {
 	assert.Nil(t, files)
 }
 
+// TestUsesHostNetwork verifies the runArgs spellings that select host
+// networking, and that other networks are not mistaken for it.
+func TestUsesHostNetwork(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		runArgs []string
+		want    bool
+	}{
+		{nil, false},
+		{[]string{"--network=host"}, true},
+		{[]string{"--net=host"}, true},
+		{[]string{"--cap-add=SYS_PTRACE", "--network", "host"}, true},
+		{[]string{"--net", "host"}, true},
+		{[]string{"--network=bridge"}, false},
+		{[]string{"--network", "hostnet"}, false},
+		{[]string{"--hostname", "host"}, false},
+		{[]string{"--network"}, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, UsesHostNetwork(&RawDevContainer{RunArgs: tt.runArgs}), "%v", tt.runArgs)
+	}
+}
+
 // --- FindDevContainerJSON tests ---
 
 // TestFindDevContainerJSON verifies that the function correctly finds
@@ -0,0 +1,59 @@
+// jsonc.go parses devcontainer.json files as written by editors: with
+// comments, trailing commas, and sometimes a UTF-8 byte order mark.
+//
+// The supported subset is that of VS Code's JSONC: // line comments, /* */
+// block comments, and trailing commas in objects and arrays at any depth.
+// Other JSON5 extensions (unquoted keys, single quotes, hexadecimal
+// numbers) are syntax errors.
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/jsonc"
+)
+
+// utf8BOM is the byte order mark some editors write at the start of UTF-8
+// files. encoding/json rejects it as an invalid character.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// stripBOM returns data without a leading UTF-8 byte order mark.
+func stripBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
+
+// unmarshalJSONC parses a devcontainer.json into v. A leading byte order
+// mark is dropped, and comments and trailing commas are stripped with
+// jsonc.ToJSON, which blanks them out rather than removing them, so the
+// offsets in syntax errors still point into the file as written.
+//
+// A syntax error is reported with the line, column, and byte offset of the
+// offending character in data, instead of encoding/json's bare "invalid
+// character" message.
+func unmarshalJSONC(data []byte, v interface{}) error {
+	content := stripBOM(data)
+	bomLen := int64(len(data) - len(content))
+
+	err := json.Unmarshal(jsonc.ToJSON(content), v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset counts the bytes read, up to and including the offending one.
+		offset := max(syntaxErr.Offset-1, 0) + bomLen
+		line, col := lineColumn(data, offset)
+		return fmt.Errorf("invalid JSON at line %d, column %d (byte offset %d): %w", line, col, offset, err)
+	}
+	return err
+}
+
+// lineColumn returns the 1-based line and column of the byte at the 0-based
+// offset in data.
+func lineColumn(data []byte, offset int64) (line, col int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
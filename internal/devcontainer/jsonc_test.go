@@ -0,0 +1,118 @@
+// jsonc_test.go is a regression suite of JSONC as found in real-world
+// devcontainer.json files, and of the JSON5 extensions that are rejected.
+package devcontainer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnmarshalJSONC_EdgeCases verifies the supported JSONC subset:
+// trailing commas in nested objects and arrays, line comments (also at the
+// end of the file without a newline), and block comments spanning lines,
+// while comment markers and commas inside strings are left alone.
+func TestUnmarshalJSONC_EdgeCases(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]interface{}
+	}{
+		{"trailing comma in nested object", `{"a": {"b": 1,},}`,
+			map[string]interface{}{"a": map[string]interface{}{"b": 1.0}}},
+		{"trailing comma in nested array", `{"a": [1, [2, 3,],],}`,
+			map[string]interface{}{"a": []interface{}{1.0, []interface{}{2.0, 3.0}}}},
+		{"trailing comma across blank lines", "{\"a\": [1,\n\t\n],\n}",
+			map[string]interface{}{"a": []interface{}{1.0}}},
+		{"trailing comma before line comment", "{\"a\": 1, // last\n}",
+			map[string]interface{}{"a": 1.0}},
+		{"trailing comma around block comments", `{"a": [1, /* x */ ] /* y */ , /* z */ }`,
+			map[string]interface{}{"a": []interface{}{1.0}}},
+		{"line comments before the root", "// a\n// b\n{\"a\": 1}",
+			map[string]interface{}{"a": 1.0}},
+		{"line comment at end of file without newline", "{\"a\": 1}\n// end",
+			map[string]interface{}{"a": 1.0}},
+		{"line comment with CRLF line endings", "{\r\n\"a\": 1 // one\r\n}\r\n// end\r\n",
+			map[string]interface{}{"a": 1.0}},
+		{"block comment spanning lines", "/*\n * header\n */\n{\"a\": /* inline\n multi */ 1}",
+			map[string]interface{}{"a": 1.0}},
+		{"unterminated block comment at end of file", `{"a": 1} /* end`,
+			map[string]interface{}{"a": 1.0}},
+		{"comment markers in strings", `{"url": "http://x/*y*/", "p": "a // b"}`,
+			map[string]interface{}{"url": "http://x/*y*/", "p": "a // b"}},
+		{"escaped quote before comment marker", `{"s": "say \"hi\" // not a comment",}`,
+			map[string]interface{}{"s": `say "hi" // not a comment`}},
+		{"comma and brace in string", `{"s": ",}"}`,
+			map[string]interface{}{"s": ",}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got map[string]interface{}
+			require.NoError(t, unmarshalJSONC([]byte(tt.input), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestUnmarshalJSONC_Unsupported verifies that JSON5 extensions beyond
+// comments and trailing commas are rejected with a position.
+func TestUnmarshalJSONC_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	for name, input := range map[string]string{
+		"unquoted key":       `{a: 1}`,
+		"single quotes":      `{'a': 1}`,
+		"double comma":       `{"a": 1,,}`,
+		"leading comma":      `[,1]`,
+		"hexadecimal number": `{"a": 0x10}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var got map[string]interface{}
+			err := unmarshalJSONC([]byte(input), &got)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid JSON at line 1")
+		})
+	}
+}
+
+// TestRewriteConfig_JSONCEdgeCases verifies that a devcontainer.json using
+// every supported JSONC feature is rewritten into plain JSON.
+func TestRewriteConfig_JSONCEdgeCases(t *testing.T) {
+	t.Parallel()
+
+	rawJSON := []byte(`/*
+ * Dev container for the API.
+ */
+{
+	"name": "api", // replaced by the environment name
+	"image": "mcr.microsoft.com/devcontainers/go:1", /* pinned
+	   by the platform team */
+	"forwardPorts": [
+		8080,
+	],
+	"customizations": {
+		"vscode": {
+			"extensions": ["golang.go",],
+			"settings": {"go.lintTool": "golangci-lint",},
+		},
+	},
+}
+// end of file`)
+
+	result, err := RewriteConfig(rawJSON, "feature-auth", 1, nil, nil)
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap), "the rewritten file must be plain JSON")
+	assert.Equal(t, "feature-auth", resultMap["name"])
+	assert.Equal(t, []interface{}{8080.0}, resultMap["forwardPorts"])
+	vscode := resultMap["customizations"].(map[string]interface{})["vscode"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"golang.go"}, vscode["extensions"])
+}
@@ -23,7 +23,6 @@ import (
 	"strings"
 
 	"github.com/mmr-tortoise/loam/internal/model"
-	"github.com/tidwall/jsonc"
 )
 
 // RewriteConfig takes the raw bytes of a devcontainer.json file (with JSONC
@@ -49,10 +48,8 @@ func RewriteConfig(rawJSON []byte, envName string, worktreeIndex int, portAlloca
 	// Using map[string]interface{} preserves ALL fields from the original JSON,
 	// not just the ones defined in RawDevContainer. This is critical because
 	// devcontainer.json has many optional fields we don't explicitly model.
-	cleanJSON := jsonc.ToJSON(rawJSON)
-
 	var configMap map[string]interface{}
-	if err := json.Unmarshal(cleanJSON, &configMap); err != nil {
+	if err := unmarshalJSONC(rawJSON, &configMap); err != nil {
 		return nil, fmt.Errorf("failed to parse devcontainer.json for rewriting: %w", err)
 	}
 
@@ -134,10 +131,145 @@ func applyRunArgsLabels(configMap map[string]interface{}, labels map[string]stri
 	configMap["runArgs"] = runArgs
 }
 
+// SetRunArgsNetwork returns a copy of a rewritten devcontainer.json (see
+// RewriteConfig) whose runArgs attach the container to network
+// (create --network). Any "--network" or "--net" argument already in
+// runArgs is replaced, since docker run accepts only one at creation.
+func SetRunArgsNetwork(configJSON []byte, network string) ([]byte, error) {
+	return editConfig(configJSON, func(configMap map[string]interface{}) {
+		var runArgs []interface{}
+		if arr, ok := configMap["runArgs"].([]interface{}); ok {
+			for i := 0; i < len(arr); i++ {
+				arg, _ := arr[i].(string)
+				switch {
+				case arg == "--network" || arg == "--net":
+					// The value is the next argument.
+					i++
+				case strings.HasPrefix(arg, "--network=") || strings.HasPrefix(arg, "--net="):
+				default:
+					runArgs = append(runArgs, arr[i])
+				}
+			}
+		}
+		configMap["runArgs"] = append(runArgs, "--network", network)
+	})
+}
+
+// SetImage returns a copy of a rewritten Pattern A devcontainer.json (see
+// RewriteConfig) that uses image instead of the configured one (create
+// --base-image).
+func SetImage(configJSON []byte, image string) ([]byte, error) {
+	return editConfig(configJSON, func(configMap map[string]interface{}) {
+		configMap["image"] = image
+	})
+}
+
+// SetUsers returns a copy of a rewritten devcontainer.json (see
+// RewriteConfig and RewriteComposeConfig) with remoteUser and containerUser
+// set (create --remote-user, --container-user). An empty value keeps the
+// field of the original configuration.
+func SetUsers(configJSON []byte, remoteUser, containerUser string) ([]byte, error) {
+	return editConfig(configJSON, func(configMap map[string]interface{}) {
+		if remoteUser != "" {
+			configMap["remoteUser"] = remoteUser
+		}
+		if containerUser != "" {
+			configMap["containerUser"] = containerUser
+		}
+	})
+}
+
+// SetBuildArgs returns a copy of a rewritten Pattern B devcontainer.json
+// (see RewriteConfig) with args merged into build.args (create
+// --build-arg). An arg overrides an entry of the same name from the
+// original configuration; the others are kept. The devcontainer CLI passes
+// build.args to the image build as --build-arg flags.
+func SetBuildArgs(configJSON []byte, args map[string]string) ([]byte, error) {
+	return editConfig(configJSON, func(configMap map[string]interface{}) {
+		build, ok := configMap["build"].(map[string]interface{})
+		if !ok {
+			build = make(map[string]interface{})
+			configMap["build"] = build
+		}
+		buildArgs, ok := build["args"].(map[string]interface{})
+		if !ok {
+			buildArgs = make(map[string]interface{})
+			build["args"] = buildArgs
+		}
+		for key, value := range args {
+			buildArgs[key] = value
+		}
+	})
+}
+
+// AnnotatePortsAttributes returns a copy of a rewritten devcontainer.json
+// (see RewriteConfig) whose portsAttributes labels name the container port
+// behind each shifted host port (create --annotate-ports), so that a
+// forwarded 13000 in the IDE is recognizable as the app's 3000:
+//
+//	"13000": {"label": "Application (container 3000)"}
+//
+// An existing label gets the annotation appended; a shifted port without an
+// entry or label gets "Container port 3000". Ports that were not shifted
+// are left alone.
+func AnnotatePortsAttributes(configJSON []byte, portAllocations []model.PortAllocation) ([]byte, error) {
+	return editConfig(configJSON, func(configMap map[string]interface{}) {
+		attrs, ok := configMap["portsAttributes"].(map[string]interface{})
+		if !ok {
+			attrs = make(map[string]interface{})
+		}
+
+		for _, pa := range portAllocations {
+			if pa.HostPort == pa.ContainerPort {
+				continue
+			}
+			key := strconv.Itoa(pa.HostPort)
+			attr, ok := attrs[key].(map[string]interface{})
+			if !ok {
+				if _, exists := attrs[key]; exists {
+					// Not an attribute object; leave it for the tooling to report.
+					continue
+				}
+				attr = make(map[string]interface{})
+				attrs[key] = attr
+			}
+			if label, ok := attr["label"].(string); ok && label != "" {
+				attr["label"] = fmt.Sprintf("%s (container %d)", label, pa.ContainerPort)
+			} else {
+				attr["label"] = fmt.Sprintf("Container port %d", pa.ContainerPort)
+			}
+		}
+
+		if len(attrs) > 0 {
+			configMap["portsAttributes"] = attrs
+		}
+	})
+}
+
+// editConfig parses a devcontainer.json, applies edit to it, and serializes
+// it the way RewriteConfig does. It backs the post-processing steps applied
+// to a rewritten configuration, such as SetRunArgsNetwork.
+func editConfig(configJSON []byte, edit func(configMap map[string]interface{})) ([]byte, error) {
+	var configMap map[string]interface{}
+	if err := unmarshalJSONC(configJSON, &configMap); err != nil {
+		return nil, fmt.Errorf("failed to parse devcontainer.json: %w", err)
+	}
+
+	edit(configMap)
+
+	result, err := json.MarshalIndent(configMap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize devcontainer.json: %w", err)
+	}
+	return append(result, '\n'), nil
+}
+
 // applyAppPortShift replaces the appPort field with shifted port mappings.
-// The output format is an array of "hostPort:containerPort" strings.
+// The output format is an array of "hostPort:containerPort" strings, with
+// the bind address in front ("ip:hostPort:containerPort") for ports that
+// had one.
 //
-// Example output: ["13000:3000", "18080:8080"]
+// Example output: ["13000:3000", "127.0.0.1:18080:8080"]
 //
 // If there are no port allocations, appPort is removed from the config
 // to avoid an empty array, which some tools might interpret incorrectly.
@@ -153,7 +285,11 @@ func applyAppPortShift(configMap map[string]interface{}, portAllocations []model
 	// understands for Pattern A/B configurations.
 	appPorts := make([]interface{}, 0, len(portAllocations))
 	for _, pa := range portAllocations {
-		appPorts = append(appPorts, fmt.Sprintf("%d:%d", pa.HostPort, pa.ContainerPort))
+		mapping := fmt.Sprintf("%d:%d", pa.HostPort, pa.ContainerPort)
+		if pa.HostIP != "" {
+			mapping = pa.HostIP + ":" + mapping
+		}
+		appPorts = append(appPorts, mapping)
 	}
 
 	configMap["appPort"] = appPorts
@@ -1,6 +1,7 @@
 package devcontainer
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -195,6 +196,36 @@ func TestRewriteConfig_PatternB(t *testing.T) {
 	assert.False(t, has5432, "original key 5432 should be replaced")
 }
 
+// TestRewriteConfig_AppPortBindAddress verifies that a shifted appPort
+// mapping keeps the bind address of the original entry.
+func TestRewriteConfig_AppPortBindAddress(t *testing.T) {
+	rawJSON := []byte(`{
+		"image": "node:20",
+		"appPort": ["127.0.0.1:3000:3000", "0.0.0.0:8080:80"]
+	}`)
+	raw := &RawDevContainer{AppPort: []interface{}{"127.0.0.1:3000:3000", "0.0.0.0:8080:80"}}
+
+	specs, err := ExtractPorts(raw, "app")
+	require.NoError(t, err)
+	var allocations []model.PortAllocation
+	for _, ps := range specs {
+		allocations = append(allocations, model.PortAllocation{
+			ServiceName:   ps.ServiceName,
+			ContainerPort: ps.ContainerPort,
+			HostPort:      ps.ContainerPort + 10000,
+			Protocol:      ps.Protocol,
+			HostIP:        ps.HostIP,
+		})
+	}
+
+	result, err := RewriteConfig(rawJSON, "feature-bind", 1, allocations, nil)
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, []interface{}{"127.0.0.1:13000:3000", "0.0.0.0:10080:80"}, resultMap["appPort"])
+}
+
 // TestRewriteConfig_EmptyPorts verifies that RewriteConfig works correctly
 // when there are no port allocations. This is a valid scenario for containers
 // that don't expose any ports (e.g., a pure build/test environment).
@@ -238,6 +269,53 @@ func TestRewriteConfig_EmptyPorts(t *testing.T) {
 	assert.Equal(t, "0", envMap["WORKTREE_INDEX"])
 }
 
+// TestRewriteConfig_BOM verifies that a byte order mark does not break the
+// rewrite and is not carried into the generated file.
+func TestRewriteConfig_BOM(t *testing.T) {
+	rawJSON := []byte("\xEF\xBB\xBF{\"name\": \"app\", \"image\": \"node:20\",}")
+
+	result, err := RewriteConfig(rawJSON, "feature-auth", 1, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, bytes.HasPrefix(result, utf8BOM))
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, "feature-auth", resultMap["name"])
+	assert.Equal(t, "node:20", resultMap["image"])
+}
+
+// TestRewriteConfig_NoPortsKeepsLabels verifies the create --no-ports case for
+// Pattern A/B: with no allocations, no host port mapping is emitted, while
+// labels and worktree environment variables are still applied.
+func TestRewriteConfig_NoPortsKeepsLabels(t *testing.T) {
+	rawJSON := []byte(`{
+		"name": "app",
+		"image": "node:20",
+		"appPort": ["3000:3000"],
+		"forwardPorts": [3000]
+	}`)
+
+	labels := map[string]string{"loam.name": "backend-only"}
+
+	result, err := RewriteConfig(rawJSON, "backend-only", 2, nil, labels)
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+
+	_, hasAppPort := resultMap["appPort"]
+	assert.False(t, hasAppPort, "no host ports may be published")
+	assert.NotContains(t, string(result), "3000:3000")
+
+	runArgs, ok := resultMap["runArgs"].([]interface{})
+	require.True(t, ok)
+	assert.Contains(t, runArgs, "loam.name=backend-only", "labels are still applied")
+
+	envMap, ok := resultMap["containerEnv"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "2", envMap["WORKTREE_INDEX"])
+}
+
 // TestRewriteConfig_NoExistingRunArgs verifies that label flags are correctly
 // added even when the original config has no runArgs field at all.
 func TestRewriteConfig_NoExistingRunArgs(t *testing.T) {
@@ -265,6 +343,117 @@ func TestRewriteConfig_NoExistingRunArgs(t *testing.T) {
 	assert.Equal(t, "loam.name=minimal-env", runArgs[1])
 }
 
+// TestSetRunArgsNetwork verifies the create --network case for Pattern A/B:
+// the rewritten runArgs gain "--network <name>", and a network already set
+// in the original runArgs (in either form) is replaced rather than kept.
+func TestSetRunArgsNetwork(t *testing.T) {
+	rawJSON := []byte(`{
+		"name": "app",
+		"image": "node:20",
+		"runArgs": ["--network=bridge", "--cap-add=SYS_PTRACE", "--net", "host"]
+	}`)
+
+	rewritten, err := RewriteConfig(rawJSON, "feature-auth", 1, nil, map[string]string{"loam.name": "feature-auth"})
+	require.NoError(t, err)
+
+	result, err := SetRunArgsNetwork(rewritten, "shared-proxy")
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, []interface{}{
+		"--cap-add=SYS_PTRACE",
+		"--label", "loam.name=feature-auth",
+		"--network", "shared-proxy",
+	}, resultMap["runArgs"])
+	assert.Equal(t, "feature-auth", resultMap["name"], "other fields are preserved")
+}
+
+// TestAnnotatePortsAttributes verifies that shifted ports get the container
+// port appended to an existing label or a synthesized label, and that
+// unshifted ports and unrelated entries are left alone.
+func TestAnnotatePortsAttributes(t *testing.T) {
+	input := []byte(`{
+		"portsAttributes": {
+			"13000": {"label": "Application", "onAutoForward": "notify"},
+			"9229": {"label": "Debugger"}
+		}
+	}`)
+	allocations := []model.PortAllocation{
+		{ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+		{ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+		{ContainerPort: 9229, HostPort: 9229, Protocol: "tcp"},
+	}
+
+	result, err := AnnotatePortsAttributes(input, allocations)
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	attrs := resultMap["portsAttributes"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"label": "Application (container 3000)", "onAutoForward": "notify"}, attrs["13000"])
+	assert.Equal(t, map[string]interface{}{"label": "Container port 5432"}, attrs["15432"])
+	assert.Equal(t, map[string]interface{}{"label": "Debugger"}, attrs["9229"])
+
+	// Without shifted ports, no portsAttributes is synthesized.
+	result, err = AnnotatePortsAttributes([]byte(`{"name": "x"}`), nil)
+	require.NoError(t, err)
+	resultMap = nil
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.NotContains(t, resultMap, "portsAttributes")
+}
+
+// TestSetBuildArgs verifies that build args are merged into build.args,
+// overriding same-named entries, and that build.args is created when the
+// original configuration has none.
+func TestSetBuildArgs(t *testing.T) {
+	result, err := SetBuildArgs([]byte(`{
+		"build": {"dockerfile": "Dockerfile", "args": {"NODE_VERSION": "20", "DEBIAN": "bookworm"}}
+	}`), map[string]string{"NODE_VERSION": "22"})
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, map[string]interface{}{
+		"dockerfile": "Dockerfile",
+		"args":       map[string]interface{}{"NODE_VERSION": "22", "DEBIAN": "bookworm"},
+	}, resultMap["build"])
+
+	result, err = SetBuildArgs([]byte(`{"build": {"dockerfile": "Dockerfile"}}`), map[string]string{"EXTRA": "1"})
+	require.NoError(t, err)
+	resultMap = nil
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, map[string]interface{}{"EXTRA": "1"}, resultMap["build"].(map[string]interface{})["args"])
+}
+
+// TestSetImage verifies that the image is replaced and the rest of the
+// configuration kept.
+func TestSetImage(t *testing.T) {
+	result, err := SetImage([]byte(`{"name": "app", "image": "node:20"}`), "node:22")
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, map[string]interface{}{"name": "app", "image": "node:22"}, resultMap)
+}
+
+// TestSetUsers verifies that remoteUser and containerUser are set or
+// overridden, and that an empty value keeps the original field.
+func TestSetUsers(t *testing.T) {
+	result, err := SetUsers([]byte(`{"image": "node:20", "remoteUser": "node"}`), "dev", "1000")
+	require.NoError(t, err)
+
+	var resultMap map[string]interface{}
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, map[string]interface{}{"image": "node:20", "remoteUser": "dev", "containerUser": "1000"}, resultMap)
+
+	result, err = SetUsers([]byte(`{"image": "node:20", "remoteUser": "node"}`), "", "root")
+	require.NoError(t, err)
+	resultMap = nil
+	require.NoError(t, json.Unmarshal(result, &resultMap))
+	assert.Equal(t, map[string]interface{}{"image": "node:20", "remoteUser": "node", "containerUser": "root"}, resultMap)
+}
+
 // TestRewriteConfig_NoExistingContainerEnv verifies that containerEnv is
 // correctly created when the original config doesn't have one.
 func TestRewriteConfig_NoExistingContainerEnv(t *testing.T) {
@@ -93,6 +93,82 @@ func ValidateConfig(raw *RawDevContainer) []ValidationError {
 	return errors
 }
 
+// CheckBuildPaths reports build.context and build.dockerfile paths that point
+// outside the .devcontainer directory copied into the worktree.
+//
+// Both paths are relative to devcontainer.json. CopyDevContainerDir only
+// copies the .devcontainer directory, so a path such as context: ".." is no
+// longer resolved against the source repository but against the copy:
+//   - If it still lands on the same relative location inside the worktree
+//     (the common context: ".." case), the build works, but only files
+//     committed to Git are present — untracked files from the main checkout
+//     (generated assets, local certificates) are missing.
+//   - If it lands somewhere else (e.g., "../.." or a path outside the
+//     repository), the build will read a different directory and most
+//     likely fail.
+//
+// Parameters:
+//   - srcDir: directory containing the source devcontainer.json
+//   - dstDir: the .devcontainer directory in the worktree
+//   - repoRoot: the source repository root
+//   - worktreeRoot: the worktree root
+//
+// Returns one human-readable warning per problematic path (empty = no issues).
+func CheckBuildPaths(srcDir, dstDir, repoRoot, worktreeRoot string, build *BuildConfig) []string {
+	if build == nil {
+		return nil
+	}
+
+	var warnings []string
+
+	check := func(field, value string) {
+		if value == "" || filepath.IsAbs(value) {
+			// Absolute paths are already reported by ValidateConfig.
+			return
+		}
+
+		srcTarget := filepath.Join(srcDir, value)
+		if isWithinDir(srcTarget, srcDir) {
+			return // Copied along with .devcontainer — nothing to report.
+		}
+
+		dstTarget := filepath.Join(dstDir, value)
+		srcRel, srcErr := filepath.Rel(repoRoot, srcTarget)
+		dstRel, dstErr := filepath.Rel(worktreeRoot, dstTarget)
+
+		if srcErr == nil && dstErr == nil && srcRel == dstRel && isWithinDir(dstTarget, worktreeRoot) {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s %q lies outside .devcontainer; the worktree build uses %s from the worktree checkout, "+
+					"so untracked files from the main checkout are not available to it",
+				field, value, dstTarget))
+			return
+		}
+
+		warnings = append(warnings, fmt.Sprintf(
+			"%s %q lies outside the copied .devcontainer directory and resolves to %s in the worktree "+
+				"instead of %s; the build will likely fail — keep build files under .devcontainer "+
+				"or within the repository",
+			field, value, dstTarget, srcTarget))
+	}
+
+	// The Docker default context is the devcontainer.json directory ("."),
+	// which is always inside the copy, so an empty context needs no check.
+	check("build.context", build.Context)
+	check("build.dockerfile", build.Dockerfile)
+
+	return warnings
+}
+
+// isWithinDir reports whether path is dir itself or lies beneath it.
+// Both are compared in cleaned form, so ".." segments are resolved first.
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
 // ValidateGeneratedConfig validates a generated (rewritten) devcontainer.json
 // file by parsing it and running ValidateConfig, plus additional checks
 // specific to the loam modifications.
@@ -0,0 +1,72 @@
+package devcontainer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckBuildPaths verifies detection of build paths that resolve outside
+// the .devcontainer directory copied into the worktree.
+func TestCheckBuildPaths(t *testing.T) {
+	t.Parallel()
+
+	repoRoot := filepath.Join(string(filepath.Separator), "src", "myproject")
+	worktreeRoot := filepath.Join(string(filepath.Separator), "src", "myproject-feature")
+	srcDir := filepath.Join(repoRoot, ".devcontainer")
+	dstDir := filepath.Join(worktreeRoot, ".devcontainer")
+
+	t.Run("context inside .devcontainer", func(t *testing.T) {
+		t.Parallel()
+		build := &BuildConfig{Dockerfile: "Dockerfile", Context: "."}
+		assert.Empty(t, CheckBuildPaths(srcDir, dstDir, repoRoot, worktreeRoot, build))
+	})
+
+	t.Run("context is the repository root", func(t *testing.T) {
+		t.Parallel()
+		build := &BuildConfig{Dockerfile: "Dockerfile", Context: ".."}
+
+		warnings := CheckBuildPaths(srcDir, dstDir, repoRoot, worktreeRoot, build)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], `build.context ".."`)
+		assert.Contains(t, warnings[0], worktreeRoot, "should name the directory the worktree build uses")
+		assert.Contains(t, warnings[0], "untracked files")
+	})
+
+	t.Run("dockerfile at the repository root", func(t *testing.T) {
+		t.Parallel()
+		build := &BuildConfig{Dockerfile: "../Dockerfile"}
+
+		warnings := CheckBuildPaths(srcDir, dstDir, repoRoot, worktreeRoot, build)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "build.dockerfile")
+	})
+
+	t.Run("context outside the repository", func(t *testing.T) {
+		t.Parallel()
+		build := &BuildConfig{Context: "../.."}
+
+		warnings := CheckBuildPaths(srcDir, dstDir, repoRoot, worktreeRoot, build)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "will likely fail")
+	})
+
+	t.Run("no build section", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, CheckBuildPaths(srcDir, dstDir, repoRoot, worktreeRoot, nil))
+	})
+}
+
+// TestIsWithinDir verifies path containment after cleaning "..".
+func TestIsWithinDir(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(string(filepath.Separator), "a", "b")
+	assert.True(t, isWithinDir(dir, dir))
+	assert.True(t, isWithinDir(filepath.Join(dir, "c"), dir))
+	assert.False(t, isWithinDir(filepath.Join(dir, ".."), dir))
+	assert.False(t, isWithinDir(filepath.Join(dir, "..", "bc"), dir))
+	assert.True(t, isWithinDir(filepath.Join(dir, "..", "b", "..c"), dir), `"..c" is a normal name, not a parent reference`)
+}
@@ -13,8 +13,11 @@ package docker
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 
 	// Docker API types for container listing results.
@@ -27,7 +30,10 @@ import (
 
 	// filters package provides Args type for building Docker API query filters.
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
 
+	"github.com/mmr-tortoise/loam/internal/cmdlog"
 	"github.com/mmr-tortoise/loam/internal/model"
 )
 
@@ -75,6 +81,49 @@ func ListManagedContainers(ctx context.Context, cli *Client) ([]model.ContainerI
 	return result, nil
 }
 
+// containerLister is the part of the Docker SDK client used to list
+// containers. *client.Client implements it; tests substitute a fake.
+type containerLister interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
+}
+
+// ListAllPublishedPorts returns the host ports published by all running
+// containers, managed by loam or not, sorted and without duplicates.
+//
+// Ports of managed environments are already known from their labels, but a
+// port published by an unrelated container is not. The port scanner only
+// sees it if something on this machine is listening on it, which is not
+// the case for a remote Docker host or without Docker's userland proxy, so
+// create reserves these ports as well. Stopped containers hold no bindings
+// and are not included.
+func ListAllPublishedPorts(ctx context.Context, cli *Client) ([]int, error) {
+	return listAllPublishedPorts(ctx, cli.Inner())
+}
+
+// listAllPublishedPorts implements ListAllPublishedPorts.
+func listAllPublishedPorts(ctx context.Context, api containerLister) ([]int, error) {
+	containers, err := api.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, model.WrapCLIError(model.ExitDockerNotRunning, "failed to list Docker containers", err)
+	}
+
+	seen := make(map[int]bool)
+	var ports []int
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			// PublicPort is 0 for ports that are exposed but not published.
+			// A port bound on both IPv4 and IPv6 is listed twice.
+			if p.PublicPort == 0 || seen[int(p.PublicPort)] {
+				continue
+			}
+			seen[int(p.PublicPort)] = true
+			ports = append(ports, int(p.PublicPort))
+		}
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
 // containerToInfo converts a Docker API Container struct to our domain
 // model ContainerInfo. This is a pure mapping function with no side effects.
 //
@@ -82,6 +131,12 @@ func ListManagedContainers(ctx context.Context, cli *Client) ([]model.ContainerI
 // (e.g., "/my-container"), which we strip for cleaner display in CLI output.
 // The State field from the Docker API is a short string like "running",
 // "exited", or "created".
+//
+// The list response carries no structured health, but its Status text
+// ends in "(health: starting)" while a healthcheck has not passed yet.
+// That much is taken from it, so a container that is still coming up is
+// reported as starting without an inspect call (see determineStatus).
+// Failing healthchecks are only reported by ApplyHealth.
 func containerToInfo(c types.Container) model.ContainerInfo {
 	// Extract the container name. Docker returns names as a slice,
 	// and each name has a leading "/" that we strip for readability.
@@ -98,13 +153,87 @@ func containerToInfo(c types.Container) model.ContainerInfo {
 	// in the YAML this container belongs to.
 	serviceName := c.Labels["com.docker.compose.service"]
 
-	return model.ContainerInfo{
+	info := model.ContainerInfo{
 		ContainerID:   c.ID,
 		ContainerName: name,
 		ServiceName:   serviceName,
 		Status:        c.State,
 		Labels:        c.Labels,
 	}
+	if strings.Contains(c.Status, "(health: starting)") {
+		info.Health = types.Starting
+	}
+	info.Ports = publishedPorts(c.Ports)
+	return info
+}
+
+// publishedPorts converts the ports Docker lists for a container into
+// PublishedPorts, sorted by host port. Exposed ports that are not published
+// (no public port) are left out, and a port bound on both the IPv4 and the
+// IPv6 wildcard address is reported once. The result is never nil, so that
+// a container without published ports can be told apart from a
+// ContainerInfo whose ports are not known (see UnboundPorts).
+func publishedPorts(ports []types.Port) []model.PublishedPort {
+	result := make([]model.PublishedPort, 0, len(ports))
+	seen := make(map[model.PublishedPort]bool)
+	for _, p := range ports {
+		if p.PublicPort == 0 {
+			continue
+		}
+		key := model.PublishedPort{HostPort: int(p.PublicPort), ContainerPort: int(p.PrivatePort), Protocol: p.Type}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		key.HostIP = p.IP
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].HostPort != result[j].HostPort {
+			return result[i].HostPort < result[j].HostPort
+		}
+		return result[i].Protocol < result[j].Protocol
+	})
+	return result
+}
+
+// UnboundPorts returns the port allocations of env that none of its
+// running containers publishes, e.g. because the container failed to bind
+// the port. The labels still record such allocations, so list would
+// otherwise show them as usable. Nothing is reported while no container
+// runs, since no port is expected to be bound then; containers whose ports
+// are not known (nil Ports) are not taken into account.
+func UnboundPorts(env *model.WorktreeEnv) []model.PortAllocation {
+	type binding struct {
+		hostPort, containerPort int
+		protocol                string
+	}
+	bound := make(map[binding]bool)
+	running := false
+	for _, c := range env.Containers {
+		if c.Status != "running" || c.Ports == nil {
+			continue
+		}
+		running = true
+		for _, p := range c.Ports {
+			bound[binding{p.HostPort, p.ContainerPort, p.Protocol}] = true
+		}
+	}
+	if !running {
+		return nil
+	}
+
+	var unbound []model.PortAllocation
+	for _, pa := range env.PortAllocations {
+		protocol := pa.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		if !bound[binding{pa.HostPort, pa.ContainerPort, protocol}] {
+			unbound = append(unbound, pa)
+		}
+	}
+	return unbound
 }
 
 // GroupContainersByEnv groups a slice of ContainerInfo by their
@@ -179,12 +308,14 @@ func BuildWorktreeEnv(envName string, containers []model.ContainerInfo) (*model.
 //
 // The priority order is:
 //  1. Orphaned: worktree path no longer exists → containers are orphaned
-//  2. Running: at least one container is running → environment is running
-//  3. Stopped: all containers are stopped/exited → environment is stopped
+//  2. Starting: a container is created but not started, restarting, or
+//     running with its healthcheck still starting → environment is starting
+//  3. Running: at least one container is running → environment is running
+//  4. Stopped: all containers are stopped/exited → environment is stopped
 //
 // This logic supports the lifecycle model described in the data-model spec:
 //
-//	[Created] → Running → Stopped ⇄ Running → [Deleted]
+//	[Created] → Starting → Running → Stopped ⇄ Running → [Deleted]
 //	Running/Stopped → Orphaned (when Git worktree is manually deleted)
 func determineStatus(containers []model.ContainerInfo, worktreePath string) model.WorktreeStatus {
 	// Check if the worktree directory exists on disk. If not, the environment
@@ -194,6 +325,14 @@ func determineStatus(containers []model.ContainerInfo, worktreePath string) mode
 		return model.StatusOrphaned
 	}
 
+	// A container that is still coming up makes the whole environment
+	// "starting": it is not ready for use even if others already run.
+	for _, c := range containers {
+		if isContainerStarting(c) {
+			return model.StatusStarting
+		}
+	}
+
 	// Check if any container is currently running. A single running
 	// container is enough to consider the whole environment as "running".
 	for _, c := range containers {
@@ -206,6 +345,77 @@ func determineStatus(containers []model.ContainerInfo, worktreePath string) mode
 	return model.StatusStopped
 }
 
+// isContainerStarting reports whether c is on its way up: created but not
+// yet started, being restarted, or running with a healthcheck that has
+// not passed yet.
+func isContainerStarting(c model.ContainerInfo) bool {
+	switch c.Status {
+	case "created", "restarting":
+		return true
+	case "running":
+		return c.Health == types.Starting
+	}
+	return false
+}
+
+// containerInspector is the part of the Docker SDK client used to read
+// container health. *client.Client implements it; tests substitute a fake.
+type containerInspector interface {
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+}
+
+// ApplyHealth inspects the running containers of env, records their
+// healthcheck status in env.Containers, and downgrades a running
+// environment to StatusUnhealthy if any of them is failing its healthcheck
+// ("loam list --detailed").
+//
+// Health is not part of the container list response's structured data, so
+// this costs one inspect call per running container, which is why it is
+// not done by BuildWorktreeEnv.
+func ApplyHealth(ctx context.Context, cli *Client, env *model.WorktreeEnv) error {
+	return applyHealth(ctx, cli.Inner(), env)
+}
+
+// applyHealth implements ApplyHealth.
+func applyHealth(ctx context.Context, api containerInspector, env *model.WorktreeEnv) error {
+	unhealthy, starting := false, false
+	for i := range env.Containers {
+		c := &env.Containers[i]
+		if c.Status != "running" {
+			continue
+		}
+		info, err := api.ContainerInspect(ctx, c.ContainerID)
+		if err != nil {
+			return model.WrapCLIError(
+				model.ExitDockerNotRunning,
+				fmt.Sprintf("failed to inspect container %q", c.ContainerName),
+				err,
+			)
+		}
+		if info.ContainerJSONBase == nil || info.State == nil || info.State.Health == nil {
+			continue
+		}
+		if info.State.Health.Status != types.NoHealthcheck {
+			c.Health = info.State.Health.Status
+		}
+		switch c.Health {
+		case types.Unhealthy:
+			unhealthy = true
+		case types.Starting:
+			starting = true
+		}
+	}
+
+	// A failing healthcheck outweighs one that is still starting.
+	switch {
+	case unhealthy && (env.Status == model.StatusRunning || env.Status == model.StatusStarting):
+		env.Status = model.StatusUnhealthy
+	case starting && env.Status == model.StatusRunning:
+		env.Status = model.StatusStarting
+	}
+	return nil
+}
+
 // ComposeUp starts containers using docker compose. It executes
 // "docker compose -f file1 -f file2 up -d" in the specified project
 // directory with the given environment variables.
@@ -217,16 +427,72 @@ func determineStatus(containers []model.ContainerInfo, worktreePath string) mode
 // docker compose process, which is essential for injecting worktree-specific
 // values like shifted port numbers via variable substitution in YAML files.
 //
+// pull selects the image pull policy; PullDefault leaves it to Compose.
+//
 // Returns a CLIError with ExitDockerNotRunning if the command fails,
 // since compose failures most commonly stem from Docker daemon issues.
-func ComposeUp(ctx context.Context, projectDir string, composeFiles []string, envVars map[string]string) error {
-	// Build the docker compose command arguments.
-	// Each compose file gets its own -f flag, which docker compose
-	// merges in order (later files override earlier ones).
+func ComposeUp(ctx context.Context, projectDir string, composeFiles []string, envVars map[string]string, pull PullPolicy) error {
+	return runCompose(ctx, projectDir, buildComposeUpArgs(composeFiles, pull), envVars)
+}
+
+// PullPolicy controls when images are pulled before containers start.
+// It mirrors the values of `docker compose up --pull`.
+type PullPolicy string
+
+const (
+	// PullDefault passes no policy, keeping Docker's default behavior
+	// (pull only missing images).
+	PullDefault PullPolicy = ""
+
+	// PullAlways pulls images even if a copy is cached locally, which
+	// picks up a moved tag such as "latest".
+	PullAlways PullPolicy = "always"
+
+	// PullMissing pulls only images that are not cached locally.
+	PullMissing PullPolicy = "missing"
+
+	// PullNever never pulls; starting fails if an image is not cached.
+	PullNever PullPolicy = "never"
+)
+
+// ParsePullPolicy validates a pull policy given on the command line.
+// The empty string yields PullDefault.
+func ParsePullPolicy(s string) (PullPolicy, error) {
+	switch p := PullPolicy(s); p {
+	case PullDefault, PullAlways, PullMissing, PullNever:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid pull policy %q: must be always, missing, or never", s)
+	}
+}
+
+// buildComposeUpArgs constructs the arguments for `docker compose up -d`.
+// Each compose file gets its own -f flag, which docker compose merges in
+// order (later files override earlier ones). --pull is an option of the
+// "up" subcommand, so it follows "up" rather than the global flags.
+func buildComposeUpArgs(composeFiles []string, pull PullPolicy) []string {
 	args := buildComposeArgs(composeFiles)
 	args = append(args, "up", "-d")
+	if pull != PullDefault {
+		args = append(args, "--pull", string(pull))
+	}
+	return args
+}
 
-	return runCompose(ctx, projectDir, args, envVars)
+// PullImage runs `docker pull` for a single image. It is used for Pattern A
+// (image-based) configurations, which do not go through Compose's --pull.
+func PullImage(ctx context.Context, imageName string) error {
+	cmdlog.Echo("docker", []string{"pull", imageName}, nil)
+	cmd := exec.CommandContext(ctx, "docker", "pull", imageName)
+	output, err := runCommandStreaming(cmd, ComposeProgress)
+	if err != nil {
+		return model.WrapCLIError(
+			model.ExitDockerNotRunning,
+			fmt.Sprintf("docker pull failed for image %q: %s", imageName, strings.TrimSpace(string(output))),
+			err,
+		)
+	}
+	return nil
 }
 
 // ComposeStop stops containers managed by docker compose without removing
@@ -235,11 +501,22 @@ func ComposeUp(ctx context.Context, projectDir string, composeFiles []string, en
 //
 // This preserves container state and data, allowing them to be restarted
 // later with ComposeUp. This maps to the "loam stop" CLI command.
-func ComposeStop(ctx context.Context, projectDir string, composeFiles []string) error {
+// envVars works as in ComposeUp; it carries COMPOSE_PROJECT_NAME.
+//
+// timeout is the number of seconds to wait for the containers to exit
+// before they are killed (`stop -t`); nil leaves it to Compose.
+func ComposeStop(ctx context.Context, projectDir string, composeFiles []string, envVars map[string]string, timeout *int) error {
+	return runCompose(ctx, projectDir, buildComposeStopArgs(composeFiles, timeout), envVars)
+}
+
+// buildComposeStopArgs returns the docker arguments of ComposeStop.
+func buildComposeStopArgs(composeFiles []string, timeout *int) []string {
 	args := buildComposeArgs(composeFiles)
 	args = append(args, "stop")
-
-	return runCompose(ctx, projectDir, args, nil)
+	if timeout != nil {
+		args = append(args, "-t", strconv.Itoa(*timeout))
+	}
+	return args
 }
 
 // ComposeDown stops and removes containers, networks, and optionally volumes
@@ -252,7 +529,8 @@ func ComposeStop(ctx context.Context, projectDir string, composeFiles []string)
 // When removeVolumes is true, the -v flag is added to also remove named
 // volumes declared in the Compose file and anonymous volumes attached
 // to containers. This ensures complete cleanup with no leftover data.
-func ComposeDown(ctx context.Context, projectDir string, composeFiles []string, removeVolumes bool) error {
+// envVars works as in ComposeUp; it carries COMPOSE_PROJECT_NAME.
+func ComposeDown(ctx context.Context, projectDir string, composeFiles []string, removeVolumes bool, envVars map[string]string) error {
 	args := buildComposeArgs(composeFiles)
 	args = append(args, "down")
 
@@ -261,7 +539,7 @@ func ComposeDown(ctx context.Context, projectDir string, composeFiles []string,
 		args = append(args, "-v")
 	}
 
-	return runCompose(ctx, projectDir, args, nil)
+	return runCompose(ctx, projectDir, args, envVars)
 }
 
 // buildComposeArgs constructs the common arguments for docker compose commands.
@@ -281,7 +559,8 @@ func buildComposeArgs(composeFiles []string) []string {
 // It runs "docker" with the given arguments in the specified working directory,
 // optionally injecting extra environment variables.
 //
-// The function captures both stdout and stderr for error reporting.
+// The function captures both stdout and stderr for error reporting, and
+// streams them to ComposeProgress while running if it is set.
 // On failure, it returns a CLIError with ExitDockerNotRunning because
 // compose failures most commonly indicate Docker daemon problems.
 func runCompose(ctx context.Context, projectDir string, args []string, envVars map[string]string) error {
@@ -289,6 +568,7 @@ func runCompose(ctx context.Context, projectDir string, args []string, envVars m
 	// We use "docker" as the binary and "compose" as the first argument
 	// rather than "docker-compose" (legacy standalone binary), because
 	// modern Docker ships compose as a plugin subcommand.
+	cmdlog.Echo("docker", args, envVars)
 	cmd := exec.CommandContext(ctx, "docker", args...)
 
 	// Set the working directory for the compose command.
@@ -303,9 +583,9 @@ func runCompose(ctx context.Context, projectDir string, args []string, envVars m
 		cmd.Env = append(cmd.Env, k+"="+v)
 	}
 
-	// CombinedOutput runs the command and captures both stdout and stderr
-	// into a single byte slice. This is useful for error messages.
-	output, err := cmd.CombinedOutput()
+	// Capture stdout and stderr together for error messages, streaming
+	// them live when ComposeProgress is set (see stream.go).
+	output, err := runCommandStreaming(cmd, ComposeProgress)
 	if err != nil {
 		return model.WrapCLIError(
 			model.ExitDockerNotRunning,
@@ -338,7 +618,9 @@ func RunContainer(ctx context.Context, cli *Client, imageName, containerName str
 	args = append(args, runArgs...)
 	args = append(args, imageName)
 
-	// Execute "docker run" as a child process.
+	// Execute "docker run" as a child process. The echoed command redacts
+	// --env values, which may hold secrets.
+	cmdlog.Echo("docker", args, nil)
 	cmd := exec.CommandContext(ctx, "docker", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -353,6 +635,27 @@ func RunContainer(ctx context.Context, cli *Client, imageName, containerName str
 	return nil
 }
 
+// FollowContainerLogs streams the logs of a container with
+// `docker logs --follow` until the container stops or ctx is cancelled.
+// The container's stdout and stderr are written to stdout and stderr.
+//
+// Unlike compose commands, the output is not captured: it is meant to be
+// watched live and may run indefinitely.
+func FollowContainerLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	cmdlog.Echo("docker", []string{"logs", "--follow", containerID}, nil)
+	cmd := exec.CommandContext(ctx, "docker", "logs", "--follow", containerID)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return model.WrapCLIError(
+			model.ExitDockerNotRunning,
+			fmt.Sprintf("failed to follow logs of container %q", containerID),
+			err,
+		)
+	}
+	return nil
+}
+
 // StartContainer starts a stopped container by its ID using the Docker SDK.
 // It sends a start request to the Docker daemon, which resumes the container's
 // main process. If the container is already running, Docker returns an error.
@@ -380,12 +683,13 @@ func StartContainer(ctx context.Context, cli *Client, containerID string) error
 // Docker daemon's default timeout (typically 10 seconds), it is forcefully
 // killed with SIGKILL.
 //
+// timeout overrides that wait in seconds; 0 kills the container right away.
+// nil keeps the default.
+//
 // This is used for Pattern A/B containers that are managed individually
 // rather than through docker compose.
-func StopContainer(ctx context.Context, cli *Client, containerID string) error {
-	// StopOptions with nil Timeout uses Docker's default timeout (10 seconds).
-	// This gives the container a chance to shut down gracefully.
-	err := cli.Inner().ContainerStop(ctx, containerID, container.StopOptions{})
+func StopContainer(ctx context.Context, cli *Client, containerID string, timeout *int) error {
+	err := cli.Inner().ContainerStop(ctx, containerID, stopOptions(timeout))
 	if err != nil {
 		return model.WrapCLIError(
 			model.ExitDockerNotRunning,
@@ -396,6 +700,13 @@ func StopContainer(ctx context.Context, cli *Client, containerID string) error {
 	return nil
 }
 
+// stopOptions returns the options of StopContainer. A nil Timeout uses the
+// container's own stop timeout, or Docker's default (10 seconds). This
+// gives the container a chance to shut down gracefully.
+func stopOptions(timeout *int) container.StopOptions {
+	return container.StopOptions{Timeout: timeout}
+}
+
 // RemoveContainer removes a container by its ID using the Docker SDK.
 // The container must be stopped first unless force is true.
 //
@@ -418,3 +729,21 @@ func RemoveContainer(ctx context.Context, cli *Client, containerID string, force
 	}
 	return nil
 }
+
+// NetworkExists reports whether a Docker network with the given name or ID
+// exists. "loam create --network" uses it to fail before any side effects
+// instead of when the containers start.
+func NetworkExists(ctx context.Context, cli *Client, name string) (bool, error) {
+	_, err := cli.Inner().NetworkInspect(ctx, name, network.InspectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	return false, model.WrapCLIError(
+		model.ExitDockerNotRunning,
+		fmt.Sprintf("failed to inspect network %q", name),
+		err,
+	)
+}
@@ -1,11 +1,19 @@
 package docker
 
 import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/mmr-tortoise/loam/internal/cmdlog"
 	"github.com/mmr-tortoise/loam/internal/model"
 )
 
@@ -208,6 +216,51 @@ func TestBuildWorktreeEnv_Stopped(t *testing.T) {
 		"status should be 'stopped' when all containers are stopped and worktree path exists")
 }
 
+// TestBuildWorktreeEnv_PortOrder verifies that port allocations rebuilt
+// from labels come out sorted by service name, then container port and
+// protocol, no matter how the label map is iterated.
+func TestBuildWorktreeEnv_PortOrder(t *testing.T) {
+	labels := map[string]string{
+		LabelManagedBy:              ManagedByValue,
+		LabelName:                   "test-env",
+		LabelBranch:                 "feature/test",
+		LabelWorktreePath:           "/tmp",
+		LabelSourceRepo:             "/tmp",
+		LabelConfigPattern:          "compose-multi",
+		LabelCreatedAt:              "2026-02-28T10:00:00Z",
+		"loam.original-port.5432":   "15432",
+		"loam.port-service.5432":    "db",
+		"loam.original-port.9229":   "19229",
+		"loam.port-service.9229":    "app",
+		"loam.original-port.3000":   "13000",
+		"loam.port-service.3000":    "app",
+		"loam.original-port.53/udp": "10053",
+		"loam.port-service.53/udp":  "dns",
+		"loam.original-port.53":     "10054",
+		"loam.port-service.53":      "dns",
+		"loam.original-port.6379":   "16379",
+		"loam.port-service.6379":    "cache",
+	}
+	containers := []model.ContainerInfo{{ContainerID: "abc123", Status: "running", Labels: labels}}
+
+	want := []model.PortAllocation{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 9229, HostPort: 19229, Protocol: "tcp"},
+		{ServiceName: "cache", ContainerPort: 6379, HostPort: 16379, Protocol: "tcp"},
+		{ServiceName: "db", ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 10054, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 10053, Protocol: "udp"},
+	}
+
+	// Map iteration order is randomized, so repeated builds would disagree
+	// if the allocations were not sorted.
+	for range 2 {
+		env, err := BuildWorktreeEnv("test-env", containers)
+		require.NoError(t, err)
+		assert.Equal(t, want, env.PortAllocations)
+	}
+}
+
 // TestBuildWorktreeEnv_Orphaned verifies that BuildWorktreeEnv correctly
 // sets the status to "orphaned" when the worktree path no longer exists
 // on disk. This simulates the scenario where a user manually deletes
@@ -306,7 +359,7 @@ func TestDetermineStatus_Running(t *testing.T) {
 func TestDetermineStatus_Stopped(t *testing.T) {
 	containers := []model.ContainerInfo{
 		{Status: "exited"},
-		{Status: "created"},
+		{Status: "dead"},
 	}
 
 	status := determineStatus(containers, "/tmp")
@@ -314,6 +367,122 @@ func TestDetermineStatus_Stopped(t *testing.T) {
 		"should be stopped when no containers are running")
 }
 
+// TestDetermineStatus_Starting verifies that containers which are created
+// but not started, restarting, or running with a healthcheck that has not
+// passed yet make the environment "starting", and that a passed or absent
+// healthcheck does not.
+func TestDetermineStatus_Starting(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []model.ContainerInfo
+		want       model.WorktreeStatus
+	}{
+		{
+			name:       "created",
+			containers: []model.ContainerInfo{{Status: "created"}, {Status: "exited"}},
+			want:       model.StatusStarting,
+		},
+		{
+			name:       "restarting next to running",
+			containers: []model.ContainerInfo{{Status: "running"}, {Status: "restarting"}},
+			want:       model.StatusStarting,
+		},
+		{
+			name:       "health starting",
+			containers: []model.ContainerInfo{{Status: "running", Health: "healthy"}, {Status: "running", Health: "starting"}},
+			want:       model.StatusStarting,
+		},
+		{
+			name:       "healthy",
+			containers: []model.ContainerInfo{{Status: "running", Health: "healthy"}, {Status: "running"}},
+			want:       model.StatusRunning,
+		},
+		{
+			name:       "health starting but exited",
+			containers: []model.ContainerInfo{{Status: "exited", Health: "starting"}},
+			want:       model.StatusStopped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, determineStatus(tt.containers, "/tmp"))
+		})
+	}
+
+	orphaned := []model.ContainerInfo{{Status: "created"}}
+	assert.Equal(t, model.StatusOrphaned, determineStatus(orphaned, "/tmp/loam-nonexistent-path-99999"),
+		"orphaned takes priority over starting")
+}
+
+// TestContainerToInfo_HealthStarting verifies that a healthcheck still
+// starting is read from the status text of the container list response,
+// and that other health states are left to ApplyHealth.
+func TestContainerToInfo_HealthStarting(t *testing.T) {
+	info := containerToInfo(types.Container{ID: "a", State: "running", Status: "Up 3 seconds (health: starting)"})
+	assert.Equal(t, "starting", info.Health)
+
+	info = containerToInfo(types.Container{ID: "b", State: "running", Status: "Up 2 minutes (unhealthy)"})
+	assert.Empty(t, info.Health)
+}
+
+// TestContainerToInfo_Ports verifies that the published ports Docker lists
+// are mapped into the model: unpublished ports are dropped, a port bound on
+// both IPv4 and IPv6 is reported once, and the result is sorted.
+func TestContainerToInfo_Ports(t *testing.T) {
+	info := containerToInfo(types.Container{ID: "a", State: "running", Ports: []types.Port{
+		{IP: "0.0.0.0", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+		{IP: "::", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+		{IP: "127.0.0.1", PrivatePort: 3000, PublicPort: 13000, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: 53, PublicPort: 10053, Type: "udp"},
+		{PrivatePort: 9229, Type: "tcp"},
+	}})
+	assert.Equal(t, []model.PublishedPort{
+		{HostIP: "0.0.0.0", HostPort: 10053, ContainerPort: 53, Protocol: "udp"},
+		{HostIP: "127.0.0.1", HostPort: 13000, ContainerPort: 3000, Protocol: "tcp"},
+		{HostIP: "0.0.0.0", HostPort: 15432, ContainerPort: 5432, Protocol: "tcp"},
+	}, info.Ports)
+
+	info = containerToInfo(types.Container{ID: "b", State: "running"})
+	assert.NotNil(t, info.Ports, "a container without published ports has an empty, known port list")
+	assert.Empty(t, info.Ports)
+}
+
+// TestUnboundPorts verifies that allocations missing from the ports of the
+// running containers are reported, and that nothing is reported while no
+// container runs or the ports are not known.
+func TestUnboundPorts(t *testing.T) {
+	allocations := []model.PortAllocation{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+		{ServiceName: "db", ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 10053, Protocol: "udp"},
+	}
+	env := &model.WorktreeEnv{
+		PortAllocations: allocations,
+		Containers: []model.ContainerInfo{
+			{ServiceName: "app", Status: "running", Ports: []model.PublishedPort{
+				{HostPort: 13000, ContainerPort: 3000, Protocol: "tcp"},
+			}},
+			{ServiceName: "db", Status: "running", Ports: []model.PublishedPort{}},
+			{ServiceName: "dns", Status: "running", Ports: []model.PublishedPort{
+				{HostPort: 10053, ContainerPort: 53, Protocol: "udp"},
+			}},
+		},
+	}
+	assert.Equal(t, []model.PortAllocation{allocations[1]}, UnboundPorts(env))
+
+	stopped := &model.WorktreeEnv{
+		PortAllocations: allocations,
+		Containers:      []model.ContainerInfo{{Status: "exited", Ports: []model.PublishedPort{}}},
+	}
+	assert.Empty(t, UnboundPorts(stopped))
+
+	unknown := &model.WorktreeEnv{
+		PortAllocations: allocations,
+		Containers:      []model.ContainerInfo{{Status: "running"}},
+	}
+	assert.Empty(t, UnboundPorts(unknown))
+}
+
 // TestDetermineStatus_Orphaned verifies the internal determineStatus function
 // returns "orphaned" when the worktree path does not exist on disk,
 // regardless of container states.
@@ -327,3 +496,201 @@ func TestDetermineStatus_Orphaned(t *testing.T) {
 	assert.Equal(t, model.StatusOrphaned, status,
 		"should be orphaned when worktree path does not exist, even if containers are running")
 }
+
+// TestBuildComposeUpArgs verifies that --pull follows the "up" subcommand
+// and is omitted for the default policy.
+func TestBuildComposeUpArgs(t *testing.T) {
+	files := []string{"docker-compose.yml", "docker-compose.worktree.yml"}
+
+	assert.Equal(t,
+		[]string{"compose", "-f", "docker-compose.yml", "-f", "docker-compose.worktree.yml", "up", "-d"},
+		buildComposeUpArgs(files, PullDefault))
+	assert.Equal(t,
+		[]string{"compose", "-f", "docker-compose.yml", "-f", "docker-compose.worktree.yml", "up", "-d", "--pull", "always"},
+		buildComposeUpArgs(files, PullAlways))
+	assert.Equal(t, []string{"compose", "up", "-d", "--pull", "never"}, buildComposeUpArgs(nil, PullNever))
+}
+
+// TestBuildComposeStopArgs verifies that a stop timeout is passed as -t.
+func TestBuildComposeStopArgs(t *testing.T) {
+	files := []string{"docker-compose.yml", "docker-compose.worktree.yml"}
+	timeout := 30
+	zero := 0
+
+	assert.Equal(t,
+		[]string{"compose", "-f", "docker-compose.yml", "-f", "docker-compose.worktree.yml", "stop"},
+		buildComposeStopArgs(files, nil))
+	assert.Equal(t,
+		[]string{"compose", "-f", "docker-compose.yml", "-f", "docker-compose.worktree.yml", "stop", "-t", "30"},
+		buildComposeStopArgs(files, &timeout))
+	assert.Equal(t, []string{"compose", "stop", "-t", "0"}, buildComposeStopArgs(nil, &zero))
+}
+
+// TestStopOptions verifies that a stop timeout reaches the SDK options and
+// that no timeout leaves Docker's default in place.
+func TestStopOptions(t *testing.T) {
+	assert.Nil(t, stopOptions(nil).Timeout)
+
+	timeout := 45
+	opts := stopOptions(&timeout)
+	require.NotNil(t, opts.Timeout)
+	assert.Equal(t, 45, *opts.Timeout)
+}
+
+// TestParsePullPolicy verifies the accepted --pull values.
+func TestParsePullPolicy(t *testing.T) {
+	for _, s := range []string{"", "always", "missing", "never"} {
+		p, err := ParsePullPolicy(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, PullPolicy(s), p)
+	}
+
+	for _, s := range []string{"Always", "if-not-present", "build"} {
+		_, err := ParsePullPolicy(s)
+		assert.Error(t, err, s)
+	}
+}
+
+// fakeInspector is a containerInspector returning canned health states by
+// container ID. Containers missing from health have no healthcheck.
+type fakeInspector struct {
+	health    map[string]string
+	inspected []string
+}
+
+func (f *fakeInspector) ContainerInspect(_ context.Context, containerID string) (types.ContainerJSON, error) {
+	f.inspected = append(f.inspected, containerID)
+	state := &types.ContainerState{Status: "running", Running: true}
+	if status, ok := f.health[containerID]; ok {
+		state.Health = &types.Health{Status: status}
+	}
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: containerID, State: state}}, nil
+}
+
+// TestApplyHealth verifies that a running environment with a container
+// failing its healthcheck becomes unhealthy, that healthy and
+// healthcheck-less containers leave it running, and that stopped
+// containers are not inspected.
+func TestApplyHealth(t *testing.T) {
+	newEnv := func() *model.WorktreeEnv {
+		return &model.WorktreeEnv{
+			Name:   "env-alpha",
+			Status: model.StatusRunning,
+			Containers: []model.ContainerInfo{
+				makeTestContainer("app", "alpha-app-1", "app", "running", "env-alpha", "/tmp"),
+				makeTestContainer("db", "alpha-db-1", "db", "running", "env-alpha", "/tmp"),
+				makeTestContainer("job", "alpha-job-1", "job", "exited", "env-alpha", "/tmp"),
+			},
+		}
+	}
+
+	t.Run("unhealthy container", func(t *testing.T) {
+		api := &fakeInspector{health: map[string]string{"app": "healthy", "db": "unhealthy", "job": "unhealthy"}}
+		env := newEnv()
+		require.NoError(t, applyHealth(context.Background(), api, env))
+		assert.Equal(t, model.StatusUnhealthy, env.Status)
+		assert.Equal(t, "healthy", env.Containers[0].Health)
+		assert.Equal(t, "unhealthy", env.Containers[1].Health)
+		assert.Empty(t, env.Containers[2].Health)
+		assert.Equal(t, []string{"app", "db"}, api.inspected, "stopped containers are not inspected")
+	})
+
+	t.Run("healthy and no healthcheck", func(t *testing.T) {
+		api := &fakeInspector{health: map[string]string{"app": "healthy", "db": "none"}}
+		env := newEnv()
+		require.NoError(t, applyHealth(context.Background(), api, env))
+		assert.Equal(t, model.StatusRunning, env.Status)
+		assert.Equal(t, "healthy", env.Containers[0].Health)
+		assert.Empty(t, env.Containers[1].Health)
+	})
+
+	t.Run("healthcheck starting", func(t *testing.T) {
+		api := &fakeInspector{health: map[string]string{"app": "starting", "db": "none"}}
+		env := newEnv()
+		require.NoError(t, applyHealth(context.Background(), api, env))
+		assert.Equal(t, model.StatusStarting, env.Status)
+		assert.Equal(t, "starting", env.Containers[0].Health)
+	})
+
+	t.Run("unhealthy outweighs starting", func(t *testing.T) {
+		api := &fakeInspector{health: map[string]string{"app": "starting", "db": "unhealthy"}}
+		env := newEnv()
+		env.Status = model.StatusStarting
+		require.NoError(t, applyHealth(context.Background(), api, env))
+		assert.Equal(t, model.StatusUnhealthy, env.Status)
+	})
+
+	t.Run("orphaned environment keeps its status", func(t *testing.T) {
+		api := &fakeInspector{health: map[string]string{"db": "unhealthy"}}
+		env := newEnv()
+		env.Status = model.StatusOrphaned
+		require.NoError(t, applyHealth(context.Background(), api, env))
+		assert.Equal(t, model.StatusOrphaned, env.Status)
+	})
+}
+
+// fakeLister is a containerLister returning canned containers. It records
+// the options of the last call.
+type fakeLister struct {
+	containers []types.Container
+	options    container.ListOptions
+}
+
+func (f *fakeLister) ContainerList(_ context.Context, options container.ListOptions) ([]types.Container, error) {
+	f.options = options
+	return f.containers, nil
+}
+
+// TestListAllPublishedPorts verifies that the published host ports of all
+// running containers are returned sorted and deduplicated, and that
+// exposed-only ports are skipped.
+func TestListAllPublishedPorts(t *testing.T) {
+	api := &fakeLister{containers: []types.Container{
+		{ID: "unrelated", Ports: []types.Port{
+			{IP: "0.0.0.0", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+			{IP: "::", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+			{PrivatePort: 9000, Type: "tcp"},
+		}},
+		{ID: "managed", Labels: map[string]string{LabelManagedBy: ManagedByValue}, Ports: []types.Port{
+			{IP: "0.0.0.0", PrivatePort: 3000, PublicPort: 13000, Type: "tcp"},
+			{IP: "0.0.0.0", PrivatePort: 53, PublicPort: 8053, Type: "udp"},
+		}},
+		{ID: "no-ports"},
+	}}
+
+	ports, err := listAllPublishedPorts(context.Background(), api)
+	require.NoError(t, err)
+	assert.Equal(t, []int{8053, 13000, 15432}, ports)
+	assert.False(t, api.options.All, "stopped containers hold no port bindings")
+	assert.Zero(t, api.options.Filters.Len(), "containers of other tools must be included")
+}
+
+// TestCommandEcho verifies that RunContainer and runCompose echo the docker
+// command line with the flag names but without the values of --env and of
+// the Compose environment. A stub "docker" on PATH stands in for Docker.
+// This test sets PATH and a package variable, so it must NOT use t.Parallel().
+func TestCommandEcho(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the docker stub is a shell script")
+	}
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "docker"), []byte("#!/bin/sh\nexit 0\n"), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var buf bytes.Buffer
+	orig := cmdlog.Output
+	cmdlog.Output = &buf
+	defer func() { cmdlog.Output = orig }()
+
+	require.NoError(t, RunContainer(context.Background(), nil, "node:22", "app-1",
+		[]string{"--env", "API_TOKEN=s3cret", "-e=DB_PASSWORD=hunter2", "-p", "3000:3000"}))
+	require.NoError(t, runCompose(context.Background(), t.TempDir(),
+		buildComposeArgs([]string{"docker-compose.yml"}), map[string]string{"SECRET_KEY": "topsecret"}))
+
+	out := buf.String()
+	assert.Contains(t, out, "$ docker run -d --name app-1 --env API_TOKEN=*** -e=DB_PASSWORD=*** -p 3000:3000 node:22")
+	assert.Contains(t, out, "$ SECRET_KEY=*** docker compose -f docker-compose.yml")
+	for _, secret := range []string{"s3cret", "hunter2", "topsecret"} {
+		assert.NotContains(t, out, secret)
+	}
+}
@@ -2,11 +2,14 @@ package docker
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mmr-tortoise/loam/internal/model"
+	"github.com/mmr-tortoise/loam/internal/port"
 )
 
 // Label key constants define the Docker label keys used to persist
@@ -52,6 +55,13 @@ const (
 	// This allows reconstructing the full port mapping table from labels.
 	LabelOriginalPortPrefix = LabelPrefix + "original-port."
 
+	// LabelPortServicePrefix is the prefix for the labels naming the
+	// service that owns each port, keyed like the LabelOriginalPortPrefix
+	// labels:
+	//   "loam.port-service.5432" = "db"
+	// Optional: absent for ports of older environments.
+	LabelPortServicePrefix = LabelPrefix + "port-service."
+
 	// LabelConfigPattern stores the detected devcontainer.json pattern type.
 	// Key: "loam.config-pattern", Value: one of "image", "dockerfile",
 	// "compose-single", "compose-multi".
@@ -60,6 +70,43 @@ const (
 	// LabelCreatedAt stores the ISO-8601 timestamp of environment creation.
 	// Key: "loam.created-at", Value: RFC3339 formatted timestamp.
 	LabelCreatedAt = LabelPrefix + "created-at"
+
+	// LabelIndex stores the worktree index that selects the port band.
+	// Key: "loam.index", Value: decimal index (e.g., "2").
+	// Optional: containers created before this label existed lack it.
+	LabelIndex = LabelPrefix + "index"
+
+	// LabelProjectName stores the Compose project name set with
+	// create --project-name, so lifecycle commands reuse it.
+	// Key: "loam.project-name", Value: project name (e.g., "acme-api").
+	// Optional: absent when the environment name is the project name.
+	LabelProjectName = LabelPrefix + "project-name"
+
+	// LabelProjectPrefix stores the prefix of the Compose project name (see
+	// model.WorktreeEnv.ProjectPrefix).
+	// Key: "loam.project-prefix", Value: prefix (e.g., "3fa9c2d1").
+	// Optional: absent with --project-name, for other patterns, and for
+	// older environments.
+	LabelProjectPrefix = LabelPrefix + "project-prefix"
+
+	// LabelComposeFiles stores the Compose file chain of a Compose
+	// environment, so lifecycle commands pass Compose the same files as
+	// create did. Key: "loam.compose-files", Value: the paths joined with
+	// the OS path list separator, as in COMPOSE_FILE (e.g.,
+	// "docker-compose.yml:docker-compose.worktree.yml").
+	// Optional: absent for other patterns and for older environments.
+	LabelComposeFiles = LabelPrefix + "compose-files"
+
+	// LabelConfigDir stores the directory holding the generated files of an
+	// environment created with create --config-dir.
+	// Key: "loam.config-dir", Value: absolute path.
+	// Optional: absent when the files are in the worktree.
+	LabelConfigDir = LabelPrefix + "config-dir"
+
+	// LabelShutdownAction stores the shutdownAction of devcontainer.json.
+	// Key: "loam.shutdown-action", Value: e.g. "none" or "stopCompose".
+	// Optional: absent when the configuration does not set it.
+	LabelShutdownAction = LabelPrefix + "shutdown-action"
 )
 
 // ManagedByValue is the constant value for the LabelManagedBy label.
@@ -79,7 +126,14 @@ const ManagedByValue = "loam"
 // This per-port label design avoids encoding/parsing complex structures
 // in a single label value, keeping the labels human-readable when
 // inspecting containers with `docker inspect`.
-func BuildLabels(env *model.WorktreeEnv) map[string]string {
+//
+// The environment is validated first (see model.WorktreeEnv.Validate), so
+// an inconsistent environment is never written to containers.
+func BuildLabels(env *model.WorktreeEnv) (map[string]string, error) {
+	if err := env.Validate(); err != nil {
+		return nil, fmt.Errorf("cannot build labels: %w", err)
+	}
+
 	labels := map[string]string{
 		LabelManagedBy:     ManagedByValue,
 		LabelName:          env.Name,
@@ -93,14 +147,63 @@ func BuildLabels(env *model.WorktreeEnv) map[string]string {
 		LabelCreatedAt: env.CreatedAt.UTC().Format(time.RFC3339),
 	}
 
+	// An unknown index is simply not recorded; ParseLabels will fall back
+	// to inferring it from the port labels.
+	if env.Index >= 0 {
+		labels[LabelIndex] = strconv.Itoa(env.Index)
+	}
+	if env.ProjectName != "" {
+		labels[LabelProjectName] = env.ProjectName
+	}
+	if env.ProjectPrefix != "" {
+		labels[LabelProjectPrefix] = env.ProjectPrefix
+	}
+	if len(env.ComposeFiles) > 0 {
+		labels[LabelComposeFiles] = strings.Join(env.ComposeFiles, string(os.PathListSeparator))
+	}
+	if env.ConfigDir != "" {
+		labels[LabelConfigDir] = env.ConfigDir
+	}
+	if env.ShutdownAction != "" {
+		labels[LabelShutdownAction] = env.ShutdownAction
+	}
+
 	// Encode each port allocation as a separate label.
 	// This approach trades label count for simplicity — each port
 	// mapping is self-contained and independently parseable.
 	for _, pa := range env.PortAllocations {
-		key := BuildPortLabel(pa.ContainerPort)
+		key := BuildProtocolPortLabel(pa.ContainerPort, pa.Protocol)
 		labels[key] = strconv.Itoa(pa.HostPort)
+		if pa.ServiceName != "" {
+			labels[BuildPortServiceLabel(pa.ContainerPort, pa.Protocol)] = pa.ServiceName
+		}
 	}
 
+	return labels, nil
+}
+
+// BuildResourceLabels returns the labels for the networks and volumes of a
+// Compose environment, so that they can be found by the same label filter
+// as its containers (see FilterLabels) and cleaned up if they outlive them.
+//
+// Only labels that stay the same for the lifetime of the environment are
+// included; the creation time and port labels are not. Compose may compare
+// the labels of an existing volume or network with its definition, and a
+// volume is reused across a "remove --keep-worktree" and "create --reuse"
+// cycle.
+func BuildResourceLabels(env *model.WorktreeEnv) map[string]string {
+	labels := map[string]string{
+		LabelManagedBy:    ManagedByValue,
+		LabelName:         env.Name,
+		LabelWorktreePath: env.WorktreePath,
+		LabelSourceRepo:   env.SourceRepoPath,
+	}
+	if env.ProjectName != "" {
+		labels[LabelProjectName] = env.ProjectName
+	}
+	if env.ProjectPrefix != "" {
+		labels[LabelProjectPrefix] = env.ProjectPrefix
+	}
 	return labels
 }
 
@@ -111,6 +214,15 @@ func BuildLabels(env *model.WorktreeEnv) map[string]string {
 // Required labels: managed-by, name, branch, worktree-path, source-repo,
 // config-pattern, created-at. Missing required labels cause an error.
 //
+// The index label is optional for backward compatibility; see
+// WorktreeIndexFromLabels for the fallback. The project name, project
+// prefix, and compose files labels are optional too; each is only present
+// when set.
+//
+// The result is checked with model.WorktreeEnv.ValidateStored rather than
+// Validate, so that environments created by an earlier version, e.g. with
+// a longer name, are still reconstructed.
+//
 // Note: Status and Containers are NOT reconstructed from labels because
 // they are determined at runtime from Docker container state, not from
 // static label values.
@@ -165,7 +277,12 @@ func ParseLabels(labels map[string]string) (*model.WorktreeEnv, error) {
 		return nil, fmt.Errorf("failed to parse port labels: %w", err)
 	}
 
-	return &model.WorktreeEnv{
+	index, err := WorktreeIndexFromLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &model.WorktreeEnv{
 		Name:            labels[LabelName],
 		Branch:          labels[LabelBranch],
 		WorktreePath:    labels[LabelWorktreePath],
@@ -173,7 +290,52 @@ func ParseLabels(labels map[string]string) (*model.WorktreeEnv, error) {
 		ConfigPattern:   pattern,
 		PortAllocations: ports,
 		CreatedAt:       createdAt,
-	}, nil
+		Index:           index,
+		ProjectName:     labels[LabelProjectName],
+		ProjectPrefix:   labels[LabelProjectPrefix],
+		ComposeFiles:    parseComposeFilesLabel(labels[LabelComposeFiles]),
+		ConfigDir:       labels[LabelConfigDir],
+		ShutdownAction:  labels[LabelShutdownAction],
+	}
+	if err := env.ValidateStored(); err != nil {
+		return nil, fmt.Errorf("inconsistent labels: %w", err)
+	}
+	return env, nil
+}
+
+// parseComposeFilesLabel splits a LabelComposeFiles value into paths.
+// An empty value yields nil.
+func parseComposeFilesLabel(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, string(os.PathListSeparator))
+}
+
+// WorktreeIndexFromLabels returns the worktree index recorded in the
+// LabelIndex label. Containers created before that label existed don't have
+// it, so the index is then inferred from the port labels (see
+// port.InferWorktreeIndex); if that fails too, model.UnknownWorktreeIndex
+// is returned.
+//
+// Returns an error only if the index label is present but malformed.
+func WorktreeIndexFromLabels(labels map[string]string) (int, error) {
+	if value, ok := labels[LabelIndex]; ok {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index > port.MaxWorktreeIndex {
+			return 0, fmt.Errorf("invalid label %s=%q: must be an integer 0-%d", LabelIndex, value, port.MaxWorktreeIndex)
+		}
+		return index, nil
+	}
+
+	ports, err := ParsePortLabels(labels)
+	if err != nil {
+		return model.UnknownWorktreeIndex, nil
+	}
+	if index, ok := port.InferWorktreeIndex(ports); ok {
+		return index, nil
+	}
+	return model.UnknownWorktreeIndex, nil
 }
 
 // BuildPortLabel generates a Docker label key for a specific container port.
@@ -186,10 +348,38 @@ func BuildPortLabel(containerPort int) string {
 	return fmt.Sprintf("%s%d", LabelOriginalPortPrefix, containerPort)
 }
 
+// BuildProtocolPortLabel is BuildPortLabel for a port of the given
+// protocol. TCP ports (and an empty protocol) use the plain key, so labels
+// of existing environments keep their meaning; UDP ports get a "/udp"
+// suffix, which also keeps 53/tcp and 53/udp from sharing a key:
+//
+//	BuildProtocolPortLabel(53, "udp") → "loam.original-port.53/udp"
+func BuildProtocolPortLabel(containerPort int, protocol string) string {
+	if protocol == "udp" {
+		return BuildPortLabel(containerPort) + "/udp"
+	}
+	return BuildPortLabel(containerPort)
+}
+
+// BuildPortServiceLabel returns the key of the label naming the service
+// that owns a port (see LabelPortServicePrefix). It has the same suffix as
+// the port's BuildProtocolPortLabel key:
+//
+//	BuildPortServiceLabel(53, "udp") → "loam.port-service.53/udp"
+func BuildPortServiceLabel(containerPort int, protocol string) string {
+	return LabelPortServicePrefix + strings.TrimPrefix(BuildProtocolPortLabel(containerPort, protocol), LabelOriginalPortPrefix)
+}
+
 // ParsePortLabels extracts all port allocation entries from a Docker
 // label map. It scans for labels with the LabelOriginalPortPrefix and
 // parses both the container port (from the key suffix) and the host
-// port (from the label value).
+// port (from the label value). The owning service is taken from the
+// matching LabelPortServicePrefix label; it is empty for environments
+// created before that label existed.
+//
+// The allocations are sorted by service name, then container port and
+// protocol (see sortPortAllocations), so that output built from them does
+// not change with Go's map iteration order.
 //
 // Returns an empty slice (not nil) if no port labels are found.
 // Returns an error if any port label has a malformed key or value.
@@ -205,8 +395,14 @@ func ParsePortLabels(labels map[string]string) ([]model.PortAllocation, error) {
 		}
 
 		// Extract the container port from the key suffix.
-		// For "loam.original-port.3000", the suffix is "3000".
-		portStr := strings.TrimPrefix(key, LabelOriginalPortPrefix)
+		// For "loam.original-port.3000", the suffix is "3000". UDP ports
+		// carry a "/udp" suffix (see BuildProtocolPortLabel).
+		suffix := strings.TrimPrefix(key, LabelOriginalPortPrefix)
+		portStr := suffix
+		protocol := "tcp"
+		if trimmed, ok := strings.CutSuffix(portStr, "/udp"); ok {
+			portStr, protocol = trimmed, "udp"
+		}
 		containerPort, err := strconv.Atoi(portStr)
 		if err != nil {
 			return nil, fmt.Errorf(
@@ -223,19 +419,32 @@ func ParsePortLabels(labels map[string]string) ([]model.PortAllocation, error) {
 		}
 
 		allocations = append(allocations, model.PortAllocation{
+			ServiceName:   labels[LabelPortServicePrefix+suffix],
 			ContainerPort: containerPort,
 			HostPort:      hostPort,
-			// Protocol defaults to "tcp" as specified in the data model.
-			// The protocol is not stored in labels because the vast majority
-			// of use cases are TCP. UDP ports would need a separate label
-			// scheme if ever needed in the future.
-			Protocol: "tcp",
+			Protocol:      protocol,
 		})
 	}
 
+	sortPortAllocations(allocations)
 	return allocations, nil
 }
 
+// sortPortAllocations sorts allocations by service name, then container
+// port, then protocol ("tcp" before "udp").
+func sortPortAllocations(allocations []model.PortAllocation) {
+	sort.Slice(allocations, func(i, j int) bool {
+		a, b := allocations[i], allocations[j]
+		if a.ServiceName != b.ServiceName {
+			return a.ServiceName < b.ServiceName
+		}
+		if a.ContainerPort != b.ContainerPort {
+			return a.ContainerPort < b.ContainerPort
+		}
+		return a.Protocol < b.Protocol
+	})
+}
+
 // FilterLabels returns a label filter map suitable for use with the Docker
 // API's container listing endpoint. The returned map filters for containers
 // that have the LabelManagedBy label set to ManagedByValue, effectively
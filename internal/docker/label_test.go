@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -26,10 +27,12 @@ func TestBuildLabels(t *testing.T) {
 			{ServiceName: "db", ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
 		},
 		CreatedAt: createdAt,
+		Index:     1,
 	}
 
 	// Act
-	labels := BuildLabels(env)
+	labels, err := BuildLabels(env)
+	require.NoError(t, err)
 
 	// Assert: verify all static labels are present and correct.
 	assert.Equal(t, ManagedByValue, labels[LabelManagedBy],
@@ -40,6 +43,7 @@ func TestBuildLabels(t *testing.T) {
 	assert.Equal(t, "/Users/user/repo", labels[LabelSourceRepo])
 	assert.Equal(t, "compose-multi", labels[LabelConfigPattern])
 	assert.Equal(t, "2026-02-28T10:00:00Z", labels[LabelCreatedAt])
+	assert.Equal(t, "1", labels[LabelIndex])
 
 	// Assert: verify port allocation labels.
 	assert.Equal(t, "13000", labels["loam.original-port.3000"],
@@ -47,8 +51,12 @@ func TestBuildLabels(t *testing.T) {
 	assert.Equal(t, "15432", labels["loam.original-port.5432"],
 		"port 5432 should be mapped to host port 15432")
 
-	// Assert: verify total label count (7 static + 2 port = 9).
-	assert.Len(t, labels, 9, "expected 7 static labels + 2 port labels")
+	// Assert: verify the labels naming each port's service.
+	assert.Equal(t, "app", labels["loam.port-service.3000"])
+	assert.Equal(t, "db", labels["loam.port-service.5432"])
+
+	// Assert: verify total label count (8 static + 2 port + 2 port-service = 12).
+	assert.Len(t, labels, 12, "expected 8 static labels + 2 port labels + 2 port-service labels")
 }
 
 // TestBuildLabels_NoPorts verifies that BuildLabels works correctly
@@ -64,10 +72,11 @@ func TestBuildLabels_NoPorts(t *testing.T) {
 		CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 	}
 
-	labels := BuildLabels(env)
+	labels, err := BuildLabels(env)
+	require.NoError(t, err)
 
-	// Should have only the 7 static labels, no port labels.
-	assert.Len(t, labels, 7)
+	// Should have only the 8 static labels, no port labels.
+	assert.Len(t, labels, 8)
 	assert.Equal(t, "image", labels[LabelConfigPattern])
 }
 
@@ -282,6 +291,48 @@ func TestParsePortLabels(t *testing.T) {
 	assert.Equal(t, 18080, portMap[8080])
 }
 
+// TestProtocolPortLabels verifies that UDP ports round-trip through their
+// own label key while TCP keys are unchanged.
+func TestProtocolPortLabels(t *testing.T) {
+	assert.Equal(t, "loam.original-port.53", BuildProtocolPortLabel(53, "tcp"))
+	assert.Equal(t, "loam.original-port.53", BuildProtocolPortLabel(53, ""))
+	assert.Equal(t, "loam.original-port.53/udp", BuildProtocolPortLabel(53, "udp"))
+
+	labels := map[string]string{
+		BuildProtocolPortLabel(53, "tcp"): "10053",
+		BuildProtocolPortLabel(53, "udp"): "10054",
+	}
+	allocations, err := ParsePortLabels(labels)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []model.PortAllocation{
+		{ContainerPort: 53, HostPort: 10053, Protocol: "tcp"},
+		{ContainerPort: 53, HostPort: 10054, Protocol: "udp"},
+	}, allocations)
+}
+
+// TestPortServiceLabels verifies that the owning service of each port is
+// restored from its port-service label, including for UDP ports, and that
+// ports without one (older environments) have no service.
+func TestPortServiceLabels(t *testing.T) {
+	assert.Equal(t, "loam.port-service.5432", BuildPortServiceLabel(5432, "tcp"))
+	assert.Equal(t, "loam.port-service.53/udp", BuildPortServiceLabel(53, "udp"))
+
+	labels := map[string]string{
+		BuildProtocolPortLabel(5432, "tcp"): "15432",
+		BuildPortServiceLabel(5432, "tcp"):  "db",
+		BuildProtocolPortLabel(53, "udp"):   "10053",
+		BuildPortServiceLabel(53, "udp"):    "dns",
+		BuildProtocolPortLabel(3000, "tcp"): "13000",
+	}
+	allocations, err := ParsePortLabels(labels)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []model.PortAllocation{
+		{ServiceName: "db", ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 53, HostPort: 10053, Protocol: "udp"},
+		{ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+	}, allocations)
+}
+
 // TestParsePortLabels_Empty verifies that ParsePortLabels returns an
 // empty slice when no port labels are present.
 func TestParsePortLabels_Empty(t *testing.T) {
@@ -348,10 +399,14 @@ func TestBuildAndParseLabelRoundTrip(t *testing.T) {
 			{ServiceName: "web", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
 		},
 		CreatedAt: createdAt,
+		// Deliberately differs from the index implied by the ports (1) to
+		// prove the label, not port inference, is the source of truth.
+		Index: 4,
 	}
 
 	// Build labels, then parse them back.
-	labels := BuildLabels(original)
+	labels, err := BuildLabels(original)
+	require.NoError(t, err)
 	parsed, err := ParseLabels(labels)
 	require.NoError(t, err)
 
@@ -364,6 +419,7 @@ func TestBuildAndParseLabelRoundTrip(t *testing.T) {
 	assert.Equal(t, original.SourceRepoPath, parsed.SourceRepoPath)
 	assert.Equal(t, original.ConfigPattern, parsed.ConfigPattern)
 	assert.Equal(t, original.CreatedAt.UTC(), parsed.CreatedAt.UTC())
+	assert.Equal(t, original.Index, parsed.Index)
 
 	// Port allocations: compare using maps since order may differ.
 	require.Len(t, parsed.PortAllocations, len(original.PortAllocations))
@@ -372,6 +428,7 @@ func TestBuildAndParseLabelRoundTrip(t *testing.T) {
 		for _, parsedPA := range parsed.PortAllocations {
 			if parsedPA.ContainerPort == origPA.ContainerPort {
 				assert.Equal(t, origPA.HostPort, parsedPA.HostPort)
+				assert.Equal(t, origPA.ServiceName, parsedPA.ServiceName)
 				found = true
 				break
 			}
@@ -379,3 +436,204 @@ func TestBuildAndParseLabelRoundTrip(t *testing.T) {
 		assert.True(t, found, "port allocation for container port %d should be preserved", origPA.ContainerPort)
 	}
 }
+
+// TestBuildAndParseLabels_ProjectName verifies that a --project-name
+// override is stored in its own label and restored by ParseLabels, and that
+// no label is written when the environment name is the project name.
+func TestBuildAndParseLabels_ProjectName(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:           "feature-auth",
+		Branch:         "feature/auth",
+		WorktreePath:   "/tmp/worktree",
+		SourceRepoPath: "/tmp/repo",
+		ConfigPattern:  model.PatternComposeSingle,
+		CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Index:          1,
+	}
+	labels, err := BuildLabels(env)
+	require.NoError(t, err)
+	assert.NotContains(t, labels, LabelProjectName)
+
+	env.ProjectName = "acme-auth"
+	labels, err = BuildLabels(env)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-auth", labels[LabelProjectName])
+
+	parsed, err := ParseLabels(labels)
+	require.NoError(t, err)
+	assert.Equal(t, "acme-auth", parsed.ComposeProjectName())
+	assert.Equal(t, "feature-auth", parsed.Name)
+}
+
+// TestBuildAndParseLabels_ProjectPrefix verifies that the Compose project
+// name prefix round-trips through the labels, so lifecycle commands derive
+// the same project name as create did.
+func TestBuildAndParseLabels_ProjectPrefix(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:           "feature-auth",
+		Branch:         "feature/auth",
+		WorktreePath:   "/tmp/worktree",
+		SourceRepoPath: "/tmp/repo",
+		ConfigPattern:  model.PatternComposeSingle,
+		CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Index:          1,
+	}
+	labels, err := BuildLabels(env)
+	require.NoError(t, err)
+	assert.NotContains(t, labels, LabelProjectPrefix)
+
+	env.ProjectPrefix = "3fa9c2d1"
+	labels, err = BuildLabels(env)
+	require.NoError(t, err)
+	assert.Equal(t, "3fa9c2d1", labels[LabelProjectPrefix])
+	assert.Equal(t, "3fa9c2d1", BuildResourceLabels(env)[LabelProjectPrefix])
+
+	parsed, err := ParseLabels(labels)
+	require.NoError(t, err)
+	assert.Equal(t, "3fa9c2d1-feature-auth", parsed.ComposeProjectName())
+}
+
+// TestBuildAndParseLabels_Inconsistent verifies that BuildLabels refuses
+// an invalid environment and that ParseLabels reports labels that do not
+// form a consistent environment.
+func TestBuildAndParseLabels_Inconsistent(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:           "feature auth",
+		Branch:         "feature/auth",
+		WorktreePath:   "/tmp/worktree",
+		SourceRepoPath: "/tmp/repo",
+		ConfigPattern:  model.PatternImage,
+		CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Index:          1,
+	}
+	_, err := BuildLabels(env)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid environment name")
+
+	env.Name = "feature-auth"
+	labels, err := BuildLabels(env)
+	require.NoError(t, err)
+	// Two container ports claiming the same host port, as after a bad
+	// manual edit.
+	labels[BuildPortLabel(3000)] = "13000"
+	labels[BuildPortLabel(3001)] = "13000"
+	_, err = ParseLabels(labels)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "inconsistent labels")
+	assert.Contains(t, err.Error(), "13000/tcp")
+}
+
+// TestParseLabels_LegacyName verifies that labels written before names were
+// capped at model.MaxNameLength are still parsed, so that such an
+// environment can be listed and removed.
+func TestParseLabels_LegacyName(t *testing.T) {
+	name := strings.Repeat("feature-", 9) + "auth"
+	require.Greater(t, len(name), model.MaxNameLength)
+
+	labels := map[string]string{
+		LabelManagedBy:            ManagedByValue,
+		LabelName:                 name,
+		LabelBranch:               "feature/auth",
+		LabelWorktreePath:         "/Users/user/repo-feature-auth",
+		LabelSourceRepo:           "/Users/user/repo",
+		LabelConfigPattern:        "image",
+		LabelCreatedAt:            "2026-02-28T10:00:00Z",
+		"loam.original-port.3000": "13000",
+	}
+
+	env, err := ParseLabels(labels)
+	require.NoError(t, err)
+	assert.Equal(t, name, env.Name)
+	require.Len(t, env.PortAllocations, 1)
+	assert.Equal(t, 13000, env.PortAllocations[0].HostPort)
+}
+
+// TestBuildAndParseLabels_ComposeFiles verifies that the Compose file chain
+// is stored in one label and restored in order, that the config directory
+// and shutdown action round-trip, and that none of these labels is written
+// when unset.
+func TestBuildAndParseLabels_ComposeFiles(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:           "feature-auth",
+		Branch:         "feature/auth",
+		WorktreePath:   "/tmp/worktree",
+		SourceRepoPath: "/tmp/repo",
+		ConfigPattern:  model.PatternComposeMulti,
+		CreatedAt:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Index:          1,
+	}
+	labels, err := BuildLabels(env)
+	require.NoError(t, err)
+	assert.NotContains(t, labels, LabelComposeFiles)
+	assert.NotContains(t, labels, LabelConfigDir)
+	assert.NotContains(t, labels, LabelShutdownAction)
+
+	env.ShutdownAction = model.ShutdownActionStopCompose
+	env.ComposeFiles = []string{"docker-compose.yml", "docker-compose.worktree.yml", "/home/dev/debug.yml"}
+	env.ConfigDir = "/home/dev/.cache/loam/configs/feature-auth"
+	labels, err = BuildLabels(env)
+	require.NoError(t, err)
+	assert.Contains(t, labels, LabelComposeFiles)
+
+	parsed, err := ParseLabels(labels)
+	require.NoError(t, err)
+	assert.Equal(t, env.ComposeFiles, parsed.ComposeFiles)
+	assert.Equal(t, env.ConfigDir, parsed.ConfigDir)
+	assert.Equal(t, model.ShutdownActionStopCompose, parsed.ShutdownAction)
+	assert.Equal(t, "stopCompose", labels[LabelShutdownAction])
+}
+
+// TestWorktreeIndexFromLabels verifies reading the index label and the
+// fallbacks for containers created before the label existed.
+func TestWorktreeIndexFromLabels(t *testing.T) {
+	t.Run("label present", func(t *testing.T) {
+		index, err := WorktreeIndexFromLabels(map[string]string{LabelIndex: "3"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, index)
+	})
+
+	t.Run("legacy container inferred from ports", func(t *testing.T) {
+		index, err := WorktreeIndexFromLabels(map[string]string{
+			BuildPortLabel(3000): "23000",
+			BuildPortLabel(5432): "25432",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, index)
+	})
+
+	t.Run("legacy container without usable ports", func(t *testing.T) {
+		index, err := WorktreeIndexFromLabels(map[string]string{})
+		require.NoError(t, err)
+		assert.Equal(t, model.UnknownWorktreeIndex, index)
+	})
+
+	t.Run("malformed label", func(t *testing.T) {
+		for _, value := range []string{"abc", "-1", "10"} {
+			_, err := WorktreeIndexFromLabels(map[string]string{LabelIndex: value})
+			assert.Error(t, err, "value %q should be rejected", value)
+		}
+	})
+}
+
+// TestBuildResourceLabels verifies that network and volume labels identify
+// the environment but leave out the labels that change between creates.
+func TestBuildResourceLabels(t *testing.T) {
+	env := &model.WorktreeEnv{
+		Name:            "feature-auth",
+		Branch:          "feature/auth",
+		WorktreePath:    "/tmp/wt",
+		SourceRepoPath:  "/tmp/repo",
+		ProjectName:     "acme-auth",
+		ConfigPattern:   model.PatternComposeMulti,
+		Index:           1,
+		PortAllocations: []model.PortAllocation{{ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"}},
+	}
+
+	assert.Equal(t, map[string]string{
+		LabelManagedBy:    ManagedByValue,
+		LabelName:         "feature-auth",
+		LabelWorktreePath: "/tmp/wt",
+		LabelSourceRepo:   "/tmp/repo",
+		LabelProjectName:  "acme-auth",
+	}, BuildResourceLabels(env))
+}
@@ -0,0 +1,117 @@
+// resource.go finds and removes the networks and volumes of an environment
+// by label.
+//
+// "docker compose down -v" removes the networks and volumes of the Compose
+// project, but only those it can still resolve from the Compose files. A
+// resource left behind — because the files changed, the worktree was
+// deleted by hand, or down failed half-way — would otherwise leak. The
+// Compose override labels every network and volume it creates (see
+// BuildResourceLabels), so they can be found and removed by label instead.
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// resourceAPI is the part of the Docker SDK client used to find and remove
+// networks and volumes. *client.Client implements it; tests substitute a
+// fake.
+type resourceAPI interface {
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	NetworkRemove(ctx context.Context, networkID string) error
+	VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+}
+
+// ListManagedNetworks returns the names of the networks labeled as belonging
+// to the environment envName, sorted. "loam create --attach-to-network"
+// uses it to find the network another environment's containers are on.
+func ListManagedNetworks(ctx context.Context, cli *Client, envName string) ([]string, error) {
+	return listManagedNetworks(ctx, cli.Inner(), envName)
+}
+
+// RemoveManagedNetworks removes the networks labeled as belonging to the
+// environment envName and returns the names of the removed networks. A
+// network that cannot be removed (e.g., because a container outside the
+// environment is still attached) does not stop the others from being
+// removed; the failures are returned joined together.
+func RemoveManagedNetworks(ctx context.Context, cli *Client, envName string) ([]string, error) {
+	return removeManagedNetworks(ctx, cli.Inner(), envName)
+}
+
+// RemoveManagedVolumes removes the volumes labeled as belonging to the
+// environment envName and returns the names of the removed volumes. As with
+// RemoveManagedNetworks, failures do not stop the remaining removals.
+func RemoveManagedVolumes(ctx context.Context, cli *Client, envName string) ([]string, error) {
+	return removeManagedVolumes(ctx, cli.Inner(), envName)
+}
+
+// envResourceFilter matches the networks or volumes of envName.
+func envResourceFilter(envName string) filters.Args {
+	return filters.NewArgs(
+		filters.Arg("label", LabelManagedBy+"="+ManagedByValue),
+		filters.Arg("label", LabelName+"="+envName),
+	)
+}
+
+// listManagedNetworks implements ListManagedNetworks.
+func listManagedNetworks(ctx context.Context, api resourceAPI, envName string) ([]string, error) {
+	networks, err := api.NetworkList(ctx, network.ListOptions{Filters: envResourceFilter(envName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks of environment %q: %w", envName, err)
+	}
+
+	names := make([]string, 0, len(networks))
+	for _, n := range networks {
+		names = append(names, n.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// removeManagedNetworks implements RemoveManagedNetworks.
+func removeManagedNetworks(ctx context.Context, api resourceAPI, envName string) ([]string, error) {
+	networks, err := api.NetworkList(ctx, network.ListOptions{Filters: envResourceFilter(envName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks of environment %q: %w", envName, err)
+	}
+
+	var removed []string
+	var errs []error
+	for _, n := range networks {
+		if err := api.NetworkRemove(ctx, n.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove network %q: %w", n.Name, err))
+			continue
+		}
+		removed = append(removed, n.Name)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// removeManagedVolumes implements RemoveManagedVolumes.
+func removeManagedVolumes(ctx context.Context, api resourceAPI, envName string) ([]string, error) {
+	resp, err := api.VolumeList(ctx, volume.ListOptions{Filters: envResourceFilter(envName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes of environment %q: %w", envName, err)
+	}
+
+	var removed []string
+	var errs []error
+	for _, v := range resp.Volumes {
+		// Not forced: a volume still in use by a container is reported
+		// rather than pulled out from under it.
+		if err := api.VolumeRemove(ctx, v.Name, false); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove volume %q: %w", v.Name, err))
+			continue
+		}
+		removed = append(removed, v.Name)
+	}
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResourceAPI is an in-memory resourceAPI. Like the daemon, it applies
+// the label filters of list requests. Resources named in inUse cannot be
+// removed.
+type fakeResourceAPI struct {
+	networks []network.Summary
+	volumes  []*volume.Volume
+	inUse    map[string]bool
+	removed  []string
+}
+
+func (f *fakeResourceAPI) NetworkList(_ context.Context, options network.ListOptions) ([]network.Summary, error) {
+	var result []network.Summary
+	for _, n := range f.networks {
+		if options.Filters.MatchKVList("label", n.Labels) {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeResourceAPI) NetworkRemove(_ context.Context, networkID string) error {
+	if f.inUse[networkID] {
+		return errors.New("network has active endpoints")
+	}
+	f.removed = append(f.removed, networkID)
+	return nil
+}
+
+func (f *fakeResourceAPI) VolumeList(_ context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	var result volume.ListResponse
+	for _, v := range f.volumes {
+		if options.Filters.MatchKVList("label", v.Labels) {
+			result.Volumes = append(result.Volumes, v)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeResourceAPI) VolumeRemove(_ context.Context, volumeID string, _ bool) error {
+	if f.inUse[volumeID] {
+		return errors.New("volume is in use")
+	}
+	f.removed = append(f.removed, volumeID)
+	return nil
+}
+
+// envLabels returns the resource labels of the environment name.
+func envLabels(name string) map[string]string {
+	return map[string]string{LabelManagedBy: ManagedByValue, LabelName: name}
+}
+
+// TestListManagedNetworks verifies that only the labeled networks of the
+// environment are listed, sorted by name.
+func TestListManagedNetworks(t *testing.T) {
+	api := &fakeResourceAPI{
+		networks: []network.Summary{
+			{ID: "n1", Name: "feature-auth_default", Labels: envLabels("feature-auth")},
+			{ID: "n2", Name: "feature-auth_backend", Labels: envLabels("feature-auth")},
+			{ID: "n3", Name: "feature-login_default", Labels: envLabels("feature-login")},
+			{ID: "n4", Name: "bridge"},
+		},
+	}
+
+	names, err := listManagedNetworks(context.Background(), api, "feature-auth")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature-auth_backend", "feature-auth_default"}, names)
+
+	names, err = listManagedNetworks(context.Background(), api, "feature-none")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+// TestRemoveManagedNetworks verifies that only the labeled networks of the
+// environment are removed, and that a network that cannot be removed is
+// reported without stopping the others.
+func TestRemoveManagedNetworks(t *testing.T) {
+	api := &fakeResourceAPI{
+		networks: []network.Summary{
+			{ID: "n1", Name: "feature-auth_default", Labels: envLabels("feature-auth")},
+			{ID: "n2", Name: "feature-auth_backend", Labels: envLabels("feature-auth")},
+			{ID: "n3", Name: "feature-login_default", Labels: envLabels("feature-login")},
+			{ID: "n4", Name: "bridge"},
+		},
+		inUse: map[string]bool{"n1": true},
+	}
+
+	removed, err := removeManagedNetworks(context.Background(), api, "feature-auth")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `network "feature-auth_default"`)
+	assert.Equal(t, []string{"feature-auth_backend"}, removed)
+	assert.Equal(t, []string{"n2"}, api.removed)
+}
+
+// TestRemoveManagedVolumes verifies that the labeled volumes of the
+// environment are removed and that unlabeled volumes and those of other
+// environments are left alone.
+func TestRemoveManagedVolumes(t *testing.T) {
+	api := &fakeResourceAPI{
+		volumes: []*volume.Volume{
+			{Name: "feature-auth_pgdata", Labels: envLabels("feature-auth")},
+			{Name: "feature-login_pgdata", Labels: envLabels("feature-login")},
+			{Name: "unrelated", Labels: map[string]string{LabelName: "feature-auth"}},
+		},
+	}
+
+	removed, err := removeManagedVolumes(context.Background(), api, "feature-auth")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature-auth_pgdata"}, removed)
+	assert.Equal(t, []string{"feature-auth_pgdata"}, api.removed)
+
+	removed, err = removeManagedVolumes(context.Background(), api, "feature-none")
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
@@ -0,0 +1,99 @@
+// stream.go implements live output streaming for docker compose commands.
+//
+// By default, compose output is buffered and only shown when the command
+// fails. During a slow first-run image pull that means minutes of silence,
+// so when ComposeProgress is set, each output line is forwarded as soon as
+// it is written. Output is still captured in full for the error message.
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ComposeProgress receives docker compose output line by line while the
+// command runs. nil (the default) disables streaming. The CLI sets it to
+// stderr in verbose mode, keeping stdout clean for JSON output.
+var ComposeProgress io.Writer
+
+// layerProgressPattern matches per-layer image pull lines such as
+// "a2abf6c4d29d Downloading [=====>   ]  12.5MB/30MB". A pull emits dozens
+// of these per image; the per-service "Pulling"/"Pulled" lines carry the
+// useful progress, so layer lines are not streamed (they are still captured).
+var layerProgressPattern = regexp.MustCompile(`^[0-9a-f]{12} `)
+
+// isLayerProgressLine reports whether line is per-layer pull noise.
+func isLayerProgressLine(line string) bool {
+	return layerProgressPattern.MatchString(strings.TrimSpace(line))
+}
+
+// runCommandStreaming runs cmd and returns its combined stdout and stderr.
+// When progress is non-nil, every line except per-layer pull progress is
+// also written to progress as soon as it is read.
+func runCommandStreaming(cmd *exec.Cmd, progress io.Writer) ([]byte, error) {
+	if progress == nil {
+		return cmd.CombinedOutput()
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// stdout and stderr are read concurrently, so the shared buffer and
+	// progress writer are guarded by a mutex to keep lines intact.
+	var (
+		mu       sync.Mutex
+		combined bytes.Buffer
+		wg       sync.WaitGroup
+	)
+	forward := func(r io.Reader) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		// Allow long lines (e.g., build output) beyond the 64 KiB default.
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			// Compose redraws progress with carriage returns on some
+			// terminals; keep only the final state of the line.
+			line := scanner.Text()
+			if i := strings.LastIndex(line, "\r"); i >= 0 {
+				line = line[i+1:]
+			}
+
+			mu.Lock()
+			combined.WriteString(line)
+			combined.WriteByte('\n')
+			if !isLayerProgressLine(line) {
+				_, _ = fmt.Fprintln(progress, line)
+			}
+			mu.Unlock()
+		}
+		// Drain anything left (e.g., after an overlong line) so the child
+		// never blocks on a full pipe.
+		_, _ = io.Copy(io.Discard, r)
+	}
+
+	wg.Add(2)
+	go forward(stdout)
+	go forward(stderr)
+
+	// Wait must only be called after all reads from the pipes are done.
+	wg.Wait()
+	err = cmd.Wait()
+
+	return combined.Bytes(), err
+}
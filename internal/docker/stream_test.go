@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineRecorder is an io.Writer that records each write and runs onWrite,
+// letting a test react to a line while the command is still running.
+type lineRecorder struct {
+	mu      sync.Mutex
+	lines   []string
+	onWrite func(line string)
+}
+
+func (r *lineRecorder) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	r.mu.Lock()
+	r.lines = append(r.lines, line)
+	r.mu.Unlock()
+	if r.onWrite != nil {
+		r.onWrite(line)
+	}
+	return len(p), nil
+}
+
+func (r *lineRecorder) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+// fakeCommand returns a command running script in sh, skipping the test if
+// sh is unavailable. The context bounds the test if streaming regresses.
+func fakeCommand(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return exec.CommandContext(ctx, sh, "-c", script)
+}
+
+// TestRunCommandStreaming_Incremental verifies that lines reach the progress
+// writer while the command is still running: the fake command only prints
+// its second line after the test has observed the first.
+func TestRunCommandStreaming_Incremental(t *testing.T) {
+	t.Parallel()
+
+	seen := filepath.Join(t.TempDir(), "seen")
+	cmd := fakeCommand(t, `echo " db Pulling"; while [ ! -f "$SEEN" ]; do sleep 0.01; done; echo " db Pulled"`)
+	cmd.Env = append(os.Environ(), "SEEN="+seen)
+
+	recorder := &lineRecorder{onWrite: func(line string) {
+		if strings.Contains(line, "Pulling") {
+			_ = os.WriteFile(seen, nil, 0o600)
+		}
+	}}
+
+	output, err := runCommandStreaming(cmd, recorder)
+	require.NoError(t, err, "the command would time out if the first line were not streamed")
+	assert.Equal(t, []string{" db Pulling", " db Pulled"}, recorder.Lines())
+	assert.Equal(t, " db Pulling\n db Pulled\n", string(output))
+}
+
+// TestRunCommandStreaming_FiltersLayersAndCapturesFailure verifies that
+// per-layer pull lines are captured but not streamed, that stderr is
+// streamed too, and that a failing command still returns its full output.
+func TestRunCommandStreaming_FiltersLayersAndCapturesFailure(t *testing.T) {
+	t.Parallel()
+
+	cmd := fakeCommand(t, `echo " web Pulling"
+echo "a2abf6c4d29d Downloading [=>   ]  1.2MB/31MB"
+echo " web Pulled"
+echo "Error response from daemon: port is already allocated" >&2
+exit 3`)
+
+	recorder := &lineRecorder{}
+	output, err := runCommandStreaming(cmd, recorder)
+	require.Error(t, err)
+
+	lines := recorder.Lines()
+	assert.Contains(t, lines, " web Pulling")
+	assert.Contains(t, lines, "Error response from daemon: port is already allocated")
+	assert.NotContains(t, lines, "a2abf6c4d29d Downloading [=>   ]  1.2MB/31MB")
+
+	assert.Contains(t, string(output), "a2abf6c4d29d Downloading", "layer lines are still captured")
+	assert.Contains(t, string(output), "port is already allocated")
+}
+
+// TestRunCommandStreaming_NoProgress verifies the buffered path used when
+// streaming is disabled.
+func TestRunCommandStreaming_NoProgress(t *testing.T) {
+	t.Parallel()
+
+	output, err := runCommandStreaming(fakeCommand(t, `echo out; echo err >&2`), nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "out")
+	assert.Contains(t, string(output), "err")
+}
+
+// TestIsLayerProgressLine verifies the per-layer pull line filter.
+func TestIsLayerProgressLine(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isLayerProgressLine("a2abf6c4d29d Pulling fs layer"))
+	assert.True(t, isLayerProgressLine(" 0e4c1b2d3f4a Extracting [==>  ]  2MB/10MB"))
+	assert.False(t, isLayerProgressLine(" db Pulling"))
+	assert.False(t, isLayerProgressLine("Container feature-db-1  Started"))
+}
@@ -11,7 +11,10 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -20,7 +23,7 @@ import (
 // WorktreeStatus represents the lifecycle state of a worktree environment.
 // The state transitions are:
 //
-//	[Created with devcontainer] → Running → Stopped ⇄ Running → [Deleted]
+//	[Created with devcontainer] → Starting → Running → Stopped ⇄ Running → [Deleted]
 //	[Created without devcontainer] → NoContainer → [Deleted]
 //	Running/Stopped → Orphaned (when Git worktree is manually deleted)
 type WorktreeStatus string
@@ -29,6 +32,11 @@ const (
 	// StatusRunning indicates all containers in the environment are running.
 	StatusRunning WorktreeStatus = "running"
 
+	// StatusStarting indicates the containers are on their way up: at
+	// least one is created but not yet started, restarting, or running
+	// with a healthcheck that has not passed yet.
+	StatusStarting WorktreeStatus = "starting"
+
 	// StatusStopped indicates containers exist but are not running.
 	// Configuration and data are preserved.
 	StatusStopped WorktreeStatus = "stopped"
@@ -42,6 +50,15 @@ const (
 	// associated container configuration (no devcontainer.json).
 	// The worktree exists but no Docker resources are managed.
 	StatusNoContainer WorktreeStatus = "no-container"
+
+	// StatusUnhealthy indicates containers are running but at least one
+	// is failing its Docker healthcheck. Health is only checked by
+	// "list --detailed"; elsewhere such an environment is StatusRunning.
+	StatusUnhealthy WorktreeStatus = "unhealthy"
+
+	// StatusUnknown indicates that the container state was not looked up:
+	// "list --no-docker" reports every worktree with it.
+	StatusUnknown WorktreeStatus = "unknown"
 )
 
 // String returns the string representation of WorktreeStatus.
@@ -55,7 +72,7 @@ func (s WorktreeStatus) String() string {
 // predefined valid states.
 func (s WorktreeStatus) IsValid() bool {
 	switch s {
-	case StatusRunning, StatusStopped, StatusOrphaned, StatusNoContainer:
+	case StatusRunning, StatusStarting, StatusStopped, StatusOrphaned, StatusNoContainer, StatusUnhealthy, StatusUnknown:
 		return true
 	default:
 		return false
@@ -67,7 +84,7 @@ func (s WorktreeStatus) IsValid() bool {
 func ParseWorktreeStatus(s string) (WorktreeStatus, error) {
 	status := WorktreeStatus(strings.ToLower(s))
 	if !status.IsValid() {
-		return "", fmt.Errorf("invalid worktree status: %q (valid: running, stopped, orphaned, no-container)", s)
+		return "", fmt.Errorf("invalid worktree status: %q (valid: running, starting, stopped, orphaned, no-container, unhealthy, unknown)", s)
 	}
 	return status, nil
 }
@@ -184,8 +201,148 @@ type WorktreeEnv struct {
 
 	// CreatedAt is the timestamp when this environment was created.
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Index is the worktree index that selects the environment's port band
+	// (host port = container port + Index*10000). UnknownWorktreeIndex if it
+	// could not be determined, e.g., for marker-only environments.
+	Index int `json:"index"`
+
+	// RemoteURL is the URL of the source repository's origin remote.
+	// It is not stored in labels or markers; commands that display it look
+	// it up on demand, and it stays empty for repositories without a remote.
+	RemoteURL string `json:"remoteUrl,omitempty"`
+
+	// ProjectName is the Compose project name given with create
+	// --project-name. Empty means the environment name is used; callers
+	// should use ComposeProjectName rather than reading it directly.
+	ProjectName string `json:"projectName,omitempty"`
+
+	// ProjectPrefix is put in front of the environment name to form the
+	// Compose project name when no ProjectName is given, so that
+	// environments of the same name from different repositories do not
+	// share a project (see DefaultProjectPrefix). Empty means no prefix,
+	// as for environments created before prefixes existed.
+	ProjectPrefix string `json:"projectPrefix,omitempty"`
+
+	// ComposeFiles is the Compose file chain of a Compose environment, in
+	// "-f" order: the configuration's files, the generated override, and
+	// any create --compose-file files. Paths are relative to the
+	// worktree's .devcontainer directory or absolute. Empty if the
+	// environment predates the record or is not Compose-based.
+	ComposeFiles []string `json:"composeFiles,omitempty"`
+
+	// ConfigDir is the directory outside the worktree holding the rewritten
+	// devcontainer.json and the Compose override, set with create
+	// --config-dir. Empty means they are in the worktree's .devcontainer
+	// directory.
+	ConfigDir string `json:"configDir,omitempty"`
+
+	// ShutdownAction is the shutdownAction of devcontainer.json (one of
+	// the ShutdownAction constants), recorded so that stop and remove can
+	// honor it. Empty if the configuration does not set it.
+	ShutdownAction string `json:"shutdownAction,omitempty"`
+}
+
+// Values of the devcontainer.json shutdownAction property.
+const (
+	// ShutdownActionNone asks for the container to be left running when
+	// the editor is closed.
+	ShutdownActionNone = "none"
+
+	// ShutdownActionStopContainer stops the container (the default for
+	// image and Dockerfile configurations).
+	ShutdownActionStopContainer = "stopContainer"
+
+	// ShutdownActionStopCompose stops the whole Compose project (the
+	// default for Compose configurations).
+	ShutdownActionStopCompose = "stopCompose"
+)
+
+// ComposeProjectName returns the Compose project name of the environment:
+// the --project-name override if one was given, otherwise the name, after
+// the project prefix if there is one, normalized with NormalizeProjectName.
+func (e *WorktreeEnv) ComposeProjectName() string {
+	if e.ProjectName != "" {
+		return e.ProjectName
+	}
+	if e.ProjectPrefix != "" {
+		return NormalizeProjectName(e.ProjectPrefix + "-" + e.Name)
+	}
+	return NormalizeProjectName(e.Name)
 }
 
+// Validate checks that the environment is self-consistent before labels
+// are built from it or after it is reconstructed from them: the name must
+// pass ValidateName, the pattern must be valid, the status must be valid if
+// set (it is only determined after reconstruction), and the port
+// allocations must pass ValidatePortAllocations.
+//
+// Labels written before the port service labels were added do not record
+// the service of a port, so allocations without one are checked under the
+// environment name. An allocation that keeps its
+// configured port (index 0) may use a privileged port, which
+// PortAllocation.Validate would reject; such ports are not range-checked.
+func (e *WorktreeEnv) Validate() error {
+	return e.validate(false)
+}
+
+// ValidateStored is Validate for an environment reconstructed from the
+// labels of existing containers. Those may have been written by an earlier
+// version, before names were capped at MaxNameLength and before host ports
+// were range-checked, so these two rules are skipped: an environment that
+// was accepted when it was created must stay visible to list and
+// removable. Host ports used twice are still reported.
+func (e *WorktreeEnv) ValidateStored() error {
+	return e.validate(true)
+}
+
+func (e *WorktreeEnv) validate(stored bool) error {
+	if err := ValidateName(e.Name); err != nil {
+		if !stored || len(e.Name) <= MaxNameLength || !nameRegex.MatchString(e.Name) {
+			return err
+		}
+	}
+	if !e.ConfigPattern.IsValid() {
+		return fmt.Errorf("environment %q: invalid config pattern %q", e.Name, e.ConfigPattern)
+	}
+	if e.Status != "" && !e.Status.IsValid() {
+		return fmt.Errorf("environment %q: invalid status %q", e.Name, e.Status)
+	}
+
+	ports := make([]PortAllocation, 0, len(e.PortAllocations))
+	for _, pa := range e.PortAllocations {
+		if pa.ServiceName == "" {
+			pa.ServiceName = e.Name
+		}
+		if pa.HostPort == pa.ContainerPort && pa.HostPort >= 1 && pa.HostPort < 1024 {
+			continue
+		}
+		ports = append(ports, pa)
+	}
+	validatePorts := ValidatePortAllocations
+	if stored {
+		validatePorts = validateUniqueHostPorts
+	}
+	if err := validatePorts(ports); err != nil {
+		return fmt.Errorf("environment %q: %w", e.Name, err)
+	}
+	return nil
+}
+
+// UnknownWorktreeIndex is the WorktreeEnv.Index value used when the index
+// is not known. Index 0 is a valid index, so a negative sentinel is needed.
+const UnknownWorktreeIndex = -1
+
+// MaxNameLength is the maximum length of an environment name and of a
+// derived Compose project name. It is the DNS label limit: Compose uses the
+// project name in service host names and network aliases, and Docker
+// rejects longer ones only when the containers are created.
+const MaxNameLength = 63
+
+// nameHashLength is the number of hex digits of the hash appended to a
+// truncated name (see TruncateName).
+const nameHashLength = 8
+
 // nameRegex validates environment names: alphanumeric + hyphens only,
 // must start and end with alphanumeric.
 var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]$|^[a-zA-Z0-9]$`)
@@ -197,12 +354,78 @@ func ValidateName(name string) error {
 	if name == "" {
 		return fmt.Errorf("environment name must not be empty")
 	}
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("invalid environment name %q: must be at most %d characters", name, MaxNameLength)
+	}
 	if !nameRegex.MatchString(name) {
 		return fmt.Errorf("invalid environment name %q: must contain only alphanumeric characters and hyphens, and start/end with alphanumeric", name)
 	}
 	return nil
 }
 
+// projectNameRegex mirrors Docker Compose's project name rule: lowercase
+// letters, digits, hyphens, and underscores, starting with a letter or digit.
+var projectNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateProjectName checks if the given name is accepted by Docker Compose
+// as a project name. Compose rejects invalid names only when it runs, so
+// checking up front avoids leaving a half-created environment behind.
+func ValidateProjectName(name string) error {
+	if !projectNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid Compose project name %q: must contain only lowercase letters, digits, hyphens, and underscores, and start with a letter or digit", name)
+	}
+	return nil
+}
+
+// TruncateName shortens name to at most maxLen bytes. A name that fits is
+// returned unchanged. A longer one is cut and suffixed with "-" and a short
+// hash of the full name, so that two long names sharing a prefix still map
+// to different results. Hyphens left at the cut are dropped so the result
+// never contains "--" before the hash.
+func TruncateName(name string, maxLen int) string {
+	return truncateWithHash(name, name, maxLen)
+}
+
+// truncateWithHash implements TruncateName, hashing key instead of s so
+// that callers can hash the name from before normalization.
+func truncateWithHash(s, key string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	return strings.TrimRight(s[:maxLen-len(hash)-1], "-_") + "-" + hash
+}
+
+// NormalizeProjectName derives a Compose project name from an environment
+// name. Compose only accepts lowercase project names, so the name is
+// lowercased; characters Compose rejects are dropped, and a name longer than
+// MaxNameLength is shortened with TruncateName. The hash is taken from the
+// original name, so environments whose names differ only after the cut
+// still get distinct projects. The result always passes ValidateProjectName.
+func NormalizeProjectName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	normalized := strings.TrimLeft(b.String(), "-_")
+	if normalized == "" {
+		normalized = "worktree"
+	}
+	return truncateWithHash(normalized, name, MaxNameLength)
+}
+
+// DefaultProjectPrefix returns the Compose project prefix for environments
+// of the repository at sourceRepoPath: a short hash of the path, so that it
+// differs between repositories (and clones) but stays the same for every
+// environment of one. The result passes ValidateProjectName.
+func DefaultProjectPrefix(sourceRepoPath string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(sourceRepoPath)))
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
 // PortAllocation represents a single port mapping between a container port
 // and a host port within a worktree environment.
 //
@@ -231,6 +454,11 @@ type PortAllocation struct {
 	// Label is an optional human-readable description for this port,
 	// typically sourced from portsAttributes.label in devcontainer.json.
 	Label string `json:"label,omitempty"`
+
+	// HostIP is the host address the port is bound to, carried over from
+	// PortSpec.HostIP so the shifted mapping keeps it. Empty means all
+	// interfaces. It is not recorded in labels.
+	HostIP string `json:"hostIp,omitempty"`
 }
 
 // Validate checks whether the PortAllocation has valid field values.
@@ -268,24 +496,32 @@ func (p *PortAllocation) String() string {
 // individual validity and cross-allocation host port uniqueness.
 // This enforces the "port collision zero" constitution principle.
 func ValidatePortAllocations(allocations []PortAllocation) error {
-	// Track seen host ports to detect duplicates within the same environment.
-	// Key: "hostPort/protocol", Value: service name that owns it.
-	seen := make(map[string]string)
-
+	// Validate each allocation individually first.
 	for i := range allocations {
-		// Validate each allocation individually first.
 		if err := allocations[i].Validate(); err != nil {
 			return err
 		}
+	}
+	return validateUniqueHostPorts(allocations)
+}
 
-		// Build a unique key combining port and protocol to detect duplicates.
-		// Different protocols on the same port are allowed (e.g., 3000/tcp and 3000/udp).
-		key := fmt.Sprintf("%d/%s", allocations[i].HostPort, allocations[i].Protocol)
+// validateUniqueHostPorts reports a host port used by two allocations.
+// Different protocols on the same port are allowed (e.g., 3000/tcp and
+// 3000/udp); an empty protocol counts as tcp.
+func validateUniqueHostPorts(allocations []PortAllocation) error {
+	// Key: "hostPort/protocol", Value: service name that owns it.
+	seen := make(map[string]string)
+	for _, pa := range allocations {
+		proto := pa.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		key := fmt.Sprintf("%d/%s", pa.HostPort, proto)
 		if existingService, exists := seen[key]; exists {
 			return fmt.Errorf("port allocation: host port %s is used by both %q and %q",
-				key, existingService, allocations[i].ServiceName)
+				key, existingService, pa.ServiceName)
 		}
-		seen[key] = allocations[i].ServiceName
+		seen[key] = pa.ServiceName
 	}
 	return nil
 }
@@ -306,9 +542,36 @@ type ContainerInfo struct {
 	// Status is the Docker container status (e.g., "running", "exited", "created").
 	Status string `json:"status"`
 
+	// Health is the Docker healthcheck status of a running container
+	// ("healthy", "unhealthy", "starting"), or empty if the container has
+	// no healthcheck or health was not inspected (see list --detailed).
+	Health string `json:"health,omitempty"`
+
 	// Labels is the full set of Docker labels on the container.
 	// Includes loam management labels (loam.* prefix).
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// Ports are the host ports Docker actually published for the
+	// container. Unlike the loam.port-* labels, which record the intended
+	// allocations, they are missing a port the container failed to bind.
+	Ports []PublishedPort `json:"ports,omitempty"`
+}
+
+// PublishedPort is a container port published on the host, as reported by
+// Docker.
+type PublishedPort struct {
+	// HostIP is the address the port is bound to ("0.0.0.0" for all IPv4
+	// addresses); empty if Docker did not report one.
+	HostIP string `json:"hostIp,omitempty"`
+
+	// HostPort is the port on the host.
+	HostPort int `json:"hostPort"`
+
+	// ContainerPort is the port inside the container.
+	ContainerPort int `json:"containerPort"`
+
+	// Protocol is "tcp" or "udp".
+	Protocol string `json:"protocol"`
 }
 
 // DevContainerConfig represents the parsed and transformed devcontainer.json
@@ -370,8 +633,18 @@ type PortSpec struct {
 	// Protocol is the network protocol (tcp/udp). Defaults to "tcp".
 	Protocol string `json:"protocol"`
 
+	// HostIP is the host address the port is bound to, as given in an
+	// "ip:hostPort:containerPort" appPort entry (e.g., "127.0.0.1").
+	// Empty means all interfaces.
+	HostIP string `json:"hostIp,omitempty"`
+
 	// Label is an optional description from portsAttributes.
 	Label string `json:"label,omitempty"`
+
+	// RequireLocalPort is set by portsAttributes' requireLocalPort: the
+	// port must be published at its own number on the host rather than
+	// shifted.
+	RequireLocalPort bool `json:"requireLocalPort,omitempty"`
 }
 
 // ExitCode defines standard CLI exit codes per the contracts specification.
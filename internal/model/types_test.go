@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,6 +20,9 @@ func TestWorktreeStatus_String(t *testing.T) {
 		{StatusStopped, "stopped"},
 		{StatusOrphaned, "orphaned"},
 		{StatusNoContainer, "no-container"},
+		{StatusUnhealthy, "unhealthy"},
+		{StatusStarting, "starting"},
+		{StatusUnknown, "unknown"},
 	}
 
 	for _, tt := range tests {
@@ -34,6 +38,8 @@ func TestWorktreeStatus_IsValid(t *testing.T) {
 	assert.True(t, StatusStopped.IsValid())
 	assert.True(t, StatusOrphaned.IsValid())
 	assert.True(t, StatusNoContainer.IsValid())
+	assert.True(t, StatusUnhealthy.IsValid())
+	assert.True(t, StatusStarting.IsValid())
 	assert.False(t, WorktreeStatus("invalid").IsValid())
 	assert.False(t, WorktreeStatus("").IsValid())
 }
@@ -50,6 +56,9 @@ func TestParseWorktreeStatus(t *testing.T) {
 		{"stopped", StatusStopped, false},
 		{"orphaned", StatusOrphaned, false},
 		{"no-container", StatusNoContainer, false},
+		{"unhealthy", StatusUnhealthy, false},
+		{"starting", StatusStarting, false},
+		{"unknown", StatusUnknown, false},
 		{"Running", StatusRunning, false},          // case insensitive
 		{"STOPPED", StatusStopped, false},          // case insensitive
 		{"NO-CONTAINER", StatusNoContainer, false}, // case insensitive
@@ -160,16 +169,18 @@ func TestValidateName(t *testing.T) {
 		name     string
 		hasError bool
 	}{
-		{"feature-auth", false},    // valid: alphanumeric with hyphen
-		{"a", false},               // valid: single character
-		{"feature-auth-v2", false}, // valid: multiple hyphens
-		{"abc123", false},          // valid: alphanumeric
-		{"", true},                 // invalid: empty
-		{"-feature", true},         // invalid: starts with hyphen
-		{"feature-", true},         // invalid: ends with hyphen
-		{"feature auth", true},     // invalid: space
-		{"feature_auth", true},     // invalid: underscore
-		{"feature.auth", true},     // invalid: dot
+		{"feature-auth", false},                      // valid: alphanumeric with hyphen
+		{"a", false},                                 // valid: single character
+		{"feature-auth-v2", false},                   // valid: multiple hyphens
+		{"abc123", false},                            // valid: alphanumeric
+		{"", true},                                   // invalid: empty
+		{"-feature", true},                           // invalid: starts with hyphen
+		{"feature-", true},                           // invalid: ends with hyphen
+		{"feature auth", true},                       // invalid: space
+		{"feature_auth", true},                       // invalid: underscore
+		{"feature.auth", true},                       // invalid: dot
+		{strings.Repeat("a", MaxNameLength), false},  // valid: at the limit
+		{strings.Repeat("a", MaxNameLength+1), true}, // invalid: too long
 	}
 
 	for _, tt := range tests {
@@ -184,6 +195,168 @@ func TestValidateName(t *testing.T) {
 	}
 }
 
+// TestValidateProjectName checks the Docker Compose project name rule.
+func TestValidateProjectName(t *testing.T) {
+	tests := []struct {
+		name     string
+		hasError bool
+	}{
+		{"acme-api", false},  // valid: lowercase with hyphen
+		{"acme_api2", false}, // valid: underscore and digit
+		{"1st-env", false},   // valid: starts with a digit
+		{"", true},           // invalid: empty
+		{"Acme", true},       // invalid: uppercase
+		{"acme api", true},   // invalid: space
+		{"-acme", true},      // invalid: starts with hyphen
+		{"_acme", true},      // invalid: starts with underscore
+		{"acme.api", true},   // invalid: dot
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProjectName(tt.name)
+			if tt.hasError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestWorktreeEnv_ComposeProjectName checks that the --project-name override
+// wins over the environment name.
+func TestWorktreeEnv_ComposeProjectName(t *testing.T) {
+	env := &WorktreeEnv{Name: "feature-auth"}
+	assert.Equal(t, "feature-auth", env.ComposeProjectName())
+
+	env.ProjectName = "acme-auth"
+	assert.Equal(t, "acme-auth", env.ComposeProjectName())
+
+	env = &WorktreeEnv{Name: "Feature-Auth"}
+	assert.Equal(t, "feature-auth", env.ComposeProjectName())
+
+	env = &WorktreeEnv{Name: "feature-auth", ProjectPrefix: "acme"}
+	assert.Equal(t, "acme-feature-auth", env.ComposeProjectName())
+
+	env.ProjectName = "acme-auth"
+	assert.Equal(t, "acme-auth", env.ComposeProjectName())
+}
+
+// TestDefaultProjectPrefix checks that the default prefix is stable for a
+// repository, differs between repositories, and is a valid project name.
+func TestDefaultProjectPrefix(t *testing.T) {
+	a := DefaultProjectPrefix("/home/alice/src/api")
+	assert.Equal(t, a, DefaultProjectPrefix("/home/alice/src/api/"))
+	assert.NotEqual(t, a, DefaultProjectPrefix("/home/alice/work/api"))
+	assert.Len(t, a, 8)
+	assert.NoError(t, ValidateProjectName(a))
+}
+
+// TestWorktreeEnv_Validate checks the self-consistency rules of an
+// environment: name, pattern, status, and port allocations.
+func TestWorktreeEnv_Validate(t *testing.T) {
+	valid := func() *WorktreeEnv {
+		return &WorktreeEnv{
+			Name:          "feature-auth",
+			ConfigPattern: PatternComposeMulti,
+			PortAllocations: []PortAllocation{
+				{ServiceName: "app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+				{ContainerPort: 5432, HostPort: 15432, Protocol: "tcp"},
+			},
+		}
+	}
+	require.NoError(t, valid().Validate(), "an unset status and a missing service name are allowed")
+
+	privileged := valid()
+	privileged.PortAllocations = []PortAllocation{{ContainerPort: 80, HostPort: 80, Protocol: "tcp"}}
+	assert.NoError(t, privileged.Validate(), "an unshifted privileged port is kept as configured")
+
+	tests := []struct {
+		name    string
+		modify  func(e *WorktreeEnv)
+		wantErr string
+	}{
+		{"bad name", func(e *WorktreeEnv) { e.Name = "feature/auth" }, "invalid environment name"},
+		{"empty name", func(e *WorktreeEnv) { e.Name = "" }, "must not be empty"},
+		{"invalid pattern", func(e *WorktreeEnv) { e.ConfigPattern = "compose-triple" }, "invalid config pattern"},
+		{"invalid status", func(e *WorktreeEnv) { e.Status = "sleeping" }, "invalid status"},
+		{"duplicate host ports", func(e *WorktreeEnv) { e.PortAllocations[1].HostPort = 13000 }, "host port 13000/tcp is used by both"},
+		{"shifted host port out of range", func(e *WorktreeEnv) { e.PortAllocations[0].HostPort = 70000 }, "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := valid()
+			tt.modify(env)
+			err := env.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+// TestWorktreeEnv_ValidateStored checks that an environment written by an
+// earlier version, with a name longer than MaxNameLength or a host port
+// below 1024, passes ValidateStored but not Validate, and that duplicate
+// host ports and invalid names are still reported.
+func TestWorktreeEnv_ValidateStored(t *testing.T) {
+	legacy := &WorktreeEnv{
+		Name:          strings.Repeat("a", MaxNameLength+5),
+		ConfigPattern: PatternImage,
+		PortAllocations: []PortAllocation{
+			{ContainerPort: 3000, HostPort: 80, Protocol: "tcp"},
+		},
+	}
+	assert.Error(t, legacy.Validate())
+	assert.NoError(t, legacy.ValidateStored())
+
+	legacy.PortAllocations = append(legacy.PortAllocations, PortAllocation{ContainerPort: 3001, HostPort: 80})
+	err := legacy.ValidateStored()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "host port 80/tcp is used by both")
+
+	legacy.PortAllocations = nil
+	legacy.Name = strings.Repeat("a", MaxNameLength) + "/b"
+	assert.Error(t, legacy.ValidateStored())
+}
+
+// TestNormalizeProjectName checks that names are lowercased, that over-long
+// names are truncated with a hash suffix, and that the results are valid
+// and distinct.
+func TestNormalizeProjectName(t *testing.T) {
+	assert.Equal(t, "feature-auth", NormalizeProjectName("feature-auth"))
+	assert.Equal(t, "feature-auth", NormalizeProjectName("Feature-AUTH"))
+	assert.Equal(t, "worktree", NormalizeProjectName("--"))
+
+	long := strings.Repeat("feature-", 10) + "auth"
+	longOther := strings.Repeat("feature-", 10) + "login"
+	got := NormalizeProjectName(long)
+	gotOther := NormalizeProjectName(longOther)
+	assert.Len(t, got, MaxNameLength)
+	assert.NotEqual(t, got, gotOther)
+	assert.Equal(t, got, NormalizeProjectName(long), "normalization must be deterministic")
+
+	for _, name := range []string{"Feature-AUTH", long, strings.ToUpper(long), "x-" + strings.Repeat("A", 100)} {
+		result := NormalizeProjectName(name)
+		assert.NoError(t, ValidateProjectName(result), name)
+		assert.LessOrEqual(t, len(result), MaxNameLength, name)
+		assert.NotContains(t, result, "--", name)
+	}
+}
+
+// TestTruncateName checks that names within the limit are unchanged and
+// that longer names keep a prefix, end in a hash, and stay distinct.
+func TestTruncateName(t *testing.T) {
+	assert.Equal(t, "feature-auth", TruncateName("feature-auth", 20))
+
+	a := TruncateName("feature-authentication-flow", 20)
+	b := TruncateName("feature-authentication-form", 20)
+	assert.Len(t, a, 20)
+	assert.True(t, strings.HasPrefix(a, "feature-aut"), a)
+	assert.NotEqual(t, a, b)
+	assert.NoError(t, ValidateName(a))
+}
+
 // TestPortAllocation_Validate checks individual port allocation validation:
 // - ContainerPort range: 1-65535
 // - HostPort range: 1024-65535
@@ -1,7 +1,9 @@
 package port
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/mmr-tortoise/loam/internal/model"
 )
@@ -25,12 +27,68 @@ const (
 	// dynamicRangeEnd is the end of the dynamic port range.
 	dynamicRangeEnd = 65535
 
-	// maxWorktreeIndex is the maximum supported worktree index (0-9).
+	// MaxWorktreeIndex is the maximum supported worktree index (0-9).
 	// This gives us 10 concurrent environments, which is the design limit
 	// documented in the spec. Index 0 uses original ports unchanged.
-	maxWorktreeIndex = 9
+	MaxWorktreeIndex = 9
+
+	// defaultProbeWorkers bounds how many goroutines AllocatePorts uses to
+	// probe OS port availability concurrently. Each probe is a short
+	// net.Listen/Close pair, so a small pool is enough to hide the latency
+	// of environments with many ports without flooding the network stack.
+	defaultProbeWorkers = 8
+
+	// DefaultMaxEnvironments is the default limit on concurrent environments:
+	// one per worktree index 0..MaxWorktreeIndex.
+	DefaultMaxEnvironments = MaxWorktreeIndex + 1
+
+	// maxShiftSpan is the largest index shift (index * portShiftMultiplier)
+	// the banding scheme supports. Bands past maxPort (indices 7-9) already
+	// take all their ports from the shared dynamic range; more such indices
+	// would exhaust it, so larger limits need a different banding scheme.
+	maxShiftSpan = MaxWorktreeIndex * portShiftMultiplier
 )
 
+// AllocatorConfig holds the configurable limits of port allocation.
+type AllocatorConfig struct {
+	// MaxEnvironments is the number of worktree indices (0 through
+	// MaxEnvironments-1) available to environments. It can only lower the
+	// limit of DefaultMaxEnvironments; see Validate.
+	MaxEnvironments int
+}
+
+// DefaultAllocatorConfig returns the configuration used when nothing is
+// configured.
+func DefaultAllocatorConfig() AllocatorConfig {
+	return AllocatorConfig{MaxEnvironments: DefaultMaxEnvironments}
+}
+
+// MaxIndex returns the highest worktree index allowed by the configuration.
+func (c AllocatorConfig) MaxIndex() int {
+	return c.MaxEnvironments - 1
+}
+
+// Validate checks that the configuration fits the port-banding scheme.
+func (c AllocatorConfig) Validate() error {
+	if c.MaxEnvironments < 1 {
+		return fmt.Errorf("maximum number of environments must be at least 1, got %d", c.MaxEnvironments)
+	}
+	if shift := c.MaxIndex() * portShiftMultiplier; shift > maxShiftSpan {
+		return fmt.Errorf("maximum number of environments %d is too large: index %d would shift ports by %d, "+
+			"past the port space (at most %d environments are supported)",
+			c.MaxEnvironments, c.MaxIndex(), shift, DefaultMaxEnvironments)
+	}
+	return nil
+}
+
+// probeKey identifies a single OS availability probe result.
+// The same port number may be free for UDP but taken for TCP, so the
+// protocol is part of the key.
+type probeKey struct {
+	port     int
+	protocol string
+}
+
 // Allocator computes host port assignments for worktree environments using
 // an offset-based port shifting strategy.
 //
@@ -51,16 +109,86 @@ type Allocator struct {
 	// environments. The allocator checks new allocations against this list
 	// to enforce the zero-collision guarantee across environments.
 	existingAllocations []model.PortAllocation
+
+	// probeWorkers is the maximum number of concurrent OS probes performed
+	// by AllocatePorts. A value of 1 (or less) disables concurrency and
+	// probes every port sequentially.
+	probeWorkers int
+
+	// probeCache holds OS availability results gathered concurrently at the
+	// start of AllocatePorts. It is only populated for the duration of a
+	// single AllocatePorts call and is read exclusively by the (sequential)
+	// assignment phase, so no locking is required when reading it.
+	probeCache map[probeKey]bool
+
+	// config holds the allocation limits; see SetConfig.
+	config AllocatorConfig
+
+	// reservedPorts holds host ports that must not be allocated for any
+	// protocol; see SetReservedPorts.
+	reservedPorts map[int]bool
+
+	// preflight makes AllocatePorts try every port and report all failures
+	// together instead of stopping at the first; see SetPreflight.
+	preflight bool
+
+	// forcedPorts maps container ports to the host ports they are pinned
+	// to; see SetForcedPorts.
+	forcedPorts map[int]int
 }
 
 // NewAllocator creates a new Allocator with the given Scanner.
 // The scanner must not be nil — it is required for port availability checks.
 func NewAllocator(scanner *Scanner) *Allocator {
 	return &Allocator{
-		scanner: scanner,
+		scanner:      scanner,
+		probeWorkers: defaultProbeWorkers,
+		config:       DefaultAllocatorConfig(),
+	}
+}
+
+// SetConfig replaces the allocation limits. The configuration must be
+// valid (see AllocatorConfig.Validate).
+func (a *Allocator) SetConfig(cfg AllocatorConfig) {
+	a.config = cfg
+}
+
+// SetProbeWorkers sets the maximum number of goroutines used to probe port
+// availability in AllocatePorts. Passing 1 (or less) makes AllocatePorts
+// fully sequential, which is useful for debugging and for comparing results.
+func (a *Allocator) SetProbeWorkers(n int) {
+	a.probeWorkers = n
+}
+
+// SetPreflight selects how AllocatePorts handles a port that cannot be
+// allocated. By default it fails fast with that port's error. In preflight
+// mode it still tries the remaining ports and returns one error listing
+// every unresolvable port and why, so an environment with several services
+// shows all of its problems at once.
+func (a *Allocator) SetPreflight(preflight bool) {
+	a.preflight = preflight
+}
+
+// SetReservedPorts registers host ports that are taken by something other
+// than a worktree environment, such as the published ports of unrelated
+// Docker containers. They are avoided for both TCP and UDP, since only the
+// port number is known.
+func (a *Allocator) SetReservedPorts(ports []int) {
+	a.reservedPorts = make(map[int]bool, len(ports))
+	for _, p := range ports {
+		a.reservedPorts[p] = true
 	}
 }
 
+// SetForcedPorts pins container ports to fixed host ports (container port →
+// host port), for services that must be reachable at a known address.
+// AllocatePorts assigns a pinned port as given instead of shifting it, after
+// checking it like any other candidate; it fails rather than falling back to
+// another port. Pinned ports apply to both protocols.
+func (a *Allocator) SetForcedPorts(forced map[int]int) {
+	a.forcedPorts = forced
+}
+
 // SetExistingAllocations registers port allocations from other worktree
 // environments. The allocator will avoid assigning any port that conflicts
 // with these existing allocations.
@@ -80,20 +208,21 @@ func (a *Allocator) SetExistingAllocations(allocs []model.PortAllocation) {
 //  2. Compute shiftedPort = originalPort + (worktreeIndex * 10000).
 //  3. If shiftedPort > 65535, skip to step 5 (overflow).
 //  4. Verify shiftedPort is available (not used by OS, not in existingAllocations).
-//     If available, return it. If not, search upward within the same 10000-block.
+//     If available, return it. If not, search upward to the end of the index's
+//     band (index*10000 .. index*10000+9999), never into another index's band.
 //  5. Fall back: search the IANA dynamic range (49152-65535) for any free port.
 //
 // Parameters:
 //   - originalPort: the port number from the container/Compose definition
-//   - worktreeIndex: 0-based environment index (0-9)
+//   - worktreeIndex: 0-based environment index (0 to the configured MaxIndex)
 //   - serviceName: Docker service name, used for labeling the allocation
 //   - protocol: "tcp" or "udp"
 //
 // Returns the allocated PortAllocation or an error if no port could be assigned.
 func (a *Allocator) AllocatePort(originalPort, worktreeIndex int, serviceName, protocol string) (*model.PortAllocation, error) {
-	// Validate the worktree index against the design limit.
-	if worktreeIndex < 0 || worktreeIndex > maxWorktreeIndex {
-		return nil, fmt.Errorf("worktree index %d out of range (0-%d)", worktreeIndex, maxWorktreeIndex)
+	// Validate the worktree index against the configured limit.
+	if worktreeIndex < 0 || worktreeIndex > a.config.MaxIndex() {
+		return nil, fmt.Errorf("worktree index %d out of range (0-%d)", worktreeIndex, a.config.MaxIndex())
 	}
 
 	// Default protocol to TCP if unspecified, matching Docker's default behavior.
@@ -101,17 +230,9 @@ func (a *Allocator) AllocatePort(originalPort, worktreeIndex int, serviceName, p
 		protocol = "tcp"
 	}
 
-	var hostPort int
-
-	if worktreeIndex == 0 {
-		// Index 0 is the primary worktree — use the original port as-is.
-		// This means the first worktree behaves identically to a standard
-		// devcontainer setup, reducing surprise for users.
-		hostPort = originalPort
-	} else {
-		// Apply the deterministic shift formula.
-		hostPort = originalPort + (worktreeIndex * portShiftMultiplier)
-	}
+	// Apply the deterministic shift formula. Index 0 is the primary
+	// worktree and keeps the original port (see shiftPort).
+	hostPort := shiftPort(originalPort, worktreeIndex)
 
 	// Check if the shifted port exceeds the valid range.
 	if hostPort > maxPort {
@@ -126,15 +247,16 @@ func (a *Allocator) AllocatePort(originalPort, worktreeIndex int, serviceName, p
 		hostPort = fallbackPort
 	} else if !a.isPortAvailableForAllocation(hostPort, protocol) {
 		// The shifted port is within range but already in use. Try to find
-		// the next available port within the same 10000-block.
+		// the next available port within this worktree index's band.
 		//
-		// The block boundaries ensure we don't accidentally step into another
-		// worktree's port range. For index 1, the block is 10000-19999.
+		// The search is bounded by the band (index*10000 .. index*10000+9999),
+		// not by hostPort+9999: starting the block at the shifted port would
+		// let a high port like 19999 at index 1 continue into 20000+, which
+		// belongs to index 2. For index 1, the search never leaves 10000-19999.
+		// A shifted port that already lies past its band (original >= 10000)
+		// has no room to search, so it goes straight to the dynamic range.
 		blockStart := hostPort
-		blockEnd := hostPort + portShiftMultiplier - 1
-		if blockEnd > maxPort {
-			blockEnd = maxPort
-		}
+		_, blockEnd := bandBounds(worktreeIndex)
 
 		found := false
 		for candidate := blockStart + 1; candidate <= blockEnd; candidate++ {
@@ -171,37 +293,109 @@ func (a *Allocator) AllocatePort(originalPort, worktreeIndex int, serviceName, p
 // collisions (e.g., two services both wanting port 3000 at the same index).
 // Each successful allocation is temporarily added to existingAllocations so
 // subsequent ports in the same batch can see it.
+//
+// OS availability of each port's primary (shifted) candidate is probed
+// concurrently by a bounded worker pool before any assignment happens. The
+// assignment itself stays single-threaded and walks the ports in input order,
+// so the result is identical to a fully sequential run for the same inputs.
+//
+// Ports pinned with SetForcedPorts are assigned before all others, so that
+// a shifted port earlier in the list cannot take a pinned host port. The
+// result is still in input order.
+//
+// A port that cannot be allocated fails the whole call; in preflight mode
+// (see SetPreflight) the error lists every such port.
 func (a *Allocator) AllocatePorts(ports []model.PortSpec, worktreeIndex int) ([]model.PortAllocation, error) {
-	allocations := make([]model.PortAllocation, 0, len(ports))
+	assigned := make([]*model.PortAllocation, len(ports))
+	var failures []error
 
-	for _, ps := range ports {
-		// Use ContainerPort as the base for shifting. The HostPort in PortSpec
-		// may be 0 (e.g., from forwardPorts which only specifies container ports),
-		// so we always shift based on ContainerPort for consistency.
-		proto := ps.Protocol
-		if proto == "" {
-			proto = "tcp"
-		}
+	// Warm the probe cache concurrently, then make sure it does not outlive
+	// this call — a later AllocatePorts must observe fresh OS state.
+	if a.probeWorkers > 1 {
+		a.probeCache = a.prefetchAvailability(ports, worktreeIndex)
+		defer func() { a.probeCache = nil }()
+	}
 
-		alloc, err := a.AllocatePort(ps.ContainerPort, worktreeIndex, ps.ServiceName, proto)
-		if err != nil {
-			return nil, fmt.Errorf("failed to allocate port for %s:%d: %w", ps.ServiceName, ps.ContainerPort, err)
-		}
+	for _, forcedPass := range []bool{true, false} {
+		for i, ps := range ports {
+			hostPort, forced := a.forcedPorts[ps.ContainerPort]
+			if forced != forcedPass {
+				continue
+			}
+
+			// Use ContainerPort as the base for shifting. The HostPort in PortSpec
+			// may be 0 (e.g., from forwardPorts which only specifies container ports),
+			// so we always shift based on ContainerPort for consistency.
+			proto := ps.Protocol
+			if proto == "" {
+				proto = "tcp"
+			}
 
-		// Copy the label from the original port spec.
-		alloc.Label = ps.Label
+			var alloc *model.PortAllocation
+			var err error
+			if forced {
+				alloc, err = a.allocateForcedPort(ps.ContainerPort, hostPort, ps.ServiceName, proto)
+			} else {
+				alloc, err = a.AllocatePort(ps.ContainerPort, worktreeIndex, ps.ServiceName, proto)
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to allocate port for %s:%d: %w", ps.ServiceName, ps.ContainerPort, err)
+				if !a.preflight {
+					return nil, err
+				}
+				failures = append(failures, err)
+				continue
+			}
 
-		// Register this allocation so subsequent ports in the same batch
-		// won't collide with it. This is critical for correctness when
-		// multiple services expose ports that would shift to the same value.
-		a.existingAllocations = append(a.existingAllocations, *alloc)
+			// Copy the label and bind address from the original port spec.
+			alloc.Label = ps.Label
+			alloc.HostIP = ps.HostIP
 
-		allocations = append(allocations, *alloc)
+			// Register this allocation so subsequent ports in the same batch
+			// won't collide with it. This is critical for correctness when
+			// multiple services expose ports that would shift to the same value.
+			a.existingAllocations = append(a.existingAllocations, *alloc)
+
+			assigned[i] = alloc
+		}
+	}
+
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("%d of %d port(s) could not be allocated:\n%w",
+			len(failures), len(ports), errors.Join(failures...))
 	}
 
+	allocations := make([]model.PortAllocation, 0, len(ports))
+	for _, alloc := range assigned {
+		allocations = append(allocations, *alloc)
+	}
 	return allocations, nil
 }
 
+// allocateForcedPort assigns the pinned hostPort to containerPort (see
+// SetForcedPorts). Unlike AllocatePort, it never searches for another port:
+// a pinned port that is taken is an error. A port pinned to its own number
+// (requireLocalPort) may be privileged, as it would be without loam; other
+// pins must be in 1024-65535.
+func (a *Allocator) allocateForcedPort(containerPort, hostPort int, serviceName, protocol string) (*model.PortAllocation, error) {
+	minPort := 1024
+	if hostPort == containerPort {
+		minPort = 1
+	}
+	if hostPort < minPort || hostPort > maxPort {
+		return nil, fmt.Errorf("pinned host port %d out of range (%d-%d)", hostPort, minPort, maxPort)
+	}
+	if !a.isPortAvailableForAllocation(hostPort, protocol) {
+		return nil, fmt.Errorf("pinned host port %d/%s is in use or allocated to another environment", hostPort, protocol)
+	}
+	return &model.PortAllocation{
+		ServiceName:   serviceName,
+		ContainerPort: containerPort,
+		HostPort:      hostPort,
+		Protocol:      protocol,
+	}, nil
+}
+
 // isPortAvailableForAllocation checks both the OS-level availability via Scanner
 // AND that the port doesn't conflict with any existing allocations from other
 // worktree environments.
@@ -210,7 +404,13 @@ func (a *Allocator) AllocatePorts(ports []model.PortSpec, worktreeIndex int) ([]
 //   - Scanner catches ports used by non-worktree processes (e.g., a local MySQL)
 //   - existingAllocations catches ports used by other worktree environments that
 //     might be stopped (containers not running, so Scanner wouldn't detect them)
+//
+// Reserved ports (see SetReservedPorts) are never available.
 func (a *Allocator) isPortAvailableForAllocation(port int, protocol string) bool {
+	if a.reservedPorts[port] {
+		return false
+	}
+
 	// First, check against known allocations from other worktree environments.
 	for _, alloc := range a.existingAllocations {
 		if alloc.HostPort == port && alloc.Protocol == protocol {
@@ -218,10 +418,141 @@ func (a *Allocator) isPortAvailableForAllocation(port int, protocol string) bool
 		}
 	}
 
+	// Reuse a result from the concurrent prefetch when one exists, so the
+	// common case (the shifted port is free) costs no extra probe.
+	if available, ok := a.probeCache[probeKey{port: port, protocol: protocol}]; ok {
+		return available
+	}
+
 	// Then, check the OS to see if the port is actually free on the host.
 	return a.scanner.IsPortAvailable(port, protocol)
 }
 
+// prefetchAvailability probes the primary shifted (or pinned) candidate of
+// every port spec concurrently and returns the results keyed by port and
+// protocol.
+//
+// Only the OS probe runs in parallel. Each worker writes to its own slot in a
+// pre-sized results slice (indexed by job number), so the goroutines never
+// share mutable state; the map is built afterwards on the calling goroutine.
+// existingAllocations is never touched here, which keeps the later
+// sequential assignment phase free of data races.
+func (a *Allocator) prefetchAvailability(ports []model.PortSpec, worktreeIndex int) map[probeKey]bool {
+	// Collect the unique, in-range candidates in input order.
+	keys := make([]probeKey, 0, len(ports))
+	seen := make(map[probeKey]bool, len(ports))
+	for _, ps := range ports {
+		proto := ps.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		candidate := shiftPort(ps.ContainerPort, worktreeIndex)
+		if forced, ok := a.forcedPorts[ps.ContainerPort]; ok {
+			candidate = forced
+		}
+		if candidate < 1 || candidate > maxPort {
+			// Overflowing ports go straight to the dynamic range search,
+			// so probing them here would be wasted work.
+			continue
+		}
+		key := probeKey{port: candidate, protocol: proto}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	results := make([]bool, len(keys))
+
+	workers := a.probeWorkers
+	if workers > len(keys) {
+		workers = len(keys)
+	}
+
+	// Fan out: a fixed number of goroutines drain a channel of job indexes.
+	// sync.WaitGroup lets us block until every worker has finished.
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = a.scanner.IsPortAvailable(keys[i].port, keys[i].protocol)
+			}
+		}()
+	}
+	for i := range keys {
+		jobs <- i
+	}
+	// Closing the channel ends each worker's range loop.
+	close(jobs)
+	wg.Wait()
+
+	cache := make(map[probeKey]bool, len(keys))
+	for i, key := range keys {
+		cache[key] = results[i]
+	}
+	return cache
+}
+
+// bandBounds returns the inclusive port range reserved for a worktree index:
+// [index*10000, index*10000+9999], capped at maxPort for the top band.
+//
+// Example: bandBounds(1) → (10000, 19999); bandBounds(6) → (60000, 65535).
+func bandBounds(worktreeIndex int) (start, end int) {
+	start = worktreeIndex * portShiftMultiplier
+	end = start + portShiftMultiplier - 1
+	if end > maxPort {
+		end = maxPort
+	}
+	return start, end
+}
+
+// InferWorktreeIndex reconstructs the worktree index of an existing
+// environment from its port allocations, for environments whose index was
+// not recorded anywhere else.
+//
+// Each allocation whose host port equals containerPort + k*10000 votes for
+// index k. Allocations that were moved by the in-band or dynamic-range
+// fallback don't fit the formula and are ignored. The index with the most
+// votes wins (ties go to the lower index).
+//
+// Returns false if no allocation fits the shift formula.
+func InferWorktreeIndex(allocs []model.PortAllocation) (int, bool) {
+	votes := make(map[int]int)
+	for _, pa := range allocs {
+		diff := pa.HostPort - pa.ContainerPort
+		if diff < 0 || diff%portShiftMultiplier != 0 {
+			continue
+		}
+		idx := diff / portShiftMultiplier
+		if idx > MaxWorktreeIndex {
+			continue
+		}
+		votes[idx]++
+	}
+
+	best, bestVotes := 0, 0
+	for idx, n := range votes {
+		if n > bestVotes || (n == bestVotes && idx < best) {
+			best, bestVotes = idx, n
+		}
+	}
+	return best, bestVotes > 0
+}
+
+// shiftPort applies the deterministic shift formula. Index 0 is the primary
+// worktree and keeps the original port, so the first worktree behaves
+// identically to a standard devcontainer setup.
+func shiftPort(originalPort, worktreeIndex int) int {
+	if worktreeIndex == 0 {
+		return originalPort
+	}
+	return originalPort + (worktreeIndex * portShiftMultiplier)
+}
+
 // findAvailablePortExcludingExisting searches a port range for the first port
 // that is both OS-available and not in the existing allocations list.
 //
@@ -101,6 +101,36 @@ func TestAllocatePort_InvalidIndex(t *testing.T) {
 	assert.Error(t, err, "negative index should be rejected")
 }
 
+// TestAllocatePort_ConfiguredLimit verifies that a lowered environment limit
+// rejects indices past it.
+func TestAllocatePort_ConfiguredLimit(t *testing.T) {
+	allocator := NewAllocator(NewScanner())
+	allocator.SetConfig(AllocatorConfig{MaxEnvironments: 3})
+
+	_, err := allocator.AllocatePort(3000, 3, "app", "tcp")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of range (0-2)")
+
+	_, err = allocator.AllocatePort(3000, 2, "app", "tcp")
+	assert.NoError(t, err)
+}
+
+// TestAllocatorConfig_Validate verifies that the limit must be positive and
+// keep the largest index shift within the port-banding scheme.
+func TestAllocatorConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, DefaultAllocatorConfig().Validate())
+	assert.Equal(t, MaxWorktreeIndex, DefaultAllocatorConfig().MaxIndex())
+	assert.NoError(t, AllocatorConfig{MaxEnvironments: 1}.Validate())
+	assert.NoError(t, AllocatorConfig{MaxEnvironments: 5}.Validate())
+
+	assert.Error(t, AllocatorConfig{MaxEnvironments: 0}.Validate())
+	err := AllocatorConfig{MaxEnvironments: DefaultMaxEnvironments + 1}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "past the port space")
+}
+
 // TestAllocatePorts_MultipleServices verifies that allocating ports for
 // multiple services at once produces the correct shifted ports.
 //
@@ -303,3 +333,342 @@ func TestAllocatePorts_ExistingAndExternalConflict(t *testing.T) {
 	assert.NotEqual(t, 13000, alloc.HostPort, "should avoid existing allocation")
 	assert.NotEqual(t, 13001, alloc.HostPort, "should avoid externally occupied port")
 }
+
+// TestAllocatePorts_SkipProbeUsesLabelsOnly verifies that with OS probing
+// disabled (remote Docker hosts), an externally occupied port is allocated
+// while existing allocations from labels are still avoided.
+func TestAllocatePorts_SkipProbeUsesLabelsOnly(t *testing.T) {
+	listener, err := net.Listen("tcp", ":13000")
+	if err != nil {
+		t.Skip("port 13000 is in use on this machine")
+	}
+	defer func() { _ = listener.Close() }()
+
+	scanner := NewScanner()
+	scanner.SetSkipProbe(true)
+	allocator := NewAllocator(scanner)
+
+	alloc, err := allocator.AllocatePort(3000, 1, "app", "tcp")
+	require.NoError(t, err)
+	assert.Equal(t, 13000, alloc.HostPort, "the local listener must not be probed")
+
+	allocator.SetExistingAllocations([]model.PortAllocation{
+		{ServiceName: "other-app", ContainerPort: 3000, HostPort: 13000, Protocol: "tcp"},
+	})
+	alloc, err = allocator.AllocatePort(3000, 1, "app", "tcp")
+	require.NoError(t, err)
+	assert.Equal(t, 13001, alloc.HostPort, "label-based conflicts are still detected")
+}
+
+// TestAllocatePorts_KeepsLabelAndHostIP verifies that the label and bind
+// address of a port spec are carried over to its allocation.
+func TestAllocatePorts_KeepsLabelAndHostIP(t *testing.T) {
+	scanner := NewScanner()
+	scanner.SetSkipProbe(true)
+	allocator := NewAllocator(scanner)
+
+	allocs, err := allocator.AllocatePorts([]model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 3000, Protocol: "tcp", HostIP: "127.0.0.1", Label: "Web"},
+	}, 1)
+	require.NoError(t, err)
+	require.Len(t, allocs, 1)
+	assert.Equal(t, "127.0.0.1", allocs[0].HostIP)
+	assert.Equal(t, "Web", allocs[0].Label)
+}
+
+// TestAllocatePort_ReservedPorts verifies that reserved ports, such as
+// those published by unrelated containers, are skipped for both protocols.
+func TestAllocatePort_ReservedPorts(t *testing.T) {
+	scanner := NewScanner()
+	scanner.SetSkipProbe(true)
+	allocator := NewAllocator(scanner)
+	allocator.SetReservedPorts([]int{13000, 13001})
+
+	alloc, err := allocator.AllocatePort(3000, 1, "app", "tcp")
+	require.NoError(t, err)
+	assert.Equal(t, 13002, alloc.HostPort)
+
+	alloc, err = allocator.AllocatePort(3000, 1, "app", "udp")
+	require.NoError(t, err)
+	assert.Equal(t, 13002, alloc.HostPort)
+}
+
+// TestAllocatePorts_ForcedPorts verifies that pinned ports keep their host
+// port while the others are shifted around them, that a pinned port that is
+// taken or out of range fails instead of moving, and that a privileged port
+// may be pinned to itself.
+func TestAllocatePorts_ForcedPorts(t *testing.T) {
+	scanner := NewScanner()
+	scanner.SetSkipProbe(true)
+	allocator := NewAllocator(scanner)
+	// 13001 is where 3001 would shift to; pinning 5432 there pushes the
+	// shifted 3001, which comes first, to the next free port.
+	allocator.SetForcedPorts(map[int]int{3000: 4000, 5432: 13001})
+
+	allocs, err := allocator.AllocatePorts([]model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 3001, Protocol: "tcp"},
+		{ServiceName: "db", ContainerPort: 5432, Protocol: "tcp"},
+	}, 1)
+	require.NoError(t, err)
+	require.Len(t, allocs, 3)
+	assert.Equal(t, 4000, allocs[0].HostPort, "pinned port skips the shift formula")
+	assert.Equal(t, 13002, allocs[1].HostPort, "shifted port avoids the pinned one")
+	assert.Equal(t, 13001, allocs[2].HostPort)
+	assert.Equal(t, "db", allocs[2].ServiceName, "result stays in input order")
+
+	allocator = NewAllocator(scanner)
+	allocator.SetForcedPorts(map[int]int{3000: 4000})
+	allocator.SetExistingAllocations([]model.PortAllocation{
+		{ServiceName: "app", ContainerPort: 3000, HostPort: 4000, Protocol: "tcp"},
+	})
+	_, err = allocator.AllocatePorts([]model.PortSpec{{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp"}}, 2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pinned host port 4000/tcp")
+
+	allocator = NewAllocator(scanner)
+	allocator.SetForcedPorts(map[int]int{3000: 80})
+	_, err = allocator.AllocatePorts([]model.PortSpec{{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp"}}, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of range")
+
+	// A privileged port pinned to itself (requireLocalPort) is accepted.
+	allocator = NewAllocator(scanner)
+	allocator.SetForcedPorts(map[int]int{80: 80})
+	allocs, err = allocator.AllocatePorts([]model.PortSpec{{ServiceName: "app", ContainerPort: 80, Protocol: "tcp"}}, 0)
+	require.NoError(t, err)
+	require.Len(t, allocs, 1)
+	assert.Equal(t, 80, allocs[0].HostPort)
+}
+
+// exhaustDynamicRange makes every TCP port of the dynamic range report as
+// taken, through the probe cache, so that overflowing ports cannot be
+// allocated. Probing must be sequential for the cache to be kept.
+func exhaustDynamicRange(allocator *Allocator) {
+	allocator.SetProbeWorkers(1)
+	allocator.probeCache = make(map[probeKey]bool)
+	for p := dynamicRangeStart; p <= dynamicRangeEnd; p++ {
+		allocator.probeCache[probeKey{port: p, protocol: "tcp"}] = false
+	}
+}
+
+// TestAllocatePorts_Preflight verifies that preflight mode reports every
+// unresolvable port in one error, while the default mode stops at the
+// first.
+func TestAllocatePorts_Preflight(t *testing.T) {
+	// At index 7, every port from 2536 up overflows into the dynamic range.
+	ports := []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp"},
+		{ServiceName: "app", ContainerPort: 53, Protocol: "udp"},
+		{ServiceName: "db", ContainerPort: 5432, Protocol: "tcp"},
+	}
+
+	allocator := NewAllocator(NewScanner())
+	exhaustDynamicRange(allocator)
+	_, err := allocator.AllocatePorts(ports, 7)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app:3000")
+	assert.NotContains(t, err.Error(), "db:5432", "the default mode must fail fast")
+
+	allocator = NewAllocator(NewScanner())
+	exhaustDynamicRange(allocator)
+	allocator.SetPreflight(true)
+	allocs, err := allocator.AllocatePorts(ports, 7)
+	require.Error(t, err)
+	assert.Nil(t, allocs)
+	assert.Contains(t, err.Error(), "2 of 3 port(s) could not be allocated")
+	assert.Contains(t, err.Error(), "app:3000")
+	assert.Contains(t, err.Error(), "db:5432")
+	assert.Contains(t, err.Error(), "port overflow")
+	assert.NotContains(t, err.Error(), "app:53", "allocatable ports are not reported")
+}
+
+// TestBandBounds verifies that each worktree index owns an aligned
+// 10000-port band, with the top band capped at the maximum port number.
+func TestBandBounds(t *testing.T) {
+	tests := []struct {
+		index      int
+		start, end int
+	}{
+		{index: 0, start: 0, end: 9999},
+		{index: 1, start: 10000, end: 19999},
+		{index: 5, start: 50000, end: 59999},
+		{index: 6, start: 60000, end: 65535},
+	}
+
+	for _, tt := range tests {
+		start, end := bandBounds(tt.index)
+		assert.Equal(t, tt.start, start, "band start for index %d", tt.index)
+		assert.Equal(t, tt.end, end, "band end for index %d", tt.index)
+	}
+}
+
+// TestAllocatePort_FallbackStaysInBand verifies that when the shifted port is
+// taken, the in-band search never steps into the next index's band. Port 9999
+// at index 1 shifts to 19999, the last port of band 1; the old behavior
+// (block = [hostPort, hostPort+9999]) would have picked 20000 from band 2.
+func TestAllocatePort_FallbackStaysInBand(t *testing.T) {
+	allocator := NewAllocator(NewScanner())
+	allocator.SetExistingAllocations([]model.PortAllocation{
+		{ServiceName: "other", ContainerPort: 9999, HostPort: 19999, Protocol: "tcp"},
+	})
+
+	alloc, err := allocator.AllocatePort(9999, 1, "app", "tcp")
+	require.NoError(t, err)
+
+	_, band1End := bandBounds(1)
+	assert.False(t, alloc.HostPort > band1End && alloc.HostPort < dynamicRangeStart,
+		"fallback port %d must not leak into another index's band", alloc.HostPort)
+	assert.GreaterOrEqual(t, alloc.HostPort, dynamicRangeStart,
+		"with no room left in band 1, the allocator should use the dynamic range")
+}
+
+// TestAllocatePort_FallbackSearchesWithinBand verifies that a conflict in the
+// middle of a band is still resolved by the next free port in the same band.
+func TestAllocatePort_FallbackSearchesWithinBand(t *testing.T) {
+	allocator := NewAllocator(NewScanner())
+	allocator.SetExistingAllocations([]model.PortAllocation{
+		{ServiceName: "other", ContainerPort: 9998, HostPort: 29998, Protocol: "tcp"},
+	})
+
+	alloc, err := allocator.AllocatePort(9998, 2, "app", "tcp")
+	require.NoError(t, err)
+
+	// 29999 is the only other port left in band 2 above the shifted port.
+	// If the OS happens to be using it, the dynamic range is the only option.
+	if alloc.HostPort != 29999 {
+		assert.GreaterOrEqual(t, alloc.HostPort, dynamicRangeStart,
+			"port %d is outside both band 2 and the dynamic range", alloc.HostPort)
+	}
+}
+
+// TestInferWorktreeIndex verifies that the index is recovered from shifted
+// ports, ignoring allocations moved by a fallback.
+func TestInferWorktreeIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		allocs []model.PortAllocation
+		want   int
+		wantOK bool
+	}{
+		{
+			name: "all ports shifted",
+			allocs: []model.PortAllocation{
+				{ContainerPort: 3000, HostPort: 33000},
+				{ContainerPort: 5432, HostPort: 35432},
+			},
+			want: 3, wantOK: true,
+		},
+		{
+			name: "one port moved by in-band fallback",
+			allocs: []model.PortAllocation{
+				{ContainerPort: 3000, HostPort: 23001},
+				{ContainerPort: 5432, HostPort: 25432},
+			},
+			want: 2, wantOK: true,
+		},
+		{
+			name:   "index 0 keeps original ports",
+			allocs: []model.PortAllocation{{ContainerPort: 8080, HostPort: 8080}},
+			want:   0, wantOK: true,
+		},
+		{
+			name:   "only dynamic-range ports",
+			allocs: []model.PortAllocation{{ContainerPort: 9000, HostPort: 49152}},
+			wantOK: false,
+		},
+		{
+			name:   "no ports",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := InferWorktreeIndex(tt.allocs)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+// TestAllocatePorts_ConcurrentMatchesSequential verifies that concurrent
+// availability probing does not change the outcome: the same inputs must
+// produce exactly the same allocations, in the same order, as a fully
+// sequential run. This includes intra-batch conflicts (two services on 3000),
+// which are resolved single-threaded after probing.
+func TestAllocatePorts_ConcurrentMatchesSequential(t *testing.T) {
+	ports := []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000, Protocol: "tcp"},
+		{ServiceName: "worker", ContainerPort: 3000, Protocol: "tcp"},
+		{ServiceName: "db", ContainerPort: 5432, Protocol: "tcp"},
+		{ServiceName: "redis", ContainerPort: 6379, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 5353, Protocol: "udp"},
+		{ServiceName: "api", ContainerPort: 8080},
+		{ServiceName: "overflow", ContainerPort: 9000},
+	}
+	existing := []model.PortAllocation{
+		{ServiceName: "other-app", ContainerPort: 5432, HostPort: 45432, Protocol: "tcp"},
+	}
+
+	sequential := NewAllocator(NewScanner())
+	sequential.SetProbeWorkers(1)
+	sequential.SetExistingAllocations(append([]model.PortAllocation(nil), existing...))
+	want, err := sequential.AllocatePorts(ports, 4)
+	require.NoError(t, err)
+
+	concurrent := NewAllocator(NewScanner())
+	concurrent.SetExistingAllocations(append([]model.PortAllocation(nil), existing...))
+	got, err := concurrent.AllocatePorts(ports, 4)
+	require.NoError(t, err)
+
+	assert.Equal(t, want, got, "concurrent probing must not change the allocation result")
+	assert.Nil(t, concurrent.probeCache, "probe cache must be discarded after AllocatePorts returns")
+}
+
+// TestPrefetchAvailability verifies that the concurrent prefetch probes each
+// unique in-range candidate exactly once and skips candidates that overflow.
+func TestPrefetchAvailability(t *testing.T) {
+	allocator := NewAllocator(NewScanner())
+
+	ports := []model.PortSpec{
+		{ServiceName: "app", ContainerPort: 3000},
+		{ServiceName: "worker", ContainerPort: 3000, Protocol: "tcp"},
+		{ServiceName: "dns", ContainerPort: 3000, Protocol: "udp"},
+		{ServiceName: "big", ContainerPort: 9000},
+	}
+
+	cache := allocator.prefetchAvailability(ports, 6)
+
+	// 3000 → 63000 (tcp and udp are distinct keys); 9000 → 69000 overflows.
+	assert.Len(t, cache, 2)
+	assert.Contains(t, cache, probeKey{port: 63000, protocol: "tcp"})
+	assert.Contains(t, cache, probeKey{port: 63000, protocol: "udp"})
+}
+
+// BenchmarkAllocatePorts compares sequential and concurrent probing for an
+// environment with many forwarded ports.
+func BenchmarkAllocatePorts(b *testing.B) {
+	ports := make([]model.PortSpec, 0, 32)
+	for i := 0; i < 32; i++ {
+		ports = append(ports, model.PortSpec{
+			ServiceName:   fmt.Sprintf("svc%d", i),
+			ContainerPort: 4000 + i,
+			Protocol:      "tcp",
+		})
+	}
+
+	for _, workers := range []int{1, defaultProbeWorkers} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				allocator := NewAllocator(NewScanner())
+				allocator.SetProbeWorkers(workers)
+				if _, err := allocator.AllocatePorts(ports, 4); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ package port
 import (
 	"fmt"
 	"net"
+	"strconv"
 )
 
 // Scanner checks whether specific ports are available on the host machine.
@@ -20,28 +21,52 @@ import (
 // asks the OS directly, rather than parsing /proc/net/* or relying on external
 // commands like `lsof` or `ss` which may require elevated permissions.
 //
-// The struct is currently stateless, but is defined as a struct (rather than
-// bare functions) so that future options (e.g., bind address, timeout) can be
-// added without breaking the API. It also makes the Scanner injectable as a
-// dependency, which improves testability of the Allocator.
-type Scanner struct{}
+// The zero configuration probes all interfaces. Options are set with
+// SetBindAddress and SetSkipProbe. Being a struct (rather than bare
+// functions) also makes the Scanner injectable as a dependency, which
+// improves testability of the Allocator.
+type Scanner struct {
+	// bindAddress is the host address probed, e.g. "127.0.0.1" or "::1".
+	// Empty means all interfaces.
+	bindAddress string
 
-// NewScanner creates a new Scanner instance.
-// Currently no configuration is needed, but this constructor follows Go
-// convention and allows future expansion (e.g., custom bind address).
+	// skipProbe makes every port report as available without touching the
+	// network stack. See SetSkipProbe.
+	skipProbe bool
+}
+
+// NewScanner creates a new Scanner instance that probes all interfaces.
 func NewScanner() *Scanner {
 	return &Scanner{}
 }
 
+// SetBindAddress sets the host address that ports are probed on. Use it
+// when ports are published on a specific interface: a port taken on
+// 127.0.0.1 only may still be free on another address, and vice versa.
+// An empty address restores the default of all interfaces.
+func (s *Scanner) SetBindAddress(addr string) {
+	s.bindAddress = addr
+}
+
+// SetSkipProbe disables OS-level probing, so IsPortAvailable reports every
+// port with a known protocol as available. This is for remote Docker hosts,
+// whose ports cannot be probed from here; the Allocator then relies solely
+// on the existing allocations recorded in container labels.
+func (s *Scanner) SetSkipProbe(skip bool) {
+	s.skipProbe = skip
+}
+
 // IsPortAvailable checks whether a single port is free on the host machine.
 //
 // For TCP, it attempts net.Listen("tcp", ":port"). For UDP, it attempts
 // net.ListenPacket("udp", ":port"). If the listen/bind succeeds, the port
 // is available — the listener is immediately closed via defer.
 //
-// We bind to all interfaces (":port" rather than "127.0.0.1:port") because
-// Docker typically publishes ports on 0.0.0.0, so we need to check the same
-// address space to avoid false positives.
+// By default we bind to all interfaces (":port" rather than
+// "127.0.0.1:port") because Docker typically publishes ports on 0.0.0.0, so
+// we need to check the same address space to avoid false positives. With
+// SetBindAddress, the given address is probed instead; an address that
+// cannot be bound on this host reports every port as unavailable.
 //
 // Parameters:
 //   - port: the port number to check (1-65535)
@@ -49,7 +74,14 @@ func NewScanner() *Scanner {
 //
 // Returns true if the port is free, false if it is already in use or invalid.
 func (s *Scanner) IsPortAvailable(port int, protocol string) bool {
-	addr := fmt.Sprintf(":%d", port)
+	if s.skipProbe {
+		// Still reject unknown protocols, matching the fail-safe below.
+		return protocol == "tcp" || protocol == "udp"
+	}
+
+	// JoinHostPort brackets IPv6 addresses ("[::1]:3000") and yields
+	// ":3000" for the default empty address.
+	addr := net.JoinHostPort(s.bindAddress, strconv.Itoa(port))
 
 	switch protocol {
 	case "tcp":
@@ -74,6 +74,44 @@ func TestIsPortAvailable_UnknownProtocol(t *testing.T) {
 	assert.False(t, available, "unknown protocol should return false (fail-safe)")
 }
 
+// TestIsPortAvailable_BindAddress verifies that the configured bind address
+// is the one probed: a port held on loopback is reported as in use there,
+// and an address that is not local to this host cannot be probed at all.
+func TestIsPortAvailable_BindAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "failed to start test listener")
+	defer func() { _ = listener.Close() }()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	scanner := NewScanner()
+	scanner.SetBindAddress("127.0.0.1")
+	assert.False(t, scanner.IsPortAvailable(port, "tcp"), "port %d is held on 127.0.0.1", port)
+
+	free, err := scanner.FindAvailablePort(50000, 50100, "tcp")
+	require.NoError(t, err)
+	assert.True(t, scanner.IsPortAvailable(free, "tcp"))
+
+	// 192.0.2.0/24 (TEST-NET-1) is never assigned to a local interface.
+	scanner.SetBindAddress("192.0.2.1")
+	assert.False(t, scanner.IsPortAvailable(free, "tcp"), "a non-local address cannot be bound")
+}
+
+// TestIsPortAvailable_SkipProbe verifies that a scanner with probing
+// disabled reports occupied ports as available, but still rejects unknown
+// protocols.
+func TestIsPortAvailable_SkipProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err, "failed to start test listener")
+	defer func() { _ = listener.Close() }()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	scanner := NewScanner()
+	scanner.SetSkipProbe(true)
+	assert.True(t, scanner.IsPortAvailable(port, "tcp"), "probing is skipped")
+	assert.True(t, scanner.IsPortAvailable(port, "udp"))
+	assert.False(t, scanner.IsPortAvailable(port, "sctp"))
+}
+
 // TestFindAvailablePort verifies that FindAvailablePort successfully finds
 // a free port within a given range.
 func TestFindAvailablePort(t *testing.T) {
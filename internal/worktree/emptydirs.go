@@ -0,0 +1,62 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RemoveEmptyParents removes the empty ancestors of path, innermost first,
+// and returns the directories it removed. It stops at the first directory
+// that is not empty, and never touches boundary or anything outside it: if
+// path is not inside boundary, nothing is removed. Ancestors that no longer
+// exist are skipped.
+//
+// It is meant to run after a worktree at path was removed, so that nested
+// worktree directories (e.g., ~/worktrees/<repo>/feature/auth) do not leave
+// empty directories behind.
+func RemoveEmptyParents(path, boundary string) ([]string, error) {
+	boundary = filepath.Clean(boundary)
+	var removed []string
+	for dir := filepath.Dir(filepath.Clean(path)); isStrictlyInside(dir, boundary); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+		if len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(dir); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
+
+// EmptyParentsBoundary returns the boundary for RemoveEmptyParents after
+// removing the worktree at worktreePath of the repository at repoRoot: the
+// fixed root of the project's worktree path template tmpl (see
+// PathTemplateRoot) if the worktree lies below it, and the repository's
+// parent directory, where worktrees go by default, otherwise.
+func EmptyParentsBoundary(worktreePath, repoRoot, tmpl string) string {
+	if tmpl != "" {
+		if root := PathTemplateRoot(tmpl, repoRoot); root != "" && isStrictlyInside(worktreePath, root) {
+			return root
+		}
+	}
+	return filepath.Dir(repoRoot)
+}
+
+// isStrictlyInside reports whether dir is below root (and not root itself).
+func isStrictlyInside(dir, root string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
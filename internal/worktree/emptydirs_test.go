@@ -0,0 +1,54 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRemoveEmptyParents verifies that empty ancestors are removed up to,
+// but not including, the boundary, that a non-empty directory stops the
+// walk, and that nothing outside the boundary is touched.
+func TestRemoveEmptyParents(t *testing.T) {
+	root := t.TempDir()
+	worktreePath := filepath.Join(root, "myrepo", "feature", "auth")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "myrepo", "feature"), 0o755))
+
+	removed, err := RemoveEmptyParents(worktreePath, root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "myrepo", "feature"), filepath.Join(root, "myrepo")}, removed)
+	assert.DirExists(t, root)
+
+	// A sibling keeps its parent.
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "myrepo", "feature", "other"), 0o755))
+	removed, err = RemoveEmptyParents(worktreePath, root)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.DirExists(t, filepath.Join(root, "myrepo", "feature", "other"))
+
+	// A path outside the boundary is left alone.
+	outside := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(outside, "empty"), 0o755))
+	removed, err = RemoveEmptyParents(filepath.Join(outside, "empty", "wt"), root)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.DirExists(t, filepath.Join(outside, "empty"))
+}
+
+// TestEmptyParentsBoundary verifies that the template root is used for
+// worktrees below it and the repository's parent otherwise.
+func TestEmptyParentsBoundary(t *testing.T) {
+	base := t.TempDir()
+	repoRoot := filepath.Join(base, "src", "myrepo")
+	tmpl := filepath.Join(base, "worktrees") + "/{{.Repo}}/{{.Branch}}"
+
+	assert.Equal(t, filepath.Join(base, "worktrees"),
+		EmptyParentsBoundary(filepath.Join(base, "worktrees", "myrepo", "feature", "auth"), repoRoot, tmpl))
+	assert.Equal(t, filepath.Join(base, "src"),
+		EmptyParentsBoundary(filepath.Join(base, "elsewhere", "wt"), repoRoot, tmpl))
+	assert.Equal(t, filepath.Join(base, "src"),
+		EmptyParentsBoundary(filepath.Join(base, "src", "myrepo-feature"), repoRoot, ""))
+}
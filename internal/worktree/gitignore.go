@@ -0,0 +1,67 @@
+package worktree
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitignoreFileName is the name of the per-directory ignore file.
+const GitignoreFileName = ".gitignore"
+
+// EnsureIgnored adds entries to the .gitignore file in dir, creating it if
+// needed, and returns the entries that were added. Entries already present
+// (as written, or anchored with a leading "/") are skipped, so repeated
+// calls do not duplicate lines.
+//
+// A .gitignore created here also lists itself, so that it does not show up
+// in `git status` either. Note that ignore rules only affect untracked
+// files: a tracked file that was modified is still reported.
+func EnsureIgnored(dir string, entries []string) ([]string, error) {
+	path := filepath.Join(dir, GitignoreFileName)
+
+	content, err := os.ReadFile(path)
+	created := os.IsNotExist(err)
+	if err != nil && !created {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if created {
+		entries = append(append([]string{}, entries...), GitignoreFileName)
+	}
+
+	present := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		present[strings.TrimPrefix(line, "/")] = true
+	}
+
+	var added []string
+	for _, entry := range entries {
+		if present[entry] {
+			continue
+		}
+		present[entry] = true
+		added = append(added, entry)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(content)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("# Generated by loam\n")
+	for _, entry := range added {
+		buf.WriteString(entry + "\n")
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return added, nil
+}
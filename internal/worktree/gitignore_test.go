@@ -0,0 +1,45 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnsureIgnored_Create verifies that a new .gitignore lists the entries
+// and itself, and that a second call adds nothing.
+func TestEnsureIgnored_Create(t *testing.T) {
+	dir := t.TempDir()
+
+	added, err := EnsureIgnored(dir, []string{"docker-compose.worktree.yml", "devcontainer.json"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docker-compose.worktree.yml", "devcontainer.json", GitignoreFileName}, added)
+
+	added, err = EnsureIgnored(dir, []string{"docker-compose.worktree.yml", "devcontainer.json"})
+	require.NoError(t, err)
+	assert.Empty(t, added)
+
+	content, err := os.ReadFile(filepath.Join(dir, GitignoreFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "# Generated by loam\ndocker-compose.worktree.yml\ndevcontainer.json\n.gitignore\n", string(content))
+}
+
+// TestEnsureIgnored_Existing verifies that entries are appended to an
+// existing file without a trailing newline, that anchored entries count as
+// present, and that the file does not list itself.
+func TestEnsureIgnored_Existing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, GitignoreFileName)
+	require.NoError(t, os.WriteFile(path, []byte("/devcontainer.json\nnode_modules"), 0o644))
+
+	added, err := EnsureIgnored(dir, []string{"docker-compose.worktree.yml", "devcontainer.json"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docker-compose.worktree.yml"}, added)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "/devcontainer.json\nnode_modules\n# Generated by loam\ndocker-compose.worktree.yml\n", string(content))
+}
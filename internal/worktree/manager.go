@@ -16,13 +16,17 @@
 package worktree
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/mmr-tortoise/loam/internal/cmdlog"
 	"github.com/mmr-tortoise/loam/internal/model"
 )
 
@@ -58,6 +62,32 @@ type MarkerFile struct {
 
 	// CreatedAt is the ISO 8601 timestamp when this environment was created.
 	CreatedAt string `json:"createdAt"`
+
+	// Index is the worktree index selecting the environment's port band,
+	// once it is known. nil for environments without one and for markers
+	// written before the index was recorded.
+	Index *int `json:"index,omitempty"`
+
+	// ConfigChecksum is the checksum (see ConfigChecksum) of the rewritten
+	// .devcontainer/devcontainer.json create wrote into the worktree. It
+	// tells remove --keep-worktree whether the file was edited since.
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+}
+
+// ConfigChecksum returns the hex-encoded SHA-256 checksum of data, the
+// form stored in MarkerFile.ConfigChecksum.
+func ConfigChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WorktreeIndex returns the recorded worktree index, or
+// model.UnknownWorktreeIndex if none is recorded.
+func (m *MarkerFile) WorktreeIndex() int {
+	if m.Index == nil {
+		return model.UnknownWorktreeIndex
+	}
+	return *m.Index
 }
 
 // WriteMarkerFile writes a MarkerFile as JSON to the worktree directory.
@@ -127,10 +157,12 @@ type WorktreeInfo struct {
 
 // Manager provides Git worktree operations by invoking the git CLI.
 //
-// It is currently stateless — all methods receive the repository path
-// as a parameter. The struct exists as a receiver to support future
-// extensions such as configurable git binary path or logging middleware.
-type Manager struct{}
+// All methods receive the repository path as a parameter. The only state
+// is how git is invoked (see SetQuiet).
+type Manager struct {
+	// quiet passes --quiet to `git worktree add`.
+	quiet bool
+}
 
 // NewManager creates a new worktree Manager instance.
 //
@@ -142,6 +174,25 @@ func NewManager() *Manager {
 	return &Manager{}
 }
 
+// SetQuiet makes the Manager pass --quiet to `git worktree add` (create
+// --quiet-git). git's output is captured rather than shown, but it ends up
+// in error messages, where progress lines such as "Preparing worktree (new
+// branch ...)" bury the actual error. `git worktree remove` prints nothing
+// but errors and has no --quiet option.
+func (m *Manager) SetQuiet(quiet bool) {
+	m.quiet = quiet
+}
+
+// worktreeAddArgs returns the arguments for `git worktree add args...`,
+// with --quiet if the Manager is quiet.
+func (m *Manager) worktreeAddArgs(args ...string) []string {
+	result := []string{"worktree", "add"}
+	if m.quiet {
+		result = append(result, "--quiet")
+	}
+	return append(result, args...)
+}
+
 // Add creates a new Git worktree at the specified path on a new branch.
 //
 // This method handles two cases:
@@ -162,22 +213,145 @@ func (m *Manager) Add(repoPath, branch, worktreePath, baseBranch string) error {
 	// If the branch exists, we cannot use -b (it would fail with "already exists").
 	if m.BranchExists(repoPath, branch) {
 		// Branch exists — just create a worktree that checks out the existing branch.
-		_, err := runGit(repoPath, "worktree", "add", worktreePath, branch)
-		return err
+		return m.runWorktreeAdd(repoPath, worktreePath, worktreePath, branch)
 	}
 
 	// Branch does not exist — create a new branch at the specified base.
 	// Build the command arguments: git worktree add -b <branch> <worktreePath> [baseBranch]
-	args := []string{"worktree", "add", "-b", branch, worktreePath}
+	args := []string{"-b", branch, worktreePath}
 	if baseBranch != "" {
 		args = append(args, baseBranch)
 	}
 	// When baseBranch is empty, git defaults to HEAD as the starting point.
 
-	_, err := runGit(repoPath, args...)
+	return m.runWorktreeAdd(repoPath, worktreePath, args...)
+}
+
+// AddFromRemote fetches a ref from a remote into a new local branch and
+// creates a worktree that checks out that branch.
+//
+// This is used for refs that only exist on the remote, such as GitHub pull
+// request heads ("pull/123/head"). The fetch writes directly to
+// refs/heads/<branch>, so no remote-tracking branch is created.
+//
+// An existing local branch is never overwritten: if branch already exists,
+// an error is returned instead of force-updating it, because the user may
+// have local commits on it.
+//
+// Parameters:
+//   - repoPath: absolute path to the main Git repository
+//   - remote: the remote to fetch from (e.g., "origin")
+//   - remoteRef: the ref on the remote (e.g., "pull/123/head")
+//   - branch: the local branch name to create
+//   - worktreePath: absolute path where the new worktree will be created
+func (m *Manager) AddFromRemote(repoPath, remote, remoteRef, branch, worktreePath string) error {
+	if m.BranchExists(repoPath, branch) {
+		return model.NewCLIError(model.ExitGitError,
+			fmt.Sprintf("branch %q already exists; delete it or choose a different branch name", branch))
+	}
+
+	// "<src>:<dst>" refspec fetches src from the remote and stores it as dst locally.
+	if _, err := runGit(repoPath, "fetch", remote, remoteRef+":refs/heads/"+branch); err != nil {
+		return err
+	}
+
+	return m.runWorktreeAdd(repoPath, worktreePath, worktreePath, branch)
+}
+
+// Clone clones the repository at url into dest with `git clone`, creating
+// the parent directories of dest as needed. dest must not exist or must be
+// an empty directory. The clone is a normal (non-bare) repository whose
+// default branch is checked out, so it can serve as the main checkout for
+// worktrees; other branches are available as origin/<branch>.
+func (m *Manager) Clone(url, dest string) error {
+	parent := filepath.Dir(dest)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for clone: %w", err)
+	}
+	// "--" keeps a URL starting with "-" from being parsed as an option.
+	_, err := runGit(parent, "clone", "--", url, dest)
+	return err
+}
+
+// Fetch updates the remote-tracking branches of remote (e.g., "origin")
+// with `git fetch`. Pruned remote branches are removed locally as well.
+func (m *Manager) Fetch(repoPath, remote string) error {
+	_, err := runGit(repoPath, "fetch", "--prune", remote)
+	return err
+}
+
+// InitSubmodules checks out the submodules of the worktree at worktreePath
+// with `git submodule update --init --recursive`. `git worktree add` leaves
+// submodule directories empty, which breaks builds that depend on them.
+// It may clone from the network, so callers run it only on request.
+func (m *Manager) InitSubmodules(worktreePath string) error {
+	_, err := runGit(worktreePath, "submodule", "update", "--init", "--recursive")
+	return err
+}
+
+// AddDetached creates a worktree with a detached HEAD at the given commit,
+// using `git worktree add --detach <worktreePath> <commit>`. No branch is
+// created, which suits reviewing a tag or an arbitrary commit.
+//
+// Parameters:
+//   - repoPath: absolute path to the main Git repository
+//   - worktreePath: absolute path where the new worktree will be created
+//   - commit: any commit-ish (SHA, tag, branch); empty means HEAD
+func (m *Manager) AddDetached(repoPath, worktreePath, commit string) error {
+	args := []string{"--detach", worktreePath}
+	if commit != "" {
+		args = append(args, commit)
+	}
+	return m.runWorktreeAdd(repoPath, worktreePath, args...)
+}
+
+// runWorktreeAdd runs `git worktree add args...` for a worktree at
+// worktreePath, after preparing the destination (see prepareDestination).
+func (m *Manager) runWorktreeAdd(repoPath, worktreePath string, args ...string) error {
+	if err := prepareDestination(worktreePath); err != nil {
+		return err
+	}
+	_, err := runGit(repoPath, m.worktreeAddArgs(args...)...)
 	return err
 }
 
+// prepareDestination checks worktreePath before `git worktree add` runs.
+// The check cannot wait for git's own refusal: with -b, git creates the
+// branch before it looks at the destination, so a refused add would leave
+// the branch behind. An empty directory (e.g., one created by hand or left
+// behind by an earlier failed attempt) is removed, which older git versions
+// need. A non-empty directory is never touched; the error says that
+// something is in the way. A missing path or anything other than a
+// directory is left to git.
+func prepareDestination(worktreePath string) error {
+	entries, err := os.ReadDir(worktreePath)
+	if err != nil {
+		return nil
+	}
+	if len(entries) > 0 {
+		return model.NewCLIError(model.ExitGitError,
+			fmt.Sprintf("destination %s already exists and is not empty; remove it or choose another path", worktreePath))
+	}
+	if err := os.Remove(worktreePath); err != nil {
+		return fmt.Errorf("failed to remove empty destination %s: %w", worktreePath, err)
+	}
+	return nil
+}
+
+// ShortCommit resolves a commit-ish to its abbreviated SHA (e.g., "a1b2c3d")
+// using `git rev-parse --short`. The "^{commit}" suffix makes git reject
+// names that do not point at a commit, such as tree or blob SHAs.
+func (m *Manager) ShortCommit(repoPath, commit string) (string, error) {
+	if commit == "" {
+		commit = "HEAD"
+	}
+	output, err := runGit(repoPath, "rev-parse", "--short", "--verify", commit+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
 // List returns information about all worktrees associated with the given repository.
 //
 // It runs `git worktree list --porcelain` which produces machine-parseable output.
@@ -198,6 +372,33 @@ func (m *Manager) List(repoPath string) ([]WorktreeInfo, error) {
 	return parsePorcelainOutput(output), nil
 }
 
+// ListWorktrees returns the linked worktrees of the repository: the result
+// of List without the main working tree (the entry at GetMainRepoRoot) and
+// without bare entries. Most callers care only about these; use List when
+// the main working tree is needed too.
+//
+// repoPath may be the main working tree or any of its worktrees.
+func (m *Manager) ListWorktrees(repoPath string) ([]WorktreeInfo, error) {
+	mainRoot, err := m.GetMainRepoRoot(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	worktrees, err := m.List(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	linked := make([]WorktreeInfo, 0, len(worktrees))
+	for _, wt := range worktrees {
+		if wt.IsBare || filepath.Clean(wt.Path) == mainRoot {
+			continue
+		}
+		linked = append(linked, wt)
+	}
+	return linked, nil
+}
+
 // ListPaths returns just the filesystem paths of all worktrees associated with
 // the given repository. This is a convenience wrapper around List() that extracts
 // only the Path field from each WorktreeInfo.
@@ -222,6 +423,31 @@ func (m *Manager) ListPaths(repoPath string) ([]string, error) {
 	return paths, nil
 }
 
+// WorktreeForBranch returns the path of the worktree that has branch checked
+// out, and whether there is one. Git allows a branch to be checked out in at
+// most one worktree, so the first match is the only one.
+//
+// The main working tree is included in the search: when the branch is
+// checked out there, its path (the repository root) is returned. branch may
+// be a short name ("feature/auth") or a full ref ("refs/heads/feature/auth").
+func (m *Manager) WorktreeForBranch(repoPath, branch string) (string, bool, error) {
+	worktrees, err := m.List(repoPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	ref := branch
+	if !strings.HasPrefix(ref, "refs/heads/") {
+		ref = "refs/heads/" + ref
+	}
+	for _, wt := range worktrees {
+		if !wt.IsBare && wt.Branch == ref {
+			return wt.Path, true, nil
+		}
+	}
+	return "", false, nil
+}
+
 // Remove deletes a Git worktree at the specified path.
 //
 // This runs `git worktree remove <worktreePath>`, which removes the worktree
@@ -242,6 +468,31 @@ func (m *Manager) Remove(repoPath, worktreePath string, force bool) error {
 	return err
 }
 
+// Repair fixes the links between the repository at repoPath and its
+// worktrees with `git worktree repair`. Without paths, the .git files of
+// all registered worktrees are pointed back at the repository, which heals
+// them after the main repository was moved. Worktrees that were moved
+// themselves must be passed in paths, as absolute paths, so git can update
+// the repository's record of where they are.
+//
+// git exits with an error after repairing a broken .git file, having
+// reported the breakage before fixing it. So when paths are given, the
+// outcome is judged by WorktreeState instead: Repair succeeds if every path
+// is a valid worktree afterwards.
+func (m *Manager) Repair(repoPath string, paths ...string) error {
+	args := append([]string{"worktree", "repair"}, paths...)
+	_, err := runGit(repoPath, args...)
+	if err == nil || len(paths) == 0 {
+		return err
+	}
+	for _, path := range paths {
+		if valid, reason := m.WorktreeState(path); !valid {
+			return fmt.Errorf("worktree at %s is still broken (%s): %w", path, reason, err)
+		}
+	}
+	return nil
+}
+
 // IsWorktree checks whether the given path is a Git worktree (as opposed to
 // a main repository working directory).
 //
@@ -277,6 +528,53 @@ func (m *Manager) IsWorktree(path string) bool {
 	return strings.HasPrefix(string(content), "gitdir:")
 }
 
+// WorktreeState checks whether path is a healthy Git worktree: its .git file
+// must contain a "gitdir:" pointer, and the administrative directory it
+// points to (the main repository's .git/worktrees/<name>) must exist and
+// hold a HEAD. A worktree whose admin directory is gone — e.g., after the
+// main repository was moved or `git worktree prune` ran while the worktree
+// was unreachable — is reported as broken, which IsWorktree cannot tell.
+//
+// Relative gitdir paths (written with `worktree.useRelativePaths`) are
+// resolved against path. When valid is false, reason describes the problem
+// in a form suitable for display; it is empty for a healthy worktree.
+func (m *Manager) WorktreeState(path string) (valid bool, reason string) {
+	gitPath := filepath.Join(path, ".git")
+
+	info, err := os.Lstat(gitPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, "no .git file"
+		}
+		return false, fmt.Sprintf("cannot access .git: %v", err)
+	}
+	if info.IsDir() {
+		return false, ".git is a directory (a main working tree, not a linked worktree)"
+	}
+
+	content, err := os.ReadFile(gitPath)
+	if err != nil {
+		return false, fmt.Sprintf("cannot read .git file: %v", err)
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	gitdir = strings.TrimSpace(gitdir)
+	if !ok || gitdir == "" || strings.Contains(gitdir, "\n") {
+		return false, "malformed .git file (expected a single \"gitdir: <path>\" line)"
+	}
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(path, gitdir)
+	}
+
+	adminInfo, err := os.Stat(gitdir)
+	if err != nil || !adminInfo.IsDir() {
+		return false, fmt.Sprintf("gitdir %s does not exist", gitdir)
+	}
+	if _, err := os.Stat(filepath.Join(gitdir, "HEAD")); err != nil {
+		return false, fmt.Sprintf("gitdir %s has no HEAD", gitdir)
+	}
+	return true, ""
+}
+
 // GetRepoRoot returns the absolute path to the top-level directory of the
 // Git repository containing the given path.
 //
@@ -295,6 +593,26 @@ func (m *Manager) GetRepoRoot(path string) (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
+// GetMainRepoRoot returns the absolute path to the main working tree of the
+// repository containing path, even when path is inside a linked worktree.
+//
+// It uses `git rev-parse --git-common-dir`, which points at the main
+// repository's .git directory from any worktree; the main working tree is
+// its parent. For a bare repository there is no working tree, and the
+// repository directory itself is returned.
+func (m *Manager) GetMainRepoRoot(path string) (string, error) {
+	output, err := runGit(path, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+
+	commonDir := filepath.Clean(strings.TrimSpace(output))
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Dir(commonDir), nil
+	}
+	return commonDir, nil
+}
+
 // GetCurrentBranch returns the name of the currently checked-out branch
 // at the given path.
 //
@@ -309,6 +627,47 @@ func (m *Manager) GetCurrentBranch(path string) (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
+// DefaultRemote is the remote name GetRemoteURL uses when none is given.
+const DefaultRemote = "origin"
+
+// GetRemoteURL returns the URL of the named remote (DefaultRemote when
+// remote is empty) for the repository containing path. Worktrees share
+// their remotes with the main repository, so any worktree path works.
+//
+// A repository without that remote is not an error: an empty string is
+// returned, since purely local repositories are common. The remote list is
+// checked first so that only real failures (e.g., path is not a Git
+// repository) surface as errors.
+func (m *Manager) GetRemoteURL(path, remote string) (string, error) {
+	if remote == "" {
+		remote = DefaultRemote
+	}
+
+	// `git remote` prints one configured remote name per line.
+	output, err := runGit(path, "remote")
+	if err != nil {
+		return "", err
+	}
+	found := false
+	for _, name := range strings.Fields(output) {
+		if name == remote {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", nil
+	}
+
+	// get-url applies any url.<base>.insteadOf rewrites, matching the URL
+	// git actually uses for fetch.
+	output, err = runGit(path, "remote", "get-url", remote)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
 // BranchExists checks whether a branch with the given name exists in the repository.
 //
 // This uses `git rev-parse --verify <branch>` which exits with code 0 if the
@@ -322,6 +681,122 @@ func (m *Manager) BranchExists(repoPath, branch string) bool {
 	return err == nil
 }
 
+// BehindUpstream returns how many commits the local branch is behind its
+// upstream (`git rev-list --count <branch>..<branch>@{upstream}`), as of
+// the last fetch: nothing is fetched here.
+//
+// A branch without an upstream, and a name that is not a local branch
+// (e.g., a tag or commit), is not an error: 0 is returned.
+func (m *Manager) BehindUpstream(repoPath, branch string) (int, error) {
+	ref := "refs/heads/" + strings.TrimPrefix(branch, "refs/heads/")
+
+	// for-each-ref prints nothing for a missing branch and an empty
+	// upstream for a branch without one, instead of failing like
+	// rev-parse <branch>@{upstream} does.
+	output, err := runGit(repoPath, "for-each-ref", "--format=%(upstream)", ref)
+	if err != nil {
+		return 0, err
+	}
+	upstream := strings.TrimSpace(output)
+	if upstream == "" {
+		return 0, nil
+	}
+
+	output, err = runGit(repoPath, "rev-list", "--count", ref+".."+upstream)
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output of git rev-list --count: %q", output)
+	}
+	return count, nil
+}
+
+// DefaultBranch returns the name of the repository's default branch (e.g.,
+// "main"), as opposed to whatever branch is currently checked out.
+//
+// It is read from refs/remotes/origin/HEAD, which `git clone` sets and
+// `git remote set-head origin --auto` refreshes. Without it (e.g., in a
+// repository that was never cloned), "main" and then "master" are tried,
+// as local or origin remote-tracking branches.
+func (m *Manager) DefaultBranch(repoPath string) (string, error) {
+	output, err := runGit(repoPath, "symbolic-ref", "--quiet", "--short", "refs/remotes/"+DefaultRemote+"/HEAD")
+	if err == nil {
+		if branch, ok := strings.CutPrefix(strings.TrimSpace(output), DefaultRemote+"/"); ok && branch != "" {
+			return branch, nil
+		}
+	}
+
+	for _, branch := range []string{"main", "master"} {
+		if m.BranchExists(repoPath, "refs/heads/"+branch) ||
+			m.BranchExists(repoPath, "refs/remotes/"+DefaultRemote+"/"+branch) {
+			return branch, nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine the default branch: %s/HEAD is not set and neither main nor master exists "+
+		"(run `git remote set-head %s --auto`)", DefaultRemote, DefaultRemote)
+}
+
+// DeleteBranch deletes a local branch with `git branch -D`, regardless of
+// whether it has been merged. It is used to undo a branch created moments
+// earlier by Add or AddFromRemote, so the branch holds no work of its own.
+// A branch checked out in a worktree cannot be deleted; remove the worktree
+// first.
+func (m *Manager) DeleteBranch(repoPath, branch string) error {
+	_, err := runGit(repoPath, "branch", "-D", branch)
+	return err
+}
+
+// IsTracked reports whether relPath (relative to the worktree at path) is
+// tracked by Git, using `git ls-files --error-unmatch`.
+func (m *Manager) IsTracked(path, relPath string) bool {
+	_, err := runGit(path, "ls-files", "--error-unmatch", "--", relPath)
+	return err == nil
+}
+
+// RestoreFile discards working tree changes to relPath (relative to the
+// worktree at path) by restoring it from HEAD with `git restore`. It
+// reports false, without changing anything, when the file is not tracked,
+// so the caller can decide what to do with an untracked file.
+func (m *Manager) RestoreFile(path, relPath string) (bool, error) {
+	if !m.IsTracked(path, relPath) {
+		return false, nil
+	}
+	if _, err := runGit(path, "restore", "--source=HEAD", "--worktree", "--", relPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ChangedFiles returns the paths, relative to the worktree root, of files
+// with uncommitted changes in the worktree at path: modified, staged,
+// deleted, and untracked files. Ignored files are not included.
+//
+// It runs `git status --porcelain -z --untracked-files=all`. With -z, each
+// entry is "XY <path>" terminated by NUL, and renames and copies are
+// followed by an extra NUL-terminated entry holding the original path.
+func (m *Manager) ChangedFiles(path string) ([]string, error) {
+	output, err := runGit(path, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue // the trailing empty entry
+		}
+		files = append(files, entry[3:])
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++ // skip the original path of a rename or copy
+		}
+	}
+	return files, nil
+}
+
 // runGit executes a git command with the given arguments in the specified directory.
 //
 // It captures both stdout and stderr. On success (exit code 0), it returns
@@ -339,6 +814,7 @@ func runGit(repoPath string, args ...string) (string, error) {
 	fullArgs := append([]string{"-C", repoPath}, args...)
 
 	// #nosec G204 — args are constructed internally, not from user input
+	cmdlog.Echo("git", fullArgs, nil)
 	cmd := exec.Command("git", fullArgs...)
 
 	// Capture stdout and stderr separately so we can include stderr
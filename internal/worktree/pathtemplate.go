@@ -0,0 +1,98 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// PathTemplateData is the data available to a worktree path template
+// (see config.ProjectConfig.WorktreePathTemplate).
+type PathTemplateData struct {
+	// Repo is the base name of the source repository directory.
+	Repo string
+
+	// Branch is the branch name, e.g. "feature/auth". Slashes produce
+	// nested directories. It is empty for a detached worktree.
+	Branch string
+
+	// Name is the environment name, e.g. "feature-auth".
+	Name string
+
+	// Index is the worktree index selecting the port band.
+	Index int
+}
+
+// RenderPathTemplate renders a worktree path template with text/template
+// and returns the resulting absolute, cleaned path.
+//
+// A leading "~/" is expanded to the user's home directory, and a relative
+// result is resolved against repoRoot (so "../{{.Name}}" is a sibling of
+// the repository). The rendered path is rejected if it is empty, spans
+// multiple lines, or points at or inside the repository itself, where a
+// worktree would show up as untracked files.
+func RenderPathTemplate(tmpl, repoRoot string, data PathTemplateData) (string, error) {
+	t, err := template.New("worktreePathTemplate").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid worktree path template %q: %w", tmpl, err)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render worktree path template %q: %w", tmpl, err)
+	}
+
+	rendered := strings.TrimSpace(sb.String())
+	switch {
+	case rendered == "":
+		return "", fmt.Errorf("worktree path template %q rendered an empty path", tmpl)
+	case strings.ContainsAny(rendered, "\n\r\x00"):
+		return "", fmt.Errorf("worktree path template %q rendered an invalid path %q", tmpl, rendered)
+	}
+
+	path, err := resolveTemplatePath(rendered, repoRoot)
+	if err != nil {
+		return "", err
+	}
+
+	if rel, err := filepath.Rel(repoRoot, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("worktree path %s from template %q is inside the repository %s", path, tmpl, repoRoot)
+	}
+	return path, nil
+}
+
+// PathTemplateRoot returns the directory every path rendered from tmpl lies
+// below: the template's fixed leading directories, resolved like
+// RenderPathTemplate does. For "~/worktrees/{{.Repo}}/{{.Branch}}" that is
+// ~/worktrees. It returns "" if the template starts with an action, or if
+// the fixed part is the file system root, which is no useful boundary.
+func PathTemplateRoot(tmpl, repoRoot string) string {
+	fixed, _, _ := strings.Cut(strings.TrimSpace(tmpl), "{{")
+	i := strings.LastIndexAny(fixed, `/\`)
+	if i < 0 {
+		return ""
+	}
+	root, err := resolveTemplatePath(fixed[:i+1], repoRoot)
+	if err != nil || filepath.Dir(root) == root {
+		return ""
+	}
+	return root
+}
+
+// resolveTemplatePath expands a leading "~" in path to the user's home
+// directory, resolves a relative path against repoRoot, and cleans it.
+func resolveTemplatePath(path, repoRoot string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~ in worktree path %q: %w", path, err)
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoRoot, path)
+	}
+	return filepath.Clean(path), nil
+}
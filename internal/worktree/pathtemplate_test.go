@@ -0,0 +1,92 @@
+package worktree
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRenderPathTemplate verifies rendering of typical layouts, including
+// nested directories from branch names, home expansion, and paths relative
+// to the repository. HOME is overridden, so the test is not parallel.
+func TestRenderPathTemplate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	repoRoot := filepath.Join(t.TempDir(), "src", "myrepo")
+	data := PathTemplateData{Repo: "myrepo", Branch: "feature/auth", Name: "feature-auth", Index: 3}
+
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{
+			name: "nested per-repo layout",
+			tmpl: "~/worktrees/{{.Repo}}/{{.Branch}}",
+			want: filepath.Join(home, "worktrees", "myrepo", "feature", "auth"),
+		},
+		{
+			name: "sibling directory relative to the repository",
+			tmpl: "../{{.Repo}}-worktrees/{{.Index}}-{{.Name}}",
+			want: filepath.Join(filepath.Dir(repoRoot), "myrepo-worktrees", "3-feature-auth"),
+		},
+		{
+			name: "absolute with surrounding whitespace",
+			tmpl: " /srv/wt/{{.Name}}\n",
+			want: "/srv/wt/feature-auth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderPathTemplate(tt.tmpl, repoRoot, data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestRenderPathTemplate_Invalid verifies that bad templates and unusable
+// rendered paths are rejected.
+func TestRenderPathTemplate_Invalid(t *testing.T) {
+	t.Parallel()
+
+	repoRoot := filepath.Join(t.TempDir(), "myrepo")
+	data := PathTemplateData{Repo: "myrepo", Branch: "main", Name: "main"}
+
+	for name, tmpl := range map[string]string{
+		"parse error":        "~/wt/{{.Repo",
+		"unknown field":      "~/wt/{{.Project}}",
+		"empty result":       "{{if .Index}}x{{end}}",
+		"inside repository":  "wt/{{.Name}}",
+		"repository itself":  ".",
+		"multi-line result":  "/wt/{{.Name}}\n/other",
+		"ancestor-like name": "{{.Repo}}/../{{.Repo}}/sub",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := RenderPathTemplate(tmpl, repoRoot, data)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestPathTemplateRoot verifies that the fixed leading directories of a
+// template are resolved like rendered paths, and that templates without
+// a usable fixed part have no root. HOME is overridden, so the test is not
+// parallel.
+func TestPathTemplateRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repoRoot := filepath.Join(t.TempDir(), "src", "myrepo")
+
+	assert.Equal(t, filepath.Join(home, "worktrees"), PathTemplateRoot("~/worktrees/{{.Repo}}/{{.Branch}}", repoRoot))
+	assert.Equal(t, filepath.Join(home, "worktrees"), PathTemplateRoot("~/worktrees/wt-{{.Name}}", repoRoot))
+	assert.Equal(t, filepath.Dir(repoRoot), PathTemplateRoot("../{{.Repo}}-{{.Name}}", repoRoot))
+	assert.Equal(t, "/srv/wt", PathTemplateRoot("/srv/wt/{{.Name}}", repoRoot))
+	assert.Empty(t, PathTemplateRoot("{{.Name}}", repoRoot))
+	assert.Empty(t, PathTemplateRoot("/{{.Name}}", repoRoot))
+}
@@ -0,0 +1,152 @@
+package worktree
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultSeedFiles is the default allowlist of files copied from the main
+// checkout into a new worktree by CopySeedFiles. These are typically
+// gitignored, so `git worktree add` does not bring them along, yet the
+// Dev Container often cannot start without them.
+var DefaultSeedFiles = []string{".env", ".env.local"}
+
+// CopySeedFiles copies untracked files (e.g., .env) from the main checkout
+// into the same relative paths in a new worktree.
+//
+// Only files matching an allow pattern are considered, and any file matching
+// a deny pattern is skipped. Patterns use filepath.Match syntax and are
+// relative to the repository root (e.g., ".env", "certs/*.pem"). A deny
+// pattern matches if it matches either the relative path or the base name,
+// so "*.key" excludes key files in any allowed directory.
+//
+// To avoid surprises, the copy is deliberately conservative:
+//   - Existing files in the worktree are never overwritten. Tracked files
+//     are already checked out, so this also prevents clobbering them.
+//   - Only regular files are copied; directories and symlinks are skipped
+//     (a symlink could point at a secret outside the repository).
+//   - Patterns that escape the repository root (absolute or "..") are rejected.
+//
+// Returns the relative paths of the copied files, sorted.
+func CopySeedFiles(srcRoot, dstRoot string, allow, deny []string) ([]string, error) {
+	// Validate all patterns up front so a bad pattern fails before any copy.
+	for _, pattern := range append(append([]string(nil), allow...), deny...) {
+		if err := validateSeedPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	// A set is used because overlapping allow patterns (".env*" and ".env")
+	// can match the same file more than once.
+	copied := make(map[string]bool)
+
+	for _, pattern := range allow {
+		// filepath.Glob only returns an error for malformed patterns, which
+		// validateSeedPattern has already ruled out.
+		matches, err := filepath.Glob(filepath.Join(srcRoot, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed file pattern %q: %w", pattern, err)
+		}
+
+		for _, src := range matches {
+			rel, err := filepath.Rel(srcRoot, src)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute relative path for %s: %w", src, err)
+			}
+			if copied[rel] || matchesAnySeedPattern(rel, deny) {
+				continue
+			}
+
+			// Lstat (not Stat) so that symlinks are seen as symlinks.
+			info, err := os.Lstat(src)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+
+			dst := filepath.Join(dstRoot, rel)
+			if _, err := os.Lstat(dst); err == nil {
+				continue // Never overwrite what is already in the worktree.
+			}
+
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create directory for %s: %w", dst, err)
+			}
+			if err := copySeedFile(src, dst, info.Mode().Perm()); err != nil {
+				return nil, err
+			}
+			copied[rel] = true
+		}
+	}
+
+	result := make([]string, 0, len(copied))
+	for rel := range copied {
+		result = append(result, rel)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// validateSeedPattern rejects patterns that are malformed or that could
+// reach outside the repository root.
+func validateSeedPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("seed file pattern must not be empty")
+	}
+	if filepath.IsAbs(pattern) {
+		return fmt.Errorf("seed file pattern %q must be relative to the repository root", pattern)
+	}
+	clean := filepath.Clean(pattern)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("seed file pattern %q must not escape the repository root", pattern)
+	}
+	// filepath.Match reports ErrBadPattern for malformed patterns such as "[".
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid seed file pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// matchesAnySeedPattern reports whether rel (or its base name) matches any
+// of the given patterns.
+func matchesAnySeedPattern(rel string, patterns []string) bool {
+	base := filepath.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copySeedFile copies a single regular file, creating dst with the given
+// permissions. O_EXCL makes the "never overwrite" rule hold even if the
+// file appears between the existence check and the copy.
+func copySeedFile(src, dst string, perm os.FileMode) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		_ = dstFile.Close()
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	// Close explicitly (not via defer) so a failed flush is reported.
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSeedTestFile creates a file (and its parent directories) under root.
+func writeSeedTestFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+// TestCopySeedFiles_DefaultAllowlist verifies that only the allowlisted
+// files are copied, with contents and permissions preserved.
+func TestCopySeedFiles_DefaultAllowlist(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	writeSeedTestFile(t, src, ".env", "SECRET=1")
+	writeSeedTestFile(t, src, ".env.local", "LOCAL=1")
+	writeSeedTestFile(t, src, ".env.production", "PROD=1")
+	writeSeedTestFile(t, src, "config/local.yml", "x: 1")
+
+	copied, err := CopySeedFiles(src, dst, DefaultSeedFiles, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".env", ".env.local"}, copied)
+
+	data, err := os.ReadFile(filepath.Join(dst, ".env"))
+	require.NoError(t, err)
+	assert.Equal(t, "SECRET=1", string(data))
+
+	info, err := os.Stat(filepath.Join(dst, ".env"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "permissions should be preserved")
+
+	assert.NoFileExists(t, filepath.Join(dst, ".env.production"), "non-allowlisted file must not be copied")
+	assert.NoFileExists(t, filepath.Join(dst, "config", "local.yml"), "non-allowlisted file must not be copied")
+}
+
+// TestCopySeedFiles_GlobAndDenylist verifies glob allow patterns in
+// subdirectories and that deny patterns match by path or base name.
+func TestCopySeedFiles_GlobAndDenylist(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	writeSeedTestFile(t, src, "certs/dev.crt", "cert")
+	writeSeedTestFile(t, src, "certs/dev.key", "key")
+	writeSeedTestFile(t, src, ".env", "A=1")
+	writeSeedTestFile(t, src, ".env.secrets", "B=2")
+
+	copied, err := CopySeedFiles(src, dst, []string{"certs/*", ".env*"}, []string{"*.key", ".env.secrets"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".env", filepath.Join("certs", "dev.crt")}, copied)
+
+	assert.FileExists(t, filepath.Join(dst, "certs", "dev.crt"))
+	assert.NoFileExists(t, filepath.Join(dst, "certs", "dev.key"), "denylisted by base name")
+	assert.NoFileExists(t, filepath.Join(dst, ".env.secrets"), "denylisted by path")
+}
+
+// TestCopySeedFiles_NeverOverwrites verifies that files already present in
+// the worktree (e.g., tracked files) are left untouched.
+func TestCopySeedFiles_NeverOverwrites(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	writeSeedTestFile(t, src, ".env", "from-main")
+	writeSeedTestFile(t, dst, ".env", "already-here")
+
+	copied, err := CopySeedFiles(src, dst, DefaultSeedFiles, nil)
+	require.NoError(t, err)
+	assert.Empty(t, copied)
+
+	data, err := os.ReadFile(filepath.Join(dst, ".env"))
+	require.NoError(t, err)
+	assert.Equal(t, "already-here", string(data))
+}
+
+// TestCopySeedFiles_SkipsSymlinksAndDirs verifies that only regular files are
+// copied, so a symlink cannot smuggle in a file from outside the repository.
+func TestCopySeedFiles_SkipsSymlinksAndDirs(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	outside := filepath.Join(t.TempDir(), "id_rsa")
+	require.NoError(t, os.WriteFile(outside, []byte("private"), 0o600))
+	if err := os.Symlink(outside, filepath.Join(src, ".env")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(src, ".env.local"), 0o755))
+
+	copied, err := CopySeedFiles(src, dst, DefaultSeedFiles, nil)
+	require.NoError(t, err)
+	assert.Empty(t, copied)
+	assert.NoFileExists(t, filepath.Join(dst, ".env"))
+}
+
+// TestCopySeedFiles_RejectsEscapingPatterns verifies that patterns reaching
+// outside the repository root are refused before anything is copied.
+func TestCopySeedFiles_RejectsEscapingPatterns(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	writeSeedTestFile(t, src, ".env", "A=1")
+
+	for _, pattern := range []string{"../.env", "/etc/passwd", "..", "", "["} {
+		_, err := CopySeedFiles(src, dst, []string{".env", pattern}, nil)
+		assert.Error(t, err, "pattern %q should be rejected", pattern)
+	}
+	assert.NoFileExists(t, filepath.Join(dst, ".env"), "nothing is copied when any pattern is invalid")
+}